@@ -0,0 +1,262 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// API schemas can be much larger than other probed pages
+const maxAPIDocSize = 5 * 1024 * 1024
+
+var pathTemplateRegex = regexp.MustCompile(`\{[^}/]+\}`)
+
+// APIOperation is an operation of an OpenAPI/Swagger document
+type APIOperation struct {
+	Method string
+	URL    string
+	// Parameters in "name (in)" format (Ex: "id (path)")
+	Params []string
+}
+
+type openAPIParam struct {
+	Name string `json:"name" yaml:"name"`
+	In   string `json:"in" yaml:"in"`
+	Ref  string `json:"$ref" yaml:"$ref"`
+}
+
+type openAPIOperation struct {
+	Parameters  []openAPIParam `json:"parameters" yaml:"parameters"`
+	RequestBody *struct{}      `json:"requestBody" yaml:"requestBody"`
+}
+
+type openAPIPathItem struct {
+	Parameters []openAPIParam    `json:"parameters" yaml:"parameters"`
+	Get        *openAPIOperation `json:"get" yaml:"get"`
+	Put        *openAPIOperation `json:"put" yaml:"put"`
+	Post       *openAPIOperation `json:"post" yaml:"post"`
+	Delete     *openAPIOperation `json:"delete" yaml:"delete"`
+	Options    *openAPIOperation `json:"options" yaml:"options"`
+	Head       *openAPIOperation `json:"head" yaml:"head"`
+	Patch      *openAPIOperation `json:"patch" yaml:"patch"`
+	Trace      *openAPIOperation `json:"trace" yaml:"trace"`
+}
+
+type openAPIDocument struct {
+	Swagger  string   `json:"swagger" yaml:"swagger"`
+	OpenAPI  string   `json:"openapi" yaml:"openapi"`
+	Host     string   `json:"host" yaml:"host"`
+	BasePath string   `json:"basePath" yaml:"basePath"`
+	Schemes  []string `json:"schemes" yaml:"schemes"`
+	Servers  []struct {
+		URL string `json:"url" yaml:"url"`
+	} `json:"servers" yaml:"servers"`
+	Paths map[string]openAPIPathItem `json:"paths" yaml:"paths"`
+}
+
+// ParseOpenAPI enumerates the operations of an OpenAPI 3 or Swagger 2 document in JSON or YAML,
+// relative servers are resolved against docURL. ok is false when data isn't such a document.
+func ParseOpenAPI(data []byte, docURL *url.URL) (operations []APIOperation, ok bool) {
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, false
+		}
+	}
+	if (doc.Swagger == "" && doc.OpenAPI == "") || len(doc.Paths) == 0 {
+		return nil, false
+	}
+
+	base := openAPIBaseURL(doc, docURL)
+	paths := make([]string, 0, len(doc.Paths))
+	for p := range doc.Paths {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		item := doc.Paths[p]
+		methods := []struct {
+			name string
+			op   *openAPIOperation
+		}{
+			{"GET", item.Get}, {"POST", item.Post}, {"PUT", item.Put}, {"PATCH", item.Patch},
+			{"DELETE", item.Delete}, {"HEAD", item.Head}, {"OPTIONS", item.Options}, {"TRACE", item.Trace},
+		}
+		for _, m := range methods {
+			if m.op == nil {
+				continue
+			}
+			var params []string
+			for _, param := range append(item.Parameters, m.op.Parameters...) {
+				params = append(params, param.String())
+			}
+			if m.op.RequestBody != nil {
+				params = append(params, "body (body)")
+			}
+			operations = append(operations, APIOperation{
+				Method: m.name,
+				URL:    strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(p, "/"),
+				Params: Unique(params),
+			})
+		}
+	}
+	return operations, true
+}
+
+func (p openAPIParam) String() string {
+	name := p.Name
+	if name == "" && p.Ref != "" {
+		// Unresolved reference, its last segment is usually the parameter name
+		name = p.Ref[strings.LastIndex(p.Ref, "/")+1:]
+	}
+	if p.In == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, p.In)
+}
+
+// Base URL of the operations: servers (OpenAPI 3) or scheme, host and basePath (Swagger 2)
+func openAPIBaseURL(doc openAPIDocument, docURL *url.URL) string {
+	base := &url.URL{Scheme: docURL.Scheme, Host: docURL.Host}
+	if doc.OpenAPI != "" {
+		// Server variables can't be resolved, fall back to the document host
+		if len(doc.Servers) > 0 && !strings.Contains(doc.Servers[0].URL, "{") {
+			if u, err := docURL.Parse(doc.Servers[0].URL); err == nil {
+				return u.String()
+			}
+		}
+		return base.String()
+	}
+	if doc.Host != "" {
+		base.Host = doc.Host
+	}
+	if len(doc.Schemes) > 0 && (doc.Schemes[0] == "http" || doc.Schemes[0] == "https") {
+		base.Scheme = doc.Schemes[0]
+	}
+	base.Path = doc.BasePath
+	return base.String()
+}
+
+// Report the operations of an OpenAPI/Swagger document, and crawl its GET endpoints with --crawl-api-docs
+func (crawler *Crawler) reportOpenAPI(docURL *url.URL, data []byte) bool {
+	operations, ok := ParseOpenAPI(data, docURL)
+	if !ok || crawler.apiSet.Duplicate("doc "+docURL.String()) {
+		return ok
+	}
+	Logger.Infof("Found API document %s with %d operations", docURL, len(operations))
+	for _, op := range operations {
+		if crawler.apiSet.Duplicate(op.Method + " " + op.URL) {
+			continue
+		}
+		params := strings.Join(op.Params, ", ")
+		outputFormat := fmt.Sprintf("[openapi] - [from: %s] - [%s] - %s", docURL, op.Method, op.URL)
+		if params != "" {
+			outputFormat += " - " + params
+		}
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     docURL.String(),
+			OutputType: "openapi",
+			Output:     op.URL,
+			Methods:    []string{op.Method},
+			Details:    map[string]string{"params": params},
+		})
+
+		if crawler.opts.CrawlAPIDocs && op.Method == "GET" {
+			crawler.mainVisits.visit(pathTemplateRegex.ReplaceAllString(op.URL, "1"))
+		}
+	}
+	return true
+}
+
+// Parse crawled responses that are OpenAPI/Swagger documents
+func (crawler *Crawler) findOpenAPI(response *colly.Response) {
+	if !bytes.Contains(response.Body, []byte("paths")) ||
+		!(bytes.Contains(response.Body, []byte("swagger")) || bytes.Contains(response.Body, []byte("openapi"))) {
+		return
+	}
+	crawler.reportOpenAPI(response.Request.URL, response.Body)
+}
+
+// Probe a host once for GraphQL endpoints and OpenAPI/Swagger documents
+func (crawler *Crawler) discoverAPIs(u *url.URL) {
+	base := u.Scheme + "://" + u.Host
+	if crawler.apiSet.Duplicate("probe " + base) {
+		return
+	}
+
+	for _, p := range crawler.rules.GraphQLPaths {
+		endpoint := base + p
+		if !crawler.graphqlQuery(endpoint, "query{__typename}", "__typename") {
+			continue
+		}
+		introspection := "disabled"
+		if crawler.graphqlQuery(endpoint, "query{__schema{queryType{name}}}", "__schema") {
+			introspection = "enabled"
+		}
+		outputFormat := fmt.Sprintf("[graphql] - [introspection: %s] - %s", introspection, endpoint)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     u.String(),
+			OutputType: "graphql",
+			Output:     endpoint,
+			Details:    map[string]string{"introspection": introspection},
+		})
+		break
+	}
+
+	for _, p := range crawler.rules.OpenAPIPaths {
+		docURL, _ := url.Parse(base + p)
+		body, status, ok := crawler.fetchAPI("GET", docURL.String(), "")
+		if ok && status == 200 && crawler.reportOpenAPI(docURL, body) {
+			break
+		}
+	}
+}
+
+// Send a GraphQL query, true when the answer holds field in its data
+func (crawler *Crawler) graphqlQuery(endpoint, query, field string) bool {
+	payload, _ := json.Marshal(map[string]string{"query": query})
+	body, _, ok := crawler.fetchAPI("POST", endpoint, string(payload))
+	if !ok {
+		return false
+	}
+	var answer struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &answer); err != nil {
+		return false
+	}
+	_, found := answer.Data[field]
+	return found
+}
+
+// Send a probe request, a non empty payload is sent as JSON
+func (crawler *Crawler) fetchAPI(method, u, payload string) ([]byte, int, bool) {
+	req, err := crawler.newRequest(method, u)
+	if err != nil {
+		return nil, 0, false
+	}
+	if payload != "" {
+		req.Body = ioutil.NopCloser(strings.NewReader(payload))
+		req.ContentLength = int64(len(payload))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(payload)), nil
+		}
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := crawler.client.Do(req)
+	if err != nil {
+		Logger.Debugf("Failed to send %s to %s: %s", method, u, err)
+		return nil, 0, false
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxAPIDocSize))
+	return body, resp.StatusCode, true
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseOpenAPISwagger(t *testing.T) {
+	doc := `{
+		"swagger": "2.0",
+		"host": "api.example.com",
+		"basePath": "/v1",
+		"schemes": ["https"],
+		"paths": {
+			"/users/{id}": {
+				"parameters": [{"name": "id", "in": "path"}],
+				"get": {"parameters": [{"name": "fields", "in": "query"}]},
+				"delete": {}
+			},
+			"/login": {"post": {"parameters": [{"$ref": "#/parameters/credentials"}]}}
+		}
+	}`
+	docURL, _ := url.Parse("http://example.com/swagger.json")
+	operations, ok := ParseOpenAPI([]byte(doc), docURL)
+	if !ok {
+		t.Fatal("swagger document not parsed")
+	}
+	want := []APIOperation{
+		{"POST", "https://api.example.com/v1/login", []string{"credentials"}},
+		{"GET", "https://api.example.com/v1/users/{id}", []string{"id (path)", "fields (query)"}},
+		{"DELETE", "https://api.example.com/v1/users/{id}", []string{"id (path)"}},
+	}
+	if !reflect.DeepEqual(operations, want) {
+		t.Errorf("ParseOpenAPI() = %v, want %v", operations, want)
+	}
+}
+
+func TestParseOpenAPIYAML(t *testing.T) {
+	doc := `openapi: 3.0.0
+servers:
+  - url: /api
+paths:
+  /orders:
+    post:
+      requestBody:
+        content: {}
+`
+	docURL, _ := url.Parse("https://example.com/docs/openapi.yaml")
+	operations, ok := ParseOpenAPI([]byte(doc), docURL)
+	want := []APIOperation{{"POST", "https://example.com/api/orders", []string{"body (body)"}}}
+	if !ok || !reflect.DeepEqual(operations, want) {
+		t.Errorf("ParseOpenAPI() = %v, %v, want %v", operations, ok, want)
+	}
+
+	if _, ok := ParseOpenAPI([]byte("<html>openapi paths</html>"), docURL); ok {
+		t.Error("HTML page parsed as an API document")
+	}
+}
+
+func TestDiscoverAPIs(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/api/graphql":
+			var query struct {
+				Query string `json:"query"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&query)
+			if strings.Contains(query.Query, "__schema") {
+				fmt.Fprint(w, `{"errors":[{"message":"introspection is disabled"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":{"__typename":"Query"}}`)
+		case "/v2/api-docs":
+			fmt.Fprint(w, `{"swagger":"2.0","paths":{"/api/users/{id}":{"get":{}}}}`)
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html>home</html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	found := findingsByKey(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+		opts.APIDiscovery = true
+		opts.CrawlAPIDocs = true
+	}))
+
+	if r, ok := found["graphql "+ts.URL+"/api/graphql"]; !ok || r.Details["introspection"] != "disabled" {
+		t.Errorf("graphql endpoint not reported, got %v", found)
+	}
+	if _, ok := found["openapi "+ts.URL+"/api/users/{id}"]; !ok {
+		t.Errorf("openapi operation not reported, got %v", found)
+	}
+	crawled := false
+	for _, r := range requested {
+		if r == "GET /api/users/1" {
+			crawled = true
+		}
+	}
+	if !crawled {
+		t.Errorf("GET endpoint of the API document not crawled, requests %v", requested)
+	}
+}
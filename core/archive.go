@@ -0,0 +1,184 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Archive kinds read by ReadArchive
+const (
+	ArchiveZip   = "zip"
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+)
+
+const (
+	// Members listed per archive, the others are only counted
+	maxArchiveMembers = 1000
+	// Text members bigger than this are listed but not analyzed
+	maxArchiveMemberSize = 1024 * 1024
+	// Decompressed bytes read per archive, as a multiple of its size (zip bombs)
+	archiveExpansion = 10
+)
+
+// ArchiveMember is a file of an archive, Content is only read for text files
+type ArchiveMember struct {
+	Name    string
+	Size    int64
+	Content []byte
+}
+
+// ArchiveKind detects zip, tar and tar.gz archives by their magic number, "" for other bodies
+func ArchiveKind(body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte("PK\x03\x04")), bytes.HasPrefix(body, []byte("PK\x05\x06")):
+		return ArchiveZip
+	case isTar(body):
+		return ArchiveTar
+	case len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b:
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return ""
+		}
+		defer gr.Close()
+		header := make([]byte, 512)
+		if n, _ := io.ReadFull(gr, header); isTar(header[:n]) {
+			return ArchiveTarGz
+		}
+	}
+	return ""
+}
+
+// POSIX tar headers have the "ustar" magic at offset 257
+func isTar(header []byte) bool {
+	return len(header) >= 262 && string(header[257:262]) == "ustar"
+}
+
+// ReadArchive lists the files of an archive of kind and reads the text ones,
+// reading at most maxBytes decompressed bytes in total
+func ReadArchive(kind string, body []byte, maxBytes int64) (members []ArchiveMember, total int, err error) {
+	budget := maxBytes
+	read := func(r io.Reader, size int64) []byte {
+		if size > maxArchiveMemberSize || size > budget {
+			return nil
+		}
+		data, err := ioutil.ReadAll(io.LimitReader(r, size))
+		budget -= int64(len(data))
+		if err != nil || !isText(data) {
+			return nil
+		}
+		return data
+	}
+
+	switch kind {
+	case ArchiveZip:
+		zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			total++
+			if len(members) >= maxArchiveMembers {
+				continue
+			}
+			member := ArchiveMember{Name: f.Name, Size: int64(f.UncompressedSize64)}
+			if rc, err := f.Open(); err == nil {
+				member.Content = read(rc, member.Size)
+				rc.Close()
+			}
+			members = append(members, member)
+		}
+		return members, total, nil
+	case ArchiveTar, ArchiveTarGz:
+		var r io.Reader = bytes.NewReader(body)
+		if kind == ArchiveTarGz {
+			gr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			defer gr.Close()
+			r = gr
+		}
+		tr := tar.NewReader(r)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				return members, total, nil
+			}
+			if err != nil {
+				// Truncated archives still list their first members
+				return members, total, nil
+			}
+			if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+				continue
+			}
+			total++
+			if len(members) >= maxArchiveMembers {
+				continue
+			}
+			members = append(members, ArchiveMember{Name: header.Name, Size: header.Size, Content: read(tr, header.Size)})
+		}
+	}
+	return nil, 0, fmt.Errorf("unknown archive kind %q", kind)
+}
+
+// Text members are valid UTF-8 without NUL bytes
+func isText(data []byte) bool {
+	return len(data) > 0 && utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
+
+// List the files of an archive response and analyze its text members like JavaScript files:
+// links, subdomains, secrets and the other findings of analyzeJS
+func (crawler *Crawler) inspectArchive(response *colly.Response) {
+	if response.StatusCode != 200 {
+		return
+	}
+	kind := ArchiveKind(response.Body)
+	if kind == "" {
+		return
+	}
+	source := response.Request.URL.String()
+	if maxSize := crawler.opts.MaxArchiveSize; maxSize > 0 && len(response.Body) > maxSize {
+		Logger.Debugf("Archive larger than %d bytes not inspected: %s", maxSize, source)
+		return
+	}
+
+	members, total, err := ReadArchive(kind, response.Body, int64(len(response.Body))*archiveExpansion)
+	if err != nil {
+		Logger.Debugf("Failed to read archive %s: %s", source, err)
+		return
+	}
+	outputFormat := fmt.Sprintf("[archive] - [%s] - [files: %d] - %s", kind, total, source)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     source,
+		OutputType: "archive",
+		Output:     source,
+		Details:    map[string]string{"kind": kind, "files": strconv.Itoa(total)},
+	})
+
+	for _, member := range members {
+		name := strings.TrimPrefix(member.Name, "./")
+		outputFormat := fmt.Sprintf("[archive-file] - [from: %s] - [size-%d] - %s", source, member.Size, name)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     source,
+			OutputType: "archive-file",
+			Output:     name,
+			Length:     int(member.Size),
+		})
+		if member.Content != nil {
+			crawler.analyzeJS(source+"#"+name, response.Request.URL, string(member.Content))
+		}
+	}
+}
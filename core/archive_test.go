@@ -0,0 +1,104 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func zipArchive(t *testing.T, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write(content)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadArchive(t *testing.T) {
+	zipped := zipArchive(t, map[string][]byte{
+		"app/config.js": []byte(`fetch("/api/v1/users")`),
+		"logo.bin":      {0x89, 0x00, 0x01},
+	})
+	if kind := ArchiveKind(zipped); kind != ArchiveZip {
+		t.Fatalf("ArchiveKind() = %q", kind)
+	}
+	members, total, err := ReadArchive(ArchiveZip, zipped, 1024)
+	if err != nil || total != 2 {
+		t.Fatalf("ReadArchive() = %v, %d, %v", members, total, err)
+	}
+	for _, m := range members {
+		if text := m.Content != nil; text != (m.Name == "app/config.js") {
+			t.Errorf("member %s read as text: %v", m.Name, text)
+		}
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	content := []byte("DEBUG=true\nAPI=https://internal.example.com/\n")
+	_ = tw.WriteHeader(&tar.Header{Name: "./logs/app.log", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	_, _ = tw.Write(content)
+	_ = tw.Close()
+	_ = gw.Close()
+	if kind := ArchiveKind(buf.Bytes()); kind != ArchiveTarGz {
+		t.Fatalf("ArchiveKind() = %q", kind)
+	}
+	members, total, err = ReadArchive(ArchiveTarGz, buf.Bytes(), 1024)
+	if err != nil || total != 1 || string(members[0].Content) != string(content) {
+		t.Errorf("ReadArchive() = %v, %d, %v", members, total, err)
+	}
+	// The decompressed bytes budget is spent, the members are only listed
+	if members, _, _ = ReadArchive(ArchiveTarGz, buf.Bytes(), 10); members[0].Content != nil {
+		t.Error("member read over the budget")
+	}
+
+	if kind := ArchiveKind([]byte("<html></html>")); kind != "" {
+		t.Errorf("ArchiveKind(html) = %q", kind)
+	}
+}
+
+func TestCrawlerArchives(t *testing.T) {
+	archive := zipArchive(t, map[string][]byte{"config/.env": []byte("HEALTH_URL=\"/internal/health\"\n")})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><a href="/backup.zip">backup</a></html>`)
+		case "/backup.zip":
+			w.Header().Set("Content-Type", "application/zip")
+			_, _ = w.Write(archive)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html>page</html>`)
+		}
+	}))
+	defer ts.Close()
+
+	found := findingsByKey(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 3
+		opts.Archives = true
+	}))
+
+	if a, ok := found["archive "+ts.URL+"/backup.zip"]; !ok || a.Details["files"] != "1" {
+		t.Errorf("archive reported as %+v", a)
+	}
+	if _, ok := found["archive-file config/.env"]; !ok {
+		t.Error("archive file not listed")
+	}
+	if l, ok := found["linkfinder /internal/health"]; !ok || l.Source != ts.URL+"/backup.zip#config/.env" {
+		t.Errorf("link of the archive file reported as %+v", l)
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// authTracker watches HTML pages for a marker only authenticated pages have
+// (logout link, account name...) to notice when the session silently expires
+type authTracker struct {
+	marker *regexp.Regexp
+
+	mu        sync.Mutex
+	lost      bool
+	pages     int
+	anonymous int
+}
+
+func newAuthTracker(marker string) (*authTracker, error) {
+	re, err := compileRegex(marker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth marker regex: %s", err)
+	}
+	return &authTracker{marker: re}, nil
+}
+
+// check records a page and reports whether the authentication state changed,
+// lost is true when the page is the first anonymous one after authenticated pages
+func (a *authTracker) check(body string) (changed bool, lost bool) {
+	authenticated := a.marker.MatchString(body)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pages++
+	if !authenticated {
+		a.anonymous++
+	}
+	if authenticated == a.lost {
+		a.lost = !authenticated
+		return true, a.lost
+	}
+	return false, a.lost
+}
+
+// stats returns the number of checked pages and how many of them were anonymous
+func (a *authTracker) stats() (int, int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pages, a.anonymous
+}
@@ -0,0 +1,31 @@
+package core
+
+import "testing"
+
+func TestAuthTracker(t *testing.T) {
+	a, err := newAuthTracker(`(?i)logout`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		body    string
+		changed bool
+		lost    bool
+	}{
+		{`<a href="/logout">Logout</a>`, false, false},
+		{`<a href="/login">Login</a>`, true, true},
+		{`<a href="/login">Login</a>`, false, true},
+		{`<a href="/logout">Logout</a>`, true, false},
+	}
+	for i, s := range steps {
+		changed, lost := a.check(s.body)
+		if changed != s.changed || lost != s.lost {
+			t.Errorf("step %d: check() = %v, %v, want %v, %v", i, changed, lost, s.changed, s.lost)
+		}
+	}
+
+	if pages, anonymous := a.stats(); pages != 4 || anonymous != 2 {
+		t.Errorf("stats() = %d, %d", pages, anonymous)
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// AWSIdentity is an AWS account, Cognito pool or IAM principal referenced by a page or script,
+// Account is the AWS account ID it belongs to when the value tells it
+type AWSIdentity struct {
+	Kind    string
+	Value   string
+	Account string
+}
+
+const awsRegion = `(?:us|eu|ap|ca|sa|me|af|il|cn|us-gov)-[a-z]+-\d`
+
+// AWS identifiers, the first group (when there is one) is the reported value
+var awsIdentityRegexes = []struct {
+	Kind  string
+	Regex *regexp.Regexp
+}{
+	{"cognito-user-pool", regexp.MustCompile(`\b(` + awsRegion + `_[A-Za-z0-9]{9})\b`)},
+	{"cognito-identity-pool", regexp.MustCompile(`\b(` + awsRegion + `:[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})\b`)},
+	{"iam-arn", regexp.MustCompile(`\barn:aws(?:-cn|-us-gov)?:(?:iam|sts)::\d{12}:[A-Za-z-]+/[A-Za-z0-9+=,.@_/-]+`)},
+	{"arn", regexp.MustCompile(`\barn:aws(?:-cn|-us-gov)?:(?:[a-z0-9-]+):` + `(?:` + awsRegion + `)?:\d{12}:[A-Za-z0-9+=,.@_/:-]+`)},
+	{"aws-account-id", regexp.MustCompile(`(?i)(?:aws[_-]?account[_-]?id|accountId)["']?\s*[:=]\s*["'](\d{12})["']`)},
+}
+
+var (
+	arnAccountRegex = regexp.MustCompile(`^arn:[^:]+:[^:]*:[^:]*:(\d{12}):`)
+	iamARNRegex     = regexp.MustCompile(`^arn:[^:]+:(?:iam|sts):`)
+)
+
+// GetAWSIdentities finds Cognito user and identity pool IDs, IAM and other ARNs and AWS
+// account IDs. The accounts of ARNs are also reported as aws-account-id identities.
+func GetAWSIdentities(source string) []AWSIdentity {
+	var identities []AWSIdentity
+	seen := make(map[string]bool)
+	add := func(identity AWSIdentity) {
+		if key := identity.Kind + "|" + identity.Value; !seen[key] {
+			seen[key] = true
+			identities = append(identities, identity)
+		}
+	}
+	for _, r := range awsIdentityRegexes {
+		for _, match := range r.Regex.FindAllStringSubmatch(source, -1) {
+			value := match[0]
+			if len(match) > 1 {
+				value = match[1]
+			}
+			// IAM ARNs also match the generic ARN regex
+			if r.Kind == "arn" && iamARNRegex.MatchString(value) {
+				continue
+			}
+			identity := AWSIdentity{Kind: r.Kind, Value: value}
+			if m := arnAccountRegex.FindStringSubmatch(value); m != nil {
+				identity.Account = m[1]
+			}
+			if r.Kind == "aws-account-id" {
+				identity.Account = value
+			}
+			add(identity)
+		}
+	}
+	for _, identity := range identities {
+		if identity.Kind != "aws-account-id" && identity.Account != "" {
+			add(AWSIdentity{Kind: "aws-account-id", Value: identity.Account, Account: identity.Account})
+		}
+	}
+	return identities
+}
+
+// Find AWS identifiers from response, they map the AWS surface beyond S3 buckets
+func (crawler *Crawler) findAWSIdentities(source, resp string) {
+	for _, identity := range GetAWSIdentities(resp) {
+		if crawler.awsIdentitySet.Duplicate(identity.Kind + "|" + identity.Value) {
+			continue
+		}
+		outputFormat := fmt.Sprintf("[aws-identity] - [%s] - [%s] - %s", identity.Kind, source, identity.Value)
+		var details map[string]string
+		if identity.Account != "" {
+			details = map[string]string{"account": identity.Account}
+		}
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     source,
+			OutputType: "aws-identity",
+			Output:     identity.Value,
+			Rule:       identity.Kind,
+			Details:    details,
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetAWSIdentities(t *testing.T) {
+	js := `Amplify.configure({Auth: {userPoolId: "us-east-1_AbC123xYz", identityPoolId: "eu-west-2:0b9e5c3e-6f3a-4c6e-9a53-2f1e4d0c7b21"}});
+		const role = "arn:aws:iam::123456789012:role/Cognito_UnauthRole";
+		const queue = "arn:aws:sqs:us-east-1:210987654321:orders";
+		const cfg = {"awsAccountId": "555555555555", "build": "us-east-1_short"};`
+
+	want := []AWSIdentity{
+		{Kind: "cognito-user-pool", Value: "us-east-1_AbC123xYz"},
+		{Kind: "cognito-identity-pool", Value: "eu-west-2:0b9e5c3e-6f3a-4c6e-9a53-2f1e4d0c7b21"},
+		{Kind: "iam-arn", Value: "arn:aws:iam::123456789012:role/Cognito_UnauthRole", Account: "123456789012"},
+		{Kind: "arn", Value: "arn:aws:sqs:us-east-1:210987654321:orders", Account: "210987654321"},
+		{Kind: "aws-account-id", Value: "555555555555", Account: "555555555555"},
+		{Kind: "aws-account-id", Value: "123456789012", Account: "123456789012"},
+		{Kind: "aws-account-id", Value: "210987654321", Account: "210987654321"},
+	}
+	if got := GetAWSIdentities(js); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAWSIdentities() =\n%v\nwant\n%v", got, want)
+	}
+}
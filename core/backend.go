@@ -0,0 +1,67 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// BackendConfig is a reference to a SaaS backend, these often allow direct API access
+type BackendConfig struct {
+	Service string
+	Value   string
+}
+
+// Backend references, the first group (when there is one) is the reported value
+var backendConfigRegexes = []struct {
+	Service string
+	Regex   *regexp.Regexp
+}{
+	{"firebase-database", regexp.MustCompile(`\b[a-z0-9-]+(?:-default-rtdb)?\.firebaseio\.com\b`)},
+	{"firebase-database", regexp.MustCompile(`\b[a-z0-9-]+\.[a-z0-9-]+\.firebasedatabase\.app\b`)},
+	{"firebase-auth-domain", regexp.MustCompile(`\b[a-z0-9-]+\.firebaseapp\.com\b`)},
+	{"firebase-storage", regexp.MustCompile(`\b[a-z0-9-]+\.appspot\.com\b`)},
+	{"firebase-project-id", regexp.MustCompile(`["']?projectId["']?\s*:\s*["']([a-z0-9-]{6,30})["']`)},
+	{"supabase-url", regexp.MustCompile(`https://[a-z0-9]{20}\.supabase\.co\b`)},
+	{"supabase-anon-key", regexp.MustCompile(`(?i)supabase[_-]?(?:anon)?[_-]?key["']?\s*[:=]\s*["'](eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+)["']`)},
+	{"algolia-app-id", regexp.MustCompile(`(?i)(?:algolia[_-]?app(?:lication)?[_-]?id|applicationId|appId)["']?\s*[:=]\s*["']([A-Z0-9]{10})["']`)},
+	{"algolia-app-id", regexp.MustCompile(`\b([A-Z0-9]{10})-dsn\.algolia\.net\b`)},
+	{"algolia-api-key", regexp.MustCompile(`(?i)algolia[_-]?(?:search)?[_-]?(?:api)?[_-]?key["']?\s*[:=]\s*["']([a-f0-9]{32})["']`)},
+	{"auth0-domain", regexp.MustCompile(`\b[a-z0-9-]+(?:\.[a-z]{2})?\.auth0\.com\b`)},
+	{"pusher-key", regexp.MustCompile(`(?i)pusher[_-]?(?:app)?[_-]?key["']?\s*[:=]\s*["']([a-f0-9]{20})["']`)},
+}
+
+// GetBackendConfigs finds Firebase, Supabase, Algolia and similar backend references
+func GetBackendConfigs(source string) []BackendConfig {
+	var configs []BackendConfig
+	seen := make(map[BackendConfig]bool)
+	for _, r := range backendConfigRegexes {
+		for _, match := range r.Regex.FindAllStringSubmatch(source, -1) {
+			value := match[0]
+			if len(match) > 1 {
+				value = match[1]
+			}
+			config := BackendConfig{Service: r.Service, Value: value}
+			if !seen[config] {
+				seen[config] = true
+				configs = append(configs, config)
+			}
+		}
+	}
+	return configs
+}
+
+// Find references to SaaS backends from response
+func (crawler *Crawler) findBackendConfigs(source, resp string) {
+	for _, config := range GetBackendConfigs(resp) {
+		if crawler.backendSet.Duplicate(config.Service + "|" + config.Value) {
+			continue
+		}
+		outputFormat := fmt.Sprintf("[backend-config] - [%s] - [%s] - %s", config.Service, source, config.Value)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     source,
+			OutputType: "backend-config",
+			Output:     config.Value,
+			Rule:       config.Service,
+		})
+	}
+}
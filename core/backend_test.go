@@ -0,0 +1,27 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetBackendConfigs(t *testing.T) {
+	js := `const firebaseConfig = {apiKey: "x", authDomain: "acme-prod.firebaseapp.com",
+		databaseURL: "https://acme-prod.firebaseio.com", projectId: "acme-prod",
+		storageBucket: "acme-prod.appspot.com"};
+		createClient("https://abcdefghijklmnopqrst.supabase.co", SUPABASE_ANON_KEY = "eyJhbGciOi.eyJyb2xlIjoiYW5vbiJ9.c2ln");
+		algoliasearch(ALGOLIA_APP_ID="LATENCY123", "x");`
+
+	want := []BackendConfig{
+		{Service: "firebase-database", Value: "acme-prod.firebaseio.com"},
+		{Service: "firebase-auth-domain", Value: "acme-prod.firebaseapp.com"},
+		{Service: "firebase-storage", Value: "acme-prod.appspot.com"},
+		{Service: "firebase-project-id", Value: "acme-prod"},
+		{Service: "supabase-url", Value: "https://abcdefghijklmnopqrst.supabase.co"},
+		{Service: "supabase-anon-key", Value: "eyJhbGciOi.eyJyb2xlIjoiYW5vbiJ9.c2ln"},
+		{Service: "algolia-app-id", Value: "LATENCY123"},
+	}
+	if got := GetBackendConfigs(js); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBackendConfigs() = %v, want %v", got, want)
+	}
+}
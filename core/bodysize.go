@@ -0,0 +1,65 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// TruncatedHeader is set on responses whose body was cut at --max-response-size,
+// to the size of the body when it's known (Content-Length), "unknown" otherwise
+const TruncatedHeader = "X-Gospider-Truncated"
+
+// sizeCapTransport cuts response bodies at limit bytes so large downloads (bundles, exports,
+// media served by mistake) can't take the memory of the crawl. Bodies are read as they
+// come, the rest of a cut body is never downloaded.
+type sizeCapTransport struct {
+	base  http.RoundTripper
+	limit int64
+}
+
+func (t *sizeCapTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength > t.limit {
+		resp.Header.Set(TruncatedHeader, strconv.FormatInt(resp.ContentLength, 10))
+	}
+	resp.Body = &cappedBody{ReadCloser: resp.Body, header: resp.Header, remaining: t.limit}
+	return resp, nil
+}
+
+// cappedBody ends a body after its limit, and marks the response truncated
+// when there was more to read
+type cappedBody struct {
+	io.ReadCloser
+	header    http.Header
+	remaining int64
+	once      sync.Once
+}
+
+func (b *cappedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		b.once.Do(func() {
+			var next [1]byte
+			if n, _ := io.ReadFull(b.ReadCloser, next[:]); n > 0 && b.header.Get(TruncatedHeader) == "" {
+				// colly keeps the header map of the response, the mark is seen by its callbacks
+				b.header.Set(TruncatedHeader, "unknown")
+			}
+		})
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// Whether the body of a response was cut at --max-response-size
+func truncated(header *http.Header) bool {
+	return header != nil && header.Get(TruncatedHeader) != ""
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCrawlerMaxResponseSize(t *testing.T) {
+	padding := strings.Repeat("<p>filler</p>", 4096)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/sized">sized</a><a href="/streamed">streamed</a><a href="/small">small</a>`)
+		case "/sized":
+			body := `<a href="/sized-early">early</a>` + padding + `<a href="/sized-late">late</a>`
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+			fmt.Fprint(w, body)
+		case "/streamed":
+			// Chunked, the size isn't known before reading
+			fmt.Fprint(w, `<a href="/streamed-early">early</a>`)
+			w.(http.Flusher).Flush()
+			fmt.Fprint(w, padding+`<a href="/streamed-late">late</a>`)
+		default:
+			fmt.Fprint(w, `<html>page</html>`)
+		}
+	}))
+	defer ts.Close()
+
+	maxSize := 16 * 1024
+	found := make(map[string]SpiderOutput)
+	for _, r := range findingsOf(crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 3
+		opts.MaxResponseSize = maxSize
+	}), "url") {
+		found[strings.TrimPrefix(r.Output, ts.URL)] = r
+	}
+
+	sized := len(`<a href="/sized-early">early</a>` + padding + `<a href="/sized-late">late</a>`)
+	if r, ok := found["/sized"]; !ok || r.Details["truncated"] != fmt.Sprint(sized) || r.Length != maxSize {
+		t.Errorf("sized page reported as %+v", r)
+	}
+	if r, ok := found["/streamed"]; !ok || r.Details["truncated"] != "unknown" || r.Length != maxSize {
+		t.Errorf("streamed page reported as %+v", r)
+	}
+	if r, ok := found["/small"]; !ok || r.Details != nil {
+		t.Errorf("small page reported as %+v", r)
+	}
+	for _, path := range []string{"/sized-early", "/streamed-early"} {
+		if _, ok := found[path]; !ok {
+			t.Errorf("link %s of the part read not crawled", path)
+		}
+	}
+	for _, path := range []string{"/sized-late", "/streamed-late"} {
+		if _, ok := found[path]; ok {
+			t.Errorf("link %s after the cut crawled", path)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BucketObject is an object key of a S3 or GCS bucket seen in a URL
+type BucketObject struct {
+	Provider string // "s3" or "gcs"
+	Bucket   string
+	Key      string
+}
+
+const bucketKeyPattern = `([^\s"'<>?#\\]+)`
+
+// Bucket URLs with an object path, the first group is the bucket and the second one the key
+var bucketObjectRegexes = []struct {
+	Provider string
+	Regex    *regexp.Regexp
+}{
+	{"s3", regexp.MustCompile(`(?i)https?://([a-z0-9.-]+)\.s3(?:[.-][a-z0-9-]+)?\.amazonaws\.com/` + bucketKeyPattern)},
+	{"s3", regexp.MustCompile(`(?i)https?://s3(?:[.-][a-z0-9-]+)?\.amazonaws\.com/([a-z0-9._-]+)/` + bucketKeyPattern)},
+	{"gcs", regexp.MustCompile(`(?i)https?://([a-z0-9._-]+)\.storage\.googleapis\.com/` + bucketKeyPattern)},
+	{"gcs", regexp.MustCompile(`(?i)https?://storage\.googleapis\.com/([a-z0-9._-]+)/` + bucketKeyPattern)},
+}
+
+// Listing pages fetched per bucket, 1000 keys each
+const maxBucketListingPages = 5
+
+// bucketListingURL returns the URL listing the objects of a bucket
+var bucketListingURL = func(provider, bucket string) string {
+	if provider == "gcs" {
+		return "https://storage.googleapis.com/" + bucket + "/"
+	}
+	return "https://" + bucket + ".s3.amazonaws.com/"
+}
+
+// GetBucketObjects finds S3 and GCS object URLs in source
+func GetBucketObjects(source string) []BucketObject {
+	var objects []BucketObject
+	seen := make(map[BucketObject]bool)
+	for _, r := range bucketObjectRegexes {
+		for _, match := range r.Regex.FindAllStringSubmatch(source, -1) {
+			key, err := url.PathUnescape(match[2])
+			if err != nil {
+				key = match[2]
+			}
+			object := BucketObject{Provider: r.Provider, Bucket: strings.ToLower(match[1]), Key: key}
+			if !seen[object] {
+				seen[object] = true
+				objects = append(objects, object)
+			}
+		}
+	}
+	return objects
+}
+
+// S3 ListObjects (v1) result, the GCS XML API answers the same format
+type bucketListing struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string   `xml:"Name"`
+	IsTruncated bool     `xml:"IsTruncated"`
+	NextMarker  string   `xml:"NextMarker"`
+	Contents    []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+}
+
+// ParseBucketListing reads a bucket listing XML page, ok is false when body isn't one
+func ParseBucketListing(body []byte) (bucket string, keys []string, truncated bool, ok bool) {
+	var listing bucketListing
+	if err := xml.Unmarshal(body, &listing); err != nil || listing.Name == "" {
+		return "", nil, false, false
+	}
+	for _, content := range listing.Contents {
+		keys = append(keys, content.Key)
+	}
+	return listing.Name, keys, listing.IsTruncated, true
+}
+
+// bucketInventory aggregates the object keys seen per bucket during a crawl
+type bucketInventory struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]bool
+}
+
+func newBucketInventory() *bucketInventory {
+	return &bucketInventory{buckets: make(map[string]map[string]bool)}
+}
+
+// add records keys of a bucket, it returns true when the bucket is new
+func (inv *bucketInventory) add(provider, bucket string, keys ...string) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	id := provider + ":" + bucket
+	objects, ok := inv.buckets[id]
+	if !ok {
+		objects = make(map[string]bool)
+		inv.buckets[id] = objects
+	}
+	for _, key := range keys {
+		objects[key] = true
+	}
+	return !ok
+}
+
+// Record bucket objects referenced by a response, and list new buckets with --bucket-listing
+func (crawler *Crawler) findBucketObjects(resp string) {
+	for _, object := range GetBucketObjects(resp) {
+		if crawler.buckets.add(object.Provider, object.Bucket, object.Key) && crawler.opts.BucketListing {
+			crawler.listBucket(object.Provider, object.Bucket)
+		}
+	}
+}
+
+// Record the keys of a crawled bucket listing page
+func (crawler *Crawler) findBucketListing(response []byte, host string) {
+	bucket, keys, _, ok := ParseBucketListing(response)
+	if !ok {
+		return
+	}
+	provider := "s3"
+	if strings.HasSuffix(host, "googleapis.com") {
+		provider = "gcs"
+	}
+	crawler.buckets.add(provider, bucket, keys...)
+}
+
+// Fetch the listing pages of a bucket when its listing URL is in scope
+func (crawler *Crawler) listBucket(provider, bucket string) {
+	listURL := bucketListingURL(provider, bucket)
+	u, err := url.Parse(listURL)
+	if err != nil || !crawler.scope.InScope(u) {
+		return
+	}
+
+	marker := ""
+	for page := 0; page < maxBucketListingPages; page++ {
+		pageURL := listURL
+		if marker != "" {
+			pageURL += "?marker=" + url.QueryEscape(marker)
+		}
+		req, err := crawler.newRequest("GET", pageURL)
+		if err != nil {
+			return
+		}
+		resp, err := crawler.client.Do(req)
+		if err != nil {
+			Logger.Debugf("Failed to list bucket %s: %s", bucket, err)
+			return
+		}
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+		resp.Body.Close()
+
+		_, keys, truncated, ok := ParseBucketListing(body)
+		if resp.StatusCode != 200 || !ok {
+			return
+		}
+		Logger.Infof("Bucket %s:%s is listable", provider, bucket)
+		crawler.buckets.add(provider, bucket, keys...)
+		if !truncated || len(keys) == 0 {
+			return
+		}
+		marker = keys[len(keys)-1]
+	}
+}
+
+// Report the object keys seen per bucket
+func (crawler *Crawler) reportBuckets() {
+	crawler.buckets.mu.Lock()
+	defer crawler.buckets.mu.Unlock()
+
+	ids := make([]string, 0, len(crawler.buckets.buckets))
+	for id := range crawler.buckets.buckets {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		var keys []string
+		for key := range crawler.buckets.buckets[id] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		args := strings.SplitN(id, ":", 2)
+		outputFormat := fmt.Sprintf("[bucket-objects] - [%s] - %s - %s", args[0], args[1], strings.Join(keys, ", "))
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     args[0],
+			OutputType: "bucket-objects",
+			Output:     args[1],
+			Objects:    keys,
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGetBucketObjects(t *testing.T) {
+	source := `<img src="https://assets.s3.amazonaws.com/img/logo%20big.png">
+		<script src="https://s3.eu-west-1.amazonaws.com/Static-Files/js/app.js?v=1"></script>
+		"https://media.storage.googleapis.com/videos/intro.mp4"
+		'https://storage.googleapis.com/backups/db.sql.gz'
+		https://assets.s3.amazonaws.com/img/logo%20big.png
+		https://assets.s3.amazonaws.com/`
+	want := []BucketObject{
+		{"s3", "assets", "img/logo big.png"},
+		{"s3", "static-files", "js/app.js"},
+		{"gcs", "media", "videos/intro.mp4"},
+		{"gcs", "backups", "db.sql.gz"},
+	}
+	if got := GetBucketObjects(source); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBucketObjects() = %v, want %v", got, want)
+	}
+}
+
+func TestParseBucketListing(t *testing.T) {
+	body := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>assets</Name>
+  <IsTruncated>true</IsTruncated>
+  <Contents><Key>a.txt</Key></Contents>
+  <Contents><Key>b/c.txt</Key></Contents>
+</ListBucketResult>`)
+	bucket, keys, truncated, ok := ParseBucketListing(body)
+	if !ok || bucket != "assets" || !truncated || !reflect.DeepEqual(keys, []string{"a.txt", "b/c.txt"}) {
+		t.Errorf("ParseBucketListing() = %q, %v, %v, %v", bucket, keys, truncated, ok)
+	}
+	if _, _, _, ok := ParseBucketListing([]byte("<html></html>")); ok {
+		t.Error("HTML page parsed as a bucket listing")
+	}
+}
+
+func TestCrawlerBucketObjects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><img src="https://assets.s3.amazonaws.com/img/logo.png"></html>`)
+		case "/listing/assets/":
+			w.Header().Set("Content-Type", "application/xml")
+			if r.URL.Query().Get("marker") == "" {
+				fmt.Fprint(w, `<ListBucketResult><Name>assets</Name><IsTruncated>true</IsTruncated><Contents><Key>a.txt</Key></Contents></ListBucketResult>`)
+				return
+			}
+			fmt.Fprint(w, `<ListBucketResult><Name>assets</Name><IsTruncated>false</IsTruncated><Contents><Key>z.txt</Key></Contents></ListBucketResult>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	listingURL := bucketListingURL
+	bucketListingURL = func(provider, bucket string) string {
+		return ts.URL + "/listing/" + bucket + "/"
+	}
+	defer func() { bucketListingURL = listingURL }()
+
+	found := findingsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.BucketListing = true
+	}), "bucket-objects")
+
+	if len(found) != 1 {
+		t.Fatalf("expected one bucket-objects finding, got %v", found)
+	}
+	want := []string{"a.txt", "img/logo.png", "z.txt"}
+	if found[0].Source != "s3" || found[0].Output != "assets" || !reflect.DeepEqual(found[0].Objects, want) {
+		t.Errorf("unexpected bucket finding %+v", found[0])
+	}
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"errors"
+	"github.com/gocolly/colly/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errCrawlStopped fails the requests not sent because the crawl was stopped
+var errCrawlStopped = errors.New("crawl stopped")
+
+// crawlBudget stops a crawl when it's interrupted or runs out of time or URLs.
+// Once stopped no new request is scheduled, the ones already sent finish normally.
+type crawlBudget struct {
+	maxURLs     int
+	maxJSFiles  int
+	maxDuration time.Duration
+	// Budget of the crawl a subdomain crawl was promoted from, its URLs and time are taken from it
+	parent *crawlBudget
+
+	mu       sync.Mutex
+	start    time.Time
+	end      time.Time
+	deadline time.Time
+	reason   string
+	urls     int
+	jsFiles  int
+}
+
+func newCrawlBudget(maxURLs, maxJSFiles int, maxDuration time.Duration) *crawlBudget {
+	return &crawlBudget{maxURLs: maxURLs, maxJSFiles: maxJSFiles, maxDuration: maxDuration}
+}
+
+// newSubBudget creates the budget of a subdomain crawl, stopped with parent and counting against it
+func newSubBudget(parent *crawlBudget) *crawlBudget {
+	return &crawlBudget{parent: parent}
+}
+
+// begin starts the crawl clock
+func (b *crawlBudget) begin() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.start = time.Now()
+	if b.maxDuration > 0 {
+		b.deadline = b.start.Add(b.maxDuration)
+	}
+}
+
+// finish stops the crawl clock
+func (b *crawlBudget) finish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.end = time.Now()
+}
+
+// stop stops the crawl, the first reason is kept
+func (b *crawlBudget) stop(reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopLocked(reason)
+}
+
+func (b *crawlBudget) stopLocked(reason string) {
+	if b.reason == "" {
+		b.reason = reason
+		Logger.Warnf("Stopping crawl: %s, waiting for requests in flight", reason)
+	}
+}
+
+// stopped returns why the crawl was stopped, empty while it goes on
+func (b *crawlBudget) stopped() string {
+	if b.parent != nil {
+		if reason := b.parent.stopped(); reason != "" {
+			return reason
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.reason == "" && !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		b.stopLocked("max crawl duration reached")
+	}
+	return b.reason
+}
+
+// allow counts a request at depth about to be scheduled in stats, false when the crawl is stopped
+func (b *crawlBudget) allow(stats *CrawlStats, depth int) bool {
+	if b.stopped() != "" {
+		return false
+	}
+	if root := b.root(); !root.take(&root.urls, root.maxURLs) {
+		root.stop("max URLs reached")
+		return false
+	}
+	stats.send(depth)
+	return true
+}
+
+// allowJS counts a link finder request at depth, false when the crawl is stopped or
+// maxJSFiles were fetched. Running out of JavaScript budget doesn't stop the crawl.
+func (b *crawlBudget) allowJS(stats *CrawlStats, depth int) bool {
+	if b.stopped() != "" {
+		return false
+	}
+	if root := b.root(); !root.take(&root.jsFiles, root.maxJSFiles) {
+		return false
+	}
+	stats.sendJS(depth)
+	return true
+}
+
+// root is the budget of the whole crawl, subdomain crawls included
+func (b *crawlBudget) root() *crawlBudget {
+	for b.parent != nil {
+		b = b.parent
+	}
+	return b
+}
+
+// take counts one more in *sent, false when limit were already sent (0 for no limit)
+func (b *crawlBudget) take(sent *int, limit int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if limit > 0 && *sent >= limit {
+		return false
+	}
+	*sent++
+	return true
+}
+
+// Start time, time spent crawling and the reason the crawl was stopped if it was
+func (b *crawlBudget) summary() (start time.Time, elapsed time.Duration, reason string) {
+	reason = b.stopped()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	end := b.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return b.start, end.Sub(b.start).Round(time.Millisecond), reason
+}
+
+// Check run last before each request of a collector, the link finder has its own budget.
+// Requests refused once the crawl is stopped stay pending in the resume state so a new run continues them.
+func budgetCheck(budget *crawlBudget, stats *CrawlStats, state *CrawlState, collector string) func(r *colly.Request) bool {
+	allow := budget.allow
+	if collector == "linkfinder" {
+		allow = budget.allowJS
+	}
+	return func(r *colly.Request) bool {
+		if allow(stats, r.Depth) {
+			return true
+		}
+		if state != nil && budget.stopped() != "" {
+			state.enqueue(collector, r)
+		}
+		return false
+	}
+}
+
+// stopTransport fails requests once the crawl is stopped, colly runs the request callbacks
+// before waiting for a free slot so scheduled requests would be sent otherwise
+type stopTransport struct {
+	base   http.RoundTripper
+	budget *crawlBudget
+}
+
+func (t *stopTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.budget.stopped() != "" {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, errCrawlStopped
+	}
+	return t.base.RoundTrip(req)
+}
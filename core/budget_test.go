@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrawlBudget(t *testing.T) {
+	b := newCrawlBudget(2, 0, 0)
+	stats := newCrawlStats()
+	b.begin()
+	if !b.allow(stats, 1) || !b.allow(stats, 1) || b.allow(stats, 1) {
+		t.Error("max URLs not enforced")
+	}
+	if _, _, reason := b.summary(); stats.Snapshot().Requests != 2 || reason != "max URLs reached" {
+		t.Errorf("summary() = %d, %q", stats.Snapshot().Requests, reason)
+	}
+
+	// The link finder budget is apart from the crawl one
+	b = newCrawlBudget(1, 2, 0)
+	stats = newCrawlStats()
+	if !b.allowJS(stats, 2) || !b.allowJS(stats, 2) || b.allowJS(stats, 2) || b.stopped() != "" {
+		t.Error("max JS files not enforced")
+	}
+	if !b.allow(stats, 1) || b.allow(stats, 1) {
+		t.Error("JS files counted against max URLs")
+	}
+	if snapshot := stats.Snapshot(); snapshot.Requests != 3 || snapshot.JSRequests != 2 {
+		t.Errorf("counted %d requests, %d JS", snapshot.Requests, snapshot.JSRequests)
+	}
+
+	b = newCrawlBudget(0, 0, time.Millisecond)
+	b.begin()
+	if !b.allow(stats, 1) {
+		t.Error("request refused before the deadline")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if b.allow(stats, 1) || b.stopped() != "max crawl duration reached" {
+		t.Error("max crawl duration not enforced")
+	}
+	b.stop("interrupted")
+	if b.stopped() != "max crawl duration reached" {
+		t.Error("first stop reason not kept")
+	}
+
+	// A subdomain crawl takes its URLs from the crawl it was promoted from and stops with it
+	b = newCrawlBudget(2, 0, 0)
+	sub := newSubBudget(b)
+	if !b.allow(newCrawlStats(), 1) || !sub.allow(newCrawlStats(), 1) || sub.allow(newCrawlStats(), 1) || b.stopped() != "max URLs reached" {
+		t.Error("max URLs not shared with the subdomain crawl")
+	}
+	b = newCrawlBudget(0, 0, 0)
+	sub = newSubBudget(b)
+	b.stop("interrupted")
+	if sub.allow(newCrawlStats(), 1) || sub.stopped() != "interrupted" {
+		t.Error("subdomain crawl not stopped with its parent")
+	}
+}
+
+// Site of a page linking to 20 others
+func linkedPagesServer(onHome func()) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, `<html>page</html>`)
+			return
+		}
+		if onHome != nil {
+			onHome()
+		}
+		for i := 0; i < 20; i++ {
+			fmt.Fprintf(w, `<a href="/page%d">page</a>`, i)
+		}
+	}))
+}
+
+func TestCrawlerMaxURLs(t *testing.T) {
+	var mu sync.Mutex
+	ts := linkedPagesServer(nil)
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL)
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.MaxURLs = 5
+	var found []string
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.OutputType == "url" {
+			found = append(found, r.Output)
+		}
+	}
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler.Run()
+
+	if len(found) != 5 {
+		t.Errorf("crawled %d URLs, want 5: %v", len(found), found)
+	}
+	if _, _, reason := crawler.budget.summary(); crawler.Stats().Requests != 5 || reason != "max URLs reached" {
+		t.Errorf("stopped with %d requests: %q", crawler.Stats().Requests, reason)
+	}
+}
+
+func TestCrawlerContextStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// Interrupted while the first page is in flight
+	ts := linkedPagesServer(func() {
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+	})
+	defer ts.Close()
+
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.Context = ctx
+	var mu sync.Mutex
+	var found []string
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.OutputType == "url" {
+			found = append(found, r.Output)
+		}
+	}
+	other := linkedPagesServer(nil)
+	defer other.Close()
+	if err := CrawlSites([]string{ts.URL, other.URL}, opts, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{ts.URL}; !reflect.DeepEqual(found, want) {
+		t.Errorf("found %v, want only the page in flight %v", found, want)
+	}
+}
+
+func TestCrawlerMaxURLsResume(t *testing.T) {
+	ts := linkedPagesServer(nil)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gospider-budget")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	crawl := func(maxURLs int) []SpiderOutput {
+		return findingsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+			opts.Depth = 2
+			opts.Resume = filepath.Join(dir, "state.db")
+			opts.MaxURLs = maxURLs
+		}), "url")
+	}
+
+	if found := crawl(1); len(found) != 1 {
+		t.Fatalf("first run found %v", found)
+	}
+	// Pages refused by the budget are left for the next run
+	if found := crawl(0); len(found) != 20 {
+		t.Errorf("resumed run found %d pages, want 20: %v", len(found), found)
+	}
+}
+
+func TestCrawlerLinkFinderLimits(t *testing.T) {
+	var mu sync.Mutex
+	var scripts, inFlight, maxInFlight int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".js") {
+			mu.Lock()
+			scripts++
+			inFlight++
+			if inFlight > maxInFlight {
+				maxInFlight = inFlight
+			}
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			fmt.Fprint(w, `var a = 1;`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		for i := 0; i < 6; i++ {
+			fmt.Fprintf(w, `<script src="/static/app%d.js"></script>`, i)
+		}
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/page1">1</a><a href="/page2">2</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	pages := findingsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+		opts.MaxURLs = 3
+		opts.JSConcurrent = 1
+		opts.MaxJSFiles = 4
+	}), "url")
+
+	if len(pages) != 3 {
+		t.Errorf("crawled %v, JavaScript fetches took the budget of pages", pages)
+	}
+	if scripts != 4 || maxInFlight != 1 {
+		t.Errorf("fetched %d scripts, %d at a time", scripts, maxInFlight)
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"github.com/gocolly/colly/v2"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// canonicalDedup collapses the URL variants of a page onto its canonical URL. The query
+// parameters a page drops in its <link rel="canonical"> (Ex: utm_source, sessionid) are
+// learned per host and left out of the dedup key of the next URLs of the host.
+type canonicalDedup struct {
+	mu sync.Mutex
+	// Query parameters not changing the page, by host
+	ignored map[string]map[string]bool
+	// Dedup keys of the pages requested or known by their canonical URL
+	seen map[string]bool
+}
+
+func newCanonicalDedup() *canonicalDedup {
+	return &canonicalDedup{
+		ignored: make(map[string]map[string]bool),
+		seen:    make(map[string]bool),
+	}
+}
+
+// Dedup key of u: scheme, host, path and the sorted query without the ignored parameters
+func (d *canonicalDedup) key(u *url.URL) string {
+	host := strings.ToLower(u.Host)
+	var params []string
+	for name, values := range u.Query() {
+		if d.ignored[host][name] {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	sort.Strings(params)
+	key := strings.ToLower(u.Scheme) + "://" + host + u.EscapedPath()
+	if len(params) > 0 {
+		key += "?" + strings.Join(params, "&")
+	}
+	return key
+}
+
+// Check run before each request, variants of a page already requested aren't crawled
+func (d *canonicalDedup) check(r *colly.Request) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := d.key(r.URL)
+	if d.seen[key] {
+		Logger.Debugf("Variant of a canonical URL: %s", r.URL)
+		return false
+	}
+	d.seen[key] = true
+	return true
+}
+
+// learn records the canonical URL of a page: it won't be requested again, and when both
+// are the same page path the parameters the canonical URL leaves out are ignored for the
+// host. It returns the parameters newly ignored.
+func (d *canonicalDedup) learn(page, canonical *url.URL) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seen[d.key(canonical)] = true
+
+	host := strings.ToLower(page.Host)
+	if host != strings.ToLower(canonical.Host) || page.EscapedPath() != canonical.EscapedPath() {
+		return nil
+	}
+	pageQuery, canonicalQuery := page.Query(), canonical.Query()
+	// Only a canonical URL keeping the other parameters as is tells which ones don't matter
+	for name, values := range canonicalQuery {
+		if strings.Join(pageQuery[name], "&") != strings.Join(values, "&") {
+			return nil
+		}
+	}
+	var learned []string
+	for name := range pageQuery {
+		if _, ok := canonicalQuery[name]; ok || d.ignored[host][name] {
+			continue
+		}
+		if d.ignored[host] == nil {
+			d.ignored[host] = make(map[string]bool)
+		}
+		d.ignored[host][name] = true
+		learned = append(learned, name)
+	}
+	sort.Strings(learned)
+	return learned
+}
+
+// Learn the canonical URL of a crawled page, see canonicalDedup
+func (crawler *Crawler) findCanonical(e *colly.HTMLElement) {
+	if crawler.canonical == nil {
+		return
+	}
+	href := strings.TrimSpace(e.Attr("href"))
+	if href == "" {
+		return
+	}
+	canonical, err := url.Parse(e.Request.AbsoluteURL(href))
+	if err != nil || canonical.Host == "" || !crawler.scope.InScope(canonical) {
+		return
+	}
+	canonical.Host = asciiHost(canonical.Host)
+	canonical.Fragment = ""
+	if learned := crawler.canonical.learn(e.Request.URL, canonical); len(learned) > 0 {
+		Logger.Infof("Ignoring parameters of %s not in its canonical URL: %s", canonical.Host, strings.Join(learned, ", "))
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestCanonicalDedup(t *testing.T) {
+	parse := func(raw string) *url.URL {
+		u, _ := url.Parse(raw)
+		return u
+	}
+	d := newCanonicalDedup()
+	learned := d.learn(parse("https://example.com/p?id=1&utm_source=mail&sid=abc"), parse("https://example.com/p?id=1"))
+	if !reflect.DeepEqual(learned, []string{"sid", "utm_source"}) {
+		t.Errorf("learned %v", learned)
+	}
+	if got := d.key(parse("https://Example.com/p?utm_source=ads&id=2&b=1")); got != "https://example.com/p?b=1&id=2" {
+		t.Errorf("key = %q", got)
+	}
+	// A canonical URL changing the other parameters tells nothing about them
+	if learned := d.learn(parse("https://example.com/list?page=2&sort=asc"), parse("https://example.com/list?page=1")); learned != nil {
+		t.Errorf("learned %v from another page", learned)
+	}
+	if !d.seen["https://example.com/p?id=1"] || !d.seen["https://example.com/list?page=1"] {
+		t.Error("canonical URLs not marked as seen")
+	}
+}
+
+func TestCrawlerCanonicalDedup(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.RequestURI())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><a href="/item?id=1&utm_source=home">item</a></html>`)
+		case "/item":
+			id := r.URL.Query().Get("id")
+			fmt.Fprintf(w, `<html><head><link rel="canonical" href="/item?id=%s"></head>
+<a href="/item?id=%s">self</a><a href="/item?id=1&utm_source=related">related</a><a href="/item?id=2&utm_source=related">next</a></html>`, id, id)
+		}
+	}))
+	defer ts.Close()
+
+	urls := len(findingsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 4
+		opts.Concurrent = 1
+		opts.CanonicalDedup = true
+	}), "url"))
+
+	sort.Strings(requested)
+	want := []string{"/", "/item?id=1&utm_source=home", "/item?id=2&utm_source=related"}
+	if !reflect.DeepEqual(requested, want) || urls != 3 {
+		t.Errorf("requested %v, reported %d urls", requested, urls)
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CertNames returns the CommonName and SubjectAltName DNS names of a certificate that are
+// domain or one of its subdomains, wildcards stripped (Ex: *.api.example.com gives api.example.com)
+func CertNames(cert *x509.Certificate, domain string) []string {
+	domain = strings.ToLower(domain)
+	var names []string
+	seen := make(map[string]bool)
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		name = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(name)), "*.")
+		if name == "" || seen[name] || (name != domain && !strings.HasSuffix(name, "."+domain)) {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// certHarvester sees the peer certificates of every TLS handshake of a crawler through
+// tls.Config.VerifyConnection, proxied connections included, and hands them to report
+type certHarvester struct {
+	mu     sync.Mutex
+	report func(host string, certs []*x509.Certificate)
+}
+
+// Hook the harvester into the TLS handshakes of config, the connections are never refused
+func (h *certHarvester) hook(config *tls.Config) {
+	config.VerifyConnection = func(cs tls.ConnectionState) error {
+		h.mu.Lock()
+		report := h.report
+		h.mu.Unlock()
+		if report != nil && len(cs.PeerCertificates) > 0 {
+			report(cs.ServerName, cs.PeerCertificates)
+		}
+		return nil
+	}
+}
+
+// Set the function the certificates are handed to, the crawler doesn't exist when the transport is built
+func (h *certHarvester) setReport(report func(host string, certs []*x509.Certificate)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.report = report
+}
+
+// Report the subdomains of the certificate chain of a host through the subdomain dedup set.
+// It runs in the TLS handshake, the subdomains are seeded in the background.
+func (crawler *Crawler) findCertSubdomains(host string, certs []*x509.Certificate) {
+	for _, cert := range certs {
+		for _, sub := range CertNames(cert, crawler.domain) {
+			if crawler.subSet.Duplicate(sub) {
+				continue
+			}
+			outputFormat := fmt.Sprintf("[cert-subdomain] - [from: %s] - %s", host, sub)
+			crawler.Report(outputFormat, SpiderOutput{
+				Source:     host,
+				OutputType: "cert-subdomain",
+				Output:     sub,
+			})
+			if crawler.opts.CrawlSubs {
+				crawler.crawlSubdomain(sub)
+			} else if crawler.opts.Subs {
+				crawler.certSeedWg.Add(1)
+				go func(sub string) {
+					defer crawler.certSeedWg.Done()
+					crawler.seedSubdomain(sub)
+				}(sub)
+			}
+		}
+	}
+}
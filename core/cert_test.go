@@ -0,0 +1,117 @@
+package core
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCertNames(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "Example.com"},
+		DNSNames: []string{"example.com", "*.API.example.com", "www.example.com", "example.org", "notexample.com"},
+	}
+	want := []string{"example.com", "api.example.com", "www.example.com"}
+	if got := CertNames(cert, "example.com"); !reflect.DeepEqual(got, want) {
+		t.Errorf("CertNames() = %v, want %v", got, want)
+	}
+}
+
+// Self-signed certificate of names
+func testCertificate(t *testing.T, names ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCrawlerCertSubdomains(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html>shop</html>`)
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{testCertificate(t, "shop.example.test", "*.internal.example.test", "other.org")}}
+	ts.StartTLS()
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	server := fakeDNSServer(t, map[string]net.IP{"shop.example.test": net.IPv4(127, 0, 0, 1)})
+
+	found := findingsByKey(crawlForTest(t, "https://shop.example.test:"+port+"/", func(opts *Options) {
+		opts.Resolvers = server
+	}))
+
+	if sub, ok := found["cert-subdomain internal.example.test"]; !ok || sub.Source != "shop.example.test" {
+		t.Errorf("certificate subdomain reported as %+v, found %v", sub, found)
+	}
+	if _, ok := found["cert-subdomain other.org"]; ok {
+		t.Error("name of another domain reported")
+	}
+}
+
+func TestCrawlerCertSubdomainsSeed(t *testing.T) {
+	requested := make(chan string, 10)
+	release := make(chan struct{})
+	// Proxy for every host of example.test, slow to answer the seeds
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested <- r.Host + r.URL.Path
+		<-release
+		http.NotFound(w, r)
+	}))
+	defer proxy.Close()
+
+	site, _ := url.Parse("http://example.test/")
+	opts := DefaultOptions()
+	opts.Quiet = true
+	opts.Proxy = proxy.URL
+	opts.Subs = true
+	opts.Robots = true
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(testCertificate(t, "shop.example.test").Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The handshake goes on while the subdomain is seeded
+	handshake := make(chan struct{})
+	go func() {
+		crawler.findCertSubdomains("example.test", []*x509.Certificate{cert})
+		close(handshake)
+	}()
+	if path := <-requested; path != "shop.example.test/robots.txt" {
+		t.Errorf("requested %s", path)
+	}
+	select {
+	case <-handshake:
+	case <-time.After(time.Second):
+		t.Error("handshake held by the seed of the subdomain")
+	}
+	close(release)
+	crawler.wait()
+}
@@ -0,0 +1,173 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms of Options.Compress
+const (
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// CompressExt is the extension appended to the files compressed with algo, empty without compression
+func CompressExt(algo string) string {
+	switch algo {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// ValidCompression reports whether algo is a supported Options.Compress value
+func ValidCompression(algo string) error {
+	if algo != "" && CompressExt(algo) == "" {
+		return fmt.Errorf("unknown compression %q, use gzip or zstd", algo)
+	}
+	return nil
+}
+
+func newCompressWriter(w io.Writer, algo string) (io.WriteCloser, error) {
+	switch algo {
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		return zstd.NewWriter(w)
+	}
+	return nil, ValidCompression(algo)
+}
+
+// Compress data as one gzip or zstd stream
+func compressBytes(data []byte, algo string) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := newCompressWriter(&buf, algo)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// compressedFile is an output file appended to through one compressed stream. Several sinks
+// may write to the same file (external.txt is shared by the sites), two streams appended
+// at once would mix their blocks so they share the file and its stream.
+type compressedFile struct {
+	path string
+	mu   sync.Mutex
+	f    *os.File
+	w    io.WriteCloser
+	refs int
+}
+
+// Compressed output files open, by path
+var compressedFiles = struct {
+	mu    sync.Mutex
+	files map[string]*compressedFile
+}{files: make(map[string]*compressedFile)}
+
+// openOutputFile opens path to append to, as a gzip or zstd stream with algo. Compressed files
+// get a new stream (a gzip member or zstd frame) appended, the tools reading them handle that.
+// The data is compressed in blocks, the file is complete once closed.
+func openOutputFile(path string, perm os.FileMode, algo string) (io.WriteCloser, error) {
+	if algo == "" {
+		return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	}
+	compressedFiles.mu.Lock()
+	defer compressedFiles.mu.Unlock()
+	if cf, ok := compressedFiles.files[path]; ok {
+		cf.refs++
+		return cf, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newCompressWriter(f, algo)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	cf := &compressedFile{path: path, f: f, w: w, refs: 1}
+	compressedFiles.files[path] = cf
+	return cf, nil
+}
+
+func (cf *compressedFile) Write(p []byte) (int, error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.w.Write(p)
+}
+
+// Close ends the stream and closes the file once every sink writing to it is closed
+func (cf *compressedFile) Close() error {
+	compressedFiles.mu.Lock()
+	defer compressedFiles.mu.Unlock()
+	cf.refs--
+	if cf.refs > 0 {
+		return nil
+	}
+	delete(compressedFiles.files, cf.path)
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	err := cf.w.Close()
+	if closeErr := cf.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// OpenDecompressed opens path for reading, files ending with .gz or .zst are decompressed
+func OpenDecompressed(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasSuffix(path, CompressExt(CompressGzip)):
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read %s: %s", path, err)
+		}
+		return readCloser{Reader: r, closers: []io.Closer{r, f}}, nil
+	case strings.HasSuffix(path, CompressExt(CompressZstd)):
+		d, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read %s: %s", path, err)
+		}
+		return readCloser{Reader: d, closers: []io.Closer{d.IOReadCloser(), f}}, nil
+	}
+	return f, nil
+}
+
+// readCloser closes a decompressor and the file under it
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r readCloser) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
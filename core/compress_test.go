@@ -0,0 +1,139 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func readDecompressed(t *testing.T, path string) string {
+	t.Helper()
+	r, err := OpenDecompressed(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCompressedOutput(t *testing.T) {
+	for _, algo := range []string{CompressGzip, CompressZstd} {
+		t.Run(algo, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gospider-compress")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			// Two sites writing external.txt at once, then a later run appending to it
+			a, err := NewCompressedOutput(dir, "external.txt", algo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := NewCompressedOutput(dir, "external.txt", algo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := ""
+			for i := 0; i < 1000; i++ {
+				line := strings.Repeat("x", i%50)
+				if err := a.Write(SpiderOutput{}, "a"+line); err != nil {
+					t.Fatal(err)
+				}
+				if err := b.Write(SpiderOutput{}, "b"+line); err != nil {
+					t.Fatal(err)
+				}
+				want += "a" + line + "\nb" + line + "\n"
+			}
+			a.Close()
+			b.Close()
+			c, err := NewCompressedOutput(dir, "external.txt", algo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.WriteToFile("next run")
+			c.Close()
+			want += "next run\n"
+
+			path := filepath.Join(dir, "external.txt"+CompressExt(algo))
+			if got := readDecompressed(t, path); got != want {
+				t.Errorf("decompressed output differs, %d bytes instead of %d", len(got), len(want))
+			}
+			if info, err := os.Stat(path); err != nil || info.Size() >= int64(len(want)/4) {
+				t.Errorf("output not compressed: %v %v", info, err)
+			}
+		})
+	}
+}
+
+func TestCompressedResponseStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := NewResponseStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	store.compress = CompressZstd
+
+	u, _ := url.Parse("https://example.com/")
+	headers := http.Header{"Content-Type": {"text/html"}}
+	relPath, err := store.Save(&colly.Response{
+		StatusCode: 200,
+		Body:       []byte("<html>home</html>"),
+		Headers:    &headers,
+		Request:    &colly.Request{URL: u, Method: "GET"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(relPath, ".txt.zst") {
+		t.Errorf("compressed response saved as %s", relPath)
+	}
+	if got := readDecompressed(t, filepath.Join(dir, relPath)); !strings.HasSuffix(got, "\r\n\r\n<html>home</html>") {
+		t.Errorf("unexpected stored response:\n%s", got)
+	}
+}
+
+func TestLoadCompressedPreviousRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	o, err := NewCompressedOutput(dir, "example_com", CompressGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.WriteToFile(`{"input":"https://example.com/","type":"url","output":"https://example.com/a"}`)
+	o.Close()
+
+	run, err := LoadPreviousRun(filepath.Join(dir, "example_com.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := run.sites["https://example.com/"]["url https://example.com/a"]; !ok {
+		t.Errorf("finding not loaded: %v", run.sites)
+	}
+}
+
+func TestValidCompression(t *testing.T) {
+	for algo, valid := range map[string]bool{"": true, "gzip": true, "zstd": true, "bzip2": false} {
+		if err := ValidCompression(algo); (err == nil) != valid {
+			t.Errorf("ValidCompression(%q) = %v", algo, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/gocolly/colly/v2"
+	"sort"
+	"strings"
+)
+
+// Parsers of structured responses, see Options.ContentParsers
+const (
+	ParserJSON    = "json"
+	ParserXML     = "xml"
+	ParserRSS     = "rss"
+	ParserSitemap = "sitemap"
+)
+
+// ContentParsers are all the parsers, enabled by default
+var ContentParsers = []string{ParserJSON, ParserXML, ParserRSS, ParserSitemap}
+
+// Contexts of the links found in JSON documents, XML documents and RSS/Atom feeds,
+// shown in the url findings of the pages they lead to like the ones of EmbeddedLinks
+const (
+	ContextJSON = "json"
+	ContextXML  = "xml"
+	ContextRSS  = "rss"
+)
+
+// ContentParser returns the parser of a response by its Content-Type, and for XML by its
+// root element (RSS/Atom feeds and sitemaps have their own), "" when the body isn't structured
+func ContentParser(contentType string, body []byte) string {
+	mediaType := strings.ToLower(MediaType(contentType))
+	switch {
+	case mediaType == "application/json", mediaType == "text/json", mediaType == "application/x-ndjson",
+		strings.HasSuffix(mediaType, "+json"):
+		return ParserJSON
+	case mediaType == "application/rss+xml", mediaType == "application/atom+xml":
+		return ParserRSS
+	// XHTML is crawled as HTML
+	case mediaType == "application/xhtml+xml":
+		return ""
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		switch xmlRoot(body) {
+		case "rss", "feed", "RDF":
+			return ParserRSS
+		case "urlset", "sitemapindex":
+			return ParserSitemap
+		}
+		return ParserXML
+	}
+	return ""
+}
+
+// Local name of the root element of an XML document
+func xmlRoot(data []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// JSONLinks returns the string values of a JSON document, or of a stream of documents
+// (Ex: NDJSON), shaped like a URL or a path. Object keys are skipped, object values
+// are walked by key order.
+func JSONLinks(data []byte) []string {
+	var links []string
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			if looksLikeLink(v) {
+				links = append(links, strings.TrimSpace(v))
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(v[key])
+			}
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return links
+		}
+		walk(value)
+	}
+}
+
+// XMLLinks returns the attribute values and texts of an XML document shaped like a URL
+// or a path, in document order. It covers RSS and Atom feeds (<link>, <link href>,
+// <enclosure url>, <guid>), sitemaps (<loc>) and any other XML.
+func XMLLinks(data []byte) []string {
+	var links []string
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return links
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				// Namespace declarations are URLs, not links
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				if looksLikeLink(attr.Value) {
+					links = append(links, strings.TrimSpace(attr.Value))
+				}
+			}
+		case xml.CharData:
+			if text := string(t); looksLikeLink(text) {
+				links = append(links, strings.TrimSpace(text))
+			}
+		}
+	}
+}
+
+// Crawl the links of JSON and XML responses with the enabled parsers, links out of scope
+// are reported as external ones. Sitemaps reached by the crawl are parsed like the brute
+// forced ones.
+func (crawler *Crawler) parseContent(response *colly.Response) {
+	parser := ContentParser(response.Headers.Get("Content-Type"), response.Body)
+	if parser == "" || !crawler.contentParsers[parser] {
+		return
+	}
+
+	source := response.Request.URL.String()
+	var refs []string
+	var context string
+	switch parser {
+	case ParserSitemap:
+		if !crawler.sitemapSet.Duplicate(source) {
+			crawler.parseSitemapBody(source, response.Body, 0)
+		}
+		return
+	case ParserJSON:
+		refs, context = JSONLinks(response.Body), ContextJSON
+	case ParserRSS:
+		refs, context = XMLLinks(response.Body), ContextRSS
+	default:
+		refs, context = XMLLinks(response.Body), ContextXML
+	}
+
+	for _, ref := range refs {
+		urlString := crawler.resolveRequestRef(response.Request, ref)
+		if urlString == "" {
+			continue
+		}
+		crawler.reportExternalRef(source, urlString)
+		if !crawler.urlSet.Duplicate(crawler.urlKey(urlString)) {
+			crawler.linkContexts.add(urlString, context)
+			if err := crawler.mainVisits.visitFrom(response.Request, urlString); err != nil {
+				crawler.linkContexts.take(urlString)
+			}
+		}
+	}
+}
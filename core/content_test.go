@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestContentParser(t *testing.T) {
+	for _, tc := range []struct {
+		contentType string
+		body        string
+		want        string
+	}{
+		{"application/json; charset=utf-8", `{}`, ParserJSON},
+		{"application/hal+json", `{}`, ParserJSON},
+		{"application/rss+xml", `<rss/>`, ParserRSS},
+		{"text/xml", `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"/>`, ParserRSS},
+		{"application/xml", `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"/>`, ParserSitemap},
+		{"application/xml", `<catalog/>`, ParserXML},
+		{"application/xhtml+xml", `<html/>`, ""},
+		{"text/html", `<html/>`, ""},
+	} {
+		if got := ContentParser(tc.contentType, []byte(tc.body)); got != tc.want {
+			t.Errorf("ContentParser(%q, %q) = %q, want %q", tc.contentType, tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestJSONLinks(t *testing.T) {
+	data := `{"next": "/api/items?page=2", "self": "https://example.com/api/items", "/key/path": "value",
+"items": [{"id": 1, "image": "//cdn.example.com/1.png", "name": "a b"}, {"id": 2, "url": "./2"}], "type": "application/json"}
+{"href": "../up"}`
+	want := []string{"//cdn.example.com/1.png", "./2", "/api/items?page=2", "https://example.com/api/items", "../up"}
+	if got := JSONLinks([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("JSONLinks() = %v, want %v", got, want)
+	}
+}
+
+func TestXMLLinks(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+<link href="https://example.com/blog"/>
+<entry><title>Post</title><link>https://example.com/blog/post</link><enclosure url="/media/post.mp3"/></entry>
+</feed>`
+	want := []string{"https://example.com/blog", "https://example.com/blog/post", "/media/post.mp3"}
+	if got := XMLLinks([]byte(data)); !reflect.DeepEqual(got, want) {
+		t.Errorf("XMLLinks() = %v, want %v", got, want)
+	}
+}
+
+func TestCrawlerContentParsers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><a href="/api/products">products</a><a href="/feed">feed</a></html>`)
+		case "/api/products":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"items": [{"href": "/api/products/1"}], "docs": "https://docs.example.org/api"}`)
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			fmt.Fprint(w, `<rss><channel><item><link>/blog/post</link></item></channel></rss>`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html>page</html>`)
+		}
+	}))
+	defer ts.Close()
+
+	crawl := func(parsers []string) map[string]SpiderOutput {
+		return findingsByKey(crawlForTest(t, ts.URL, func(opts *Options) {
+			opts.Depth = 3
+			opts.ContentParsers = parsers
+			// The link finder also finds the paths of JSON responses
+			if parsers == nil {
+				opts.LinkFinderContent = nil
+			}
+		}))
+	}
+
+	found := crawl(DefaultOptions().ContentParsers)
+	if product, ok := found["url "+ts.URL+"/api/products/1"]; !ok || product.Details["context"] != ContextJSON {
+		t.Errorf("JSON link reported as %+v", product)
+	}
+	if post, ok := found["url "+ts.URL+"/blog/post"]; !ok || post.Details["context"] != ContextRSS {
+		t.Errorf("feed link reported as %+v", post)
+	}
+	if _, ok := found["external https://docs.example.org/api"]; !ok {
+		t.Error("out of scope JSON link not reported as external")
+	}
+
+	found = crawl(nil)
+	if _, ok := found["url "+ts.URL+"/api/products/1"]; ok {
+		t.Error("JSON link crawled with the parsers disabled")
+	}
+}
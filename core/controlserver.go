@@ -0,0 +1,67 @@
+package core
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ServeControl serves the endpoints changing the running crawls at addr, until the returned
+// server is closed: hosts and paths posted to addr/scope are taken out of their scope, headers
+// posted to addr/auth are added to their requests, and the queues of the crawls run with a
+// resume state are at addr/snapshot.
+// Every request needs the "Authorization: Bearer <token>" header. Without a host, addr
+// only listens on 127.0.0.1.
+func ServeControl(addr, token string) (*http.Server, error) {
+	if token == "" {
+		return nil, errors.New("failed to serve crawl control: a token is required")
+	}
+	addr, err := controlListenAddr(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve crawl control: %s", err)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve crawl control: %s", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scope", serveScope)
+	mux.HandleFunc("/auth", serveAuth)
+	mux.HandleFunc("/snapshot", serveSnapshot)
+	server := &http.Server{Addr: ln.Addr().String(), Handler: requireBearer(token, mux)}
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			Logger.Errorf("Control server failed: %s", err)
+		}
+	}()
+	Logger.Infof("Serving crawl control on http://%s", ln.Addr())
+	return server, nil
+}
+
+// Address the control server listens on, the loopback one when addr has no host
+func controlListenAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// requireBearer answers 401 to the requests without the bearer token
+func requireBearer(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="gospider"`)
+			http.Error(w, "missing or wrong bearer token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServeControl(t *testing.T) {
+	if _, err := ServeControl("127.0.0.1:0", ""); err == nil {
+		t.Error("control served without a token")
+	}
+	server, err := ServeControl("127.0.0.1:0", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	metrics, err := ServeMetrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		metrics.Close()
+		crawlMetrics.mu.Lock()
+		crawlMetrics.enabled = false
+		crawlMetrics.mu.Unlock()
+	}()
+
+	// Read only requests, or adding a header nobody checks
+	request := func(base, path, authorization string) int {
+		method, body := "GET", ""
+		if path == "/auth" {
+			method, body = "POST", "X-Session: abc\n"
+		}
+		req, err := http.NewRequest(method, base+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	control := fmt.Sprintf("http://%s", server.Addr)
+	for _, path := range []string{"/auth", "/scope", "/snapshot"} {
+		for _, authorization := range []string{"", "Bearer wrong", "s3cret", "Basic czNjcmV0"} {
+			if code := request(control, path, authorization); code != http.StatusUnauthorized {
+				t.Errorf("%s with %q answered %d", path, authorization, code)
+			}
+		}
+		if code := request(control, path, "Bearer s3cret"); code != http.StatusOK {
+			t.Errorf("%s with the token answered %d", path, code)
+		}
+		if code := request(fmt.Sprintf("http://%s", metrics.Addr), path, ""); code != http.StatusNotFound {
+			t.Errorf("metrics server answered %d to %s", code, path)
+		}
+	}
+}
+
+func TestControlListenAddr(t *testing.T) {
+	for _, tt := range []struct {
+		addr, want string
+	}{
+		{":9091", "127.0.0.1:9091"},
+		{"0.0.0.0:9091", "0.0.0.0:9091"},
+		{"[::1]:9091", "[::1]:9091"},
+	} {
+		if got, err := controlListenAddr(tt.addr); err != nil || got != tt.want {
+			t.Errorf("controlListenAddr(%q) = %q, %v, want %q", tt.addr, got, err, tt.want)
+		}
+	}
+	if _, err := controlListenAddr("9091"); err == nil {
+		t.Error("no error for an address without a port")
+	}
+}
@@ -3,20 +3,28 @@ package core
 import (
 	"bufio"
 	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gocolly/colly/v2"
 	"github.com/gocolly/colly/v2/extensions"
+	"github.com/gocolly/colly/v2/storage"
 	"github.com/jaeles-project/gospider/stringset"
 	"github.com/spf13/cobra"
+	"golang.org/x/net/publicsuffix"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultHTTPTransport is the base transport, every crawler works on its own clone
 var DefaultHTTPTransport = &http.Transport{
 	DialContext: (&net.Dialer{
 		Timeout: 10 * time.Second,
@@ -33,68 +41,308 @@ var DefaultHTTPTransport = &http.Transport{
 	TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
 }
 
+// How often the resume state is saved during a crawl
+var stateCheckpointInterval = 10 * time.Second
+
+// Subdomains resolved and probed at the same time with --resolve-subs
+const subProbeWorkers = 10
+
+// Serializes stdout so findings of concurrent crawlers don't interleave
+var stdoutMu sync.Mutex
+
 type Crawler struct {
-	cmd                 *cobra.Command
 	C                   *colly.Collector
 	LinkFinderCollector *colly.Collector
-	Output              *Output
+	Sinks               []OutputSink
+
+	opts     Options
+	client   *http.Client
+	headers  http.Header
+	renderer *Renderer
+	auth     *authTracker
+	session  *loginSession
+	budget   *crawlBudget
+	control  *crawlControl
+	stats    *CrawlStats
+	state    *CrawlState
+	scope    *Scope
+	store    *ResponseStore
+	queue    *sharedQueue
+	// Global rate and per host backoff with --rate-limit, nil without
+	limits *rateLimits
+	// Traffic per host with --politeness-report
+	politeness *politenessTransport
+	// Screenshots of the pages with --screenshot
+	screenshots *screenshotter
+	// Probes of the buckets found with --check-buckets
+	bucketChecks *bucketChecker
+	// Findings of the previous run with --diff
+	diff *crawlDiff
+	// Paths and URLs of --seed-file
+	seeds []string
+	// Media types per host with --mime-stats
+	contentTypes *contentTypeStats
+	// 401 and 403 answers held back with --restricted-areas
+	restricted *restrictedAreas
+	// Responses not reported as url findings
+	responseFilter *ResponseFilter
+	// Error statuses reported anyway, see isReportedError
+	reportErrors []IntRange
 
-	subSet  *stringset.StringFilter
-	awsSet  *stringset.StringFilter
-	jsSet   *stringset.StringFilter
-	urlSet  *stringset.StringFilter
-	formSet *stringset.StringFilter
+	subSet  stringset.Filter
+	awsSet  stringset.Filter
+	jsSet   stringset.Filter
+	urlSet  stringset.Filter
+	formSet stringset.Filter
+	xhrSet  stringset.Filter
+
+	sitemapSet stringset.Filter
+	methodSet  stringset.Filter
+	secretSet  stringset.Filter
+
+	negotiationSet stringset.Filter
+	misconfigSet   stringset.Filter
+	gitSet         stringset.Filter
+	graphqlSet     stringset.Filter
+	backendSet     stringset.Filter
+	awsIdentitySet stringset.Filter
+	googleKeySet   stringset.Filter
+	externalSet    stringset.Filter
+	includeSet     stringset.Filter
+	sourceMapSet   stringset.Filter
+	apiSet         stringset.Filter
+	customSet      stringset.Filter
+	paramSet       stringset.Filter
+	bucketCheckSet stringset.Filter
+	anomalySet     stringset.Filter
+	jsLibSet       stringset.Filter
+
+	rules        *Rules
+	secretRules  []SecretRule
+	extractRules []ExtractRule
+	buckets      *bucketInventory
+	// Representatives of the URL patterns with --sample-per-pattern
+	sampler *urlSampler
+	// Canonical URLs of the pages with --canonical-dedup
+	canonical *canonicalDedup
+	// Locale variants of the pages with --locale-dedup
+	locales *localeDedup
+	// Where the links found outside href and src come from
+	linkContexts *linkContexts
+	// Enabled parsers of JSON and XML responses
+	contentParsers map[string]bool
+	// Contents of the responses LinkFinder scans, see LinkFinderContents
+	linkFinderContent map[string]bool
+	// Response handlers still running past --handler-timeout
+	slowHandlers *handlerPool
+	// Requests of the collectors found outside their own fetches
+	mainVisits       *visitGate
+	linkFinderVisits *visitGate
+	// JavaScript files the link finder may fetch
+	jsPolicy *jsPolicy
+	// Unmasked findings with --redact, also in Sinks to be closed
+	sensitive *SensitiveOutput
 
 	site   *url.URL
 	domain string
+	// Name of the findings file of the site in the output folder
+	outputName string
+
+	subCrawled   int
+	subCrawlerMu sync.Mutex
+	subCrawlerWg sync.WaitGroup
+	// Subdomains being resolved and probed, at most subProbeWorkers at a time
+	resolver   *net.Resolver
+	subProbes  chan struct{}
+	subProbeWg sync.WaitGroup
+	// Subdomains of TLS certificates being seeded, away from the handshake that found them
+	certSeedWg sync.WaitGroup
 }
 
+// NewCrawler creates a Crawler configured by the gospider command flags, it exits on invalid configuration
 func NewCrawler(site *url.URL, cmd *cobra.Command) *Crawler {
-	domain := GetDomain(site)
-	if domain == "" {
-		Logger.Error("Failed to parse domain")
+	crawler, err := NewCrawlerWithOptions(site, OptionsFromCommand(cmd))
+	if err != nil {
+		Logger.Error(err)
 		os.Exit(1)
 	}
-	Logger.Infof("Crawling site: %s", site)
+	return crawler
+}
 
-	maxDepth, _ := cmd.Flags().GetInt("depth")
-	concurrent, _ := cmd.Flags().GetInt("concurrent")
-	delay, _ := cmd.Flags().GetInt("delay")
-	randomDelay, _ := cmd.Flags().GetInt("random-delay")
+// NewCrawlerWithOptions creates a Crawler for site
+func NewCrawlerWithOptions(site *url.URL, opts Options) (*Crawler, error) {
+	return newCrawler(site, opts, nil)
+}
+
+// newCrawler creates a Crawler for site, sharing the dedup filters and rate limits of a Manager,
+// or the budget and rate limits of a parent crawl, when shared is set
+func newCrawler(site *url.URL, opts Options, shared *crawlShare) (*Crawler, error) {
+	// Unicode hosts are crawled by their punycode form, the domain and scope are built from it
+	site = asciiURL(site)
+	domain := GetDomain(site)
+	if domain == "" {
+		return nil, fmt.Errorf("failed to parse domain of %s", site)
+	}
+	if unicode := unicodeOutput(site.String()); unicode != "" {
+		Logger.Infof("Crawling site: %s (%s)", site, unicode)
+	} else {
+		Logger.Infof("Crawling site: %s", site)
+	}
+	// Known entry points crawled next to the site
+	var seeds []string
+	if opts.SeedFile != "" {
+		var err error
+		if seeds, err = ReadSeedFile(opts.SeedFile); err != nil {
+			return nil, fmt.Errorf("failed to read seed file: %s", err)
+		}
+	}
+	// Findings of the previous run are hidden with --diff
+	var diff *crawlDiff
+	if opts.Diff != "" {
+		previous, err := LoadPreviousRun(opts.Diff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load previous run: %s", err)
+		}
+		diff = newCrawlDiff(previous, site.String())
+	}
 
 	c := colly.NewCollector(
 		colly.Async(true),
-		colly.MaxDepth(maxDepth),
+		colly.MaxDepth(opts.Depth),
 		colly.IgnoreRobotsTxt(),
+		// Bodies are cut by sizeCapTransport, which tells when it cut them
+		colly.MaxBodySize(0),
 	)
+	// Visited URLs are shared with the link finder collector, set before the client so its jar is kept
+	visited := &storage.InMemoryStorage{}
+	if err := c.SetStorage(visited); err != nil {
+		return nil, err
+	}
+
+	// Load the login script, the session it gets is kept by the cookie jar
+	var session *loginSession
+	if opts.LoginConfig != "" {
+		if opts.NoCookieJar {
+			return nil, fmt.Errorf("login config needs the cookie jar, it can't be disabled")
+		}
+		config, err := LoadLoginConfig(opts.LoginConfig)
+		if err != nil {
+			return nil, err
+		}
+		session = newLoginSession(config)
+	}
 
 	// Setup http client
 	client := &http.Client{}
 
-	// Set proxy
-	proxy, _ := cmd.Flags().GetString("proxy")
-	if proxy != "" {
-		Logger.Info("Proxy: %s", proxy)
-		pU, err := url.Parse(proxy)
+	// Keep the cookies set by the site across requests, the collectors and probes share the client
+	if !opts.NoCookieJar {
+		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 		if err != nil {
-			Logger.Error("Failed to set proxy")
-		} else {
-			DefaultHTTPTransport.Proxy = http.ProxyURL(pU)
+			return nil, err
+		}
+		client.Jar = jar
+	}
+	transport := DefaultHTTPTransport.Clone()
+
+	// Resolve hosts with the configured resolvers, the crawl and the subdomain checks alike
+	resolver := net.DefaultResolver
+	if opts.Resolvers != "" {
+		servers, err := ParseResolvers(opts.Resolvers)
+		if err != nil {
+			return nil, err
+		}
+		resolver = NewResolver(servers)
+		transport.DialContext = (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Resolver:  resolver,
+		}).DialContext
+	}
+	// Spread the connections over several local addresses
+	if len(opts.SourceIPs) > 0 {
+		ips, err := ParseSourceIPs(opts.SourceIPs)
+		if err != nil {
+			return nil, err
+		}
+		transport.DialContext = SourceIPDialer(ips, resolver)
+	}
+
+	// Set proxy, a proxy list takes precedence
+	if opts.ProxyList != "" {
+		proxies, err := LoadProxyList(opts.ProxyList)
+		if err != nil {
+			return nil, err
+		}
+		Logger.Infof("Rotating %d proxies", len(proxies))
+		transport.Proxy = RoundRobinProxy(proxies)
+	} else if opts.Proxy != "" {
+		Logger.Info("Proxy: ", opts.Proxy)
+		pU, err := ParseProxy(opts.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(pU)
+	}
+
+	// Custom dialer and TLS config disable HTTP/2 unless it's forced
+	transport.ForceAttemptHTTP2 = !opts.NoHTTP2
+
+	// Set connection pool
+	transport.MaxIdleConns = opts.MaxIdleConns
+	transport.MaxConnsPerHost = opts.MaxConnsPerHost
+	transport.IdleConnTimeout = opts.IdleConnTimeout
+	transport.DisableKeepAlives = opts.NoKeepAlive
+
+	// Set TLS versions and cipher suites
+	if opts.TLSMinVersion != "" {
+		v, err := ParseTLSVersion(opts.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.MinVersion = v
+	}
+	if opts.TLSMaxVersion != "" {
+		v, err := ParseTLSVersion(opts.TLSMaxVersion)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.MaxVersion = v
+	}
+	if opts.TLSCiphers != "" {
+		ciphers, err := ParseCipherSuites(opts.TLSCiphers)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig.CipherSuites = ciphers
+	}
+
+	// Set SNI different from the connect host (Ex: origin behind CDN)
+	if opts.SNI != "" {
+		transport.TLSClientConfig.ServerName = opts.SNI
+	}
+	// Present a client certificate to servers requiring mutual TLS
+	if opts.ClientCert != "" {
+		cert, err := LoadClientCertificate(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, err
 		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
 	}
+	// Harvest the subdomains of the certificates of the hosts connected to
+	certs := &certHarvester{}
+	certs.hook(transport.TLSClientConfig)
 
 	// Set request timeout
-	timeout, _ := cmd.Flags().GetInt("timeout")
+	timeout := opts.Timeout
 	if timeout == 0 {
 		Logger.Info("Your input timeout is 0. Gospider will set it to 10 seconds")
-		client.Timeout = 10 * time.Second
-	} else {
-		client.Timeout = time.Duration(timeout) * time.Second
+		timeout = 10 * time.Second
 	}
+	client.Timeout = timeout
 
 	// Disable redirect
-	noRedirect, _ := cmd.Flags().GetBool("no-redirect")
-	if noRedirect {
+	if opts.NoRedirect {
 		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 			nextLocation := req.Response.Header.Get("Location")
 			Logger.Debugf("Found Redirect: %s", nextLocation)
@@ -110,61 +358,133 @@ func NewCrawler(site *url.URL, cmd *cobra.Command) *Crawler {
 	}
 
 	// Set client transport
-	client.Transport = DefaultHTTPTransport
+	client.Transport = transport
+	// Count every request on the wire, retries included
+	var politeness *politenessTransport
+	if opts.PolitenessReport {
+		politeness = newPolitenessTransport(transport, site.String())
+		client.Transport = politeness
+	}
+	if opts.MaxResponseSize > 0 {
+		client.Transport = &sizeCapTransport{base: client.Transport, limit: int64(opts.MaxResponseSize)}
+	}
+	var limits *rateLimits
+	if opts.RateLimit > 0 {
+		// Waiting for the limiter mustn't count as request time,
+		// the limiter applies the timeout to each attempt instead of the client
+		limiter := newRateLimitTransport(client.Transport, opts.RateLimit, opts.Concurrent, timeout)
+		if shared != nil && shared.limits != nil {
+			limiter.limits = shared.limits
+		}
+		limits = limiter.limits
+		client.Transport = limiter
+		client.Timeout = 0
+	}
+	if opts.Retries > 0 {
+		// Backing off mustn't count as request time either, each attempt has its own timeout
+		client.Transport = newRetryTransport(client.Transport, opts.Retries, client.Timeout)
+		client.Timeout = 0
+	}
+	budget := newCrawlBudget(opts.MaxURLs, opts.MaxJSFiles, opts.MaxCrawlDuration)
+	if shared != nil && shared.budget != nil {
+		budget = newSubBudget(shared.budget)
+	}
+	stats := newCrawlStats()
+	// Pause, concurrency and blacklist changes of the TUI apply to scheduled requests
+	crawlControls.mu.Lock()
+	var control *crawlControl
+	if crawlControls.enabled {
+		control = crawlControls
+	}
+	crawlControls.mu.Unlock()
+	if control != nil {
+		controlled := &controlTransport{base: client.Transport, control: control, budget: budget, parallelism: opts.Concurrent}
+		if client.Timeout > 0 {
+			controlled.timeout = client.Timeout
+			client.Timeout = 0
+		}
+		client.Transport = controlled
+	}
+	client.Transport = &stopTransport{base: client.Transport, budget: budget}
 	c.SetClient(client)
 
+	// Headers sent with every request, also used by the probes outside colly
+	headers := http.Header{}
+
 	// Get headers here to overwrite if "burp" flag used
-	burpFile, _ := cmd.Flags().GetString("burp")
-	if burpFile != "" {
-		bF, err := os.Open(burpFile)
+	if opts.Burp != "" {
+		bF, err := os.Open(opts.Burp)
 		if err != nil {
 			Logger.Errorf("Failed to open Burp File: %s", err)
 		} else {
 			rd := bufio.NewReader(bF)
 			req, err := http.ReadRequest(rd)
 			if err != nil {
-				Logger.Errorf("Failed to Parse Raw Request in %s: %s", burpFile, err)
+				Logger.Errorf("Failed to Parse Raw Request in %s: %s", opts.Burp, err)
 			} else {
 				// Set cookie
-				c.OnRequest(func(r *colly.Request) {
-					r.Headers.Set("Cookie", GetRawCookie(req.Cookies()))
-				})
+				headers.Set("Cookie", GetRawCookie(req.Cookies()))
 
 				// Set headers
-				c.OnRequest(func(r *colly.Request) {
-					for k, v := range req.Header {
-						r.Headers.Set(strings.TrimSpace(k), strings.TrimSpace(v[0]))
-					}
-				})
-
+				for k, v := range req.Header {
+					headers.Set(strings.TrimSpace(k), strings.TrimSpace(v[0]))
+				}
 			}
+			bF.Close()
 		}
 	}
 
 	// Set cookies
-	cookie, _ := cmd.Flags().GetString("cookie")
-	if cookie != "" && burpFile == "" {
-		c.OnRequest(func(r *colly.Request) {
-			r.Headers.Set("Cookie", cookie)
-		})
+	if opts.Cookie != "" && opts.Burp == "" {
+		headers.Set("Cookie", opts.Cookie)
 	}
 
 	// Set headers
-	headers, _ := cmd.Flags().GetStringArray("header")
-	if burpFile == "" {
-		for _, h := range headers {
+	if opts.Burp == "" {
+		for _, h := range opts.Headers {
 			headerArgs := strings.SplitN(h, ":", 2)
-			headerKey := strings.TrimSpace(headerArgs[0])
-			headerValue := strings.TrimSpace(headerArgs[1])
-			c.OnRequest(func(r *colly.Request) {
-				r.Headers.Set(headerKey, headerValue)
-			})
+			if len(headerArgs) != 2 {
+				return nil, fmt.Errorf("invalid header %q, use \"Key: Value\"", h)
+			}
+			headers.Set(strings.TrimSpace(headerArgs[0]), strings.TrimSpace(headerArgs[1]))
 		}
 	}
 
+	// Credentials of the auth flags replace an Authorization header of the Burp request or --header
+	if opts.AuthBasic != "" {
+		credentials, err := ExpandEnv(opts.AuthBasic)
+		if err != nil {
+			return nil, fmt.Errorf("invalid auth-basic: %s", err)
+		}
+		authorization, err := BasicAuthorization(credentials)
+		if err != nil {
+			return nil, err
+		}
+		headers.Set("Authorization", authorization)
+	} else if opts.AuthBearer != "" {
+		headers.Set("Authorization", "Bearer "+opts.AuthBearer)
+	}
+
+	// Expand the ${NAME} environment variables, the {{placeholders}} are rendered for each request
+	for k := range headers {
+		template, err := ParseTemplate(headers.Get(k))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %s", k, err)
+		}
+		headers.Set(k, template)
+	}
+
+	c.OnRequest(func(r *colly.Request) {
+		rendered := renderHeaders(headers)
+		for k := range rendered {
+			r.Headers.Set(k, rendered.Get(k))
+		}
+		// Credentials posted to the auth API during the run
+		liveCredentials.apply(*r.Headers)
+	})
+
 	// Set User-Agent
-	randomUA, _ := cmd.Flags().GetString("user-agent")
-	switch ua := strings.ToLower(randomUA); {
+	switch ua := strings.ToLower(opts.UserAgent); {
 	case ua == "mobi":
 		extensions.RandomMobileUserAgent(c)
 	case ua == "web":
@@ -176,29 +496,31 @@ func NewCrawler(site *url.URL, cmd *cobra.Command) *Crawler {
 	// Set referer
 	extensions.Referer(c)
 
-	// Init Output
-	var output *Output
-	outputFolder, _ := cmd.Flags().GetString("output")
-	if outputFolder != "" {
-		filename := strings.ReplaceAll(site.Hostname(), ".", "_")
-		output = NewOutput(outputFolder, filename)
-	}
+	// Checks run before each request, see requestGate
+	var checks, linkFinderChecks []func(r *colly.Request) bool
 
-	// Set url whitelist regex
-	sRegex := regexp.MustCompile(`^https?:\/\/(?:[\w\-\_]+\.)+` + domain)
-	mRegex := regexp.MustCompile(`^https?:\/\/` + domain)
-	c.URLFilters = append(c.URLFilters, sRegex, mRegex)
+	// Set crawl scope, colly URL filters are regex only so it's checked before each request
+	scope, err := NewScope(site, opts)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, func(r *colly.Request) bool {
+		if !scope.InScope(r.URL) {
+			Logger.Debugf("Out of scope: %s", r.URL)
+			return false
+		}
+		return true
+	})
 
 	// Set Limit Rule
-	err := c.Limit(&colly.LimitRule{
+	err = c.Limit(&colly.LimitRule{
 		DomainGlob:  domain,
-		Parallelism: concurrent,
-		Delay:       time.Duration(delay) * time.Second,
-		RandomDelay: time.Duration(randomDelay) * time.Second,
+		Parallelism: opts.Concurrent,
+		Delay:       opts.Delay,
+		RandomDelay: opts.RandomDelay,
 	})
 	if err != nil {
-		Logger.Errorf("Failed to set Limit Rule: %s", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to set Limit Rule: %s", err)
 	}
 
 	// GoSpider default disallowed  regex
@@ -206,121 +528,759 @@ func NewCrawler(site *url.URL, cmd *cobra.Command) *Crawler {
 	c.DisallowedURLFilters = append(c.DisallowedURLFilters, regexp.MustCompile(disallowedRegex))
 
 	// Set optional blacklist url regex
-	blacklists, _ := cmd.Flags().GetString("blacklist")
-	if blacklists != "" {
-		c.DisallowedURLFilters = append(c.DisallowedURLFilters, regexp.MustCompile(blacklists))
+	if opts.Blacklist != "" {
+		blacklistRegex, err := compileRegex(opts.Blacklist)
+		if err != nil {
+			return nil, fmt.Errorf("invalid blacklist regex: %s", err)
+		}
+		c.DisallowedURLFilters = append(c.DisallowedURLFilters, blacklistRegex)
 	}
 
-	linkFinderCollector := c.Clone()
-	// Try to request as much as Javascript source and don't care about domain.
-	// The result of link finder will be send to Link Finder Collector to check is it working or not.
-	linkFinderCollector.URLFilters = nil
+	// Load detector rules, built in unless overridden by the rules folder
+	detectorRules, err := LoadRules(opts.RulesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load secret rules
+	var secretRules []SecretRule
+	if opts.Secrets || opts.SecretRules != "" || opts.VerifyGoogleKeys {
+		rules := detectorRules.Secrets
+		if opts.SecretRules != "" {
+			userRules, err := LoadSecretRules(opts.SecretRules)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(append([]SecretRule{}, rules...), userRules...)
+		}
+		secretRules, err = CompileSecretRules(rules)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return &Crawler{
-		cmd:                 cmd,
+	// Load custom extract rules, from the flags then the YAML file
+	var extractRules []ExtractRule
+	for _, raw := range opts.ExtractRules {
+		rule, err := ParseExtractRule(raw, false)
+		if err != nil {
+			return nil, err
+		}
+		extractRules = append(extractRules, rule)
+	}
+	for _, raw := range opts.ExtractSelectors {
+		rule, err := ParseExtractRule(raw, true)
+		if err != nil {
+			return nil, err
+		}
+		extractRules = append(extractRules, rule)
+	}
+	if opts.ExtractConfig != "" {
+		rules, err := LoadExtractRules(opts.ExtractConfig)
+		if err != nil {
+			return nil, err
+		}
+		extractRules = append(extractRules, rules...)
+	}
+	extractRules, err = CompileExtractRules(extractRules)
+	if err != nil {
+		return nil, err
+	}
+
+	responseFilter, err := NewResponseFilter(opts)
+	if err != nil {
+		return nil, err
+	}
+	reportErrors, err := parseIntRanges("status code", opts.ReportErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	// Track authentication state of the crawl
+	var auth *authTracker
+	if opts.AuthMarker != "" {
+		auth, err = newAuthTracker(opts.AuthMarker)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Set per pattern depth limits, colly only knows one global max depth
+	// so the limit is enforced before each request instead
+	if len(opts.DepthRules) > 0 {
+		var depthRules []DepthRule
+		for _, raw := range opts.DepthRules {
+			rule, err := ParseDepthRule(raw)
+			if err != nil {
+				return nil, err
+			}
+			depthRules = append(depthRules, rule)
+		}
+		c.MaxDepth = 0
+		checks = append(checks, func(r *colly.Request) bool {
+			maxDepth := MaxDepthFor(r.URL.String(), depthRules, opts.Depth)
+			if maxDepth > 0 && r.Depth > maxDepth {
+				Logger.Debugf("Max depth %d reached: %s", maxDepth, r.URL)
+				return false
+			}
+			return true
+		})
+	}
+
+	linkFinderCollector, err := newLinkFinderCollector(c, client, visited, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Javascript sources on other hosts are only fetched when whitelisted or with --js-scope all,
+	// the explicit exclusions of the scope always apply
+	jsPolicy, err := newJSPolicy(scope, opts)
+	if err != nil {
+		return nil, err
+	}
+	linkFinderChecks = append(linkFinderChecks, func(r *colly.Request) bool {
+		if !jsPolicy.allowed(r.URL) {
+			Logger.Debugf("Out of scope: %s", r.URL)
+			return false
+		}
+		return true
+	})
+
+	// Init Output
+	var sinks []OutputSink
+	// Only gets the unmasked sensitive findings
+	var sensitive *SensitiveOutput
+	filename := HostFileName(site.Host)
+	if opts.OutputFolder != "" {
+		output, err := NewCompressedOutput(opts.OutputFolder, filename, opts.Compress)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, output)
+		// Links to other domains are also collected across sites for supply-chain review
+		external, err := NewCompressedOutput(opts.OutputFolder, "external.txt", opts.Compress)
+		if err != nil {
+			_ = closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, typeSink{OutputSink: external, outputType: "external"})
+		if opts.SplitOutput {
+			split, err := NewSplitOutput(filepath.Join(opts.OutputFolder, hostDirName(site.Host)))
+			if err != nil {
+				_ = closeSinks(sinks)
+				return nil, err
+			}
+			split.compress = opts.Compress
+			sinks = append(sinks, split)
+		}
+		if opts.Redact {
+			sensitive, err = NewSensitiveOutput(filepath.Join(opts.OutputFolder, filename+"-sensitive.jsonl"), opts.Compress)
+			if err != nil {
+				_ = closeSinks(sinks)
+				return nil, err
+			}
+			sinks = append(sinks, sensitive)
+		}
+	}
+	for _, spec := range opts.OutputSinks {
+		sink, err := NewOutputSink(spec, filename)
+		if err != nil {
+			_ = closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if opts.WordlistOutput != "" {
+		wordlist, err := OpenWordlist(opts.WordlistOutput)
+		if err != nil {
+			_ = closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, wordlist)
+	}
+	if opts.ExportBurp != "" {
+		header := renderHeaders(headers)
+		if ua := strings.ToLower(opts.UserAgent); ua != "web" && ua != "mobi" {
+			header.Set("User-Agent", opts.UserAgent)
+		}
+		export, err := OpenRequestExport(opts.ExportBurp, header)
+		if err != nil {
+			_ = closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, export)
+	}
+
+	// Init raw response store
+	var store *ResponseStore
+	if opts.SaveResponses != "" {
+		store, err = NewResponseStore(opts.SaveResponses)
+		if err != nil {
+			_ = closeSinks(sinks)
+			return nil, err
+		}
+		store.compress = opts.Compress
+	}
+
+	// Open the state of an interrupted crawl to resume
+	var state *CrawlState
+	if opts.Resume != "" {
+		state, err = OpenCrawlState(opts.Resume, site.String())
+		if err == nil && opts.ImportSnapshot != "" {
+			if err = importSnapshot(state, opts.ImportSnapshot); err != nil {
+				_ = state.Close()
+			}
+		}
+		if err != nil {
+			_ = closeSinks(sinks)
+			if store != nil {
+				store.Close()
+			}
+			return nil, err
+		}
+	}
+	// Crawl the site with the other instances sharing the Redis server
+	var queue *sharedQueue
+	if opts.Redis != "" {
+		queue, err = openSharedQueue(opts.Redis, site.String())
+		if err != nil {
+			_ = closeSinks(sinks)
+			if store != nil {
+				store.Close()
+			}
+			return nil, err
+		}
+	}
+	// Variants of pages already requested are refused before they are sampled or counted
+	var canonical *canonicalDedup
+	if opts.CanonicalDedup {
+		canonical = newCanonicalDedup()
+		checks = append(checks, canonical.check)
+	}
+	var locales *localeDedup
+	if opts.LocaleDedup {
+		locales = newLocaleDedup()
+		checks = append(checks, locales.check)
+	}
+	// Requests are claimed in the shared set of seen URLs, which replaces the visited URLs of the collector
+	if queue != nil {
+		c.AllowURLRevisit = true
+		checks = append(checks, queue.check)
+		linkFinderChecks = append(linkFinderChecks, queue.claim)
+	}
+	// Sampled out URLs are refused before they take a slot or the budget
+	var sampler *urlSampler
+	if opts.SamplePerPattern > 0 {
+		sampler = newURLSampler(opts.SamplePerPattern)
+		checks = append(checks, sampler.check)
+		linkFinderChecks = append(linkFinderChecks, sampler.check)
+	}
+	if control != nil {
+		checks = append(checks, control.check)
+		linkFinderChecks = append(linkFinderChecks, control.check)
+	}
+	// Count requests against the crawl budget once they passed the other checks
+	checks = append(checks, budgetCheck(budget, stats, state, "main"))
+	linkFinderChecks = append(linkFinderChecks, budgetCheck(budget, stats, state, "linkfinder"))
+
+	// Record the session requests are sent with, last so only requests passing all checks are tracked
+	if session != nil {
+		checks = append(checks, func(r *colly.Request) bool {
+			session.track(r)
+			return true
+		})
+	}
+	c.OnRequest(requestGate(checks, state, queue, "main"))
+	linkFinderCollector.OnRequest(requestGate(linkFinderChecks, state, queue, "linkfinder"))
+
+	crawler := &Crawler{
 		C:                   c,
 		LinkFinderCollector: linkFinderCollector,
+		slowHandlers:        newHandlerPool(opts.Concurrent),
+		mainVisits:          newVisitGate(c),
+		linkFinderVisits:    newVisitGate(linkFinderCollector),
 		site:                site,
 		domain:              domain,
-		Output:              output,
+		outputName:          filename,
+		opts:                opts,
+		Sinks:               sinks,
+		sensitive:           sensitive,
+		jsPolicy:            jsPolicy,
+		client:              client,
+		headers:             headers,
+		auth:                auth,
+		session:             session,
+		budget:              budget,
+		limits:              limits,
+		control:             control,
+		stats:               stats,
+		scope:               scope,
+		responseFilter:      responseFilter,
+		reportErrors:        reportErrors,
+		store:               store,
+		state:               state,
+		queue:               queue,
+		politeness:          politeness,
 		urlSet:              stringset.NewStringFilter(),
 		subSet:              stringset.NewStringFilter(),
 		jsSet:               stringset.NewStringFilter(),
 		formSet:             stringset.NewStringFilter(),
 		awsSet:              stringset.NewStringFilter(),
+		awsIdentitySet:      stringset.NewStringFilter(),
+		xhrSet:              stringset.NewStringFilter(),
+		sitemapSet:          stringset.NewStringFilter(),
+		methodSet:           stringset.NewStringFilter(),
+		secretSet:           stringset.NewStringFilter(),
+		negotiationSet:      stringset.NewStringFilter(),
+		misconfigSet:        stringset.NewStringFilter(),
+		gitSet:              stringset.NewStringFilter(),
+		graphqlSet:          stringset.NewStringFilter(),
+		backendSet:          stringset.NewStringFilter(),
+		googleKeySet:        stringset.NewStringFilter(),
+		externalSet:         stringset.NewStringFilter(),
+		includeSet:          stringset.NewStringFilter(),
+		sourceMapSet:        stringset.NewStringFilter(),
+		apiSet:              stringset.NewStringFilter(),
+		customSet:           stringset.NewStringFilter(),
+		paramSet:            stringset.NewStringFilter(),
+		bucketCheckSet:      stringset.NewStringFilter(),
+		anomalySet:          stringset.NewStringFilter(),
+		jsLibSet:            stringset.NewStringFilter(),
+		resolver:            resolver,
+		subProbes:           make(chan struct{}, subProbeWorkers),
+		rules:               detectorRules,
+		secretRules:         secretRules,
+		extractRules:        extractRules,
+		buckets:             newBucketInventory(),
+		sampler:             sampler,
+		canonical:           canonical,
+		locales:             locales,
+		linkContexts:        newLinkContexts(),
+		contentParsers:      make(map[string]bool),
+		linkFinderContent:   make(map[string]bool),
+		diff:                diff,
+		seeds:               seeds,
+	}
+	certs.setReport(crawler.findCertSubdomains)
+	for _, parser := range opts.ContentParsers {
+		crawler.contentParsers[strings.ToLower(parser)] = true
+	}
+	for _, content := range opts.LinkFinderContent {
+		crawler.linkFinderContent[strings.ToLower(content)] = true
+	}
+
+	if shared != nil && shared.filters != nil {
+		for name, field := range crawler.filterFields() {
+			*field = shared.filter(name)
+		}
+	}
+	if queue != nil {
+		for name, field := range crawler.filterFields() {
+			*field = queue.filter(name)
+		}
+	}
+	if state != nil {
+		if err := state.LoadFilters(crawler.filters()); err != nil {
+			crawler.Close()
+			return nil, fmt.Errorf("failed to load state: %s", err)
+		}
+	}
+
+	if session != nil {
+		if err := crawler.login(); err != nil {
+			crawler.Close()
+			return nil, err
+		}
+	}
+
+	// Render HTML pages with headless Chrome.
+	// The collectors share the client so setting the transport here covers both.
+	if opts.Render || opts.Screenshot != "" {
+		renderer, err := NewRenderer(opts.ChromePath, opts.Proxy, scope, timeout, opts.RenderWait)
+		if err != nil {
+			crawler.Close()
+			return nil, fmt.Errorf("failed to start headless Chrome: %s", err)
+		}
+		crawler.renderer = renderer
+	}
+	// Buckets are probed apart from the crawl transport, with their own rate limit
+	if opts.CheckBuckets {
+		crawler.bucketChecks = newBucketChecker(transport, timeout)
+	}
+	if opts.RestrictedAreas {
+		crawler.restricted = newRestrictedAreas()
+	}
+	if opts.MIMEStats {
+		crawler.contentTypes = newContentTypeStats()
+	}
+	if opts.Screenshot != "" {
+		screenshots, err := newScreenshotter(site.String(), filepath.Join(opts.Screenshot, filename), scope, opts.NormalizeURLs)
+		if err != nil {
+			crawler.Close()
+			return nil, err
+		}
+		screenshots.capture = crawler.captureScreenshot
+		screenshots.report = crawler.reportScreenshot
+		crawler.screenshots = screenshots
+	}
+	if opts.Render {
+		client.Transport = &renderTransport{
+			base:        client.Transport,
+			renderer:    crawler.renderer,
+			onXHR:       crawler.findXHR,
+			screenshots: crawler.screenshots,
+		}
+	}
+	return crawler, nil
+}
+
+// The link finder collector fetches JavaScript files, most of them on other hosts, with its own
+// limits across all hosts so they can't take the slots of the crawl. It shares the client,
+// the visited URLs and the URL filters of c.
+func newLinkFinderCollector(c *colly.Collector, client *http.Client, visited storage.Storage, opts Options) (*colly.Collector, error) {
+	lf := colly.NewCollector(
+		colly.Async(true),
+		colly.IgnoreRobotsTxt(),
+	)
+	if err := lf.SetStorage(visited); err != nil {
+		return nil, err
+	}
+	lf.SetClient(client)
+	lf.MaxDepth = c.MaxDepth
+	lf.MaxBodySize = c.MaxBodySize
+	lf.UserAgent = c.UserAgent
+	lf.DisallowedURLFilters = c.DisallowedURLFilters
+
+	parallelism, delay := opts.JSConcurrent, opts.JSDelay
+	if parallelism == 0 {
+		parallelism = opts.Concurrent
+	}
+	if delay == 0 {
+		delay = opts.Delay
+	}
+	err := lf.Limit(&colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: parallelism,
+		Delay:       delay,
+		RandomDelay: opts.RandomDelay,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set link finder Limit Rule: %s", err)
+	}
+	return lf, nil
+}
+
+// requestGate aborts requests failing one of the checks. With a resume state it also skips
+// requests finished by the previous run and tracks the others until they're done.
+// In distributed mode the requests popped from the shared queue are released when aborted.
+// colly runs every OnRequest callback even after an abort, so all checks go through here.
+func requestGate(checks []func(r *colly.Request) bool, state *CrawlState, queue *sharedQueue, collector string) colly.RequestCallback {
+	return func(r *colly.Request) {
+		// Links with Unicode hosts are requested in punycode, the form scope and limits match
+		r.URL.Host = asciiHost(r.URL.Host)
+		if state != nil && !state.begin(collector, r) {
+			r.Abort()
+			return
+		}
+		if queue != nil {
+			queue.begin(r)
+		}
+		for _, check := range checks {
+			if !check(r) {
+				r.Abort()
+				if queue != nil {
+					queue.finish(r)
+				}
+				return
+			}
+		}
+		if state != nil {
+			state.enqueue(collector, r)
+		}
+	}
+}
+
+// Dedup filters saved in the resume state
+func (crawler *Crawler) filters() map[string]stringset.Filter {
+	filters := make(map[string]stringset.Filter)
+	for name, field := range crawler.filterFields() {
+		filters[name] = *field
+	}
+	return filters
+}
+
+// Dedup filter fields by name, set to shared filters in distributed mode
+func (crawler *Crawler) filterFields() map[string]*stringset.Filter {
+	return map[string]*stringset.Filter{
+		"url":         &crawler.urlSet,
+		"sub":         &crawler.subSet,
+		"js":          &crawler.jsSet,
+		"form":        &crawler.formSet,
+		"aws":         &crawler.awsSet,
+		"identity":    &crawler.awsIdentitySet,
+		"xhr":         &crawler.xhrSet,
+		"sitemap":     &crawler.sitemapSet,
+		"method":      &crawler.methodSet,
+		"secret":      &crawler.secretSet,
+		"negotiation": &crawler.negotiationSet,
+		"misconfig":   &crawler.misconfigSet,
+		"git":         &crawler.gitSet,
+		"graphql":     &crawler.graphqlSet,
+		"backend":     &crawler.backendSet,
+		"google-key":  &crawler.googleKeySet,
+		"external":    &crawler.externalSet,
+		"include":     &crawler.includeSet,
+		"sourcemap":   &crawler.sourceMapSet,
+		"api":         &crawler.apiSet,
+		"custom":      &crawler.customSet,
+		"param":       &crawler.paramSet,
+		"bucket":      &crawler.bucketCheckSet,
+		"anomaly":     &crawler.anomalySet,
+		"jslib":       &crawler.jsLibSet,
 	}
 }
 
 func (crawler *Crawler) Start() {
 	// Setup Link Finder
 	crawler.setupLinkFinder()
+	crawler.setupExtractors()
+
+	// Count responses by status class
+	for _, c := range []*colly.Collector{crawler.C, crawler.LinkFinderCollector} {
+		c.OnResponse(func(response *colly.Response) {
+			crawler.stats.answered(response.StatusCode, len(response.Body))
+		})
+		c.OnError(func(response *colly.Response, err error) {
+			crawler.stats.answered(response.StatusCode, len(response.Body))
+		})
+	}
+
+	// Count the content types per host and flag the ones that don't fit their response
+	if crawler.contentTypes != nil {
+		for _, c := range []*colly.Collector{crawler.C, crawler.LinkFinderCollector} {
+			c.OnResponse(crawler.checkContentType)
+		}
+	}
+
+	// Mark requests done once their links are queued
+	if crawler.state != nil {
+		for _, c := range []*colly.Collector{crawler.C, crawler.LinkFinderCollector} {
+			c.OnScraped(func(response *colly.Response) {
+				crawler.state.finish(response.Request)
+			})
+			c.OnError(func(response *colly.Response, err error) {
+				// Requests not sent because the crawl stopped stay pending
+				if !errors.Is(err, errCrawlStopped) {
+					crawler.state.finish(response.Request)
+				}
+			})
+		}
+	}
+
+	// Let the next requests of the shared queue go once requests are done
+	if crawler.queue != nil {
+		for _, c := range []*colly.Collector{crawler.C, crawler.LinkFinderCollector} {
+			c.OnScraped(func(response *colly.Response) {
+				crawler.queue.finish(response.Request)
+			})
+			c.OnError(func(response *colly.Response, err error) {
+				crawler.queue.finish(response.Request)
+			})
+		}
+	}
+
+	// Store raw responses of both collectors
+	if crawler.store != nil {
+		for _, c := range []*colly.Collector{crawler.C, crawler.LinkFinderCollector} {
+			c.OnResponse(crawler.saveResponse)
+			c.OnError(func(response *colly.Response, err error) {
+				if response.StatusCode > 0 {
+					crawler.saveResponse(response)
+				}
+			})
+		}
+	}
+
+	// Subtrees answering something else than 401 and 403 aren't restricted areas
+	if crawler.restricted != nil {
+		crawler.C.OnResponse(func(response *colly.Response) {
+			crawler.restricted.allow(response.Request.URL)
+		})
+	}
+
+	// Collapse the variants of pages onto their canonical URL, before the links of the page are crawled
+	crawler.C.OnHTML(`link[rel~="canonical"][href]`, crawler.findCanonical)
 
 	// Handle url
 	crawler.C.OnHTML("[href]", func(e *colly.HTMLElement) {
-		urlString := e.Request.AbsoluteURL(e.Attr("href"))
-		urlString = FixUrl(urlString, crawler.site)
+		urlString := crawler.resolveRef(e, e.Attr("href"))
 		if urlString == "" {
 			return
 		}
-		if !crawler.urlSet.Duplicate(urlString) {
+		crawler.reportExternalRef(e.Request.URL.String(), urlString)
+		if !crawler.urlSet.Duplicate(crawler.urlKey(urlString)) {
 			_ = e.Request.Visit(urlString)
 		}
 	})
 
 	// Handle form
-	crawler.C.OnHTML("form[action]", func(e *colly.HTMLElement) {
-		formUrl := e.Request.URL.String()
-		if !crawler.formSet.Duplicate(formUrl) {
-			outputFormat := fmt.Sprintf("[form] - %s", formUrl)
-			fmt.Println(outputFormat)
-			if crawler.Output != nil {
-				crawler.Output.WriteToFile(outputFormat)
-			}
-
-		}
-	})
+	crawler.C.OnHTML("form", crawler.handleForm)
 
-	// Find Upload Form
+	// Find file inputs outside forms (script driven uploads), the upload forms are reported by handleForm
 	uploadFormSet := stringset.NewStringFilter()
 	crawler.C.OnHTML(`input[type="file"]`, func(e *colly.HTMLElement) {
+		if e.DOM.Closest("form").Length() > 0 {
+			return
+		}
 		uploadUrl := e.Request.URL.String()
 		if !uploadFormSet.Duplicate(uploadUrl) {
 			outputFormat := fmt.Sprintf("[upload-form] - %s", uploadUrl)
-			fmt.Println(outputFormat)
-			if crawler.Output != nil {
-				crawler.Output.WriteToFile(outputFormat)
-			}
+			crawler.Report(outputFormat, SpiderOutput{
+				Source:     uploadUrl,
+				OutputType: "upload-form",
+				Output:     uploadUrl,
+			})
 		}
 
 	})
 
+	// Inventory third party scripts and stylesheets
+	crawler.C.OnHTML("script[src], link[href]", crawler.checkInclude)
+
 	// Handle js files
 	crawler.C.OnHTML("[src]", func(e *colly.HTMLElement) {
-		jsFileUrl := e.Request.AbsoluteURL(e.Attr("src"))
-		jsFileUrl = FixUrl(jsFileUrl, crawler.site)
+		jsFileUrl := crawler.resolveRef(e, e.Attr("src"))
 		if jsFileUrl == "" {
 			return
 		}
+		crawler.reportExternalRef(e.Request.URL.String(), jsFileUrl)
 
 		fileExt := GetExtType(jsFileUrl)
 		if fileExt == ".js" || fileExt == ".xml" || fileExt == ".json" {
 			if !crawler.jsSet.Duplicate(jsFileUrl) {
 				outputFormat := fmt.Sprintf("[javascript] - %s", jsFileUrl)
-				fmt.Println(outputFormat)
-				if crawler.Output != nil {
-					crawler.Output.WriteToFile(outputFormat)
-				}
-
-				// If JS file is minimal format. Try to find original format
-				if strings.Contains(jsFileUrl, ".min.js") {
-					originalJS := strings.ReplaceAll(jsFileUrl, ".min.js", ".js")
-					_ = crawler.LinkFinderCollector.Visit(originalJS)
+				crawler.Report(outputFormat, SpiderOutput{
+					Source:     e.Request.URL.String(),
+					OutputType: "javascript",
+					Output:     jsFileUrl,
+				})
+				// Files not fetched are known by their URL only
+				if crawler.opts.JSLibs || crawler.opts.JSLibVulns {
+					crawler.findJSLibraries(e.Request.URL.String(), jsFileUrl, "")
 				}
 
 				// Send Javascript to Link Finder Collector
-				_ = crawler.LinkFinderCollector.Visit(jsFileUrl)
+				crawler.fetchJS(e.Request, jsFileUrl)
 			}
 		}
 	})
 
+	// Links in srcset, data-* and action attributes, meta refresh, inline scripts and
+	// event handlers, CSS and comments, after [href] so links of both are labeled as href ones
+	crawler.C.OnHTML("html", crawler.findEmbeddedLinks)
+
 	crawler.C.OnResponse(func(response *colly.Response) {
-		respStr := DecodeChars(string(response.Body))
-		respLen := len(respStr)
+		crawler.processResponse(response.Request.URL.String(), func(task *responseTask) {
+			task.at("session")
+			if crawler.checkSession(response) {
+				return
+			}
+			respStr := DecodeChars(string(response.Body))
+			respLen := len(respStr)
 
-		crawler.findSubdomains(respStr)
-		crawler.findAWSS3(respStr)
+			u := response.Request.URL.String()
+			context := crawler.linkContexts.take(u)
+			task.at("subdomains")
+			crawler.findSubdomains(u, respStr)
+			task.at("aws")
+			crawler.findCloudStorage(u, respStr)
+			crawler.findAWSIdentities(u, respStr)
+			task.at("buckets")
+			crawler.findBucketObjects(respStr)
+			crawler.findBucketListing(response.Body, response.Request.URL.Hostname())
+			task.at("backends")
+			crawler.findBackendConfigs(u, respStr)
+			task.at("secrets")
+			crawler.findSecrets(u, string(response.Body))
+			task.at("extract")
+			crawler.findCustom(u, string(response.Body))
+			crawler.checkAuth(response)
+			task.at("openapi")
+			crawler.findOpenAPI(response)
+			task.at("content-parsers")
+			crawler.parseContent(response)
+			task.at("linkfinder")
+			crawler.findResponsePaths(response)
+			crawler.findParameters(u, response.Request.URL)
+			task.at("api-discovery")
+			if crawler.opts.APIDiscovery {
+				crawler.discoverAPIs(response.Request.URL)
+			}
+			task.at("methods")
+			if crawler.opts.Methods {
+				crawler.findMethods(response.Request.URL)
+			}
+			task.at("accept-probe")
+			if crawler.opts.AcceptProbe {
+				crawler.probeNegotiation(response)
+			}
+			task.at("misconfig")
+			if crawler.opts.Misconfig {
+				crawler.checkMisconfig(response.Request.URL)
+			}
+			task.at("git-tree")
+			if crawler.opts.GitTree {
+				crawler.checkGit(response.Request.URL)
+			}
+			task.at("archives")
+			if crawler.opts.Archives {
+				crawler.inspectArchive(response)
+			}
 
-		// Verify which link is working
-		u := response.Request.URL.String()
-		outputFormat := fmt.Sprintf("[url] - [code-%d] - [length-%d] - %s", response.StatusCode, respLen, u)
-		fmt.Println(outputFormat)
-		if crawler.Output != nil {
-			crawler.Output.WriteToFile(outputFormat)
-		}
+			if crawler.responseFilter.Hide(response.StatusCode, respLen, response.Headers.Get("Content-Type"), response.Body) {
+				return
+			}
+
+			task.at("report")
+			// Verify which link is working
+			title := GetTitle(string(response.Body))
+			// Rendered pages are captured while rendering them
+			if crawler.screenshots != nil && !crawler.opts.Render && response.StatusCode == 200 &&
+				LinkFinderContentType(response.Headers.Get("Content-Type"), u) == LinkFinderHTML {
+				crawler.screenshots.take(response.Request.URL, title)
+			}
+			label, details := contextLabel(context), contextDetails(context)
+			// Pages cut at --max-response-size are scanned as far as they were read
+			if truncated(response.Headers) {
+				label += "[truncated] - "
+				if details == nil {
+					details = make(map[string]string)
+				}
+				details["truncated"] = response.Headers.Get(TruncatedHeader)
+			}
+			outputFormat := fmt.Sprintf("[url] - %s[code-%d] - [length-%d] - %s", label, response.StatusCode, respLen, u)
+			if crawler.opts.Title && title != "" {
+				outputFormat = fmt.Sprintf("[url] - %s[code-%d] - [length-%d] - [title: %s] - %s", label, response.StatusCode, respLen, title, u)
+			}
+			crawler.Report(outputFormat, SpiderOutput{
+				Source:     u,
+				OutputType: "url",
+				Output:     u,
+				StatusCode: response.StatusCode,
+				Length:     respLen,
+				Title:      title,
+				Headers:    crawler.captureHeaders(response.Headers),
+				Details:    details,
+			})
+		})
 	})
 
 	crawler.C.OnError(func(response *colly.Response, err error) {
 		Logger.Debugf("Error request: %s - Status code: %v - Error: %s", response.Request.URL.String(), response.StatusCode, err)
+		if crawler.checkSession(response) {
+			return
+		}
+		u := response.Request.URL.String()
+		context := crawler.linkContexts.take(u)
 		/*
 			1xx Informational
 			2xx Success
@@ -329,93 +1289,641 @@ func (crawler *Crawler) Start() {
 			5xx Server Error
 		*/
 
-		if response.StatusCode == 404 || response.StatusCode == 429 || response.StatusCode < 100 || response.StatusCode >= 500 {
+		if !crawler.isReportedError(response.StatusCode) {
+			return
+		}
+		if crawler.responseFilter.Hide(response.StatusCode, len(response.Body), response.Headers.Get("Content-Type"), response.Body) {
 			return
 		}
 
-		u := response.Request.URL.String()
-		outputFormat := fmt.Sprintf("[url] - [code-%d] - %s", response.StatusCode, u)
-		fmt.Println(outputFormat)
-		if crawler.Output != nil {
-			crawler.Output.WriteToFile(outputFormat)
+		outputFormat := fmt.Sprintf("[url] - %s[code-%d] - %s", contextLabel(context), response.StatusCode, u)
+		record := SpiderOutput{
+			Source:     u,
+			OutputType: "url",
+			Output:     u,
+			StatusCode: response.StatusCode,
+			Headers:    crawler.captureHeaders(response.Headers),
+			Details:    contextDetails(context),
+		}
+		if crawler.holdDenied(response, outputFormat, record) {
+			return
+		}
+		crawler.Report(outputFormat, record)
+	})
+
+	crawler.mainVisits.visit(crawler.site.String())
+	crawler.visitSeeds()
+
+	// Continue the requests an interrupted run left pending
+	if crawler.state != nil {
+		pending := crawler.state.Pending()
+		for _, u := range pending["main"] {
+			crawler.mainVisits.visit(u)
+		}
+		for _, u := range pending["linkfinder"] {
+			crawler.linkFinderVisits.visit(u)
+		}
+	}
+}
+
+// Whether an error answer is reported as a url finding: 404, 429 and 5xx are dropped unless
+// --report-errors has them, failed requests without status never reported
+func (crawler *Crawler) isReportedError(statusCode int) bool {
+	if statusCode < 100 {
+		return false
+	}
+	if statusCode == 404 || statusCode == 429 || statusCode >= 500 {
+		return inRanges(statusCode, crawler.reportErrors)
+	}
+	return true
+}
+
+// Report prints a finding to stdout and output file and passes it to Options.OnResult.
+// In JSON mode the record is written as a JSON line instead of the plain format.
+func (crawler *Crawler) Report(plain string, record SpiderOutput) {
+	// Only the representatives of each URL pattern are reported when sampling
+	if crawler.sampler != nil && sampleTypes[record.OutputType] {
+		if u, err := url.Parse(record.Output); err == nil && !crawler.sampler.keep(u) {
+			return
+		}
+	}
+	record.Input = crawler.site.String()
+	// Show internationalized hosts in both forms
+	if unicode := unicodeOutput(record.Output); unicode != "" {
+		details := map[string]string{"unicode": unicode}
+		for k, v := range record.Details {
+			details[k] = v
+		}
+		record.Details = details
+		plain += " (" + unicode + ")"
+	}
+	// Sensitive values only reach the restricted findings file unmasked
+	full, redacted := record, false
+	if crawler.opts.Redact && redactable(record) {
+		record, plain = redactRecord(record, plain)
+		redacted = true
+	}
+	// Compared as written, the previous run was redacted too
+	if crawler.diff != nil && !crawler.diff.isNew(record) {
+		return
+	}
+	crawler.stats.found(record.OutputType)
+	if crawler.opts.OnResult != nil {
+		crawler.opts.OnResult(record)
+	}
+
+	line := plain
+	if crawler.opts.JSON {
+		data, err := json.Marshal(record)
+		if err != nil {
+			Logger.Errorf("Failed to encode output: %s", err)
+			return
+		}
+		line = string(data)
+	}
+
+	if !crawler.opts.Quiet {
+		stdoutMu.Lock()
+		fmt.Println(line)
+		stdoutMu.Unlock()
+	}
+	for _, sink := range crawler.Sinks {
+		r := record
+		if crawler.sensitive != nil && sink == OutputSink(crawler.sensitive) {
+			if !redacted {
+				continue
+			}
+			r = full
 		}
+		if err := sink.Write(r, line); err != nil {
+			Logger.Errorf("Failed to write output: %s", err)
+		}
+	}
+}
+
+// Report when pages stop (or start again) matching the authentication marker
+func (crawler *Crawler) checkAuth(response *colly.Response) {
+	if crawler.auth == nil || !strings.Contains(response.Headers.Get("Content-Type"), "html") {
+		return
+	}
+	changed, lost := crawler.auth.check(string(response.Body))
+	if !changed {
+		return
+	}
+
+	u := response.Request.URL.String()
+	outputType := "auth-restored"
+	if lost {
+		outputType = "auth-lost"
+		Logger.Warnf("Authentication marker not found anymore, session may be expired: %s", u)
+	}
+	outputFormat := fmt.Sprintf("[%s] - %s", outputType, u)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     u,
+		OutputType: outputType,
+		Output:     u,
 	})
+}
 
-	_ = crawler.C.Visit(crawler.site.String())
+// Create a request outside colly carrying the configured cookie and headers
+func (crawler *Crawler) newRequest(method, u string) (*http.Request, error) {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	rendered := renderHeaders(crawler.headers)
+	for k := range rendered {
+		req.Header.Set(k, rendered.Get(k))
+	}
+	if ua := strings.ToLower(crawler.opts.UserAgent); ua != "web" && ua != "mobi" {
+		req.Header.Set("User-Agent", crawler.opts.UserAgent)
+	}
+	return req, nil
+}
+
+// Save raw request and response to the response store
+func (crawler *Crawler) saveResponse(response *colly.Response) {
+	if _, err := crawler.store.Save(response); err != nil {
+		Logger.Errorf("Failed to save response of %s: %s", response.Request.URL, err)
+	}
+}
+
+// Pick the response headers to include in url findings
+func (crawler *Crawler) captureHeaders(headers *http.Header) map[string]string {
+	if len(crawler.opts.CaptureHeaders) == 0 || headers == nil {
+		return nil
+	}
+	captured := make(map[string]string)
+	for _, name := range crawler.opts.CaptureHeaders {
+		if v := headers.Get(name); v != "" {
+			captured[http.CanonicalHeaderKey(name)] = v
+		}
+	}
+	return captured
 }
 
 // Find subdomains from response
-func (crawler *Crawler) findSubdomains(resp string) {
+func (crawler *Crawler) findSubdomains(source, resp string) {
 	subs := GetSubdomains(resp, crawler.domain)
 	for _, sub := range subs {
 		if !crawler.subSet.Duplicate(sub) {
-			outputFormat := fmt.Sprintf("[subdomains] - %s", sub)
-			fmt.Println(outputFormat)
-			if crawler.Output != nil {
-				crawler.Output.WriteToFile(outputFormat)
+			if crawler.opts.ResolveSubs {
+				crawler.reportResolvedSubdomain(source, sub)
+			} else {
+				outputFormat := fmt.Sprintf("[subdomains] - %s", sub)
+				crawler.Report(outputFormat, SpiderOutput{
+					Source:     source,
+					OutputType: "subdomains",
+					Output:     sub,
+				})
+			}
+
+			// Promoted subdomain crawler seeds its own robots.txt and sitemap.xml
+			if crawler.opts.CrawlSubs {
+				crawler.crawlSubdomain(sub)
+			} else if crawler.opts.Subs {
+				crawler.seedSubdomain(sub)
 			}
 		}
 	}
 }
 
-// Find AWS S3 from response
-func (crawler *Crawler) findAWSS3(resp string) {
-	aws := GetAWSS3(resp)
-	for _, e := range aws {
-		if !crawler.awsSet.Duplicate(e) {
-			outputFormat := fmt.Sprintf("[aws-s3] - %s", e)
-			fmt.Println(outputFormat)
-			if crawler.Output != nil {
-				crawler.Output.WriteToFile(outputFormat)
+// Report a subdomain once it's resolved and probed, in the background
+func (crawler *Crawler) reportResolvedSubdomain(source, sub string) {
+	crawler.subProbeWg.Add(1)
+	go func() {
+		defer crawler.subProbeWg.Done()
+		crawler.subProbes <- struct{}{}
+		defer func() { <-crawler.subProbes }()
+
+		record := SpiderOutput{Source: source, OutputType: "subdomains", Output: sub}
+		outputFormat := fmt.Sprintf("[subdomains] - %s", sub)
+		// Probes fail once the crawl is stopped, don't report the subdomain dead
+		if crawler.budget.stopped() == "" {
+			status := crawler.checkSubdomain(sub)
+			outputFormat += " - " + status.String()
+			record.StatusCode = status.Status
+			record.Details = status.details()
+		}
+		crawler.Report(outputFormat, record)
+	}()
+}
+
+// Parse robots.txt and sitemap.xml of new subdomain the same way the root site gets seeded
+func (crawler *Crawler) seedSubdomain(sub string) {
+	if sub == crawler.site.Hostname() {
+		return
+	}
+	subURL := &url.URL{Scheme: crawler.site.Scheme, Host: sub}
+	if !crawler.scope.InScope(subURL) {
+		return
+	}
+	crawler.seedSite(subURL)
+}
+
+// Run crawls the site together with its seed sources (sitemap.xml, robots.txt, other sources),
+// blocks until the crawl is done and closes the crawler
+func (crawler *Crawler) Run() {
+	var siteWg sync.WaitGroup
+
+	crawler.budget.begin()
+	crawler.stats.setRunning(true)
+	crawlMetrics.register(crawler.site.String(), crawler.stats)
+	if crawler.control != nil {
+		crawler.control.register(crawler)
+	}
+	liveScopes.register(crawler.scope)
+	defer liveScopes.unregister(crawler.scope)
+	if crawler.restricted != nil {
+		liveCredentials.register(crawler.restricted)
+		defer liveCredentials.unregister(crawler.restricted)
+	}
+	if crawler.opts.WatchScope {
+		stop := make(chan struct{})
+		defer close(stop)
+		go crawler.watchScope(stop)
+	}
+	if crawler.opts.Context != nil {
+		finished := make(chan struct{})
+		defer close(finished)
+		go func() {
+			select {
+			case <-crawler.opts.Context.Done():
+				crawler.Stop("interrupted")
+			case <-finished:
 			}
+		}()
+	}
+
+	if crawler.state != nil {
+		liveStates.register(crawler.state)
+		defer liveStates.unregister(crawler.state)
+		stop := make(chan struct{})
+		defer close(stop)
+		go crawler.checkpointLoop(stop)
+	}
+
+	siteWg.Add(1)
+	go func() {
+		defer siteWg.Done()
+		crawler.Start()
+	}()
+
+	siteWg.Add(1)
+	go func() {
+		defer siteWg.Done()
+		crawler.seedSite(crawler.site)
+	}()
+
+	if crawler.opts.OtherSource {
+		siteWg.Add(1)
+		go func() {
+			defer siteWg.Done()
+			crawler.findOtherSources()
+		}()
+	}
+
+	siteWg.Wait()
+	// Send the requests of the shared queue until no instance has any left
+	if crawler.queue != nil {
+		crawler.queue.work(crawler.C, crawler.opts.Concurrent, crawler.budget.stopped)
+	}
+	crawler.wait()
+	if crawler.screenshots != nil {
+		crawler.screenshots.wait()
+	}
+	crawler.subProbeWg.Wait()
+	if crawler.bucketChecks != nil {
+		crawler.bucketChecks.wait()
+	}
+	crawler.WaitSubCrawlers()
+
+	if crawler.auth != nil {
+		pages, anonymous := crawler.auth.stats()
+		if anonymous > 0 {
+			Logger.Warnf("%d of %d pages of %s were crawled without authentication", anonymous, pages, crawler.site)
 		}
 	}
+	crawler.reportRestricted()
+	crawler.reportBuckets()
+	crawler.reportContentTypes()
+	crawler.reportSamples()
+	crawler.reportLocales()
+	crawler.reportRemoved()
+	crawler.Close()
+	crawler.budget.finish()
+	crawler.stats.setRunning(false)
+	crawler.printSummary()
 }
 
-// Setup link finder
-func (crawler *Crawler) setupLinkFinder() {
-	crawler.LinkFinderCollector.OnResponse(func(response *colly.Response) {
-		if response.StatusCode != 200 {
+// Stop stops the crawl: no new request is sent, the ones in flight finish
+// and Run returns once their findings are written
+func (crawler *Crawler) Stop(reason string) {
+	crawler.budget.stop(reason)
+	if crawler.control != nil {
+		crawler.control.wake()
+	}
+}
+
+// Stats returns the current counters of the crawl
+func (crawler *Crawler) Stats() StatsSnapshot {
+	return crawler.stats.Snapshot()
+}
+
+// Print how far the crawl got, to stderr when it was stopped before the end
+func (crawler *Crawler) printSummary() {
+	_, elapsed, reason := crawler.budget.summary()
+	stats := crawler.stats.Snapshot()
+	if reason == "" {
+		Logger.Infof("Finished crawling %s: %s in %s", crawler.site, stats, elapsed)
+		return
+	}
+	if !crawler.opts.Quiet {
+		stdoutMu.Lock()
+		fmt.Fprintf(os.Stderr, "Stopped crawling %s (%s): %s in %s\n", crawler.site, reason, stats, elapsed)
+		stdoutMu.Unlock()
+	}
+}
+
+// Save the resume state periodically until stop is closed
+func (crawler *Crawler) checkpointLoop(stop chan struct{}) {
+	ticker := time.NewTicker(stateCheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
 			return
+		case <-ticker.C:
+			if err := crawler.state.Checkpoint(crawler.filters()); err != nil {
+				Logger.Errorf("Failed to save crawl state: %s", err)
+			}
+		}
+	}
+}
+
+// Find URLs from 3rd party (Archive.org, CommonCrawl.org, VirusTotal.com, AlienVault.com)
+func (crawler *Crawler) findOtherSources() {
+	urls := OtherSourcesWithSource(crawler.site.Hostname(), crawler.opts.IncludeSubs)
+	for _, u := range urls {
+		url := strings.TrimSpace(u.URL)
+		if len(url) == 0 {
+			continue
 		}
+		outputFormat := fmt.Sprintf("[%s] - %s", u.Source, url)
+		if crawler.opts.IncludeOtherSource {
+			crawler.Report(outputFormat, SpiderOutput{
+				Source:     u.Source,
+				OutputType: "other-sources",
+				Output:     url,
+			})
+		}
+		crawler.mainVisits.visit(url)
+	}
+}
 
-		respStr := string(response.Body)
+// Parse robots.txt and sitemap.xml of site into the main collector
+func (crawler *Crawler) seedSite(site *url.URL) {
+	var wg sync.WaitGroup
+	if crawler.opts.Sitemap {
+		wg.Add(1)
+		go ParseSiteMap(site, crawler, &wg)
+	}
+	if crawler.opts.Robots {
+		wg.Add(1)
+		go ParseRobots(site, crawler, &wg)
+	}
+	wg.Wait()
+}
 
-		crawler.findAWSS3(respStr)
-		crawler.findSubdomains(respStr)
+// Promote a resolved and live subdomain into a new crawl target
+func (crawler *Crawler) crawlSubdomain(sub string) {
+	if sub == crawler.site.Hostname() {
+		return
+	}
+	if !crawler.scope.InScope(&url.URL{Scheme: crawler.site.Scheme, Host: sub}) {
+		return
+	}
+	if crawler.budget.stopped() != "" {
+		return
+	}
+
+	crawler.subCrawlerWg.Add(1)
+	go func() {
+		defer crawler.subCrawlerWg.Done()
+		if len(lookupHost(crawler.resolver, sub)) == 0 {
+			return
+		}
+		scheme, _, alive := ProbeHost(crawler.client, sub)
+		if !alive {
+			return
+		}
 
-		paths, err := LinkFinder(respStr)
+		crawler.subCrawlerMu.Lock()
+		if crawler.subCrawled >= crawler.opts.CrawlSubsLimit {
+			crawler.subCrawlerMu.Unlock()
+			Logger.Debugf("Reached subdomain crawl limit, skip: %s", sub)
+			return
+		}
+		crawler.subCrawled++
+		crawler.subCrawlerMu.Unlock()
+
+		// Don't let subdomain crawlers spawn crawlers themselves,
+		// other sources were already queried for the whole domain
+		subOpts := crawler.opts
+		subOpts.CrawlSubs = false
+		subOpts.Subs = false
+		subOpts.OtherSource = false
+		// Subdomain crawls take their URLs, time and requests from the budget and rate limits of this one
+		share := &crawlShare{budget: crawler.budget, limits: crawler.limits}
+		subCrawler, err := newCrawler(&url.URL{Scheme: scheme, Host: sub}, subOpts, share)
 		if err != nil {
-			Logger.Error(err)
+			Logger.Errorf("Failed to crawl subdomain %s: %s", sub, err)
 			return
 		}
+		subCrawler.Run()
+	}()
+}
+
+// WaitSubCrawlers blocks until all promoted subdomain crawlers finished
+func (crawler *Crawler) WaitSubCrawlers() {
+	crawler.subCrawlerWg.Wait()
+}
+
+// Close stops the headless browser and closes the output sinks
+func (crawler *Crawler) Close() {
+	if crawler.renderer != nil {
+		crawler.renderer.Close()
+	}
+	if crawler.screenshots != nil {
+		if err := crawler.screenshots.writeGallery(); err != nil {
+			Logger.Errorf("Failed to write screenshot gallery: %s", err)
+		}
+	}
+	if err := closeSinks(crawler.Sinks); err != nil {
+		Logger.Errorf("Failed to close output: %s", err)
+	}
+	if crawler.store != nil {
+		crawler.store.Close()
+	}
+	if crawler.queue != nil {
+		crawler.queue.Close()
+	}
+	if crawler.politeness != nil && crawler.opts.OutputFolder != "" {
+		if err := crawler.politeness.write(crawler.opts.OutputFolder, crawler.outputName); err != nil {
+			Logger.Errorf("Failed to write politeness report: %s", err)
+		}
+	}
+	if crawler.state != nil {
+		if err := crawler.state.Checkpoint(crawler.filters()); err != nil {
+			Logger.Errorf("Failed to save crawl state: %s", err)
+		}
+		_ = crawler.state.Close()
+	}
+}
 
-		var inScope bool
-		if InScope(response.Request.URL, crawler.C.URLFilters) {
-			inScope = true
+// Report XHR/fetch URLs requested while rendering a page and crawl them
+func (crawler *Crawler) findXHR(pageURL string, urls []string) {
+	for _, u := range urls {
+		if !crawler.xhrSet.Duplicate(u) {
+			outputFormat := fmt.Sprintf("[xhr] - [from: %s] - %s", pageURL, u)
+			crawler.Report(outputFormat, SpiderOutput{
+				Source:     pageURL,
+				OutputType: "xhr",
+				Output:     u,
+			})
+			crawler.mainVisits.visit(u)
 		}
-		for _, path := range paths {
-			// JS Regex Result
-			outputFormat := fmt.Sprintf("[linkfinder] - [from: %s] - %s", response.Request.URL.String(), path)
-			fmt.Println(outputFormat)
-			if crawler.Output != nil {
-				crawler.Output.WriteToFile(outputFormat)
+	}
+}
+
+// Find secrets from response
+func (crawler *Crawler) findSecrets(source, resp string) {
+	if len(crawler.secretRules) == 0 {
+		return
+	}
+	for _, s := range FindSecrets(resp, crawler.secretRules) {
+		if !crawler.secretSet.Duplicate(s.Rule + "|" + s.Match) {
+			outputFormat := fmt.Sprintf("[secret] - [%s] - [%s] - %s", s.Rule, source, s.Match)
+			crawler.Report(outputFormat, SpiderOutput{
+				Source:     source,
+				OutputType: "secret",
+				Output:     s.Match,
+				Rule:       s.Rule,
+			})
+		}
+		if crawler.opts.VerifyGoogleKeys && s.Rule == "google-api-key" {
+			crawler.verifyGoogleKey(source, s.Match)
+		}
+	}
+}
+
+// Setup link finder
+func (crawler *Crawler) setupLinkFinder() {
+	crawler.LinkFinderCollector.OnResponse(func(response *colly.Response) {
+		if response.StatusCode != 200 {
+			return
+		}
+
+		if truncated(response.Headers) {
+			Logger.Debugf("Scanning the first %d bytes of %s", len(response.Body), response.Request.URL)
+		}
+		crawler.processResponse(response.Request.URL.String(), func(task *responseTask) {
+			respStr := string(response.Body)
+			task.at("javascript")
+			crawler.findInSource(response.Request.URL.String(), respStr)
+			task.at("linkfinder")
+			paths := crawler.findResponsePaths(response)
+			if crawler.opts.JSFingerprints {
+				task.at("js-fingerprint")
+				crawler.fingerprintJS(response, paths)
+			}
+			if (crawler.opts.JSLibs || crawler.opts.JSLibVulns) && GetExtType(response.Request.URL.String()) == ".js" {
+				task.at("js-libs")
+				crawler.findJSLibraries(response.Request.URL.String(), response.Request.URL.String(), respStr)
 			}
+			if GetExtType(response.Request.URL.String()) == ".js" && response.Headers != nil {
+				task.at("sourcemap")
+				crawler.findSourceMap(response.Request.URL, *response.Headers, respStr)
+			}
+		})
+	})
+}
 
-			// Try to request JS path
-			// Try to generate URLs with main site
-			urlWithMainSite := FixUrl(path, crawler.site)
-			if urlWithMainSite != "" {
-				_ = crawler.C.Visit(urlWithMainSite)
+// Find secrets, backends and paths in a JavaScript source, paths are crawled
+// relative to the main site and to base, the URL the source comes from
+func (crawler *Crawler) analyzeJS(source string, base *url.URL, respStr string) {
+	crawler.findInSource(source, respStr)
+	paths, err := LinkFinder(respStr)
+	if err != nil {
+		Logger.Error(err)
+		return
+	}
+	crawler.followPaths(source, base, paths, "")
+}
+
+// Find secrets, backends, subdomains and the other findings of a JavaScript source
+func (crawler *Crawler) findInSource(source, respStr string) {
+	crawler.findCloudStorage(source, respStr)
+	crawler.findAWSIdentities(source, respStr)
+	crawler.findBucketObjects(respStr)
+	crawler.findBackendConfigs(source, respStr)
+	crawler.findSubdomains(source, respStr)
+	crawler.findSecrets(source, respStr)
+	crawler.findCustom(source, respStr)
+	crawler.findGraphQLOperations(source, respStr)
+}
+
+// Run LinkFinder on a response of either collector by its content type (see LinkFinderContentType),
+// the paths found are reported and crawled like the ones of JavaScript files, and returned
+func (crawler *Crawler) findResponsePaths(response *colly.Response) []string {
+	var contentType string
+	if response.Headers != nil {
+		contentType = response.Headers.Get("Content-Type")
+	}
+	content := LinkFinderContentType(contentType, response.Request.URL.String())
+	if content == "" || !crawler.linkFinderContent[content] {
+		return nil
+	}
+	paths := LinkFinderResponse(content, response.Body)
+	crawler.followPaths(response.Request.URL.String(), response.Request.URL, paths, content)
+	return paths
+}
+
+// Report and crawl the paths LinkFinder found in source, relative to the main site and to base,
+// the URL the source comes from. content is the LinkFinderContentType of source, shown for
+// the contents other than JavaScript.
+func (crawler *Crawler) followPaths(source string, base *url.URL, paths []string, content string) {
+	var details map[string]string
+	if content != "" && content != LinkFinderJS {
+		details = map[string]string{"content": content}
+	}
+	inScope := crawler.scope.InScope(base)
+	for _, path := range paths {
+		// JS Regex Result
+		outputFormat := fmt.Sprintf("[linkfinder] - [from: %s] - %s", source, path)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     source,
+			OutputType: "linkfinder",
+			Output:     path,
+			Details:    details,
+		})
+
+		// Try to request JS path
+		// Try to generate URLs with main site
+		urlWithMainSite := FixUrl(path, crawler.site)
+		if urlWithMainSite != "" {
+			if u, err := url.Parse(urlWithMainSite); err == nil {
+				crawler.findParameters(source, u)
 			}
+			crawler.mainVisits.visit(urlWithMainSite)
+		}
 
-			// Try to generate URLs with the site where Javascript file host in (must be in main or sub domain)
-			if inScope {
-				urlWithJSHostIn := FixUrl(path, response.Request.URL)
-				if urlWithJSHostIn != "" {
-					_ = crawler.C.Visit(urlWithJSHostIn)
-				}
+		// Try to generate URLs with the site where Javascript file host in (must be in main or sub domain)
+		if inScope {
+			urlWithJSHostIn := FixUrl(path, base)
+			if urlWithJSHostIn != "" {
+				crawler.mainVisits.visit(urlWithJSHostIn)
 			}
 		}
-	})
+	}
 }
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// crawlForTest crawls site with the default options changed by configure, robots.txt off
+// and quiet, and returns the findings. An OnResult set by configure is still called.
+func crawlForTest(t *testing.T, site string, configure func(*Options)) []SpiderOutput {
+	t.Helper()
+	u, err := url.Parse(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.Robots = false
+	opts.Quiet = true
+	if configure != nil {
+		configure(&opts)
+	}
+
+	var mu sync.Mutex
+	var findings []SpiderOutput
+	onResult := opts.OnResult
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		findings = append(findings, r)
+		mu.Unlock()
+		if onResult != nil {
+			onResult(r)
+		}
+	}
+	crawler, err := NewCrawlerWithOptions(u, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler.Run()
+
+	mu.Lock()
+	defer mu.Unlock()
+	return findings
+}
+
+// outputsOf returns the outputs of the findings of type outputType
+func outputsOf(findings []SpiderOutput, outputType string) map[string]bool {
+	outputs := make(map[string]bool)
+	for _, r := range findings {
+		if r.OutputType == outputType {
+			outputs[r.Output] = true
+		}
+	}
+	return outputs
+}
+
+// findingsByKey indexes the findings by type and output ("url https://example.com/")
+func findingsByKey(findings []SpiderOutput) map[string]SpiderOutput {
+	byKey := make(map[string]SpiderOutput)
+	for _, r := range findings {
+		byKey[r.OutputType+" "+r.Output] = r
+	}
+	return byKey
+}
+
+// findingsOf returns the findings of type outputType
+func findingsOf(findings []SpiderOutput, outputType string) []SpiderOutput {
+	var matching []SpiderOutput
+	for _, r := range findings {
+		if r.OutputType == outputType {
+			matching = append(matching, r)
+		}
+	}
+	return matching
+}
+
+func TestNewCrawlerWithOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><a href="/about">about</a></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html>about</html>`)
+	}))
+	defer ts.Close()
+
+	found := outputsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+	}), "url")
+	if !found[ts.URL+"/about"] {
+		t.Errorf("linked page not reported, got %v", found)
+	}
+}
+
+func TestNewCrawlerWithOptionsInvalid(t *testing.T) {
+	site, _ := url.Parse("https://example.com/")
+	opts := DefaultOptions()
+	opts.Blacklist = "("
+	if _, err := NewCrawlerWithOptions(site, opts); err == nil {
+		t.Error("invalid blacklist regex should fail")
+	}
+}
+
+func TestNewCrawlerWithOptionsHTTP2(t *testing.T) {
+	var mu sync.Mutex
+	protos := make(map[string]bool)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		protos[r.Proto] = true
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html>h2</html>`)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	crawlForTest(t, ts.URL, nil)
+	if !protos["HTTP/2.0"] {
+		t.Errorf("site not crawled over HTTP/2, got %v", protos)
+	}
+}
+
+func TestCrawlerSeedSubdomain(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]bool)
+	// Proxy for every host of example.test
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.Host+r.URL.Path] = true
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.Host == "example.test" && r.URL.Path == "/" {
+			fmt.Fprint(w, `<html>api.example.test internal.example.test</html>`)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer proxy.Close()
+
+	findings := crawlForTest(t, "http://example.test/", func(opts *Options) {
+		opts.Proxy = proxy.URL
+		opts.Subs = true
+		opts.Robots = true
+		opts.Sitemap = true
+		opts.ExcludeSubdomains = []string{"^internal"}
+	})
+
+	if subs := outputsOf(findings, "subdomains"); !subs["api.example.test"] || !subs["internal.example.test"] {
+		t.Errorf("subdomains reported as %v", subs)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"api.example.test/robots.txt", "api.example.test/sitemap.xml"} {
+		if !requested[path] {
+			t.Errorf("%s of the in-scope subdomain not requested, got %v", path, requested)
+		}
+	}
+	for path := range requested {
+		if strings.HasPrefix(path, "internal.example.test") {
+			t.Errorf("out of scope subdomain seeded: %s", path)
+		}
+	}
+}
+
+func TestCrawlerCrawlSubdomain(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	// Proxy for every host of example.test
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			http.Error(w, "no https", http.StatusBadGateway)
+			return
+		}
+		mu.Lock()
+		requested[r.Host]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.Host == "example.test" {
+			fmt.Fprint(w, `<html>api.example.test</html>`)
+			return
+		}
+		fmt.Fprint(w, `<html><a href="/1">1</a><a href="/2">2</a><a href="/3">3</a><a href="/4">4</a></html>`)
+	}))
+	defer proxy.Close()
+	server := fakeDNSServer(t, map[string]net.IP{"api.example.test": net.IPv4(127, 0, 0, 1)})
+
+	findings := crawlForTest(t, "http://example.test/", func(opts *Options) {
+		opts.Depth = 2
+		opts.Proxy = proxy.URL
+		opts.Resolvers = server
+		opts.CrawlSubs = true
+		opts.MaxURLs = 3
+	})
+
+	// The subdomain crawl gets what's left of the budget of the site, its probe comes on top
+	urls := findingsOf(findings, "url")
+	mu.Lock()
+	defer mu.Unlock()
+	if requested["api.example.test"] < 2 {
+		t.Errorf("subdomain not crawled, requested %v", requested)
+	}
+	if len(urls) != 3 || requested["example.test"]+requested["api.example.test"] != 4 {
+		t.Errorf("crawled %v over a budget of 3 URLs, requested %v", urls, requested)
+	}
+}
+
+func TestCrawlerCaptureHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.25")
+		if r.URL.Path == "/private" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("X-Powered-By", "PHP/8.2")
+		fmt.Fprint(w, `<html><a href="/private">private</a></html>`)
+	}))
+	defer ts.Close()
+
+	crawl := func(names []string) map[string]SpiderOutput {
+		urls := make(map[string]SpiderOutput)
+		for _, r := range findingsOf(crawlForTest(t, ts.URL+"/", func(opts *Options) {
+			opts.Depth = 2
+			opts.CaptureHeaders = names
+		}), "url") {
+			urls[strings.TrimPrefix(r.Output, ts.URL)] = r
+		}
+		return urls
+	}
+
+	// Names are matched case insensitively, headers missing from the response are left out
+	urls := crawl([]string{"server", "X-Powered-By", "Location"})
+	if got := urls["/"].Headers; len(got) != 2 || got["Server"] != "nginx/1.25" || got["X-Powered-By"] != "PHP/8.2" {
+		t.Errorf("headers of the page = %v", got)
+	}
+	if got := urls["/private"].Headers; len(got) != 1 || got["Server"] != "nginx/1.25" {
+		t.Errorf("headers of the error page = %v", got)
+	}
+	for path, r := range crawl(nil) {
+		if r.Headers != nil {
+			t.Errorf("headers of %s captured without --capture-headers: %v", path, r.Headers)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// responseTask tracks the handler running on a response, to tell which one
+// is to blame when they take too long
+type responseTask struct {
+	mu      sync.Mutex
+	handler string
+}
+
+// at marks the start of a handler
+func (t *responseTask) at(handler string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler = handler
+}
+
+func (t *responseTask) current() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.handler
+}
+
+// handlerPool tracks the response handlers left running past --handler-timeout, at most
+// limit at once: past it a worker waits for its handler like without a timeout
+type handlerPool struct {
+	mu      sync.Mutex
+	done    *sync.Cond
+	running int
+	limit   int
+}
+
+func newHandlerPool(limit int) *handlerPool {
+	if limit < 1 {
+		limit = 1
+	}
+	p := &handlerPool{limit: limit}
+	p.done = sync.NewCond(&p.mu)
+	return p
+}
+
+// leave counts the handler closing done as running in the background, false when the pool is full
+func (p *handlerPool) leave(done chan struct{}) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running >= p.limit {
+		return false
+	}
+	p.running++
+	go func() {
+		<-done
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.running--
+		p.done.Broadcast()
+	}()
+	return true
+}
+
+// wait blocks until no handler is left running
+func (p *handlerPool) wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.running > 0 {
+		p.done.Wait()
+	}
+}
+
+// Run the handlers of the response of u, holding the collector worker at most --handler-timeout.
+// Past it the handler running is reported as a slow-handler finding and the worker moves on to
+// the next request while the handlers left finish in the background, unless --concurrent of
+// them already are.
+func (crawler *Crawler) processResponse(u string, process func(task *responseTask)) {
+	task := &responseTask{}
+	timeout := crawler.opts.HandlerTimeout
+	if timeout <= 0 {
+		process(task)
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		process(task)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	handler := task.current()
+	left := crawler.slowHandlers.leave(done)
+	if left {
+		Logger.Warnf("The %s handler of %s is still running after %s, moving on", handler, u, timeout)
+	} else {
+		Logger.Warnf("The %s handler of %s is still running after %s, waiting for it as %d handlers already are", handler, u, timeout, crawler.slowHandlers.limit)
+	}
+	outputFormat := fmt.Sprintf("[slow-handler] - [%s] - [%s] - %s", handler, timeout, u)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     u,
+		OutputType: "slow-handler",
+		Output:     u,
+		Details:    map[string]string{"handler": handler, "timeout": timeout.String()},
+	})
+	if !left {
+		<-done
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCrawlerHandlerTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The misconfig probe of the host hangs
+		if r.Method == "TRACE" {
+			time.Sleep(300 * time.Millisecond)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/about">about</a>`)
+			return
+		}
+		fmt.Fprint(w, `<html>page</html>`)
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	found := make(map[string]time.Time)
+	slow := findingsOf(crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.Misconfig = true
+		opts.HandlerTimeout = 200 * time.Millisecond
+		opts.OnResult = func(r SpiderOutput) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.OutputType == "url" {
+				found[r.Output] = time.Now()
+			}
+		}
+	}), "slow-handler")
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slow) != 1 || slow[0].Output != ts.URL+"/" || slow[0].Details["handler"] != "misconfig" || slow[0].Details["timeout"] != "200ms" {
+		t.Fatalf("slow handlers reported: %+v", slow)
+	}
+	// The page is crawled while the probe of the seed hangs, the seed is reported once it's done
+	about, seed := found[ts.URL+"/about"], found[ts.URL+"/"]
+	if about.IsZero() || seed.IsZero() || !about.Before(seed) {
+		t.Errorf("url findings at %v (about) and %v (seed)", about, seed)
+	}
+}
+
+func TestHandlerPool(t *testing.T) {
+	p := newHandlerPool(1)
+	first, second := make(chan struct{}), make(chan struct{})
+	if !p.leave(first) {
+		t.Fatal("handler not left with a free pool")
+	}
+	if p.leave(second) {
+		t.Fatal("handler left with a full pool")
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		p.wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("wait returned with a handler running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(first)
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("wait still blocked after the handler finished")
+	}
+	if !p.leave(second) {
+		t.Error("handler not left once the pool freed up")
+	}
+	close(second)
+	p.wait()
+}
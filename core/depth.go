@@ -0,0 +1,42 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DepthRule overrides the max depth for URLs matching Pattern
+type DepthRule struct {
+	Pattern *regexp.Regexp
+	Depth   int
+}
+
+// ParseDepthRule parses a rule in "depth:regex" format (Ex: "5:/api/")
+func ParseDepthRule(raw string) (DepthRule, error) {
+	args := strings.SplitN(raw, ":", 2)
+	if len(args) != 2 {
+		return DepthRule{}, fmt.Errorf("invalid depth rule %q, use \"depth:regex\"", raw)
+	}
+	depth, err := strconv.Atoi(strings.TrimSpace(args[0]))
+	if err != nil || depth < 0 {
+		return DepthRule{}, fmt.Errorf("invalid depth in rule %q", raw)
+	}
+	pattern, err := compileRegex(args[1])
+	if err != nil {
+		return DepthRule{}, fmt.Errorf("invalid regex in depth rule %q: %s", raw, err)
+	}
+	return DepthRule{Pattern: pattern, Depth: depth}, nil
+}
+
+// MaxDepthFor returns the depth limit of the first rule matching u,
+// or defaultDepth when none matches. 0 means no limit.
+func MaxDepthFor(u string, rules []DepthRule, defaultDepth int) int {
+	for _, rule := range rules {
+		if rule.Pattern.MatchString(u) {
+			return rule.Depth
+		}
+	}
+	return defaultDepth
+}
@@ -0,0 +1,29 @@
+package core
+
+import "testing"
+
+func TestMaxDepthFor(t *testing.T) {
+	var rules []DepthRule
+	for _, raw := range []string{"5:/api/", "1:/blog/"} {
+		rule, err := ParseDepthRule(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rules = append(rules, rule)
+	}
+
+	tests := map[string]int{
+		"https://example.com/api/v1/users": 5,
+		"https://example.com/blog/page/2":  1,
+		"https://example.com/about":        2,
+	}
+	for u, want := range tests {
+		if got := MaxDepthFor(u, rules, 2); got != want {
+			t.Errorf("MaxDepthFor(%s) = %d, want %d", u, got, want)
+		}
+	}
+
+	if _, err := ParseDepthRule("/api/"); err == nil {
+		t.Error("ParseDepthRule without depth should fail")
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Finding types compared with --diff, the findings of the other types are always reported
+var diffTypes = map[string]bool{
+	"url":            true,
+	"sitemap":        true,
+	"robots":         true,
+	"other-sources":  true,
+	"xhr":            true,
+	"linkfinder":     true,
+	"form":           true,
+	"upload-form":    true,
+	"javascript":     true,
+	"subdomains":     true,
+	"cert-subdomain": true,
+	"secret":         true,
+	"aws-s3":         true,
+	"gcs":            true,
+	"azure-blob":     true,
+}
+
+// Longest JSON line read from a previous run
+const maxDiffLine = 16 * 1024 * 1024
+
+// PreviousRun is the findings of a previous crawl read from its JSON lines output,
+// by site and by finding
+type PreviousRun struct {
+	sites map[string]map[string]SpiderOutput
+	// js-fingerprint findings by site and JSFileKey, compared to the files crawled again
+	fingerprints map[string]map[string]SpiderOutput
+}
+
+// Identity of a finding across runs, what changes between runs (status, length, source) is left out
+func diffKey(record SpiderOutput) string {
+	return record.OutputType + " " + record.Output
+}
+
+// LoadPreviousRun reads the findings of a JSON lines output (--json), gzip or zstd compressed
+// when its name ends with .gz or .zst, the lines that aren't JSON findings are skipped
+func LoadPreviousRun(path string) (*PreviousRun, error) {
+	f, err := OpenDecompressed(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	run := &PreviousRun{sites: make(map[string]map[string]SpiderOutput), fingerprints: make(map[string]map[string]SpiderOutput)}
+	lines, findings := 0, 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), maxDiffLine)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		lines++
+		var record SpiderOutput
+		if err := json.Unmarshal([]byte(line), &record); err != nil || record.OutputType == "" {
+			continue
+		}
+		findings++
+		if record.OutputType == "js-fingerprint" {
+			if u, err := url.Parse(record.Output); err == nil {
+				if run.fingerprints[record.Input] == nil {
+					run.fingerprints[record.Input] = make(map[string]SpiderOutput)
+				}
+				run.fingerprints[record.Input][JSFileKey(u)] = record
+			}
+			continue
+		}
+		if !diffTypes[record.OutputType] {
+			continue
+		}
+		site, ok := run.sites[record.Input]
+		if !ok {
+			site = make(map[string]SpiderOutput)
+			run.sites[record.Input] = site
+		}
+		site[diffKey(record)] = record
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+	if lines > 0 && findings == 0 {
+		return nil, fmt.Errorf("no JSON finding in %s, the previous run must be written with --json", path)
+	}
+	return run, nil
+}
+
+// crawlDiff hides the findings of a site already found by the previous run and tracks
+// the ones that weren't found again
+type crawlDiff struct {
+	mu       sync.Mutex
+	previous map[string]SpiderOutput
+	seen     map[string]bool
+	// js-fingerprint findings of the previous run by JSFileKey
+	fingerprints map[string]SpiderOutput
+}
+
+// Findings of site in the previous run, the ones without input apply to every site
+func newCrawlDiff(run *PreviousRun, site string) *crawlDiff {
+	d := &crawlDiff{previous: make(map[string]SpiderOutput), seen: make(map[string]bool), fingerprints: make(map[string]SpiderOutput)}
+	for _, input := range []string{"", site} {
+		for key, record := range run.sites[input] {
+			d.previous[key] = record
+		}
+		for key, record := range run.fingerprints[input] {
+			d.fingerprints[key] = record
+		}
+	}
+	return d
+}
+
+// Fingerprint of a JavaScript file in the previous run, by JSFileKey
+func (d *crawlDiff) fingerprint(key string) (SpiderOutput, bool) {
+	record, ok := d.fingerprints[key]
+	return record, ok
+}
+
+// isNew reports whether record wasn't found by the previous run
+func (d *crawlDiff) isNew(record SpiderOutput) bool {
+	if !diffTypes[record.OutputType] {
+		return true
+	}
+	key := diffKey(record)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.seen[key] = true
+	_, found := d.previous[key]
+	return !found
+}
+
+// Findings of the previous run not found again, by type and value
+func (d *crawlDiff) removed() []SpiderOutput {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var removed []SpiderOutput
+	for key, record := range d.previous {
+		if !d.seen[key] {
+			removed = append(removed, record)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return diffKey(removed[i]) < diffKey(removed[j])
+	})
+	return removed
+}
+
+// Report the findings of the previous run this crawl didn't find again. A crawl stopped
+// before its end didn't see everything, nothing is reported then.
+func (crawler *Crawler) reportRemoved() {
+	if crawler.diff == nil {
+		return
+	}
+	if reason := crawler.budget.stopped(); reason != "" {
+		Logger.Warnf("Crawl of %s stopped (%s), removed findings aren't reported", crawler.site, reason)
+		return
+	}
+	removed := crawler.diff.removed()
+	for _, record := range removed {
+		outputFormat := fmt.Sprintf("[removed] - [%s] - %s", record.OutputType, record.Output)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     record.Source,
+			OutputType: "removed",
+			Output:     record.Output,
+			Details:    map[string]string{"type": record.OutputType},
+		})
+	}
+	Logger.Infof("%d findings of the previous run of %s not found again", len(removed), crawler.site)
+}
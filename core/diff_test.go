@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadPreviousRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	plain := filepath.Join(dir, "plain.txt")
+	_ = ioutil.WriteFile(plain, []byte("[url] - [code-200] - https://example.com/\n"), 0644)
+	if _, err := LoadPreviousRun(plain); err == nil || !strings.Contains(err.Error(), "--json") {
+		t.Errorf("plain output loaded: %v", err)
+	}
+	empty := filepath.Join(dir, "empty.jsonl")
+	_ = ioutil.WriteFile(empty, nil, 0644)
+	if _, err := LoadPreviousRun(empty); err != nil {
+		t.Errorf("empty previous run: %s", err)
+	}
+	if errs := ValidateOptions(Options{Concurrent: 1, Diff: filepath.Join(dir, "missing.jsonl")}); len(errs) != 1 {
+		t.Errorf("missing previous run not refused: %v", errs)
+	}
+}
+
+func TestCrawlerDiff(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><a href="/kept">kept</a><a href="/new">new</a></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html>page</html>`)
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "gospider-diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	site := ts.URL + "/"
+	previous := []SpiderOutput{
+		{Input: site, Source: "body", OutputType: "url", Output: ts.URL + "/", StatusCode: 200, Length: 10},
+		{Input: site, Source: "body", OutputType: "url", Output: ts.URL + "/kept", StatusCode: 500},
+		{Input: site, Source: site, OutputType: "url", Output: ts.URL + "/old"},
+		{Input: "https://other.example.org/", OutputType: "url", Output: "https://other.example.org/gone"},
+	}
+	var lines []string
+	for _, record := range previous {
+		data, _ := json.Marshal(record)
+		lines = append(lines, string(data))
+	}
+	file := filepath.Join(dir, "previous.jsonl")
+	_ = ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+
+	found := findingsByKey(crawlForTest(t, site, func(opts *Options) {
+		opts.Depth = 2
+		opts.Diff = file
+	}))
+	if _, ok := found["url "+ts.URL+"/new"]; !ok {
+		t.Errorf("new url not reported: %v", found)
+	}
+	for _, old := range []string{ts.URL + "/", ts.URL + "/kept"} {
+		if _, ok := found["url "+old]; ok {
+			t.Errorf("url %s of the previous run reported", old)
+		}
+	}
+	removed, ok := found["removed "+ts.URL+"/old"]
+	if !ok || removed.Details["type"] != "url" || removed.Source != site {
+		t.Errorf("removed url not reported: %+v", removed)
+	}
+	if _, ok := found["removed https://other.example.org/gone"]; ok {
+		t.Error("finding of another site reported removed")
+	}
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"github.com/gocolly/colly/v2"
+	"golang.org/x/net/html"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Contexts of the links found outside the href and src attributes, shown in the url
+// findings of the pages they lead to (Ex: "[url] - [comment] - [code-200] - ...")
+const (
+	ContextSrcset       = "srcset"
+	ContextData         = "data-attribute"
+	ContextAction       = "action"
+	ContextMetaRefresh  = "meta-refresh"
+	ContextInlineScript = "inline-script"
+	ContextEventHandler = "event-handler"
+	ContextCSS          = "css"
+	ContextComment      = "comment"
+)
+
+var (
+	cssURLRegex     = regexp.MustCompile(`(?i)url\(\s*['"]?([^'")\s]+)['"]?\s*\)`)
+	metaRefreshURL  = regexp.MustCompile(`(?i)url\s*=\s*['"]?([^'"\s]+)`)
+	commentURLRegex = regexp.MustCompile(`https?://[^\s"'<>()]+|(?:^|\s)/[A-Za-z0-9_~.-][^\s"'<>()]*`)
+)
+
+// EmbeddedLink is a link found outside the href and src attributes, it may be relative
+type EmbeddedLink struct {
+	Context string
+	Ref     string
+}
+
+// EmbeddedLinks returns the links of an HTML node and its children found in srcset,
+// data-*, formaction and action (forms excluded) attributes, meta refresh, inline scripts, inline event
+// handlers, CSS url() references and comments, in document order.
+func EmbeddedLinks(node *html.Node) []EmbeddedLink {
+	var links []EmbeddedLink
+	add := func(context string, refs ...string) {
+		for _, ref := range refs {
+			if ref = strings.TrimSpace(ref); ref != "" && !isInlineData(ref) {
+				links = append(links, EmbeddedLink{Context: context, Ref: ref})
+			}
+		}
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.CommentNode:
+			refs, _ := LinkFinder(n.Data)
+			add(ContextComment, refs...)
+			add(ContextComment, commentURLRegex.FindAllString(n.Data, -1)...)
+		case html.ElementNode:
+			for _, attr := range n.Attr {
+				key := strings.ToLower(attr.Key)
+				switch {
+				case key == "srcset":
+					add(ContextSrcset, srcsetURLs(attr.Val)...)
+				// Forms are handled by handleForm, only GET ones are submitted
+				case key == "action" && n.Data != "form", key == "formaction":
+					add(ContextAction, attr.Val)
+				case strings.HasPrefix(key, "data-") && looksLikeLink(attr.Val):
+					add(ContextData, attr.Val)
+				case strings.HasPrefix(key, "on"):
+					refs, _ := LinkFinder(attr.Val)
+					add(ContextEventHandler, refs...)
+				case key == "style":
+					add(ContextCSS, cssURLs(attr.Val)...)
+				}
+			}
+			switch n.Data {
+			case "meta":
+				if strings.EqualFold(htmlAttr(n, "http-equiv"), "refresh") {
+					if m := metaRefreshURL.FindStringSubmatch(htmlAttr(n, "content")); m != nil {
+						add(ContextMetaRefresh, m[1])
+					}
+				}
+			case "script":
+				if htmlAttr(n, "src") == "" {
+					refs, _ := LinkFinder(nodeText(n))
+					add(ContextInlineScript, refs...)
+				}
+			case "style":
+				add(ContextCSS, cssURLs(nodeText(n))...)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(node)
+	return links
+}
+
+// Image candidates of a srcset (Ex: "small.jpg 1x, large.jpg 2x")
+func srcsetURLs(srcset string) []string {
+	var refs []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 {
+			refs = append(refs, fields[0])
+		}
+	}
+	return refs
+}
+
+func cssURLs(css string) []string {
+	var refs []string
+	for _, m := range cssURLRegex.FindAllStringSubmatch(css, -1) {
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+// data-* attributes hold anything, only values shaped like a URL or a path are links
+func looksLikeLink(value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" || strings.ContainsAny(value, " \t\r\n{}<>") {
+		return false
+	}
+	for _, prefix := range []string{"http://", "https://", "//", "/", "./", "../"} {
+		if strings.HasPrefix(value, prefix) {
+			return len(value) > 1
+		}
+	}
+	return false
+}
+
+func isInlineData(ref string) bool {
+	ref = strings.ToLower(ref)
+	return strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "javascript:") || strings.HasPrefix(ref, "#")
+}
+
+func htmlAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.TextNode {
+			b.WriteString(c.Data)
+		}
+	}
+	return b.String()
+}
+
+// linkContexts remembers where the links found by findEmbeddedLinks come from until their page is reported
+type linkContexts struct {
+	mu       sync.Mutex
+	contexts map[string]string
+}
+
+func newLinkContexts() *linkContexts {
+	return &linkContexts{contexts: make(map[string]string)}
+}
+
+func (l *linkContexts) add(u, context string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.contexts[u] = context
+}
+
+// take returns the context of u and forgets it, empty for links of href and src attributes
+func (l *linkContexts) take(u string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	context := l.contexts[u]
+	delete(l.contexts, u)
+	return context
+}
+
+// Label of a link context in plain url findings
+func contextLabel(context string) string {
+	if context == "" {
+		return ""
+	}
+	return "[" + context + "] - "
+}
+
+// Details of url findings of links with a context, nil for href and src ones
+func contextDetails(context string) map[string]string {
+	if context == "" {
+		return nil
+	}
+	return map[string]string{"context": context}
+}
+
+// Crawl the links of a page found outside the href and src attributes
+func (crawler *Crawler) findEmbeddedLinks(e *colly.HTMLElement) {
+	if len(e.DOM.Nodes) == 0 {
+		return
+	}
+	// Comments may sit outside <html>, walk the whole document
+	root := e.DOM.Nodes[0]
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	source := e.Request.URL.String()
+	for _, link := range EmbeddedLinks(root) {
+		urlString := crawler.resolveRef(e, link.Ref)
+		if urlString == "" {
+			continue
+		}
+		crawler.reportExternalRef(source, urlString)
+		if !crawler.urlSet.Duplicate(crawler.urlKey(urlString)) {
+			crawler.linkContexts.add(urlString, link.Context)
+			if err := e.Request.Visit(urlString); err != nil {
+				crawler.linkContexts.take(urlString)
+			}
+		}
+	}
+}
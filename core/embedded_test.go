@@ -0,0 +1,76 @@
+package core
+
+import (
+	"fmt"
+	"golang.org/x/net/html"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const embeddedPage = `<!-- legacy: /old/admin -->
+<html><head>
+<meta http-equiv="Refresh" content="30; URL='/refreshed'">
+<style>.hero { background: url("/img/hero.png") }</style>
+</head><body>
+<img src="/a.png" srcset="/img/small.png 1x, /img/large.png 2x">
+<div data-endpoint="/api/items" data-count="12" data-label="a b"></div>
+<form action="/search"><button formaction="/search/advanced">Go</button></form>
+<div action="/widget/save"></div>
+<button onclick="location.href='/checkout/start'">Buy</button>
+<span style="background-image:url(/img/icon.svg)"></span>
+<script>fetch("/api/inline/user")</script>
+<a href="data:text/plain,hi" style="background:url(data:image/png;base64,AAA)">x</a>
+</body></html>`
+
+func TestEmbeddedLinks(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(embeddedPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []EmbeddedLink{
+		{ContextComment, "/old/admin"},
+		{ContextMetaRefresh, "/refreshed"},
+		{ContextCSS, "/img/hero.png"},
+		{ContextSrcset, "/img/small.png"},
+		{ContextSrcset, "/img/large.png"},
+		{ContextData, "/api/items"},
+		{ContextAction, "/search/advanced"},
+		{ContextAction, "/widget/save"},
+		{ContextEventHandler, "/checkout/start"},
+		{ContextCSS, "/img/icon.svg"},
+		{ContextInlineScript, "/api/inline/user"},
+	}
+	if got := EmbeddedLinks(doc); !reflect.DeepEqual(got, want) {
+		t.Errorf("EmbeddedLinks() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestCrawlerEmbeddedLinks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><!-- <a href="/backup/db">old</a> --><a href="/about">about</a><div data-next="/about"></div></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html>page</html>`)
+	}))
+	defer ts.Close()
+
+	found := make(map[string]SpiderOutput)
+	for _, r := range findingsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+	}), "url") {
+		found[r.Output] = r
+	}
+
+	if backup := found[ts.URL+"/backup/db"]; backup.Details["context"] != ContextComment {
+		t.Errorf("commented link reported as %+v", backup)
+	}
+	// Links of href attributes aren't labeled, even when another context has them too
+	if about, ok := found[ts.URL+"/about"]; !ok || about.Details != nil {
+		t.Errorf("href link reported as %+v", about)
+	}
+}
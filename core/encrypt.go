@@ -0,0 +1,153 @@
+package core
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/openpgp"
+	// Keys without hash preferences fall back to RIPEMD-160
+	_ "golang.org/x/crypto/ripemd160"
+)
+
+// EncryptedOutputExt is appended to the output folder path to name its encrypted archive
+const EncryptedOutputExt = ".tar.gz.gpg"
+
+// ReadRecipients reads the OpenPGP public keys of a key file, armored (gpg --export --armor)
+// or binary, every key of the file is a recipient
+func ReadRecipients(path string) (openpgp.EntityList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipient keys: %s", err)
+	}
+	defer f.Close()
+
+	keys, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if keys, err = openpgp.ReadKeyRing(f); err != nil {
+			return nil, fmt.Errorf("failed to read recipient keys of %s: %s", path, err)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key in %s", path)
+	}
+	// Fail before the crawl when a key can't encrypt (signing only, unsupported algorithm)
+	w, err := openpgp.Encrypt(ioutil.Discard, keys, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't encrypt to the keys of %s: %s", path, err)
+	}
+	w.Close()
+	return keys, nil
+}
+
+// checkEncryptedOutput refuses an output folder holding files before the run, they would be
+// removed with the plaintext output, or whose archive already exists
+func checkEncryptedOutput(folder string) error {
+	folder = filepath.Clean(folder)
+	if _, err := os.Stat(folder + EncryptedOutputExt); err == nil {
+		return fmt.Errorf("%s already exists, move it away or use another --output", folder+EncryptedOutputExt)
+	}
+	if files, err := ioutil.ReadDir(folder); err == nil && len(files) > 0 {
+		return fmt.Errorf("%s isn't empty, the output folder is removed once encrypted", folder)
+	}
+	return nil
+}
+
+// EncryptFolder packs folder as a tar.gz encrypted to the keys of the recipients key file,
+// written next to it as <folder>.tar.gz.gpg (gpg --decrypt gives the tar.gz). An existing
+// archive is never replaced. The folder is removed once the archive is complete and its
+// path is returned.
+func EncryptFolder(folder, recipients string) (string, error) {
+	keys, err := ReadRecipients(recipients)
+	if err != nil {
+		return "", err
+	}
+	folder = filepath.Clean(folder)
+	archive := folder + EncryptedOutputExt
+
+	// Written under a temporary name so a failed run never leaves a truncated archive
+	tmp, err := ioutil.TempFile(filepath.Dir(archive), filepath.Base(archive)+".*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create encrypted output: %s", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeEncryptedFolder(tmp, folder, keys); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to encrypt output: %s", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to encrypt output: %s", err)
+	}
+	// Linked rather than renamed, which would replace the archive of another run
+	if err := os.Link(tmp.Name(), archive); err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("%s already exists, the output is left in %s", archive, folder)
+		}
+		return "", fmt.Errorf("failed to encrypt output: %s", err)
+	}
+	if err := os.RemoveAll(folder); err != nil {
+		return archive, fmt.Errorf("failed to remove the plaintext output: %s", err)
+	}
+	return archive, nil
+}
+
+// Write the tar.gz of folder encrypted to keys, the paths in the archive start with the folder name
+func writeEncryptedFolder(w io.Writer, folder string, keys openpgp.EntityList) error {
+	plaintext, err := openpgp.Encrypt(w, keys, nil, &openpgp.FileHints{IsBinary: true}, nil)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(plaintext)
+	tw := tar.NewWriter(gz)
+
+	parent := filepath.Dir(folder)
+	err = filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(parent, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, c := range []io.Closer{tw, gz, plaintext} {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestEncryptFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-encrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	entity, err := openpgp.NewEntity("gospider", "", "team@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "team.asc")
+	f, _ := os.Create(keyFile)
+	w, _ := armor.Encode(f, openpgp.PublicKeyType, nil)
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	f.Close()
+
+	output := filepath.Join(dir, "output")
+	_ = os.MkdirAll(filepath.Join(output, "example.com"), 0755)
+	_ = ioutil.WriteFile(filepath.Join(output, "example_com"), []byte("[url] - https://example.com/\n"), 0644)
+	_ = ioutil.WriteFile(filepath.Join(output, "example.com", "urls.txt"), []byte("https://example.com/\n"), 0644)
+
+	archive, err := EncryptFolder(output+"/", keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive != output+EncryptedOutputExt {
+		t.Errorf("archive written to %s", archive)
+	}
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Error("plaintext output not removed")
+	}
+
+	encrypted, _ := os.Open(archive)
+	defer encrypted.Close()
+	md, err := openpgp.ReadMessage(encrypted, openpgp.EntityList{entity}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(md.UnverifiedBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(tr)
+		files[header.Name] = string(data)
+	}
+	want := map[string]string{
+		"output/":                     "",
+		"output/example.com/":         "",
+		"output/example.com/urls.txt": "https://example.com/\n",
+		"output/example_com":          "[url] - https://example.com/\n",
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("archive files %v, want %v", files, want)
+	}
+
+	if _, err := ReadRecipients(filepath.Join(dir, "output.tar.gz.gpg")); err == nil {
+		t.Error("encrypted archive read as a key file")
+	}
+
+	// The archive of the previous run is kept, so is the new output
+	previous, _ := ioutil.ReadFile(archive)
+	_ = os.MkdirAll(output, 0755)
+	_ = ioutil.WriteFile(filepath.Join(output, "example_com"), []byte("[url] - https://example.com/new\n"), 0644)
+	if _, err := EncryptFolder(output, keyFile); err == nil {
+		t.Error("existing archive overwritten")
+	}
+	if data, _ := ioutil.ReadFile(archive); !bytes.Equal(data, previous) {
+		t.Error("existing archive changed")
+	}
+	if _, err := os.Stat(filepath.Join(output, "example_com")); err != nil {
+		t.Errorf("output removed without an archive: %s", err)
+	}
+}
+
+func TestValidateEncryptOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-encrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	entity, err := openpgp.NewEntity("gospider", "", "team@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "team.asc")
+	f, _ := os.Create(keyFile)
+	if err := entity.Serialize(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	opts := DefaultOptions()
+	opts.EncryptOutput = keyFile
+	opts.OutputFolder = filepath.Join(dir, "output")
+	if errs := ValidateOptions(opts); len(errs) != 0 {
+		t.Errorf("new output folder refused: %v", errs)
+	}
+	_ = os.Mkdir(opts.OutputFolder, 0755)
+	if errs := ValidateOptions(opts); len(errs) != 0 {
+		t.Errorf("empty output folder refused: %v", errs)
+	}
+	// Files of the folder would be removed with the output
+	_ = ioutil.WriteFile(filepath.Join(opts.OutputFolder, "notes.txt"), []byte("notes"), 0644)
+	if errs := ValidateOptions(opts); len(errs) != 1 || !strings.Contains(errs[0].Error(), "isn't empty") {
+		t.Errorf("ValidateOptions() = %v", errs)
+	}
+	_ = os.RemoveAll(opts.OutputFolder)
+	_ = ioutil.WriteFile(opts.OutputFolder+EncryptedOutputExt, []byte("archive"), 0644)
+	if errs := ValidateOptions(opts); len(errs) != 1 || !strings.Contains(errs[0].Error(), "already exists") {
+		t.Errorf("ValidateOptions() = %v", errs)
+	}
+}
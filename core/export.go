@@ -0,0 +1,329 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Findings holding an endpoint of the crawled sites worth replaying
+var exportTypes = map[string]bool{
+	"url":           true,
+	"form":          true,
+	"upload-form":   true,
+	"javascript":    true,
+	"linkfinder":    true,
+	"sitemap":       true,
+	"robots":        true,
+	"xhr":           true,
+	"other-sources": true,
+	"openapi":       true,
+}
+
+// Boundary of the multipart bodies of exported forms, fixed so exports are reproducible
+const exportBoundary = "gospiderExportBoundary"
+
+// ExportRequest is a discovered request, rebuilt with the headers the crawl sends
+type ExportRequest struct {
+	Method string
+	URL    *url.URL
+	Header http.Header
+	Body   string
+	// Status code and length of the response, when the crawl requested it
+	Status int
+	Length int
+}
+
+// Raw returns the request as sent on the wire, the format Burp Repeater and ZAP paste
+func (r ExportRequest) Raw() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", r.Method, r.URL.RequestURI())
+	fmt.Fprintf(&buf, "Host: %s\r\n", r.URL.Host)
+	_ = r.Header.Write(&buf)
+	buf.WriteString("\r\n")
+	buf.WriteString(r.Body)
+	return buf.Bytes()
+}
+
+// RequestExport writes the endpoints found by the crawls, forms with their parameters included,
+// for manual testing in an intercepting proxy: a Burp items XML file when the path ends with
+// .xml, one raw HTTP request file per endpoint in the folder otherwise.
+// It's a sink shared by every crawl writing to the same path, each Close writes all the requests.
+type RequestExport struct {
+	path   string
+	header http.Header
+
+	mu       sync.Mutex
+	seen     map[string]bool
+	requests []ExportRequest
+}
+
+var (
+	exportsMu sync.Mutex
+	exports   = make(map[string]*RequestExport)
+)
+
+// OpenRequestExport returns the export of path, shared by all the crawls of the process.
+// header is sent with every request, like the cookie and headers of the crawl.
+func OpenRequestExport(path string, header http.Header) (*RequestExport, error) {
+	path = filepath.Clean(path)
+	exportsMu.Lock()
+	defer exportsMu.Unlock()
+	if e, ok := exports[path]; ok {
+		return e, nil
+	}
+	dir := path
+	if isBurpItemsPath(path) {
+		dir = filepath.Dir(path)
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create export folder: %s", err)
+	}
+	e := &RequestExport{path: path, header: header, seen: make(map[string]bool)}
+	exports[path] = e
+	return e, nil
+}
+
+func isBurpItemsPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".xml")
+}
+
+// Write adds the request of a finding
+func (e *RequestExport) Write(record SpiderOutput, _ string) error {
+	if !exportTypes[record.OutputType] {
+		return nil
+	}
+	req, ok := e.request(record)
+	if !ok {
+		return nil
+	}
+	key := req.Method + " " + req.URL.String() + " " + req.Body
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.seen[key] {
+		e.seen[key] = true
+		e.requests = append(e.requests, req)
+	}
+	return nil
+}
+
+// Rebuild the request of a finding, relative links can't be replayed and are left out
+func (e *RequestExport) request(record SpiderOutput) (ExportRequest, bool) {
+	u, err := url.Parse(strings.TrimSpace(record.Output))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ExportRequest{}, false
+	}
+	u.Fragment = ""
+	req := ExportRequest{
+		Method: http.MethodGet,
+		URL:    u,
+		Header: e.header.Clone(),
+		Status: record.StatusCode,
+		Length: record.Length,
+	}
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+
+	switch record.OutputType {
+	case "openapi":
+		if len(record.Methods) > 0 {
+			req.Method = record.Methods[0]
+		}
+	case "form", "upload-form":
+		if method := record.Details["method"]; method != "" {
+			req.Method = method
+		}
+		params := record.Details["params"]
+		if req.Method == http.MethodGet {
+			if params != "" {
+				query := u.Query()
+				values, _ := url.ParseQuery(params)
+				for name, v := range values {
+					query[name] = v
+				}
+				u.RawQuery = query.Encode()
+			}
+			break
+		}
+		contentType, body := formBody(record.Details["enctype"], params)
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		req.Body = body
+	}
+	return req, true
+}
+
+// Encode the parameters of a form the way a browser submits it
+func formBody(enctype, params string) (string, string) {
+	values, _ := url.ParseQuery(params)
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch enctype {
+	case "multipart/form-data":
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		_ = w.SetBoundary(exportBoundary)
+		for _, name := range names {
+			for _, value := range values[name] {
+				_ = w.WriteField(name, value)
+			}
+		}
+		_ = w.Close()
+		return w.FormDataContentType(), buf.String()
+	case "text/plain":
+		var buf strings.Builder
+		for _, name := range names {
+			for _, value := range values[name] {
+				buf.WriteString(name + "=" + value + "\r\n")
+			}
+		}
+		return enctype, buf.String()
+	default:
+		return "application/x-www-form-urlencoded", params
+	}
+}
+
+// Requests returns the requests collected so far
+func (e *RequestExport) Requests() []ExportRequest {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]ExportRequest(nil), e.requests...)
+}
+
+// Close writes the requests collected so far
+func (e *RequestExport) Close() error {
+	requests := e.Requests()
+	if isBurpItemsPath(e.path) {
+		f, err := os.Create(e.path)
+		if err != nil {
+			return fmt.Errorf("failed to write export: %s", err)
+		}
+		defer f.Close()
+		if err := WriteBurpItems(f, requests); err != nil {
+			return fmt.Errorf("failed to write export: %s", err)
+		}
+		return nil
+	}
+
+	// <folder>/<hostname>/<method>-<sha1 of the request>.txt, like the response store
+	for _, req := range requests {
+		raw := req.Raw()
+		hash := sha1.Sum(raw)
+		name := req.Method + "-" + hex.EncodeToString(hash[:]) + ".txt"
+		fullPath := filepath.Join(e.path, HostFileName(req.URL.Host), name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+			return fmt.Errorf("failed to write export: %s", err)
+		}
+		if err := ioutil.WriteFile(fullPath, raw, 0644); err != nil {
+			return fmt.Errorf("failed to write export: %s", err)
+		}
+	}
+	return nil
+}
+
+// Burp "Save items" format, read by Burp extensions and by ZAP's Burp import
+type burpItems struct {
+	XMLName     xml.Name   `xml:"items"`
+	BurpVersion string     `xml:"burpVersion,attr"`
+	ExportTime  string     `xml:"exportTime,attr"`
+	Items       []burpItem `xml:"item"`
+}
+
+type burpItem struct {
+	Time           string      `xml:"time"`
+	URL            burpCDATA   `xml:"url"`
+	Host           burpHost    `xml:"host"`
+	Port           string      `xml:"port"`
+	Protocol       string      `xml:"protocol"`
+	Method         burpCDATA   `xml:"method"`
+	Path           burpCDATA   `xml:"path"`
+	Extension      string      `xml:"extension"`
+	Request        burpEncoded `xml:"request"`
+	Status         string      `xml:"status"`
+	ResponseLength string      `xml:"responselength"`
+	MimeType       string      `xml:"mimetype"`
+	Response       burpEncoded `xml:"response"`
+	Comment        string      `xml:"comment"`
+}
+
+type burpCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type burpHost struct {
+	IP   string `xml:"ip,attr"`
+	Name string `xml:",chardata"`
+}
+
+type burpEncoded struct {
+	Base64 bool   `xml:"base64,attr"`
+	Value  string `xml:",cdata"`
+}
+
+// WriteBurpItems writes requests as a Burp items XML document
+func WriteBurpItems(w io.Writer, requests []ExportRequest) error {
+	now := time.Now().Format(time.ANSIC)
+	doc := burpItems{BurpVersion: "gospider " + VERSION, ExportTime: now}
+	for _, req := range requests {
+		port := req.URL.Port()
+		if port == "" {
+			port = "80"
+			if req.URL.Scheme == "https" {
+				port = "443"
+			}
+		}
+		ext := strings.TrimPrefix(path.Ext(req.URL.Path), ".")
+		if ext == "" {
+			ext = "null"
+		}
+		item := burpItem{
+			Time:      now,
+			URL:       burpCDATA{req.URL.String()},
+			Host:      burpHost{Name: req.URL.Hostname()},
+			Port:      port,
+			Protocol:  req.URL.Scheme,
+			Method:    burpCDATA{req.Method},
+			Path:      burpCDATA{req.URL.RequestURI()},
+			Extension: ext,
+			Request:   burpEncoded{Base64: true, Value: base64.StdEncoding.EncodeToString(req.Raw())},
+			Response:  burpEncoded{Base64: true},
+		}
+		if req.Status > 0 {
+			item.Status = strconv.Itoa(req.Status)
+			item.ResponseLength = strconv.Itoa(req.Length)
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
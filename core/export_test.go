@@ -0,0 +1,126 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRequestExportRequests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e, err := OpenRequestExport(filepath.Join(dir, "raw"), http.Header{"Cookie": {"sid=1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared, _ := OpenRequestExport(filepath.Join(dir, "raw")+"/", nil); shared != e {
+		t.Error("export of the same path not shared")
+	}
+	_ = e.Write(SpiderOutput{OutputType: "url", Output: "https://example.com/a#top", StatusCode: 200, Length: 10}, "")
+	_ = e.Write(SpiderOutput{OutputType: "url", Output: "https://example.com/a"}, "")
+	_ = e.Write(SpiderOutput{OutputType: "linkfinder", Output: "/relative/path"}, "")
+	_ = e.Write(SpiderOutput{OutputType: "subdomains", Output: "api.example.com"}, "")
+	_ = e.Write(SpiderOutput{OutputType: "form", Output: "https://example.com/search?lang=en", Details: map[string]string{"method": "GET", "params": "q=test"}}, "")
+	_ = e.Write(SpiderOutput{OutputType: "form", Output: "https://example.com/login", Details: map[string]string{"method": "POST", "params": "user=admin&pass="}}, "")
+	_ = e.Write(SpiderOutput{OutputType: "form", Output: "https://example.com/upload", Details: map[string]string{"method": "POST", "enctype": "multipart/form-data", "params": "name=x"}}, "")
+	_ = e.Write(SpiderOutput{OutputType: "openapi", Output: "https://example.com/api/users", Methods: []string{"DELETE"}}, "")
+
+	requests := e.Requests()
+	if len(requests) != 5 {
+		t.Fatalf("got %d requests: %v", len(requests), requests)
+	}
+	raws := make(map[string]string)
+	for _, req := range requests {
+		raws[req.Method+" "+req.URL.Path] = string(req.Raw())
+	}
+	for key, want := range map[string]string{
+		"GET /a":            "GET /a HTTP/1.1\r\nHost: example.com\r\nCookie: sid=1\r\n\r\n",
+		"GET /search":       "GET /search?lang=en&q=test HTTP/1.1\r\nHost: example.com\r\nCookie: sid=1\r\n\r\n",
+		"POST /login":       "POST /login HTTP/1.1\r\nHost: example.com\r\nContent-Length: 16\r\nContent-Type: application/x-www-form-urlencoded\r\nCookie: sid=1\r\n\r\nuser=admin&pass=",
+		"DELETE /api/users": "DELETE /api/users HTTP/1.1\r\nHost: example.com\r\nCookie: sid=1\r\n\r\n",
+	} {
+		if got := raws[key]; got != want {
+			t.Errorf("%s request = %q, want %q", key, got, want)
+		}
+	}
+	if upload := raws["POST /upload"]; !strings.Contains(upload, "Content-Type: multipart/form-data; boundary="+exportBoundary) ||
+		!strings.Contains(upload, "Content-Disposition: form-data; name=\"name\"\r\n\r\nx\r\n") {
+		t.Errorf("multipart request = %q", upload)
+	}
+
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "raw", "example_com", "*.txt"))
+	if len(files) != 5 {
+		t.Errorf("wrote %d request files", len(files))
+	}
+	posts, _ := filepath.Glob(filepath.Join(dir, "raw", "example_com", "POST-*.txt"))
+	if len(posts) != 2 {
+		t.Errorf("wrote %d POST request files", len(posts))
+	}
+}
+
+func TestCrawlerExportBurp(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><a href="/about.php">about</a>
+<form action="/login" method="post"><input name="user"><input name="pass" type="password"></form></html>`)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gospider-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.Headers = []string{"X-Api-Key: secret"}
+	opts.UserAgent = "tester"
+	opts.ExportBurp = filepath.Join(dir, "items.xml")
+	if err := CrawlSites([]string{ts.URL}, opts, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(opts.ExportBurp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items burpItems
+	if err := xml.Unmarshal(data, &items); err != nil {
+		t.Fatalf("invalid items file: %s\n%s", err, data)
+	}
+	requests := make(map[string]string)
+	for _, item := range items.Items {
+		raw, err := base64.StdEncoding.DecodeString(item.Request.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests[item.Method.Value+" "+item.Path.Value] = string(raw)
+		if item.Path.Value == "/about.php" && item.Extension != "php" {
+			t.Errorf("extension = %q", item.Extension)
+		}
+	}
+	login, ok := requests["POST /login"]
+	if !ok || !strings.HasSuffix(login, "\r\n\r\nuser=&pass=") {
+		t.Errorf("login request = %q, found %v", login, requests)
+	}
+	if about := requests["GET /about.php"]; !strings.Contains(about, "X-Api-Key: secret\r\n") || !strings.Contains(about, "User-Agent: tester\r\n") {
+		t.Errorf("about request = %q", about)
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"regexp"
+	"strings"
+)
+
+// ExtractRule pulls custom values (internal hosts, emails, tracking IDs...) out of responses,
+// they are reported as [custom:name] findings.
+// A regex rule matches every response body and JavaScript file, the first group is reported
+// when the regex has one. A selector rule reports the text (or attribute) of matching HTML
+// elements, narrowed by the regex when both are set.
+type ExtractRule struct {
+	Name     string `yaml:"name"`
+	Regex    string `yaml:"regex"`
+	Selector string `yaml:"selector"`
+	Attr     string `yaml:"attr"` // Attribute of the selected elements reported instead of their text
+
+	re *regexp.Regexp
+}
+
+// ParseExtractRule parses a name:regex flag value, or a name:css one when selector is set
+func ParseExtractRule(raw string, selector bool) (ExtractRule, error) {
+	kind := "regex"
+	if selector {
+		kind = "css"
+	}
+	parts := strings.SplitN(raw, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || parts[1] == "" {
+		return ExtractRule{}, fmt.Errorf("invalid extract rule %q, use name:%s", raw, kind)
+	}
+	rule := ExtractRule{Name: strings.TrimSpace(parts[0])}
+	if selector {
+		rule.Selector = parts[1]
+	} else {
+		rule.Regex = parts[1]
+	}
+	return rule, nil
+}
+
+// LoadExtractRules reads a YAML list of rules, each one with a name and a regex and/or a selector
+func LoadExtractRules(path string) ([]ExtractRule, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extract rules: %s", err)
+	}
+	var rules []ExtractRule
+	if err := yaml.UnmarshalStrict(raw, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse extract rules %s: %s", path, err)
+	}
+	return rules, nil
+}
+
+// CompileExtractRules checks every rule and compiles its regex and selector
+func CompileExtractRules(rules []ExtractRule) ([]ExtractRule, error) {
+	compiled := make([]ExtractRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" || strings.ContainsAny(rule.Name, " \t\n") {
+			return nil, fmt.Errorf("extract rule %q needs a name without spaces", rule.Name)
+		}
+		if rule.Regex == "" && rule.Selector == "" {
+			return nil, fmt.Errorf("extract rule %s has no regex nor selector", rule.Name)
+		}
+		if rule.Regex != "" {
+			re, err := compileRegex(rule.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex in extract rule %s: %s", rule.Name, err)
+			}
+			rule.re = re
+		}
+		// colly silently ignores invalid selectors
+		if rule.Selector != "" {
+			if _, err := cascadia.Compile(rule.Selector); err != nil {
+				return nil, fmt.Errorf("invalid selector in extract rule %s: %s", rule.Name, err)
+			}
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// Values of the compiled regex of the rule in source, the first group when it has one
+func (rule ExtractRule) find(source string) []string {
+	var values []string
+	for _, match := range rule.re.FindAllStringSubmatch(source, -1) {
+		value := match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Register the selector rules on the main collector
+func (crawler *Crawler) setupExtractors() {
+	for _, rule := range crawler.extractRules {
+		if rule.Selector == "" {
+			continue
+		}
+		rule := rule
+		crawler.C.OnHTML(rule.Selector, func(e *colly.HTMLElement) {
+			value := strings.TrimSpace(e.Text)
+			if rule.Attr != "" {
+				value = e.Attr(rule.Attr)
+			}
+			values := []string{value}
+			if rule.re != nil {
+				values = rule.find(value)
+			}
+			for _, v := range values {
+				crawler.reportCustom(e.Request.URL.String(), rule.Name, v)
+			}
+		})
+	}
+}
+
+// Find the values of the regex rules in a response or JavaScript source
+func (crawler *Crawler) findCustom(source, resp string) {
+	for _, rule := range crawler.extractRules {
+		if rule.Selector != "" {
+			continue
+		}
+		for _, value := range rule.find(resp) {
+			crawler.reportCustom(source, rule.Name, value)
+		}
+	}
+}
+
+func (crawler *Crawler) reportCustom(source, name, value string) {
+	if value == "" || crawler.customSet.Duplicate(name+"|"+value) {
+		return
+	}
+	outputFormat := fmt.Sprintf("[custom:%s] - [%s] - %s", name, source, value)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     source,
+		OutputType: "custom",
+		Output:     value,
+		Rule:       name,
+	})
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestParseExtractRule(t *testing.T) {
+	rule, err := ParseExtractRule(`host:https?://([a-z]+\.corp\.local)`, false)
+	if err != nil || rule.Name != "host" || rule.Regex != `https?://([a-z]+\.corp\.local)` {
+		t.Errorf("ParseExtractRule() = %+v, %v", rule, err)
+	}
+	rule, err = ParseExtractRule("next:a:not([href])", true)
+	if err != nil || rule.Name != "next" || rule.Selector != "a:not([href])" {
+		t.Errorf("ParseExtractRule() = %+v, %v", rule, err)
+	}
+	for _, raw := range []string{"noname", ":regex", "name:"} {
+		if _, err := ParseExtractRule(raw, false); err == nil {
+			t.Errorf("ParseExtractRule(%q) returned no error", raw)
+		}
+	}
+}
+
+func TestCompileExtractRules(t *testing.T) {
+	for _, rule := range []ExtractRule{
+		{Name: "with space", Regex: "x"},
+		{Name: "empty"},
+		{Name: "regex", Regex: "(["},
+		{Name: "selector", Selector: "a[["},
+	} {
+		if _, err := CompileExtractRules([]ExtractRule{rule}); err == nil {
+			t.Errorf("CompileExtractRules(%+v) returned no error", rule)
+		}
+	}
+}
+
+func TestCrawlerExtractRules(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app.js" {
+			fmt.Fprint(w, `fetch("https://billing.corp.local/api")`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><meta name="csrf-token" content="abc123"></head>
+<body>Contact admin@example.com or https://jira.corp.local/browse/X
+<span class="ga">UA-1234-5</span><script src="/app.js"></script></body></html>`)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gospider-extract")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "extract.yaml")
+	if err := ioutil.WriteFile(config, []byte("- name: csrf\n  selector: meta[name=csrf-token]\n  attr: content\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	for _, r := range findingsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.ExtractRules = []string{`email:[\w.+-]+@example\.com`, `host:https://([a-z]+\.corp\.local)`}
+		opts.ExtractSelectors = []string{"tracking:span.ga"}
+		opts.ExtractConfig = config
+	}), "custom") {
+		found = append(found, r.Rule+"="+r.Output)
+	}
+
+	sort.Strings(found)
+	want := []string{"csrf=abc123", "email=admin@example.com", "host=billing.corp.local", "host=jira.corp.local", "tracking=UA-1234-5"}
+	if fmt.Sprint(found) != fmt.Sprint(want) {
+		t.Errorf("found %v, want %v", found, want)
+	}
+}
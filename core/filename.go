@@ -0,0 +1,100 @@
+package core
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Longest file name made from a host, the output files add suffixes like "-politeness.json"
+const maxHostFileName = 100
+
+// Characters Windows doesn't allow in file names, plus the path separators
+const windowsUnsafeChars = `<>:"/\|?*`
+
+// Device names Windows reserves, with or without extension
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+var hostFileReplacer = strings.NewReplacer(".", "_", ":", "_", "[", "", "]", "")
+
+// Names given to hosts in this process, two hosts sanitized to the same name get "-2", "-3"...
+var hostFileNames = struct {
+	sync.Mutex
+	hosts map[string]string
+	names map[string]string
+}{hosts: make(map[string]string), names: make(map[string]string)}
+
+// HostFileName returns the file and folder name of the output of host, safe on Windows and Unix:
+// dots and port separators become "_" (Ex: example_com, example_com_8443, 2001_db8__1),
+// internationalized hosts are in punycode, Windows device names get a "_" suffix and long hosts
+// are cut with a hash. The same host always gets the same name, hosts whose names collide get
+// a numbered suffix in the order they come.
+func HostFileName(host string) string {
+	host = strings.ToLower(asciiHost(host))
+	name := hostFileBase(host)
+
+	hostFileNames.Lock()
+	defer hostFileNames.Unlock()
+	if taken, ok := hostFileNames.hosts[host]; ok {
+		return taken
+	}
+	unique := name
+	for i := 2; hostFileNames.names[unique] != "" && hostFileNames.names[unique] != host; i++ {
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+	hostFileNames.hosts[host] = unique
+	hostFileNames.names[unique] = host
+	return unique
+}
+
+// Sanitized name of host, before collisions are handled
+func hostFileBase(host string) string {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, ""
+	}
+	name := hostFileReplacer.Replace(hostname)
+	if port != "" {
+		name += "_" + port
+	}
+	name = safeFileName(name)
+	if len(name) > maxHostFileName {
+		sum := sha1.Sum([]byte(host))
+		name = fmt.Sprintf("%s-%x", name[:maxHostFileName-13], sum[:6])
+	}
+	return name
+}
+
+// safeFileName makes a single path segment safe on Windows and Unix: characters Windows doesn't
+// allow and control characters become "_", trailing dots and spaces are removed and device names
+// like CON or NUL.txt get a "_" suffix (CON_, NUL_.txt)
+func safeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(windowsUnsafeChars, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "_"
+	}
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if windowsReservedNames[strings.ToUpper(base)] {
+		name = base + "_" + ext
+	}
+	return name
+}
+
+// Folder name of host that keeps its dots (Ex: example.com, 127.0.0.1_8080)
+func hostDirName(host string) string {
+	return safeFileName(strings.ToLower(asciiHost(host)))
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHostFileName(t *testing.T) {
+	tests := map[string]string{
+		"example.com":          "example_com",
+		"Example.com:8443":     "example_com_8443",
+		"[2001:db8::1]:8080":   "2001_db8__1_8080",
+		"bücher.example":       "xn--bcher-kva_example",
+		"con":                  "con_",
+		"lpt1:80":              "lpt1_80",
+		"files.example.org:21": "files_example_org_21",
+	}
+	for host, want := range tests {
+		if got := HostFileName(host); got != want {
+			t.Errorf("HostFileName(%q) = %q, want %q", host, got, want)
+		}
+	}
+
+	long := strings.Repeat("sub.", 60) + "example.com"
+	name := HostFileName(long)
+	if len(name) > maxHostFileName || name == HostFileName(strings.Repeat("sub.", 61)+"example.com") {
+		t.Errorf("long hosts named %q", name)
+	}
+
+	// example_com.test and example.com.test sanitize to the same name
+	first := HostFileName("example_com.test")
+	second := HostFileName("example.com.test")
+	if first != "example_com_test" || second != "example_com_test-2" || HostFileName("example.com.test") != second {
+		t.Errorf("colliding hosts named %q and %q", first, second)
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	tests := map[string]string{
+		`a<b>c:d"e|f?g*h`: "a_b_c_d_e_f_g_h",
+		"name. ":          "name",
+		"NUL.txt":         "NUL_.txt",
+		"aux":             "aux_",
+		"console.js":      "console.js",
+		"tab\there":       "tab_here",
+		"..":              "_",
+	}
+	for name, want := range tests {
+		if got := safeFileName(name); got != want {
+			t.Errorf("safeFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
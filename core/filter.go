@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IntRange is an inclusive range of status codes or lengths, Max -1 for no upper bound
+type IntRange struct {
+	Min int
+	Max int
+}
+
+// ParseIntRange parses "404", "500-599", "10000-" (no upper bound) or "-100" (no lower bound)
+func ParseIntRange(raw string) (IntRange, error) {
+	raw = strings.TrimSpace(raw)
+	bounds := strings.SplitN(raw, "-", 2)
+	if len(bounds) == 1 {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return IntRange{}, fmt.Errorf("invalid number %q", raw)
+		}
+		return IntRange{Min: n, Max: n}, nil
+	}
+	r := IntRange{Min: 0, Max: -1}
+	var err error
+	if bounds[0] != "" {
+		if r.Min, err = strconv.Atoi(bounds[0]); err != nil || r.Min < 0 {
+			return IntRange{}, fmt.Errorf("invalid range %q", raw)
+		}
+	}
+	if bounds[1] != "" {
+		if r.Max, err = strconv.Atoi(bounds[1]); err != nil || r.Max < r.Min {
+			return IntRange{}, fmt.Errorf("invalid range %q", raw)
+		}
+	}
+	if bounds[0] == "" && bounds[1] == "" {
+		return IntRange{}, fmt.Errorf("invalid range %q", raw)
+	}
+	return r, nil
+}
+
+// Contains reports whether n is in the range
+func (r IntRange) Contains(n int) bool {
+	return n >= r.Min && (r.Max < 0 || n <= r.Max)
+}
+
+func parseIntRanges(name string, raws []string) ([]IntRange, error) {
+	var ranges []IntRange
+	for _, raw := range raws {
+		r, err := ParseIntRange(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", name, err)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func inRanges(n int, ranges []IntRange) bool {
+	for _, r := range ranges {
+		if r.Contains(n) {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseFilter decides which crawled responses are reported as url findings.
+// Filtered responses are still crawled, only their url finding is hidden.
+type ResponseFilter struct {
+	filterCodes   []IntRange
+	matchCodes    []IntRange
+	filterLengths []IntRange
+	contentTypes  []string
+	regex         *regexp.Regexp
+}
+
+// NewResponseFilter creates the filter of the Filter* and MatchCodes options, nil when none is set
+func NewResponseFilter(opts Options) (*ResponseFilter, error) {
+	if len(opts.FilterCodes) == 0 && len(opts.MatchCodes) == 0 && len(opts.FilterLengths) == 0 &&
+		len(opts.FilterContentTypes) == 0 && opts.FilterRegex == "" {
+		return nil, nil
+	}
+
+	f := &ResponseFilter{}
+	var err error
+	if f.filterCodes, err = parseIntRanges("filter code", opts.FilterCodes); err != nil {
+		return nil, err
+	}
+	if f.matchCodes, err = parseIntRanges("match code", opts.MatchCodes); err != nil {
+		return nil, err
+	}
+	if f.filterLengths, err = parseIntRanges("filter length", opts.FilterLengths); err != nil {
+		return nil, err
+	}
+	for _, contentType := range opts.FilterContentTypes {
+		if contentType = strings.ToLower(strings.TrimSpace(contentType)); contentType != "" {
+			f.contentTypes = append(f.contentTypes, contentType)
+		}
+	}
+	if opts.FilterRegex != "" {
+		if f.regex, err = compileRegex(opts.FilterRegex); err != nil {
+			return nil, fmt.Errorf("invalid filter regex: %s", err)
+		}
+	}
+	return f, nil
+}
+
+// Hide reports whether a response must not be reported
+func (f *ResponseFilter) Hide(statusCode, length int, contentType string, body []byte) bool {
+	if f == nil {
+		return false
+	}
+	if len(f.matchCodes) > 0 && !inRanges(statusCode, f.matchCodes) {
+		return true
+	}
+	if inRanges(statusCode, f.filterCodes) || inRanges(length, f.filterLengths) {
+		return true
+	}
+	if len(f.contentTypes) > 0 {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			mediaType = strings.ToLower(strings.TrimSpace(contentType))
+		}
+		// A prefix matches a whole family (Ex: image/ or font/)
+		for _, filtered := range f.contentTypes {
+			if strings.HasPrefix(mediaType, filtered) {
+				return true
+			}
+		}
+	}
+	return f.regex != nil && f.regex.Match(body)
+}
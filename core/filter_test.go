@@ -0,0 +1,141 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+)
+
+func TestParseIntRange(t *testing.T) {
+	tests := map[string]IntRange{
+		"404":      {404, 404},
+		"500-599":  {500, 599},
+		"1000000-": {1000000, -1},
+		"-100":     {0, 100},
+	}
+	for raw, want := range tests {
+		got, err := ParseIntRange(raw)
+		if err != nil || got != want {
+			t.Errorf("ParseIntRange(%q) = %v, %v, want %v", raw, got, err, want)
+		}
+	}
+	for _, raw := range []string{"", "-", "abc", "599-500", "-5-"} {
+		if _, err := ParseIntRange(raw); err == nil {
+			t.Errorf("ParseIntRange(%q) should fail", raw)
+		}
+	}
+}
+
+func TestResponseFilter(t *testing.T) {
+	opts := DefaultOptions()
+	opts.MatchCodes = []string{"200-399"}
+	opts.FilterCodes = []string{"302"}
+	opts.FilterLengths = []string{"0", "1000-"}
+	opts.FilterContentTypes = []string{"image/", "application/pdf"}
+	opts.FilterRegex = "(?i)page not found"
+	f, err := NewResponseFilter(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code        int
+		length      int
+		contentType string
+		body        string
+		hide        bool
+	}{
+		{200, 10, "text/html", "hello", false},
+		{403, 10, "text/html", "forbidden", true},
+		{302, 10, "text/html", "moved", true},
+		{200, 0, "text/html", "", true},
+		{200, 5000, "text/html", "big", true},
+		{200, 10, "image/png", "png", true},
+		{200, 10, "application/pdf; charset=binary", "pdf", true},
+		{200, 10, "text/html", "<h1>Page Not Found</h1>", true},
+	}
+	for _, test := range tests {
+		if got := f.Hide(test.code, test.length, test.contentType, []byte(test.body)); got != test.hide {
+			t.Errorf("Hide(%d, %d, %q, %q) = %v, want %v", test.code, test.length, test.contentType, test.body, got, test.hide)
+		}
+	}
+
+	if f, err := NewResponseFilter(DefaultOptions()); err != nil || f != nil || f.Hide(200, 0, "", nil) {
+		t.Error("no filter option should hide nothing")
+	}
+	opts = DefaultOptions()
+	opts.FilterRegex = "("
+	if _, err := NewResponseFilter(opts); err == nil {
+		t.Error("invalid filter regex should fail")
+	}
+}
+
+func TestCrawlerResponseFilter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><a href="/missing">missing</a></html>`)
+		case "/missing":
+			// Soft 404 with links still followed
+			fmt.Fprint(w, `<html>Page not found <a href="/home">home</a></html>`)
+		default:
+			fmt.Fprint(w, `<html>home</html>`)
+		}
+	}))
+	defer ts.Close()
+
+	found := outputsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 3
+		opts.FilterRegex = "Page not found"
+	}), "url")
+
+	if found[ts.URL+"/missing"] {
+		t.Error("filtered response reported")
+	}
+	if !found[ts.URL+"/home"] {
+		t.Errorf("links of filtered response not crawled, got %v", found)
+	}
+}
+
+func TestCrawlerReportErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><a href="/gone">gone</a><a href="/crash">crash</a><a href="/slow">slow</a><a href="/private">private</a></html>`)
+		case "/gone":
+			w.WriteHeader(http.StatusNotFound)
+		case "/crash":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/private":
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	crawl := func(reportErrors []string) string {
+		findings := crawlForTest(t, ts.URL+"/", func(opts *Options) {
+			opts.Depth = 2
+			opts.ReportErrors = reportErrors
+		})
+		var codes []int
+		for _, r := range findingsOf(findings, "url") {
+			if r.StatusCode >= 400 {
+				codes = append(codes, r.StatusCode)
+			}
+		}
+		sort.Ints(codes)
+		return fmt.Sprint(codes)
+	}
+
+	if got := crawl(nil); got != "[403]" {
+		t.Errorf("error codes reported by default: %s", got)
+	}
+	if got := crawl([]string{"404", "500-599"}); got != "[403 404 500]" {
+		t.Errorf("error codes reported with --report-errors 404,500-599: %s", got)
+	}
+}
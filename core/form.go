@@ -0,0 +1,205 @@
+package core
+
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Form is an HTML form with everything needed to rebuild its request
+type Form struct {
+	Page    string
+	Action  string
+	Method  string
+	Enctype string
+	Inputs  []FormInput
+}
+
+// FormInput is a named input, select or textarea with its default value
+type FormInput struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// ParseForm reads a form element, relative actions are resolved against its page
+func ParseForm(e *colly.HTMLElement) Form {
+	form := Form{
+		Page:    e.Request.URL.String(),
+		Action:  e.Request.AbsoluteURL(e.Attr("action")),
+		Method:  strings.ToUpper(strings.TrimSpace(e.Attr("method"))),
+		Enctype: strings.ToLower(strings.TrimSpace(e.Attr("enctype"))),
+	}
+	// A form without action submits to its own page
+	if strings.TrimSpace(e.Attr("action")) == "" {
+		form.Action = form.Page
+	}
+	if form.Method != "POST" {
+		form.Method = "GET"
+	}
+	if form.Enctype == "" {
+		form.Enctype = "application/x-www-form-urlencoded"
+	}
+
+	e.DOM.Find("input, select, textarea").Each(func(_ int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		if name == "" {
+			return
+		}
+		input := FormInput{Name: name}
+		switch goquery.NodeName(s) {
+		case "select":
+			input.Type = "select"
+			option := s.Find("option[selected]").First()
+			if option.Length() == 0 {
+				option = s.Find("option").First()
+			}
+			if value, ok := option.Attr("value"); ok {
+				input.Value = value
+			} else {
+				input.Value = strings.TrimSpace(option.Text())
+			}
+		case "textarea":
+			input.Type = "textarea"
+			input.Value = s.Text()
+		default:
+			input.Type = strings.ToLower(s.AttrOr("type", "text"))
+			input.Value = s.AttrOr("value", "")
+			if (input.Type == "checkbox" || input.Type == "radio") && input.Value == "" {
+				input.Value = "on"
+			}
+		}
+		form.Inputs = append(form.Inputs, input)
+	})
+	return form
+}
+
+// Signature identifies the form across pages: its method, its action without fragment and
+// query values, and its sorted field names. The same form included in every page of a site
+// has one signature, the different forms of a page have their own.
+func (f Form) Signature() string {
+	action := f.Action
+	if u, err := url.Parse(f.Action); err == nil {
+		var params []string
+		for name := range u.Query() {
+			params = append(params, name)
+		}
+		sort.Strings(params)
+		u.Scheme, u.Host = strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+		u.RawQuery, u.Fragment = strings.Join(params, "&"), ""
+		action = u.String()
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, input := range f.Inputs {
+		if !seen[input.Name] {
+			seen[input.Name] = true
+			names = append(names, input.Name)
+		}
+	}
+	sort.Strings(names)
+	return f.Method + " " + action + " " + strings.Join(names, ",")
+}
+
+// HasUpload reports whether the form has a file input
+func (f Form) HasUpload() bool {
+	for _, input := range f.Inputs {
+		if input.Type == "file" {
+			return true
+		}
+	}
+	return false
+}
+
+// Params returns the form parameters encoded with their default values
+func (f Form) Params() string {
+	var params []string
+	for _, input := range f.Inputs {
+		params = append(params, url.QueryEscape(input.Name)+"="+url.QueryEscape(input.Value))
+	}
+	return strings.Join(params, "&")
+}
+
+// SubmitURL returns the URL a GET submission of the form requests,
+// empty fields are filled with a harmless value matching their type
+func (f Form) SubmitURL() string {
+	u, err := url.Parse(f.Action)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	for _, input := range f.Inputs {
+		if input.Type == "file" || input.Type == "submit" || input.Type == "button" || input.Type == "image" {
+			continue
+		}
+		value := input.Value
+		if value == "" {
+			value = sampleValue(input.Type)
+		}
+		query.Add(input.Name, value)
+	}
+	u.RawQuery = query.Encode()
+	u.Fragment = ""
+	return u.String()
+}
+
+func sampleValue(inputType string) string {
+	switch inputType {
+	case "email":
+		return "test@example.com"
+	case "number", "range":
+		return "1"
+	case "url":
+		return "https://example.com"
+	case "tel":
+		return "5555555555"
+	case "date":
+		return "2020-01-01"
+	default:
+		return "test"
+	}
+}
+
+// Report a form with its reconstructed request, and submit it when it's a GET form and --crawl-forms is set.
+// A form is reported once by signature, from the first page it's found on.
+func (crawler *Crawler) handleForm(e *colly.HTMLElement) {
+	form := ParseForm(e)
+	if crawler.formSet.Duplicate(form.Signature()) {
+		return
+	}
+
+	outputFormat := fmt.Sprintf("[form] - [from: %s] - [%s] - %s", form.Page, form.Method, form.Action)
+	if params := form.Params(); params != "" {
+		outputFormat += " - " + params
+	}
+	details := map[string]string{
+		"method":  form.Method,
+		"enctype": form.Enctype,
+		"params":  form.Params(),
+	}
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     form.Page,
+		OutputType: "form",
+		Output:     form.Action,
+		Details:    details,
+	})
+	if form.HasUpload() {
+		outputFormat = fmt.Sprintf("[upload-form] - [from: %s] - [%s] - %s", form.Page, form.Method, form.Action)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     form.Page,
+			OutputType: "upload-form",
+			Output:     form.Action,
+			Details:    details,
+		})
+	}
+
+	// POST forms may change server state, only GET forms are submitted
+	if crawler.opts.CrawlForms && form.Method == "GET" {
+		if submitURL := form.SubmitURL(); submitURL != "" {
+			_ = e.Request.Visit(submitURL)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFormSubmitURL(t *testing.T) {
+	form := Form{
+		Action: "https://example.com/search?lang=en#top",
+		Method: "GET",
+		Inputs: []FormInput{
+			{Name: "q", Type: "text"},
+			{Name: "email", Type: "email"},
+			{Name: "sort", Type: "select", Value: "date"},
+			{Name: "go", Type: "submit", Value: "Search"},
+		},
+	}
+	want := "https://example.com/search?email=test%40example.com&lang=en&q=test&sort=date"
+	if got := form.SubmitURL(); got != want {
+		t.Errorf("SubmitURL() = %s, want %s", got, want)
+	}
+	if got, want := form.Params(), "q=&email=&sort=date&go=Search"; got != want {
+		t.Errorf("Params() = %s, want %s", got, want)
+	}
+}
+
+func TestCrawlForms(t *testing.T) {
+	var mu sync.Mutex
+	var searched string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/search":
+			mu.Lock()
+			searched = r.URL.RawQuery
+			mu.Unlock()
+		default:
+			fmt.Fprint(w, `<html>
+<form action="/search"><input name="q"><select name="sort"><option value="a">A</option><option value="b" selected>B</option></select></form>
+<form method="post" action="/login"><input name="user"><input type="password" name="pass"><input type="hidden" name="csrf" value="t0k"></form>
+</html>`)
+		}
+	}))
+	defer ts.Close()
+
+	findings := crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+		opts.CrawlForms = true
+	})
+	forms := make(map[string]SpiderOutput)
+	for _, r := range findingsOf(findings, "form") {
+		forms[r.Output] = r
+	}
+
+	login := forms[ts.URL+"/login"]
+	if login.Details["method"] != "POST" || login.Details["params"] != "user=&pass=&csrf=t0k" {
+		t.Errorf("unexpected login form %+v", login)
+	}
+	if _, ok := forms[ts.URL+"/search"]; !ok {
+		t.Errorf("search form not reported, got %v", forms)
+	}
+	if searched != "q=test&sort=b" {
+		t.Errorf("GET form submitted with %q", searched)
+	}
+}
+
+func TestFormSignature(t *testing.T) {
+	login := Form{Method: "POST", Action: "https://Example.com/login?next=/a#top", Inputs: []FormInput{{Name: "user"}, {Name: "pass"}, {Name: "csrf", Value: "t0k"}}}
+	other := Form{Method: "POST", Action: "https://example.com/login?next=/b", Inputs: []FormInput{{Name: "csrf", Value: "t1k"}, {Name: "pass"}, {Name: "user"}}}
+	if login.Signature() != other.Signature() {
+		t.Errorf("signatures %q and %q differ", login.Signature(), other.Signature())
+	}
+	for _, f := range []Form{
+		{Method: "GET", Action: login.Action, Inputs: login.Inputs},
+		{Method: "POST", Action: "https://example.com/register", Inputs: login.Inputs},
+		{Method: "POST", Action: login.Action, Inputs: []FormInput{{Name: "user"}, {Name: "pass"}, {Name: "otp"}}},
+	} {
+		if f.Signature() == login.Signature() {
+			t.Errorf("form %+v has the login signature", f)
+		}
+	}
+}
+
+func TestCrawlFormsDedup(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		// The same login form on every page, two upload forms on /support
+		fmt.Fprintf(w, `<html><a href="/a">a</a><a href="/b">b</a><a href="/support">support</a>
+<form method="post" action="/login?next=%s"><input name="user"><input type="hidden" name="csrf" value="%s"></form>`, r.URL.Path, r.URL.Path)
+		if r.URL.Path == "/support" {
+			fmt.Fprint(w, `<form method="post" action="/tickets" enctype="multipart/form-data"><input name="subject"><input type="file" name="attachment"></form>
+<form method="post" action="/avatar" enctype="multipart/form-data"><input type="file" name="avatar"></form>
+<input type="file" id="dropzone">`)
+		}
+	}))
+	defer ts.Close()
+
+	var findings []string
+	for _, r := range crawlForTest(t, ts.URL+"/", func(opts *Options) { opts.Depth = 2 }) {
+		if r.OutputType == "form" || r.OutputType == "upload-form" {
+			findings = append(findings, r.OutputType+" "+strings.TrimPrefix(r.Output, ts.URL))
+		}
+	}
+
+	sort.Strings(findings)
+	want := "[form /avatar form /login?next=/ form /tickets upload-form /avatar upload-form /support upload-form /tickets]"
+	if fmt.Sprint(findings) != want {
+		t.Errorf("findings = %v, want %s", findings, want)
+	}
+}
@@ -0,0 +1,300 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Largest .git/index downloaded
+	maxGitIndexSize = 10 * 1024 * 1024
+	// Loose objects downloaded per repository when there is no index
+	maxGitObjects = 500
+	// Largest loose object downloaded
+	maxGitObjectSize = 1024 * 1024
+)
+
+var (
+	gitHeadRegex = regexp.MustCompile(`^(?:ref: (refs/[^\s]+)|([0-9a-f]{40}))\s*$`)
+	gitSHARegex  = regexp.MustCompile(`^[0-9a-f]{40}$`)
+)
+
+// GitFile is a file of a repository tree, SHA is the blob it points to
+type GitFile struct {
+	Path string
+	SHA  string
+	// Size of the file in the working tree, 0 when read from tree objects
+	Size uint32
+}
+
+// ParseGitIndex returns the files listed in a .git/index (versions 2, 3 and 4)
+func ParseGitIndex(data []byte) ([]GitFile, error) {
+	if len(data) < 12 || string(data[:4]) != "DIRC" {
+		return nil, errors.New("not a git index")
+	}
+	version := binary.BigEndian.Uint32(data[4:8])
+	if version < 2 || version > 4 {
+		return nil, fmt.Errorf("unsupported git index version %d", version)
+	}
+	count := binary.BigEndian.Uint32(data[8:12])
+
+	var files []GitFile
+	offset := 12
+	previous := ""
+	for i := uint32(0); i < count; i++ {
+		// ctime, mtime, dev, ino, mode, uid, gid, size, sha and flags
+		if offset+62 > len(data) {
+			return files, errors.New("truncated git index")
+		}
+		entry := data[offset:]
+		size := binary.BigEndian.Uint32(entry[36:40])
+		sha := hex.EncodeToString(entry[40:60])
+		flags := binary.BigEndian.Uint16(entry[60:62])
+		pos := 62
+		if version >= 3 && flags&0x4000 != 0 {
+			pos += 2
+		}
+
+		var name string
+		if version == 4 {
+			// Paths are compressed against the previous one: bytes to strip, then the suffix
+			strip, n := gitIndexVarint(entry[pos:])
+			if n == 0 || int(strip) > len(previous) {
+				return files, errors.New("invalid git index path")
+			}
+			pos += n
+			end := bytes.IndexByte(entry[pos:], 0)
+			if end < 0 {
+				return files, errors.New("truncated git index")
+			}
+			name = previous[:len(previous)-int(strip)] + string(entry[pos:pos+end])
+			pos += end + 1
+		} else {
+			end := bytes.IndexByte(entry[pos:], 0)
+			if end < 0 {
+				return files, errors.New("truncated git index")
+			}
+			name = string(entry[pos : pos+end])
+			// Entries are NUL padded to a multiple of 8 bytes
+			pos = (pos + end + 8) &^ 7
+		}
+		offset += pos
+		previous = name
+		files = append(files, GitFile{Path: name, SHA: sha, Size: size})
+	}
+	return files, nil
+}
+
+// Offset encoded varint of index v4 path prefixes, n is 0 when data is truncated
+func gitIndexVarint(data []byte) (value uint64, n int) {
+	if len(data) == 0 {
+		return 0, 0
+	}
+	c := data[0]
+	value = uint64(c & 0x7f)
+	n = 1
+	for c&0x80 != 0 {
+		if n >= len(data) {
+			return 0, 0
+		}
+		c = data[n]
+		n++
+		value = ((value + 1) << 7) | uint64(c&0x7f)
+	}
+	return value, n
+}
+
+// ParseGitObject inflates a loose object and returns its type and content
+func ParseGitObject(data []byte) (string, []byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+	raw, err := ioutil.ReadAll(io.LimitReader(zr, maxGitObjectSize*4))
+	if err != nil {
+		return "", nil, err
+	}
+	end := bytes.IndexByte(raw, 0)
+	if end < 0 {
+		return "", nil, errors.New("invalid git object header")
+	}
+	header := strings.Fields(string(raw[:end]))
+	if len(header) != 2 {
+		return "", nil, errors.New("invalid git object header")
+	}
+	return header[0], raw[end+1:], nil
+}
+
+// Tree entry of a tree object, Dir for subtrees
+type gitTreeEntry struct {
+	Name string
+	SHA  string
+	Dir  bool
+}
+
+// Entries of a tree object: "<mode> <name>\0<20 bytes sha>" each
+func parseGitTree(content []byte) []gitTreeEntry {
+	var entries []gitTreeEntry
+	for len(content) > 0 {
+		space := bytes.IndexByte(content, ' ')
+		nul := bytes.IndexByte(content, 0)
+		if space < 0 || nul < space || nul+21 > len(content) {
+			break
+		}
+		mode := string(content[:space])
+		entries = append(entries, gitTreeEntry{
+			Name: string(content[space+1 : nul]),
+			SHA:  hex.EncodeToString(content[nul+1 : nul+21]),
+			Dir:  mode == "40000" || mode == "040000",
+		})
+		content = content[nul+21:]
+	}
+	return entries
+}
+
+// Check a host once for an exposed .git folder and report the file paths of its
+// repository, from .git/index or else the loose objects of the HEAD commit
+func (crawler *Crawler) checkGit(u *url.URL) {
+	base := u.Scheme + "://" + u.Host
+	if crawler.gitSet.Duplicate(base) {
+		return
+	}
+	gitURL := base + "/.git/"
+	head, status, ok := crawler.probeBody("GET", gitURL+"HEAD", nil)
+	m := gitHeadRegex.FindStringSubmatch(strings.TrimSpace(head))
+	if !ok || status != 200 || m == nil {
+		return
+	}
+	crawler.reportMisconfig("git-exposed", gitURL, status)
+
+	files, err := crawler.gitIndexFiles(gitURL)
+	source := gitURL + "index"
+	if err != nil {
+		Logger.Debugf("No git index at %s: %s", gitURL, err)
+		commit := m[2]
+		if m[1] != "" {
+			commit = crawler.gitRef(gitURL, m[1])
+		}
+		if commit == "" {
+			return
+		}
+		files = crawler.gitTreeFiles(gitURL, commit)
+		source = gitURL + "objects/" + commit[:2] + "/" + commit[2:]
+	}
+
+	for _, file := range files {
+		outputFormat := fmt.Sprintf("[git-file] - [from: %s] - %s", gitURL, file.Path)
+		record := SpiderOutput{
+			Source:     source,
+			OutputType: "git-file",
+			Output:     file.Path,
+			Details:    map[string]string{"sha": file.SHA},
+		}
+		if file.Size > 0 {
+			record.Details["size"] = strconv.FormatUint(uint64(file.Size), 10)
+		}
+		crawler.Report(outputFormat, record)
+	}
+}
+
+// Download a file of the .git folder, at most max bytes
+func (crawler *Crawler) fetchGit(u string, max int64) ([]byte, error) {
+	req, err := crawler.newRequest("GET", u)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := crawler.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, max))
+}
+
+func (crawler *Crawler) gitIndexFiles(gitURL string) ([]GitFile, error) {
+	data, err := crawler.fetchGit(gitURL+"index", maxGitIndexSize)
+	if err != nil {
+		return nil, err
+	}
+	return ParseGitIndex(data)
+}
+
+// Commit a ref points to, from its loose file or packed-refs
+func (crawler *Crawler) gitRef(gitURL, ref string) string {
+	if data, err := crawler.fetchGit(gitURL+ref, 128); err == nil {
+		if sha := strings.TrimSpace(string(data)); gitSHARegex.MatchString(sha) {
+			return sha
+		}
+	}
+	data, err := crawler.fetchGit(gitURL+"packed-refs", 1024*1024)
+	if err != nil {
+		return ""
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref && gitSHARegex.MatchString(fields[0]) {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// Walk the tree of a commit through loose objects, at most maxGitObjects are downloaded
+func (crawler *Crawler) gitTreeFiles(gitURL, commit string) []GitFile {
+	fetched := 0
+	object := func(sha string) (string, []byte) {
+		if fetched >= maxGitObjects {
+			return "", nil
+		}
+		fetched++
+		data, err := crawler.fetchGit(gitURL+"objects/"+sha[:2]+"/"+sha[2:], maxGitObjectSize)
+		if err != nil {
+			return "", nil
+		}
+		kind, content, err := ParseGitObject(data)
+		if err != nil {
+			return "", nil
+		}
+		return kind, content
+	}
+
+	kind, content := object(commit)
+	if kind != "commit" || !bytes.HasPrefix(content, []byte("tree ")) || len(content) < 45 {
+		return nil
+	}
+	var files []GitFile
+	var walk func(dir, sha string)
+	walk = func(dir, sha string) {
+		kind, content := object(sha)
+		if kind != "tree" {
+			return
+		}
+		for _, entry := range parseGitTree(content) {
+			name := path.Join(dir, entry.Name)
+			if entry.Dir {
+				walk(name, entry.SHA)
+				continue
+			}
+			files = append(files, GitFile{Path: name, SHA: entry.SHA})
+		}
+	}
+	walk("", string(content[5:45]))
+	return files
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Index v2 of files, with their sizes and a fake blob hash
+func gitIndex(paths ...string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("DIRC")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(2))
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(paths)))
+	for i, p := range paths {
+		entry := make([]byte, 62)
+		binary.BigEndian.PutUint32(entry[36:40], uint32(100+i))
+		sha := sha1.Sum([]byte(p))
+		copy(entry[40:60], sha[:])
+		binary.BigEndian.PutUint16(entry[60:62], uint16(len(p)))
+		entry = append(entry, p...)
+		entry = append(entry, make([]byte, 8-len(entry)%8)...)
+		buf.Write(entry)
+	}
+	return buf.Bytes()
+}
+
+// Loose object of kind, returned with its hash
+func gitObject(kind string, content []byte) (string, []byte) {
+	raw := append([]byte(fmt.Sprintf("%s %d\x00", kind, len(content))), content...)
+	sum := sha1.Sum(raw)
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	_, _ = zw.Write(raw)
+	_ = zw.Close()
+	return hex.EncodeToString(sum[:]), buf.Bytes()
+}
+
+func gitTreeEntryBytes(mode, name, sha string) []byte {
+	raw, _ := hex.DecodeString(sha)
+	return append([]byte(mode+" "+name+"\x00"), raw...)
+}
+
+func TestParseGitIndex(t *testing.T) {
+	files, err := ParseGitIndex(gitIndex("README.md", "src/app/config.php", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	if !reflect.DeepEqual(paths, []string{"README.md", "src/app/config.php", "a"}) || files[1].Size != 101 {
+		t.Errorf("ParseGitIndex() = %+v", files)
+	}
+	if _, err := ParseGitIndex([]byte("<html>")); err == nil {
+		t.Error("HTML parsed as a git index")
+	}
+}
+
+func TestCrawlerGitTree(t *testing.T) {
+	// Repository of .env and src/main.go, without index
+	blobSHA, blob := gitObject("blob", []byte("secret"))
+	subSHA, sub := gitObject("tree", gitTreeEntryBytes("100644", "main.go", blobSHA))
+	rootSHA, root := gitObject("tree", append(gitTreeEntryBytes("100644", ".env", blobSHA), gitTreeEntryBytes("40000", "src", subSHA)...))
+	commitSHA, commit := gitObject("commit", []byte("tree "+rootSHA+"\nauthor a <a@example.com> 0 +0000\n\ninit\n"))
+	objects := map[string][]byte{blobSHA: blob, subSHA: sub, rootSHA: root, commitSHA: commit}
+
+	withIndex := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch p := r.URL.Path; {
+		case p == "/.git/HEAD":
+			fmt.Fprint(w, "ref: refs/heads/main\n")
+		case p == "/.git/refs/heads/main":
+			fmt.Fprint(w, commitSHA+"\n")
+		case p == "/.git/index" && withIndex:
+			_, _ = w.Write(gitIndex("index/only.txt"))
+		case len(p) == len("/.git/objects/")+41 && objects[p[14:16]+p[17:]] != nil:
+			_, _ = w.Write(objects[p[14:16]+p[17:]])
+		case p == "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html>home</html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	crawl := func() []string {
+		var files []string
+		for _, r := range findingsOf(crawlForTest(t, ts.URL, func(opts *Options) { opts.GitTree = true }), "git-file") {
+			files = append(files, r.Output)
+		}
+		sort.Strings(files)
+		return files
+	}
+
+	if files := crawl(); !reflect.DeepEqual(files, []string{"index/only.txt"}) {
+		t.Errorf("files from the index = %v", files)
+	}
+	withIndex = false
+	if files := crawl(); !reflect.DeepEqual(files, []string{".env", "src/main.go"}) {
+		t.Errorf("files from the objects = %v", files)
+	}
+}
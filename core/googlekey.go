@@ -0,0 +1,87 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Harmless requests telling which Google APIs a key is allowed to call.
+// Image APIs answer an image when allowed, the others a JSON status.
+var googleAPIChecks = []struct {
+	Name  string
+	URL   string
+	Image bool
+}{
+	{"static-maps", "https://maps.googleapis.com/maps/api/staticmap?center=45,10&zoom=7&size=400x400&key=", true},
+	{"street-view", "https://maps.googleapis.com/maps/api/streetview?size=400x400&location=40.720032,-73.988354&key=", true},
+	{"geocode", "https://maps.googleapis.com/maps/api/geocode/json?latlng=40,30&key=", false},
+	{"directions", "https://maps.googleapis.com/maps/api/directions/json?origin=Disneyland&destination=Universal+Studios+Hollywood&key=", false},
+	{"distance-matrix", "https://maps.googleapis.com/maps/api/distancematrix/json?origins=40.6655101,-73.8918897&destinations=40.6905615,-73.9976592&key=", false},
+	{"find-place", "https://maps.googleapis.com/maps/api/place/findplacefromtext/json?input=Museum&inputtype=textquery&fields=name&key=", false},
+	{"autocomplete", "https://maps.googleapis.com/maps/api/place/autocomplete/json?input=Bingh&types=(cities)&key=", false},
+	{"elevation", "https://maps.googleapis.com/maps/api/elevation/json?locations=39.7391536,-104.9847034&key=", false},
+	{"timezone", "https://maps.googleapis.com/maps/api/timezone/json?location=39.6034810,-119.6822510&timestamp=1331161200&key=", false},
+	{"roads", "https://roads.googleapis.com/v1/nearestRoads?points=60.170880,24.942795&key=", false},
+}
+
+// GoogleKeyAllowed tells from the response of a check request whether the key may call the API
+func GoogleKeyAllowed(status int, contentType string, body []byte, image bool) bool {
+	if status != 200 {
+		return false
+	}
+	if image {
+		return strings.HasPrefix(contentType, "image/")
+	}
+	var result struct {
+		Status string          `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return false
+	}
+	return result.Status != "REQUEST_DENIED" && result.Status != "OVER_QUERY_LIMIT" && len(result.Error) == 0
+}
+
+// Report the Google APIs a leaked key can call
+func (crawler *Crawler) verifyGoogleKey(source, key string) {
+	if crawler.googleKeySet.Duplicate(key) {
+		return
+	}
+
+	var apis []string
+	for _, check := range googleAPIChecks {
+		// Not built with newRequest, the site cookies and headers mustn't be sent to Google
+		req, err := http.NewRequest("GET", check.URL+url.QueryEscape(key), nil)
+		if err != nil {
+			continue
+		}
+		resp, err := crawler.client.Do(req)
+		if err != nil {
+			Logger.Debugf("Failed to verify Google API key on %s: %s", check.Name, err)
+			continue
+		}
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		if GoogleKeyAllowed(resp.StatusCode, resp.Header.Get("Content-Type"), body, check.Image) {
+			apis = append(apis, check.Name)
+		}
+	}
+	if len(apis) == 0 {
+		return
+	}
+
+	outputFormat := fmt.Sprintf("[google-api-key] - [%s] - [%s] - %s", strings.Join(apis, ", "), source, key)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     source,
+		OutputType: "google-api-key",
+		Output:     key,
+		Details: map[string]string{
+			"apis": strings.Join(apis, ","),
+		},
+	})
+}
@@ -0,0 +1,25 @@
+package core
+
+import "testing"
+
+func TestGoogleKeyAllowed(t *testing.T) {
+	tests := []struct {
+		status      int
+		contentType string
+		body        string
+		image       bool
+		want        bool
+	}{
+		{200, "image/png", "", true, true},
+		{403, "text/plain", "The Google Maps Platform server rejected your request.", true, false},
+		{200, "application/json", `{"results":[],"status":"ZERO_RESULTS"}`, false, true},
+		{200, "application/json", `{"error_message":"This API project is not authorized","status":"REQUEST_DENIED"}`, false, false},
+		{403, "application/json", `{"error":{"code":403,"status":"PERMISSION_DENIED"}}`, false, false},
+		{200, "text/html", "<html></html>", false, false},
+	}
+	for _, test := range tests {
+		if got := GoogleKeyAllowed(test.status, test.contentType, []byte(test.body), test.image); got != test.want {
+			t.Errorf("GoogleKeyAllowed(%d, %s, %s) = %v, want %v", test.status, test.contentType, test.body, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// GraphQLOperation is a query/mutation/subscription found in a JS source
+type GraphQLOperation struct {
+	Type   string
+	Name   string
+	Fields []string // Top level fields of the selection set
+}
+
+var (
+	graphqlOpRegex = regexp.MustCompile(`\b(query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)\s*(?:\([^)]*\))?\s*(?:@[_A-Za-z][_0-9A-Za-z]*\s*)*\{`)
+	// Persisted query manifests only keep the operation name next to the hash
+	graphqlOpNameRegex = regexp.MustCompile(`["']?operationName["']?\s*:\s*["']([_A-Za-z][_0-9A-Za-z]*)["']`)
+)
+
+// FindGraphQLOperations extracts GraphQL operations embedded in JS
+// (gql tagged templates, query strings, persisted query maps)
+func FindGraphQLOperations(source string) []GraphQLOperation {
+	// Query strings are often minified into one line with escaped new lines
+	source = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`).Replace(source)
+
+	var ops []GraphQLOperation
+	// Operation names already found, to skip their persisted query entries
+	seen := make(map[string]bool)
+	for _, loc := range graphqlOpRegex.FindAllStringSubmatchIndex(source, -1) {
+		op := GraphQLOperation{
+			Type:   source[loc[2]:loc[3]],
+			Name:   source[loc[4]:loc[5]],
+			Fields: selectionFields(source[loc[1]:]),
+		}
+		if !seen[op.Name] {
+			seen[op.Name] = true
+			ops = append(ops, op)
+		}
+	}
+
+	for _, match := range graphqlOpNameRegex.FindAllStringSubmatch(source, -1) {
+		if seen[match[1]] {
+			continue
+		}
+		seen[match[1]] = true
+		ops = append(ops, GraphQLOperation{Name: match[1]})
+	}
+	return ops
+}
+
+// Top level field names of a selection set, s starts right after its opening brace.
+// Aliases are resolved to the field name, arguments and fragment spreads are skipped.
+func selectionFields(s string) []string {
+	var fields []string
+	depth, parens := 1, 0
+	for i := 0; i < len(s) && depth > 0; i++ {
+		ch := s[i]
+		switch {
+		case ch == '(':
+			parens++
+		case ch == ')':
+			if parens > 0 {
+				parens--
+			}
+		case parens > 0:
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+		case ch == '@':
+			// Skip directive names, their arguments are skipped as any others
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			i = j - 1
+		case ch == '.' && strings.HasPrefix(s[i:], "..."):
+			// Skip the fragment name, or the type condition of an inline fragment
+			j := skipSpace(s, i+3)
+			if strings.HasPrefix(s[j:], "on") && j+2 < len(s) && !isNameChar(s[j+2]) {
+				j = skipSpace(s, j+2)
+			}
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			i = j - 1
+		case depth == 1 && isNameChar(ch) && (ch < '0' || ch > '9'):
+			start := i
+			for i < len(s) && isNameChar(s[i]) {
+				i++
+			}
+			name := s[start:i]
+			// "alias: field", keep the field
+			if j := skipSpace(s, i); j < len(s) && s[j] == ':' {
+				i = j
+				continue
+			}
+			fields = append(fields, name)
+			i--
+		}
+	}
+	return Unique(fields)
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\r' || s[i] == '\n' || s[i] == ',') {
+		i++
+	}
+	return i
+}
+
+func isNameChar(ch byte) bool {
+	return ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9')
+}
+
+// Report GraphQL operations defined in a JS file
+func (crawler *Crawler) findGraphQLOperations(source, resp string) {
+	for _, op := range FindGraphQLOperations(resp) {
+		if crawler.graphqlSet.Duplicate(op.Type + " " + op.Name) {
+			continue
+		}
+		opType := op.Type
+		if opType == "" {
+			opType = "persisted"
+		}
+		outputFormat := fmt.Sprintf("[graphql-op] - [from: %s] - [%s] - %s", source, opType, op.Name)
+		if len(op.Fields) > 0 {
+			outputFormat += fmt.Sprintf(" { %s }", strings.Join(op.Fields, ", "))
+		}
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     source,
+			OutputType: "graphql-op",
+			Output:     op.Name,
+			Details: map[string]string{
+				"operation": opType,
+				"fields":    strings.Join(op.Fields, ","),
+			},
+		})
+	}
+}
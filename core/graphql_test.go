@@ -0,0 +1,23 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindGraphQLOperations(t *testing.T) {
+	js := "const Q=gql`\n  query GetUser($id: ID!) {\n    me: user(id: $id) { id name }\n    viewer @include(if: true) { id }\n    ...UserFields\n  }\n`;" +
+		`var m={q:"mutation DeleteUser($id:ID!){deleteUser(id:$id){ok}}"};` +
+		`var p={"a1b2":{operationName:"ListOrders"},"c3":{"operationName":"GetUser"}};` +
+		`if (query && query.length) { run() }`
+
+	want := []GraphQLOperation{
+		{Type: "query", Name: "GetUser", Fields: []string{"user", "viewer"}},
+		{Type: "mutation", Name: "DeleteUser", Fields: []string{"deleteUser"}},
+		{Name: "ListOrders"},
+	}
+	got := FindGraphQLOperations(js)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindGraphQLOperations() = %+v, want %+v", got, want)
+	}
+}
@@ -1,35 +1,83 @@
 package core
 
 import (
+	"html"
 	"regexp"
 	"strings"
 )
 
+const (
+	// Sources larger than this are scanned in chunks, so the copies made while decoding them stay small
+	scanChunkSize = 1000000
+	// Chunks end at the last line or statement end of their last bytes, not to cut a string or a host
+	scanChunkTail = 4096
+)
+
 const SUBRE = `(?i)(([a-zA-Z0-9]{1}|[_a-zA-Z0-9]{1}[_a-zA-Z0-9-]{0,61}[a-zA-Z0-9]{1})[.]{1})+`
 
+var titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
 var AWSS3 = regexp.MustCompile(`(?i)[a-z0-9.-]+\.s3\.amazonaws\.com|[a-z0-9.-]+\.s3-[a-z0-9-]\.amazonaws\.com|[a-z0-9.-]+\.s3-website[.-](eu|ap|us|ca|sa|cn)|//s3\.amazonaws\.com/[a-z0-9._-]+|//s3-[a-z0-9-]+\.amazonaws\.com/[a-z0-9._-]+`)
 
 // SubdomainRegex returns a Regexp object initialized to match
 // subdomain names that end with the domain provided by the parameter.
 func subdomainRegex(domain string) *regexp.Regexp {
-	// Change all the periods into literal periods for the regex
-	d := strings.Replace(domain, ".", "[.]", -1)
-	return regexp.MustCompile(SUBRE + d)
+	// Hosts may contain regex metacharacters, quote the whole domain
+	return regexp.MustCompile(SUBRE + regexp.QuoteMeta(domain))
 }
 
 func GetSubdomains(source, domain string) []string {
 	var subs []string
 	re := subdomainRegex(domain)
-	for _, match := range re.FindAllStringSubmatch(source, -1) {
-		subs = append(subs, CleanSubdomain(match[0]))
+	// Pages of internationalized domains write their hosts in Unicode as often as in punycode
+	var unicodeRe *regexp.Regexp
+	if unicode := unicodeHost(domain); unicode != domain {
+		unicodeRe = regexp.MustCompile(unicodeSUBRE + regexp.QuoteMeta(unicode))
 	}
+	scanChunks(source, func(chunk string) {
+		for _, match := range re.FindAllStringSubmatch(chunk, -1) {
+			subs = append(subs, CleanSubdomain(match[0]))
+		}
+		if unicodeRe != nil {
+			for _, match := range unicodeRe.FindAllStringSubmatch(chunk, -1) {
+				subs = append(subs, asciiHost(CleanSubdomain(match[0])))
+			}
+		}
+	})
 	return subs
 }
 
 func GetAWSS3(source string) []string {
 	var aws []string
-	for _, match := range AWSS3.FindAllStringSubmatch(source, -1) {
-		aws = append(aws, DecodeChars(match[0]))
-	}
+	scanChunks(source, func(chunk string) {
+		for _, match := range AWSS3.FindAllStringSubmatch(chunk, -1) {
+			aws = append(aws, DecodeChars(match[0]))
+		}
+	})
 	return aws
 }
+
+// scanChunks calls scan with consecutive chunks of source of about scanChunkSize bytes, the
+// whole source when it's smaller. Chunks share the memory of source, each ends after the last
+// newline or ';' of its last scanChunkTail bytes when there is one.
+func scanChunks(source string, scan func(chunk string)) {
+	for len(source) > scanChunkSize {
+		end := scanChunkSize
+		tail := source[end-scanChunkTail : end]
+		if i := strings.LastIndexAny(tail, "\n;"); i >= 0 {
+			end = end - scanChunkTail + i + 1
+		}
+		scan(source[:end])
+		source = source[end:]
+	}
+	scan(source)
+}
+
+// GetTitle returns the HTML title of source, or empty string if there is none
+func GetTitle(source string) string {
+	match := titleRegex.FindStringSubmatch(source)
+	if match == nil {
+		return ""
+	}
+	return FilterNewLines(html.UnescapeString(match[1]))
+}
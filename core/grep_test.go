@@ -0,0 +1,54 @@
+package core
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGetTitle(t *testing.T) {
+	title := GetTitle("<html><head><TITLE>\n  Swagger UI &amp; Docs\n</TITLE></head></html>")
+	if title != "Swagger UI & Docs" {
+		t.Errorf("GetTitle() = %q", title)
+	}
+	if GetTitle("var a = 1;") != "" {
+		t.Error("GetTitle() should be empty without title")
+	}
+}
+
+func TestScanChunks(t *testing.T) {
+	var b strings.Builder
+	for b.Len() < 3*scanChunkSize {
+		b.WriteString(`var a=fetch("/api/v1/items");var s="cdn.example.com";` + strings.Repeat("x", 100) + "\n")
+	}
+	b.WriteString(`fetch("/api/v2/last");load("assets.example.com")`)
+	source := b.String()
+
+	var total int
+	chunks := 0
+	scanChunks(source, func(chunk string) {
+		chunks++
+		total += len(chunk)
+		if len(chunk) > scanChunkSize {
+			t.Errorf("chunk of %d bytes", len(chunk))
+		}
+		if total < len(source) && !strings.HasSuffix(chunk, ";") && !strings.HasSuffix(chunk, "\n") {
+			t.Errorf("chunk cut inside a statement: %q", chunk[len(chunk)-40:])
+		}
+	})
+	if chunks < 3 || total != len(source) {
+		t.Errorf("%d chunks of %d bytes for %d", chunks, total, len(source))
+	}
+
+	paths, _ := LinkFinder(source)
+	sort.Strings(paths)
+	if want := []string{"/api/v1/items", "/api/v2/last"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("LinkFinder() = %v, want %v", paths, want)
+	}
+	subs := Unique(GetSubdomains(source, "example.com"))
+	sort.Strings(subs)
+	if want := []string{"assets.example.com", "cdn.example.com"}; !reflect.DeepEqual(subs, want) {
+		t.Errorf("GetSubdomains() = %v, want %v", subs, want)
+	}
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Subdomain labels of internationalized domains, letters and digits of any script
+const unicodeSUBRE = `(?i)(([\p{L}\p{N}]{1}|[_\p{L}\p{N}]{1}[_\p{L}\p{N}-]{0,61}[\p{L}\p{N}]{1})[.]{1})+`
+
+// Hosts are requested, scoped and reported in punycode, the form DNS and the Host header use
+func asciiHost(host string) string {
+	if isASCII(host) {
+		return host
+	}
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, ""
+	}
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		// Links may hold hosts no registry would accept, keep the lenient conversion
+		if ascii, err = idna.Punycode.ToASCII(hostname); err != nil {
+			return host
+		}
+	}
+	if port != "" {
+		return net.JoinHostPort(ascii, port)
+	}
+	return ascii
+}
+
+// Unicode form of a punycode host, for display
+func unicodeHost(host string) string {
+	if !isIDN(host) {
+		return host
+	}
+	unicode, err := idna.Display.ToUnicode(host)
+	if err != nil {
+		return host
+	}
+	return unicode
+}
+
+// Reports whether host has a punycode label
+func isIDN(host string) bool {
+	host = strings.ToLower(host)
+	return strings.HasPrefix(host, "xn--") || strings.Contains(host, ".xn--")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// Copy of u with a punycode host, u itself when it's already ASCII
+func asciiURL(u *url.URL) *url.URL {
+	host := asciiHost(u.Host)
+	if host == u.Host {
+		return u
+	}
+	ascii := *u
+	ascii.Host = host
+	return &ascii
+}
+
+// Unicode form of a URL or host found by the crawl, or "" when it has no punycode host
+func unicodeOutput(output string) string {
+	if !strings.Contains(strings.ToLower(output), "xn--") {
+		return ""
+	}
+	if u, err := url.Parse(output); err == nil && u.Host != "" {
+		if host := unicodeHost(u.Hostname()); host != u.Hostname() {
+			// Rebuild from the string, url.URL.String() would escape the Unicode host
+			return strings.Replace(output, u.Hostname(), host, 1)
+		}
+		return ""
+	}
+	if strings.ContainsAny(output, "/ \t") {
+		return ""
+	}
+	if host := unicodeHost(output); host != output {
+		return host
+	}
+	return ""
+}
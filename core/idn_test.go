@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestASCIIHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"example.com":        "example.com",
+		"bücher.de":          "xn--bcher-kva.de",
+		"shop.bücher.de:443": "shop.xn--bcher-kva.de:443",
+		"例え.jp":              "xn--r8jz45g.jp",
+		"127.0.0.1:8080":     "127.0.0.1:8080",
+	} {
+		if got := asciiHost(host); got != want {
+			t.Errorf("asciiHost(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestUnicodeOutput(t *testing.T) {
+	for output, want := range map[string]string{
+		"https://xn--bcher-kva.de/b%C3%BCcher?q=1": "https://bücher.de/b%C3%BCcher?q=1",
+		"shop.xn--bcher-kva.de":                    "shop.bücher.de",
+		"https://example.com/xn--not-a-host":       "",
+		"token xn--bcher-kva":                      "",
+		"https://example.com/":                     "",
+	} {
+		if got := unicodeOutput(output); got != want {
+			t.Errorf("unicodeOutput(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestGetSubdomainsIDN(t *testing.T) {
+	subs := GetSubdomains(`"https://shop.bücher.de/" "api.xn--bcher-kva.de"`, "xn--bcher-kva.de")
+	want := map[string]bool{"api.xn--bcher-kva.de": true, "shop.xn--bcher-kva.de": true}
+	if len(subs) != len(want) {
+		t.Fatalf("GetSubdomains() = %v", subs)
+	}
+	for _, sub := range subs {
+		if !want[sub] {
+			t.Errorf("unexpected subdomain %q", sub)
+		}
+	}
+}
+
+func TestScopeIDN(t *testing.T) {
+	site, _ := url.Parse("https://bücher.de/")
+	scope, err := NewScope(asciiURL(site), DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for raw, want := range map[string]bool{
+		"https://shop.bücher.de/":             true,
+		"https://xn--bcher-kva.de/katalog":    true,
+		"https://buecher.de/":                 false,
+		"https://shop.xn--bcher-kva.de.evil/": false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := scope.InScope(u); got != want {
+			t.Errorf("InScope(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestValidateSiteIDN(t *testing.T) {
+	if err := ValidateSite("https://bücher.de/"); err != nil {
+		t.Errorf("ValidateSite() = %v", err)
+	}
+	if err := ValidateSite("https://-bücher.de/"); err == nil {
+		t.Error("ValidateSite() accepted an invalid label")
+	}
+}
+
+func TestCrawlerIDN(t *testing.T) {
+	var mu sync.Mutex
+	var hosts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+		_, port, _ := net.SplitHostPort(r.Host)
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><a href="http://bücher.test:%s/katalog">Katalog</a> shop.bücher.test</html>`, port)
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	server := fakeDNSServer(t, map[string]net.IP{"xn--bcher-kva.test": net.IPv4(127, 0, 0, 1)})
+
+	found := findingsByKey(crawlForTest(t, "http://bücher.test:"+port+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.Resolvers = server
+	}))
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, host := range hosts {
+		if host != "xn--bcher-kva.test:"+port {
+			t.Errorf("request sent with Host %q", host)
+		}
+	}
+	link := found["url http://xn--bcher-kva.test:"+port+"/katalog"]
+	if link.Details["unicode"] != "http://bücher.test:"+port+"/katalog" {
+		t.Errorf("link reported as %+v, found %v", link, found)
+	}
+	if sub, ok := found["subdomains shop.xn--bcher-kva.test"]; !ok || sub.Details["unicode"] != "shop.bücher.test" {
+		t.Errorf("subdomain reported as %+v, found %v", sub, found)
+	}
+}
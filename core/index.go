@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// IndexFile is written to the output folder with the result of every site of a run
+const IndexFile = "index.json"
+
+// Status of a site crawl in the index
+const (
+	SiteCompleted = "completed"
+	// SiteAborted crawls were stopped before the end (interrupted, out of budget, failed)
+	SiteAborted = "aborted"
+	// SiteDead sites never answered a request
+	SiteDead = "dead"
+)
+
+// SiteResult is how the crawl of a site went
+type SiteResult struct {
+	Site   string `json:"site"`
+	Status string `json:"status"`
+	// Output is the findings file of the site, relative to the output folder
+	Output    string     `json:"output,omitempty"`
+	Requests  int        `json:"requests"`
+	Responses int        `json:"responses"`
+	Findings  int        `json:"findings"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+	// Reason the crawl was stopped or error it failed with
+	Reason string `json:"reason,omitempty"`
+}
+
+// Result returns how the crawl went, it's final once Run returned
+func (crawler *Crawler) Result() SiteResult {
+	start, elapsed, reason := crawler.budget.summary()
+	stats := crawler.stats.Snapshot()
+	result := SiteResult{
+		Site:      crawler.site.String(),
+		Status:    SiteCompleted,
+		Requests:  stats.Requests,
+		Responses: stats.Answered(),
+		Findings:  stats.TotalFindings(),
+		StartTime: &start,
+		Duration:  elapsed.String(),
+		Reason:    reason,
+	}
+	if crawler.opts.OutputFolder != "" {
+		result.Output = crawler.outputName + CompressExt(crawler.opts.Compress)
+	}
+	switch {
+	case result.Responses == 0:
+		result.Status = SiteDead
+	case reason != "":
+		result.Status = SiteAborted
+	}
+	return result
+}
+
+// RunIndex maps the sites of a run to their output and result, in site list order.
+// Sites are added as they finish so it also shows the progress of a run.
+type RunIndex struct {
+	mu     sync.Mutex
+	folder string
+	order  map[string]int
+	Sites  []SiteResult `json:"sites"`
+}
+
+func newRunIndex(folder string, sites []string) *RunIndex {
+	order := make(map[string]int)
+	for i, site := range sites {
+		if _, ok := order[site]; !ok {
+			order[site] = i
+		}
+	}
+	return &RunIndex{folder: folder, order: order, Sites: []SiteResult{}}
+}
+
+// Add the result of a site and rewrite the index file
+func (idx *RunIndex) add(input string, result SiteResult) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	result.Site = input
+	i := len(idx.Sites)
+	for i > 0 && idx.order[idx.Sites[i-1].Site] > idx.order[input] {
+		i--
+	}
+	idx.Sites = append(idx.Sites, SiteResult{})
+	copy(idx.Sites[i+1:], idx.Sites[i:])
+	idx.Sites[i] = result
+
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		Logger.Errorf("Failed to write run index: %s", err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(idx.folder, IndexFile), append(data, '\n'), 0644); err != nil {
+		Logger.Errorf("Failed to write run index: %s", err)
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCrawlSitesIndex(t *testing.T) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><a href="/about">about</a></html>`)
+	}))
+	defer live.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	folder, err := ioutil.TempDir("", "gospider-index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.OutputFolder = folder
+	sites := []string{dead.URL, "http://%zz", live.URL}
+	if err := CrawlSites(sites, opts, 3); err == nil {
+		t.Error("CrawlSites() returned no error for invalid site")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(folder, IndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index RunIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Sites) != 3 {
+		t.Fatalf("index has %d sites, want 3: %s", len(index.Sites), data)
+	}
+	for i, want := range []struct {
+		status   string
+		requests int
+	}{{SiteDead, 1}, {SiteAborted, 0}, {SiteCompleted, 2}} {
+		r := index.Sites[i]
+		if r.Site != sites[i] || r.Status != want.status || r.Requests != want.requests {
+			t.Errorf("index entry %d = %+v, want %s with status %s and %d requests", i, r, sites[i], want.status, want.requests)
+		}
+	}
+
+	r := index.Sites[2]
+	if r.Responses != 2 || r.Findings != 2 || r.StartTime == nil || r.Duration == "" {
+		t.Errorf("unexpected stats %+v", r)
+	}
+	if _, err := os.Stat(filepath.Join(folder, r.Output)); r.Output == "" || err != nil {
+		t.Errorf("output %q of the live site not found: %v", r.Output, err)
+	}
+	if index.Sites[1].Reason == "" {
+		t.Error("failure reason not recorded")
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Content hashes in JavaScript file names (Ex: main.3f2a1b9c.js, app-5d2f8a1e0b.min.js)
+var jsNameHashRe = regexp.MustCompile(`(?i)([.-])[0-9a-f]{8,}([.-])`)
+
+// JSFileKey identifies a JavaScript file across deployments: its URL without query (cache
+// busters) and with the content hash of its name replaced (Ex: https://example.com/static/main.{hash}.js)
+func JSFileKey(u *url.URL) string {
+	path := u.EscapedPath()
+	i := strings.LastIndex(path, "/") + 1
+	name := jsNameHashRe.ReplaceAllStringFunc(path[i:], func(s string) string {
+		if !strings.ContainsAny(s, "0123456789") {
+			return s
+		}
+		return s[:1] + "{hash}" + s[len(s)-1:]
+	})
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + path[:i] + name
+}
+
+// Identity of a secret in a fingerprint, the value itself isn't written
+func secretFingerprint(s SecretMatch) string {
+	sum := sha256.Sum256([]byte(s.Match))
+	return s.Rule + ":" + hex.EncodeToString(sum[:6])
+}
+
+// Lines of a fingerprint detail
+func detailList(details map[string]string, name string) []string {
+	if details[name] == "" {
+		return nil
+	}
+	return strings.Split(details[name], "\n")
+}
+
+// Values of list not in other
+func missingFrom(list, other []string) []string {
+	known := make(map[string]bool, len(other))
+	for _, v := range other {
+		known[v] = true
+	}
+	var missing []string
+	for _, v := range list {
+		if !known[v] {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+func sortedUnique(list []string) []string {
+	seen := make(map[string]bool, len(list))
+	var unique []string
+	for _, v := range list {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// Report the SHA-256, endpoints and secrets of a JavaScript file with --js-fingerprints.
+// With --diff, a file whose hash changed since the previous run is also reported as
+// js-changed with the endpoints and secrets it didn't have.
+func (crawler *Crawler) fingerprintJS(response *colly.Response, paths []string) {
+	var contentType string
+	if response.Headers != nil {
+		contentType = response.Headers.Get("Content-Type")
+	}
+	fileURL := response.Request.URL.String()
+	if LinkFinderContentType(contentType, fileURL) != LinkFinderJS {
+		return
+	}
+	sum := sha256.Sum256(response.Body)
+	hash := hex.EncodeToString(sum[:])
+	endpoints := sortedUnique(paths)
+	var secrets []string
+	for _, s := range FindSecrets(string(response.Body), crawler.secretRules) {
+		secrets = append(secrets, secretFingerprint(s))
+	}
+	secrets = sortedUnique(secrets)
+
+	outputFormat := fmt.Sprintf("[js-fingerprint] - [sha256:%s] - [endpoints-%d] - [secrets-%d] - %s", hash[:12], len(endpoints), len(secrets), fileURL)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     fileURL,
+		OutputType: "js-fingerprint",
+		Output:     fileURL,
+		Length:     len(response.Body),
+		Details: map[string]string{
+			"sha256":    hash,
+			"endpoints": strings.Join(endpoints, "\n"),
+			"secrets":   strings.Join(secrets, "\n"),
+		},
+	})
+
+	if crawler.diff == nil {
+		return
+	}
+	previous, ok := crawler.diff.fingerprint(JSFileKey(response.Request.URL))
+	if !ok || previous.Details["sha256"] == hash {
+		return
+	}
+	previousEndpoints := detailList(previous.Details, "endpoints")
+	newEndpoints := missingFrom(endpoints, previousEndpoints)
+	removedEndpoints := missingFrom(previousEndpoints, endpoints)
+	var newSecrets []string
+	for _, s := range missingFrom(secrets, detailList(previous.Details, "secrets")) {
+		newSecrets = append(newSecrets, strings.SplitN(s, ":", 2)[0])
+	}
+
+	outputFormat = fmt.Sprintf("[js-changed] - %s", fileURL)
+	if len(newEndpoints) > 0 {
+		outputFormat += fmt.Sprintf(" - [new endpoints: %s]", strings.Join(newEndpoints, ", "))
+	}
+	if len(newSecrets) > 0 {
+		outputFormat += fmt.Sprintf(" - [new secrets: %s]", strings.Join(newSecrets, ", "))
+	}
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     previous.Output,
+		OutputType: "js-changed",
+		Output:     fileURL,
+		Details: map[string]string{
+			"sha256":            hash,
+			"previous_sha256":   previous.Details["sha256"],
+			"new_endpoints":     strings.Join(newEndpoints, "\n"),
+			"removed_endpoints": strings.Join(removedEndpoints, "\n"),
+			"new_secrets":       strings.Join(newSecrets, "\n"),
+		},
+	})
+}
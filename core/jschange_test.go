@@ -0,0 +1,86 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestJSFileKey(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://Example.com/static/main.3f2a1b9c.js?v=12": "https://example.com/static/main.{hash}.js",
+		"https://example.com/js/app-5d2f8a1e0b.min.js":     "https://example.com/js/app-{hash}.min.js",
+		"https://example.com/js/2.8c1d2e4f.chunk.js":       "https://example.com/js/2.{hash}.chunk.js",
+		"https://example.com/js/jquery.min.js#top":         "https://example.com/js/jquery.min.js",
+		"https://example.com/3f2a1b9c/deadbeefcafe/app.js": "https://example.com/3f2a1b9c/deadbeefcafe/app.js",
+		"https://example.com/js/facebook.js":               "https://example.com/js/facebook.js",
+	} {
+		u, _ := url.Parse(raw)
+		if got := JSFileKey(u); got != want {
+			t.Errorf("JSFileKey(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func TestCrawlerJSChanged(t *testing.T) {
+	var mu sync.Mutex
+	script, name := `fetch("/api/v1/items")`, "main.3f2a1b9c.js"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/" {
+			fmt.Fprintf(w, `<html><script src="/static/%s"></script></html>`, name)
+			return
+		}
+		w.Header().Set("Content-Type", "application/javascript")
+		fmt.Fprint(w, script)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gospider-jschange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	previous := filepath.Join(dir, "previous.jsonl")
+
+	crawl := func(diff string) map[string]SpiderOutput {
+		findings := make(map[string]SpiderOutput)
+		for _, r := range crawlForTest(t, ts.URL+"/", func(opts *Options) {
+			opts.JSFingerprints = true
+			opts.Diff = diff
+		}) {
+			findings[r.OutputType] = r
+		}
+		return findings
+	}
+
+	first := crawl("")
+	fingerprint, ok := first["js-fingerprint"]
+	if !ok || len(fingerprint.Details["sha256"]) != 64 || fingerprint.Details["endpoints"] != "/api/v1/items" {
+		t.Fatalf("fingerprint %+v", fingerprint)
+	}
+	line, _ := json.Marshal(fingerprint)
+	_ = ioutil.WriteFile(previous, append(line, '\n'), 0644)
+
+	// Same file, nothing changed
+	if unchanged := crawl(previous); unchanged["js-changed"].Output != "" || unchanged["js-fingerprint"].Output == "" {
+		t.Errorf("unchanged file: %+v", unchanged)
+	}
+
+	mu.Lock()
+	script, name = `fetch("/api/v1/items");fetch("/api/v2/admin")`, "main.8c1d2e4f.js"
+	mu.Unlock()
+	changed := crawl(previous)["js-changed"]
+	if changed.Output != ts.URL+"/static/main.8c1d2e4f.js" || changed.Details["new_endpoints"] != "/api/v2/admin" ||
+		changed.Details["previous_sha256"] != fingerprint.Details["sha256"] || changed.Details["removed_endpoints"] != "" {
+		t.Errorf("js-changed %+v", changed)
+	}
+}
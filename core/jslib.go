@@ -0,0 +1,215 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// JSLibrary identifies a JavaScript library by the URLs and the contents of its files,
+// each regex captures the version. Vulnerabilities are the known ones, by version range.
+type JSLibrary struct {
+	Name            string            `yaml:"name"`
+	URLs            []string          `yaml:"urls"`
+	Contents        []string          `yaml:"contents"`
+	Vulnerabilities []JSVulnerability `yaml:"vulnerabilities"`
+
+	urls     []*regexp.Regexp
+	contents []*regexp.Regexp
+}
+
+// JSVulnerability affects the versions in [AtOrAbove, Below), every version below Below
+// when AtOrAbove is empty
+type JSVulnerability struct {
+	AtOrAbove   string   `yaml:"atOrAbove"`
+	Below       string   `yaml:"below"`
+	Severity    string   `yaml:"severity"`
+	Identifiers []string `yaml:"identifiers"`
+	Summary     string   `yaml:"summary"`
+}
+
+// Severities of the vulnerabilities, the most severe last
+var jsSeverities = []string{"low", "medium", "high", "critical"}
+
+func (l *JSLibrary) compile() error {
+	if l.Name == "" || len(l.URLs)+len(l.Contents) == 0 {
+		return fmt.Errorf("library %q needs a name and a url or content regex", l.Name)
+	}
+	for _, list := range []struct {
+		raws []string
+		res  *[]*regexp.Regexp
+	}{{l.URLs, &l.urls}, {l.Contents, &l.contents}} {
+		for _, raw := range list.raws {
+			re, err := regexp.Compile(raw)
+			if err != nil {
+				return fmt.Errorf("invalid regex %q of library %s: %s", raw, l.Name, err)
+			}
+			if re.NumSubexp() != 1 {
+				return fmt.Errorf("regex %q of library %s must capture the version", raw, l.Name)
+			}
+			*list.res = append(*list.res, re)
+		}
+	}
+	for _, vuln := range l.Vulnerabilities {
+		if vuln.Below == "" || len(vuln.Identifiers) == 0 {
+			return fmt.Errorf("vulnerability of library %s needs a below version and identifiers", l.Name)
+		}
+		if severityRank(vuln.Severity) < 0 {
+			return fmt.Errorf("unknown severity %q of %s, use %s", vuln.Severity, strings.Join(vuln.Identifiers, ", "), strings.Join(jsSeverities, ", "))
+		}
+	}
+	return nil
+}
+
+func severityRank(severity string) int {
+	for i, s := range jsSeverities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// JSLibraryVersion is a library found in a JavaScript file
+type JSLibraryVersion struct {
+	Library *JSLibrary
+	Version string
+}
+
+// Vulnerabilities returns the known vulnerabilities of the version
+func (v JSLibraryVersion) Vulnerabilities() []JSVulnerability {
+	var vulns []JSVulnerability
+	for _, vuln := range v.Library.Vulnerabilities {
+		if CompareVersions(v.Version, vuln.Below) >= 0 {
+			continue
+		}
+		if vuln.AtOrAbove != "" && CompareVersions(v.Version, vuln.AtOrAbove) < 0 {
+			continue
+		}
+		vulns = append(vulns, vuln)
+	}
+	return vulns
+}
+
+// FindJSLibraries finds the libraries of a JavaScript file from its URL and, when it was
+// fetched, its content. The content has precedence over the URL.
+func (r *Rules) FindJSLibraries(fileURL, content string) []JSLibraryVersion {
+	var found []JSLibraryVersion
+	for i := range r.JSLibraries {
+		lib := &r.JSLibraries[i]
+		version := ""
+		for _, re := range lib.contents {
+			if content == "" {
+				break
+			}
+			if m := re.FindStringSubmatch(content); m != nil {
+				version = m[1]
+				break
+			}
+		}
+		if version == "" {
+			for _, re := range lib.urls {
+				if m := re.FindStringSubmatch(fileURL); m != nil {
+					version = m[1]
+					break
+				}
+			}
+		}
+		if version != "" {
+			found = append(found, JSLibraryVersion{Library: lib, Version: version})
+		}
+	}
+	return found
+}
+
+// CompareVersions compares dotted versions numerically, it returns -1, 0 or 1.
+// A pre-release (1.2.0-beta1) is lower than its release.
+func CompareVersions(a, b string) int {
+	aRelease, aPre := splitPreRelease(a)
+	bRelease, bPre := splitPreRelease(b)
+	aParts, bParts := strings.Split(aRelease, "."), strings.Split(bRelease, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	}
+	return 1
+}
+
+func splitPreRelease(version string) (string, string) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return version, ""
+}
+
+// Report the libraries of a JavaScript file with --js-libs, with their known vulnerabilities
+// with --js-lib-vulns
+func (crawler *Crawler) findJSLibraries(source, fileURL, content string) {
+	for _, found := range crawler.rules.FindJSLibraries(fileURL, content) {
+		name, version := found.Library.Name, found.Version
+		if crawler.jsLibSet.Duplicate(fileURL + " " + name + " " + version) {
+			continue
+		}
+		label := fmt.Sprintf("[%s %s]", name, version)
+		details := map[string]string{"library": name, "version": version}
+		if crawler.opts.JSLibVulns {
+			if vulns := found.Vulnerabilities(); len(vulns) > 0 {
+				var ids []string
+				severity := ""
+				for _, vuln := range vulns {
+					for _, id := range vuln.Identifiers {
+						if !containsString(ids, id) {
+							ids = append(ids, id)
+						}
+					}
+					if severityRank(vuln.Severity) > severityRank(severity) {
+						severity = vuln.Severity
+					}
+				}
+				label += fmt.Sprintf(" - [%s: %s]", severity, strings.Join(ids, ", "))
+				details["severity"] = severity
+				details["vulnerabilities"] = strings.Join(ids, ",")
+			}
+		}
+		outputFormat := fmt.Sprintf("[js-lib] - %s - %s", label, fileURL)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     source,
+			OutputType: "js-lib",
+			Output:     fileURL,
+			Rule:       name,
+			Details:    details,
+		})
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.12.4", "1.12.4", 0},
+		{"1.9.1", "1.12.0", -1},
+		{"3.5", "3.4.1", 1},
+		{"2.0", "2.0.0", 0},
+		{"v4.17.21", "4.17.21", 0},
+		{"3.0.0-beta1", "3.0.0", -1},
+		{"3.0.0-rc1", "3.0.0-beta1", 1},
+	} {
+		if got := CompareVersions(tc.a, tc.b); got != tc.want {
+			t.Errorf("CompareVersions(%s, %s) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestFindJSLibraries(t *testing.T) {
+	rules, err := LoadRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	libs := func(found []JSLibraryVersion) string {
+		var s []string
+		for _, f := range found {
+			s = append(s, f.Library.Name+" "+f.Version)
+		}
+		return fmt.Sprint(s)
+	}
+
+	if got := libs(rules.FindJSLibraries("https://code.jquery.com/jquery-1.12.4.min.js", "")); got != "[jquery 1.12.4]" {
+		t.Errorf("by URL: %s", got)
+	}
+	if got := libs(rules.FindJSLibraries("https://cdnjs.cloudflare.com/ajax/libs/lodash.js/4.17.4/lodash.min.js", "")); got != "[lodash 4.17.4]" {
+		t.Errorf("by CDN path: %s", got)
+	}
+	// The banner wins over a stale URL
+	found := rules.FindJSLibraries("https://example.com/js/jquery-1.8.0.js", "/*! jQuery v3.6.0 | (c) OpenJS Foundation */")
+	if got := libs(found); got != "[jquery 3.6.0]" {
+		t.Errorf("by banner: %s", got)
+	}
+	if vulns := found[0].Vulnerabilities(); len(vulns) != 0 {
+		t.Errorf("jquery 3.6.0 vulnerabilities: %+v", vulns)
+	}
+	if got := libs(rules.FindJSLibraries("https://example.com/js/app.js", "console.log('jQuery')")); got != "[]" {
+		t.Errorf("unknown file: %s", got)
+	}
+
+	// 1.12.0 to 1.12.2 were patched for CVE-2015-9251
+	var ids []string
+	for _, v := range (JSLibraryVersion{Library: &rules.JSLibraries[0], Version: "1.12.1"}).Vulnerabilities() {
+		ids = append(ids, v.Identifiers...)
+	}
+	if fmt.Sprint(ids) != "[CVE-2019-11358 CVE-2020-11022 CVE-2020-11023]" {
+		t.Errorf("jquery 1.12.1 vulnerabilities: %v", ids)
+	}
+}
+
+func TestCrawlerJSLibraries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<script src="/js/vendor.min.js"></script><script src="/js/lodash-4.17.21.min.js"></script>`)
+		case "/js/vendor.min.js":
+			w.Header().Set("Content-Type", "application/javascript")
+			fmt.Fprint(w, "/*! jQuery v1.12.4 | (c) jQuery Foundation | jquery.org/license */\n!function(a,b){}(window);")
+		case "/js/lodash-4.17.21.min.js":
+			w.Header().Set("Content-Type", "application/javascript")
+			fmt.Fprint(w, "!function(){}();")
+		}
+	}))
+	defer ts.Close()
+
+	findings := make(map[string]SpiderOutput)
+	for _, r := range findingsOf(crawlForTest(t, ts.URL+"/", func(opts *Options) { opts.JSLibVulns = true }), "js-lib") {
+		findings[r.Rule] = r
+	}
+
+	if len(findings) != 2 {
+		t.Fatalf("js-lib findings: %+v", findings)
+	}
+	jquery := findings["jquery"]
+	if jquery.Output != ts.URL+"/js/vendor.min.js" || jquery.Details["version"] != "1.12.4" || jquery.Details["severity"] != "medium" ||
+		jquery.Details["vulnerabilities"] != "CVE-2015-9251,CVE-2019-11358,CVE-2020-11022,CVE-2020-11023" {
+		t.Errorf("jquery finding %+v", jquery)
+	}
+	lodash := findings["lodash"]
+	if lodash.Details["version"] != "4.17.21" || lodash.Details["severity"] != "" {
+		t.Errorf("lodash finding %+v", lodash)
+	}
+}
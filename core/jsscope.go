@@ -0,0 +1,104 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Scopes of the JavaScript fetches of the link finder
+const (
+	// Only the files on hosts in the crawl scope, and on the whitelisted domains
+	JSScopeSite = "scope"
+	// Files on any host, the explicit exclusions of the scope apart
+	JSScopeAll = "all"
+)
+
+// Reasons a JavaScript file isn't fetched by the link finder
+const (
+	JSSkippedScope = "out-of-scope"
+	JSSkippedDepth = "max-depth"
+)
+
+// jsPolicy decides which JavaScript files the link finder fetches. Files on other hosts are
+// only fetched when whitelisted or with JSScopeAll, so a third party script can't take the
+// link finder to every host it references.
+type jsPolicy struct {
+	scope     *Scope
+	all       bool
+	whitelist []string
+	// Files referenced by pages deeper than this aren't fetched, 0 for no limit
+	maxDepth int
+}
+
+func newJSPolicy(scope *Scope, opts Options) (*jsPolicy, error) {
+	p := &jsPolicy{scope: scope, maxDepth: opts.JSMaxDepth}
+	switch strings.ToLower(opts.JSScope) {
+	case "", JSScopeSite:
+	case JSScopeAll:
+		p.all = true
+	default:
+		return nil, fmt.Errorf("unknown JavaScript scope %q, use %s or %s", opts.JSScope, JSScopeSite, JSScopeAll)
+	}
+	for _, domain := range opts.JSDomainWhitelist {
+		if domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*."); domain != "" {
+			p.whitelist = append(p.whitelist, asciiHost(domain))
+		}
+	}
+	return p, nil
+}
+
+// Whether the file at u may be fetched wherever it's referenced
+func (p *jsPolicy) allowed(u *url.URL) bool {
+	if p.scope.Excluded(u) {
+		return false
+	}
+	if p.all || p.scope.InScope(u) {
+		return true
+	}
+	host := asciiHost(strings.ToLower(u.Hostname()))
+	for _, domain := range p.whitelist {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Why the file at u referenced by a page at depth isn't fetched, empty when it is
+func (p *jsPolicy) skip(u *url.URL, depth int) string {
+	if !p.allowed(u) {
+		return JSSkippedScope
+	}
+	if p.maxDepth > 0 && depth > p.maxDepth {
+		return JSSkippedDepth
+	}
+	return ""
+}
+
+// Send a JavaScript file referenced by page to the link finder, or report why it isn't
+func (crawler *Crawler) fetchJS(page *colly.Request, jsURL string) {
+	u, err := url.Parse(jsURL)
+	if err != nil {
+		return
+	}
+	if reason := crawler.jsPolicy.skip(u, page.Depth); reason != "" {
+		Logger.Debugf("JavaScript not fetched (%s): %s", reason, jsURL)
+		outputFormat := fmt.Sprintf("[js-skipped] - [%s] - [from: %s] - %s", reason, page.URL, jsURL)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     page.URL.String(),
+			OutputType: "js-skipped",
+			Output:     jsURL,
+			Details:    map[string]string{"reason": reason},
+		})
+		return
+	}
+
+	// If JS file is minimal format. Try to find original format
+	if strings.Contains(jsURL, ".min.js") {
+		crawler.linkFinderVisits.visit(strings.ReplaceAll(jsURL, ".min.js", ".js"))
+	}
+	crawler.linkFinderVisits.visit(jsURL)
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestJSPolicy(t *testing.T) {
+	site, _ := url.Parse("https://www.example.com/")
+	opts := DefaultOptions()
+	opts.Subs = true
+	opts.DenyPaths = []string{"/private/*"}
+	scope, err := NewScope(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.JSDomainWhitelist = []string{"*.jsdelivr.net"}
+	opts.JSMaxDepth = 2
+	policy, err := newJSPolicy(scope, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for raw, want := range map[string]string{
+		"https://static.example.com/app.js":  "",
+		"https://cdn.jsdelivr.net/lib.js":    "",
+		"https://tracker.example.org/t.js":   JSSkippedScope,
+		"https://notjsdelivr.net/lib.js":     JSSkippedScope,
+		"https://static.example.com/deep.js": JSSkippedDepth,
+	} {
+		u, _ := url.Parse(raw)
+		depth := 1
+		if strings.Contains(raw, "deep") {
+			depth = 3
+		}
+		if got := policy.skip(u, depth); got != want {
+			t.Errorf("skip(%s, %d) = %q, want %q", raw, depth, got, want)
+		}
+	}
+
+	opts.JSScope = JSScopeAll
+	if policy, err = newJSPolicy(scope, opts); err != nil {
+		t.Fatal(err)
+	}
+	for raw, want := range map[string]bool{
+		"https://tracker.example.org/t.js":         true,
+		"https://tracker.example.org/private/t.js": false,
+	} {
+		u, _ := url.Parse(raw)
+		if got := policy.allowed(u); got != want {
+			t.Errorf("allowed(%s) with all scope = %v, want %v", raw, got, want)
+		}
+	}
+
+	opts.JSScope = "everywhere"
+	if _, err := newJSPolicy(scope, opts); err == nil {
+		t.Error("unknown scope accepted")
+	}
+}
+
+func TestCrawlerJSScope(t *testing.T) {
+	var fetches int32
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/widget.js" {
+			atomic.AddInt32(&fetches, 1)
+		}
+		fmt.Fprint(w, `fetch("/api/widget")`)
+	}))
+	defer cdn.Close()
+	// Another host name of the loopback address is out of the scope of the site
+	cdnURL := strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1) + "/widget.js"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><script src="%s"></script></html>`, cdnURL)
+	}))
+	defer ts.Close()
+
+	crawl := func(whitelist []string) []SpiderOutput {
+		return findingsOf(crawlForTest(t, ts.URL, func(opts *Options) { opts.JSDomainWhitelist = whitelist }), "js-skipped")
+	}
+
+	skipped := crawl(nil)
+	if len(skipped) != 1 || skipped[0].Output != cdnURL || skipped[0].Details["reason"] != JSSkippedScope || fetches != 0 {
+		t.Errorf("out of scope script fetched %d times, skipped as %+v", fetches, skipped)
+	}
+	skipped = crawl([]string{"localhost"})
+	if len(skipped) != 0 || fetches != 1 {
+		t.Errorf("whitelisted script fetched %d times, skipped as %+v", fetches, skipped)
+	}
+}
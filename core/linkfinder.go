@@ -1,29 +1,124 @@
 package core
 
 import (
+	"bytes"
 	"regexp"
 	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Contents LinkFinder scans, by Content-Type (see LinkFinderContentType and Options.LinkFinderContent)
+const (
+	LinkFinderJS   = "js"
+	LinkFinderJSON = "json"
+	LinkFinderHTML = "html"
+	LinkFinderText = "text"
 )
 
+// LinkFinderContents are all the contents LinkFinder scans, enabled by default
+var LinkFinderContents = []string{LinkFinderJS, LinkFinderJSON, LinkFinderHTML, LinkFinderText}
+
 var linkFinderRegex = regexp.MustCompile(`(?:"|')(((?:[a-zA-Z]{1,10}://|//)[^"'/]{1,}\.[a-zA-Z]{2,}[^"']{0,})|((?:/|\.\./|\./)[^"'><,;| *()(%%$^/\\\[\]][^"'><,;|()]{1,})|([a-zA-Z0-9_\-/]{1,}/[a-zA-Z0-9_\-/]{1,}\.(?:[a-zA-Z]{1,4}|action)(?:[\?|#][^"|']{0,}|))|([a-zA-Z0-9_\-/]{1,}/[a-zA-Z0-9_\-/]{3,}(?:[\?|#][^"|']{0,}|))|([a-zA-Z0-9_\-]{1,}\.(?:php|asp|aspx|jsp|json|action|html|js|txt|xml)(?:[\?|#][^"|']{0,}|)))(?:"|')`)
 
 func LinkFinder(source string) ([]string, error) {
 	var links []string
-	//source = strings.ToLower(source)
-	if len(source) > 1000000 {
-		source = strings.ReplaceAll(source, ";", ";\r\n")
-		source = strings.ReplaceAll(source, ",", ",\r\n")
-	}
-	source = DecodeChars(source)
+	// Large bundles are decoded and matched chunk by chunk, see scanChunks
+	large := len(source) > scanChunkSize
+	scanChunks(source, func(chunk string) {
+		//chunk = strings.ToLower(chunk)
+		if large {
+			chunk = strings.ReplaceAll(chunk, ";", ";\r\n")
+			chunk = strings.ReplaceAll(chunk, ",", ",\r\n")
+		}
+		chunk = DecodeChars(chunk)
 
-	match := linkFinderRegex.FindAllStringSubmatch(source, -1)
-	for _, m := range match {
-		matchGroup1 := FilterNewLines(m[1])
-		if matchGroup1 == "" {
-			continue
+		match := linkFinderRegex.FindAllStringSubmatch(chunk, -1)
+		for _, m := range match {
+			matchGroup1 := FilterNewLines(m[1])
+			if matchGroup1 == "" {
+				continue
+			}
+			links = append(links, matchGroup1)
 		}
-		links = append(links, matchGroup1)
-	}
+	})
 	links = Unique(links)
 	return links, nil
 }
+
+// LinkFinderContentType returns how LinkFinder scans a response by its Content-Type, by the
+// extension of its URL when the type is missing or generic, "" for content without paths
+// (images, fonts, binaries)
+func LinkFinderContentType(contentType, rawURL string) string {
+	mediaType := strings.ToLower(MediaType(contentType))
+	switch {
+	case strings.HasSuffix(mediaType, "javascript"), strings.HasSuffix(mediaType, "ecmascript"):
+		return LinkFinderJS
+	case mediaType == "application/json", mediaType == "text/json", mediaType == "application/x-ndjson",
+		strings.HasSuffix(mediaType, "+json"):
+		return LinkFinderJSON
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return LinkFinderHTML
+	case mediaType == "text/plain", mediaType == "application/xml", mediaType == "text/xml",
+		strings.HasSuffix(mediaType, "+xml"):
+		// Scripts are often served as text/plain
+		if GetExtType(rawURL) == ".js" {
+			return LinkFinderJS
+		}
+		return LinkFinderText
+	case mediaType == "", mediaType == "application/octet-stream", mediaType == "binary/octet-stream":
+		switch GetExtType(rawURL) {
+		case ".js", ".mjs":
+			return LinkFinderJS
+		case ".json":
+			return LinkFinderJSON
+		case ".txt", ".xml":
+			return LinkFinderText
+		}
+	}
+	return ""
+}
+
+// LinkFinderResponse returns the paths of a response body of a LinkFinderContentType: the
+// whole source of JavaScript and JSON, the inline scripts of HTML, and for text the URLs
+// and paths outside quotes too
+func LinkFinderResponse(content string, body []byte) []string {
+	var paths []string
+	switch content {
+	case LinkFinderJS:
+		paths, _ = LinkFinder(string(body))
+	case LinkFinderJSON:
+		// Slashes may be escaped in JSON strings (Ex: "\/api\/users")
+		paths, _ = LinkFinder(strings.ReplaceAll(string(body), `\/`, "/"))
+	case LinkFinderHTML:
+		paths, _ = LinkFinder(inlineScripts(body))
+	case LinkFinderText:
+		paths, _ = LinkFinder(string(body))
+		for _, ref := range commentURLRegex.FindAllString(string(body), -1) {
+			paths = append(paths, strings.TrimSpace(ref))
+		}
+		paths = Unique(paths)
+	}
+	return paths
+}
+
+// Source of the inline scripts of an HTML document, JSON data blocks included
+func inlineScripts(body []byte) string {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" && htmlAttr(n, "src") == "" {
+			b.WriteString(nodeText(n))
+			b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return b.String()
+}
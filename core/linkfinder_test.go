@@ -1,6 +1,14 @@
 package core
 
-import "testing"
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
 
 func Test_ParseJSSource(t *testing.T) {
 	source := `
@@ -8,3 +16,90 @@ func Test_ParseJSSource(t *testing.T) {
 "https:\u002F\u002Fs.yimg.com\u002Fnq\u002Fstore-badges\u002F4\u002Fstore-badges\u002F"`
 	t.Log(LinkFinder(source))
 }
+
+func TestLinkFinderContentType(t *testing.T) {
+	tests := []struct {
+		contentType, url, want string
+	}{
+		{"application/javascript; charset=utf-8", "https://example.com/app", LinkFinderJS},
+		{"text/plain", "https://example.com/static/app.js", LinkFinderJS},
+		{"application/vnd.api+json", "https://example.com/api/items", LinkFinderJSON},
+		{"text/html; charset=utf-8", "https://example.com/", LinkFinderHTML},
+		{"text/plain", "https://example.com/notes", LinkFinderText},
+		{"application/octet-stream", "https://example.com/config.json", LinkFinderJSON},
+		{"", "https://example.com/bundle.mjs", LinkFinderJS},
+		{"image/png", "https://example.com/logo.png", ""},
+		{"application/octet-stream", "https://example.com/download", ""},
+	}
+	for _, test := range tests {
+		if got := LinkFinderContentType(test.contentType, test.url); got != test.want {
+			t.Errorf("LinkFinderContentType(%q, %q) = %q, want %q", test.contentType, test.url, got, test.want)
+		}
+	}
+}
+
+func TestLinkFinderResponse(t *testing.T) {
+	tests := []struct {
+		content, body string
+		want          []string
+	}{
+		{LinkFinderJSON, `{"next":"\/api\/v2\/items?page=2","name":"items"}`, []string{"/api/v2/items?page=2"}},
+		{LinkFinderHTML, `<html><a href="/about">about</a><script src="/app.js"></script>` +
+			`<script>fetch("/api/session")</script></html>`, []string{"/api/session"}},
+		{LinkFinderText, "Endpoints:\n/internal/health\nhttps://example.com/status \"./docs/api.html\"",
+			[]string{"./docs/api.html", "https://example.com/status", "/internal/health"}},
+		{"", `"/api/session"`, nil},
+	}
+	for _, test := range tests {
+		got := LinkFinderResponse(test.content, []byte(test.body))
+		sort.Strings(got)
+		sort.Strings(test.want)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("LinkFinderResponse(%q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
+
+func TestCrawlerLinkFinderContent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><a href="/config">config</a><a href="/notes">notes</a><script>var api = "/api/inline";</script></html>`)
+		case "/config":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"endpoints":{"export":"api/v2/export"}}`)
+		case "/notes":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "debug endpoint: /internal/debug\n")
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html>page</html>`)
+		}
+	}))
+	defer ts.Close()
+
+	crawl := func(contents []string) map[string]SpiderOutput {
+		return findingsByKey(crawlForTest(t, ts.URL+"/", func(opts *Options) {
+			opts.Depth = 3
+			opts.LinkFinderContent = contents
+		}))
+	}
+
+	found := crawl(LinkFinderContents)
+	for path, content := range map[string]string{"/api/inline": "html", "api/v2/export": "json", "/internal/debug": "text"} {
+		if r, ok := found["linkfinder "+path]; !ok || r.Details["content"] != content {
+			t.Errorf("%s not found in %s content: %+v", path, content, r)
+		}
+	}
+	if _, ok := found["url "+ts.URL+"/api/v2/export"]; !ok {
+		t.Error("path of the JSON response not crawled")
+	}
+
+	found = crawl([]string{LinkFinderJS})
+	for key := range found {
+		if strings.HasPrefix(key, "linkfinder ") {
+			t.Errorf("%s found with JavaScript only", key)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ISO 639-1 language codes, the first part of a locale
+var languageCodes = map[string]bool{}
+
+func init() {
+	for _, code := range strings.Fields(`aa ab ae af ak am an ar as av ay az ba be bg bh bi bm bn bo br bs
+		ca ce ch co cr cs cu cv cy da de dv dz ee el en eo es et eu fa ff fi fj fo fr fy ga gd gl gn gu gv
+		ha he hi ho hr ht hu hy hz ia id ie ig ii ik io is it iu ja jv ka kg ki kj kk kl km kn ko kr ks ku
+		kv kw ky la lb lg li ln lo lt lu lv mg mh mi mk ml mn mr ms mt my na nb nd ne ng nl nn no nr nv ny
+		oc oj om or os pa pi pl ps pt qu rm rn ro ru rw sa sc sd se sg si sk sl sm sn so sq sr ss st su sv
+		sw ta te tg th ti tk tl tn to tr ts tt tw ty ug uk ur uz ve vi vo wa wo xh yi yo za zh zu`) {
+		languageCodes[code] = true
+	}
+}
+
+// A language with an optional region or script (Ex: en, pt-BR, zh_Hant)
+var localeRe = regexp.MustCompile(`^([A-Za-z]{2})(?:[-_]([A-Za-z]{2}|[A-Za-z]{4}))?$`)
+
+// Query parameters holding the locale of a page
+var localeParams = map[string]bool{"lang": true, "locale": true, "language": true, "hl": true, "lng": true}
+
+// Normalized locale of a path segment or parameter value, empty when it isn't one
+func parseLocale(s string) string {
+	m := localeRe.FindStringSubmatch(s)
+	if m == nil || !languageCodes[strings.ToLower(m[1])] {
+		return ""
+	}
+	return strings.ToLower(strings.Replace(s, "_", "-", 1))
+}
+
+// LocaleVariant splits u into the page it's a variant of and its locale, taken from the first
+// path segment (/fr/about) or a lang, locale, language, hl or lng parameter (/about?lang=fr).
+// The locale is empty for URLs without one.
+func LocaleVariant(u *url.URL) (string, string) {
+	locale := ""
+	path := u.EscapedPath()
+	segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if l := parseLocale(segments[0]); l != "" {
+		locale = l
+		path = "/{locale}"
+		if len(segments) == 2 {
+			path += "/" + segments[1]
+		}
+	}
+	var params []string
+	for name, values := range u.Query() {
+		if localeParams[strings.ToLower(name)] && len(values) == 1 {
+			if l := parseLocale(values[0]); l != "" {
+				locale = l
+				params = append(params, url.QueryEscape(name)+"={locale}")
+				continue
+			}
+		}
+		for _, value := range values {
+			params = append(params, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	if locale == "" {
+		return "", ""
+	}
+	sort.Strings(params)
+	page := strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + path
+	if len(params) > 0 {
+		page += "?" + strings.Join(params, "&")
+	}
+	return page, locale
+}
+
+// localeDedup crawls one locale variant of each page with --locale-dedup, the first one
+// requested. The other variants are recorded and reported with it at the end of the crawl.
+type localeDedup struct {
+	mu sync.Mutex
+	// Variant crawled by page
+	crawled map[string]localeURL
+	// Variants skipped by page
+	skipped map[string][]localeURL
+	order   []string
+}
+
+type localeURL struct {
+	locale string
+	url    string
+}
+
+func newLocaleDedup() *localeDedup {
+	return &localeDedup{
+		crawled: make(map[string]localeURL),
+		skipped: make(map[string][]localeURL),
+	}
+}
+
+// Check run before each request, the other locales of a page already requested aren't crawled
+func (d *localeDedup) check(r *colly.Request) bool {
+	page, locale := LocaleVariant(r.URL)
+	if locale == "" {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	crawled, ok := d.crawled[page]
+	if !ok {
+		d.crawled[page] = localeURL{locale: locale, url: r.URL.String()}
+		d.order = append(d.order, page)
+		return true
+	}
+	if crawled.locale == locale {
+		return true
+	}
+	for _, variant := range d.skipped[page] {
+		if variant.url == r.URL.String() {
+			return false
+		}
+	}
+	d.skipped[page] = append(d.skipped[page], localeURL{locale: locale, url: r.URL.String()})
+	Logger.Debugf("Locale variant of %s: %s", crawled.url, r.URL)
+	return false
+}
+
+// Report the crawled variant of each page with the locales it has besides
+func (crawler *Crawler) reportLocales() {
+	if crawler.locales == nil {
+		return
+	}
+	d := crawler.locales
+	d.mu.Lock()
+	var records []SpiderOutput
+	var plains []string
+	for _, page := range d.order {
+		skipped := d.skipped[page]
+		if len(skipped) == 0 {
+			continue
+		}
+		crawled := d.crawled[page]
+		var locales, variants []string
+		for _, variant := range skipped {
+			if !containsString(locales, variant.locale) {
+				locales = append(locales, variant.locale)
+			}
+			variants = append(variants, variant.url)
+		}
+		sort.Strings(locales)
+		sort.Strings(variants)
+		plains = append(plains, fmt.Sprintf("[locale-variants] - [%s: %s] - %s", crawled.locale, strings.Join(locales, ", "), crawled.url))
+		records = append(records, SpiderOutput{
+			Source:     crawled.url,
+			OutputType: "locale-variants",
+			Output:     crawled.url,
+			Details: map[string]string{
+				"locale":   crawled.locale,
+				"locales":  strings.Join(locales, ","),
+				"variants": strings.Join(variants, " "),
+			},
+		})
+	}
+	d.mu.Unlock()
+
+	for i, record := range records {
+		crawler.Report(plains[i], record)
+	}
+}
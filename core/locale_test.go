@@ -0,0 +1,91 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLocaleVariant(t *testing.T) {
+	for _, tc := range []struct {
+		raw, page, locale string
+	}{
+		{"https://example.com/fr/about", "https://example.com/{locale}/about", "fr"},
+		{"https://Example.com/pt_BR/about", "https://example.com/{locale}/about", "pt-br"},
+		{"https://example.com/en", "https://example.com/{locale}", "en"},
+		{"https://example.com/about?lang=de&id=2", "https://example.com/about?id=2&lang={locale}", "de"},
+		{"https://example.com/zh-Hant/docs/", "https://example.com/{locale}/docs/", "zh-hant"},
+		// Not a language code, not the first segment
+		{"https://example.com/js/app.js", "", ""},
+		{"https://example.com/docs/fr/about", "", ""},
+		{"https://example.com/about?lang=xx", "", ""},
+	} {
+		u, _ := url.Parse(tc.raw)
+		if page, locale := LocaleVariant(u); page != tc.page || locale != tc.locale {
+			t.Errorf("LocaleVariant(%s) = %s, %s, want %s, %s", tc.raw, page, locale, tc.page, tc.locale)
+		}
+	}
+}
+
+func TestCrawlerLocaleDedup(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.RequestURI())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/en/about">en</a><a href="/fr/about">fr</a><a href="/de/about">de</a>
+<a href="/search?lang=en">en</a><a href="/search?lang=fr">fr</a><a href="/fr/news">news</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	variants := findingsOf(crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.Concurrent = 1
+		opts.LocaleDedup = true
+	}), "locale-variants")
+
+	// One variant of each page is crawled, the first one found, the others are reported with it
+	crawled := make(map[string]string)
+	for _, r := range requested {
+		u, _ := url.Parse(ts.URL + r)
+		if page, locale := LocaleVariant(u); locale != "" {
+			if crawled[page] != "" {
+				t.Errorf("%s crawled besides %s", r, crawled[page])
+			}
+			crawled[page] = locale
+		}
+	}
+	if len(requested) != 4 || len(crawled) != 3 {
+		t.Errorf("requested %v", requested)
+	}
+	if len(variants) != 2 {
+		t.Fatalf("locale-variants findings %+v", variants)
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i].Output < variants[j].Output })
+	about, search := variants[0], variants[1]
+	aboutLocale := crawled[ts.URL+"/{locale}/about"]
+	var others, otherURLs []string
+	for _, l := range []string{"de", "en", "fr"} {
+		if l != aboutLocale {
+			others = append(others, l)
+			otherURLs = append(otherURLs, ts.URL+"/"+l+"/about")
+		}
+	}
+	if about.Output != ts.URL+"/"+aboutLocale+"/about" || about.Details["locales"] != strings.Join(others, ",") ||
+		about.Details["variants"] != strings.Join(otherURLs, " ") {
+		t.Errorf("about variants %+v", about)
+	}
+	if searchLocale := crawled[ts.URL+"/search?lang={locale}"]; search.Output != ts.URL+"/search?lang="+searchLocale ||
+		search.Details["locales"] == searchLocale || len(search.Details["locales"]) != 2 {
+		t.Errorf("search variants %+v", search)
+	}
+}
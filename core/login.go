@@ -0,0 +1,263 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// LoginConfig is a scripted login performed before crawling, loaded from --login-config.
+// The session cookies it gets are kept by the cookie jar for the rest of the crawl.
+type LoginConfig struct {
+	URL    string `yaml:"url"`
+	Method string `yaml:"method"` // Default is POST with a body, GET without
+	Body   string `yaml:"body"`
+	// Headers of the login request in "Key: Value" format, the Content-Type is guessed from the body when not set
+	Headers []string `yaml:"headers"`
+	// The body and the header values can hold ${NAME} environment variables and {{placeholders}}
+	// rendered for each login, see ParseTemplate
+
+	// The login succeeded when the final response has this status, its body matches this regex
+	// and this cookie is set. When none is given any status below 400 is a success.
+	SuccessStatus int    `yaml:"success_status"`
+	SuccessRegex  string `yaml:"success_regex"`
+	SuccessCookie string `yaml:"success_cookie"`
+
+	// Responses matching this regex or with one of these statuses mean the session expired,
+	// the crawler logs in again and retries them
+	ExpiredRegex  string `yaml:"expired_regex"`
+	ExpiredStatus []int  `yaml:"expired_status"`
+	// MaxRelogins limits how many times the crawler logs in again, default is 5
+	MaxRelogins int `yaml:"max_relogins"`
+
+	successRe *regexp.Regexp
+	expiredRe *regexp.Regexp
+}
+
+// LoadLoginConfig reads and checks a YAML login config
+func LoadLoginConfig(path string) (*LoginConfig, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read login config: %s", err)
+	}
+	var config LoginConfig
+	if err := yaml.UnmarshalStrict(raw, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse login config %s: %s", path, err)
+	}
+	if err := config.compile(); err != nil {
+		return nil, fmt.Errorf("invalid login config %s: %s", path, err)
+	}
+	return &config, nil
+}
+
+// Check the config, set the defaults and compile the regexes
+func (l *LoginConfig) compile() error {
+	u, err := url.Parse(l.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL, got %q", l.URL)
+	}
+	if l.Method == "" {
+		l.Method = "GET"
+		if l.Body != "" {
+			l.Method = "POST"
+		}
+	}
+	l.Method = strings.ToUpper(l.Method)
+	for i, h := range l.Headers {
+		if !strings.Contains(h, ":") {
+			return fmt.Errorf("invalid header %q, use \"Key: Value\"", h)
+		}
+		if l.Headers[i], err = ParseTemplate(h); err != nil {
+			return fmt.Errorf("invalid header %q: %s", h, err)
+		}
+	}
+	if l.Body, err = ParseTemplate(l.Body); err != nil {
+		return fmt.Errorf("invalid body: %s", err)
+	}
+	if l.SuccessRegex != "" {
+		if l.successRe, err = compileRegex(l.SuccessRegex); err != nil {
+			return fmt.Errorf("invalid success_regex: %s", err)
+		}
+	}
+	if l.ExpiredRegex != "" {
+		if l.expiredRe, err = compileRegex(l.ExpiredRegex); err != nil {
+			return fmt.Errorf("invalid expired_regex: %s", err)
+		}
+	}
+	if l.MaxRelogins == 0 {
+		l.MaxRelogins = 5
+	}
+	return nil
+}
+
+// Succeeded reports whether the final login response meets the success conditions,
+// cookies are the ones the client holds for the login URL afterwards
+func (l *LoginConfig) Succeeded(status int, body []byte, cookies []*http.Cookie) bool {
+	if l.SuccessStatus == 0 && l.successRe == nil && l.SuccessCookie == "" {
+		return status < 400
+	}
+	if l.SuccessStatus != 0 && status != l.SuccessStatus {
+		return false
+	}
+	if l.successRe != nil && !l.successRe.Match(body) {
+		return false
+	}
+	if l.SuccessCookie != "" {
+		for _, c := range cookies {
+			if c.Name == l.SuccessCookie {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Expired reports whether a crawled response means the session expired
+func (l *LoginConfig) Expired(status int, body []byte) bool {
+	for _, s := range l.ExpiredStatus {
+		if status == s {
+			return true
+		}
+	}
+	return l.expiredRe != nil && l.expiredRe.Match(body)
+}
+
+// loginSession tracks the logins of a crawl. Every login starts a new generation,
+// so requests that failed with an older session don't trigger a login each.
+type loginSession struct {
+	config *LoginConfig
+
+	mu         sync.Mutex
+	generation int
+	relogins   int
+	// Generation each request in flight was sent with, by request ID
+	sent map[uint32]int
+	// Generation each request was last retried with, by method and URL
+	retried map[string]int
+}
+
+func newLoginSession(config *LoginConfig) *loginSession {
+	return &loginSession{
+		config:  config,
+		sent:    make(map[uint32]int),
+		retried: make(map[string]int),
+	}
+}
+
+// Record the generation a request is sent with
+func (s *loginSession) track(r *colly.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent[r.ID] = s.generation
+}
+
+// Forget a request once answered, returning the generation it was sent with
+func (s *loginSession) answered(r *colly.Request) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	generation := s.sent[r.ID]
+	delete(s.sent, r.ID)
+	return generation
+}
+
+// Perform the scripted login, the client cookie jar keeps the session cookies
+func (crawler *Crawler) login() error {
+	config := crawler.session.config
+	req, err := crawler.newRequest(config.Method, config.URL)
+	if err != nil {
+		return err
+	}
+	if config.Body != "" {
+		body := RenderTemplate(config.Body)
+		req.Body = ioutil.NopCloser(strings.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(body)), nil
+		}
+		contentType := "application/x-www-form-urlencoded"
+		if body := strings.TrimSpace(body); strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[") {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, h := range config.Headers {
+		args := strings.SplitN(h, ":", 2)
+		req.Header.Set(strings.TrimSpace(args[0]), RenderTemplate(strings.TrimSpace(args[1])))
+	}
+
+	resp, err := crawler.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("login request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if !config.Succeeded(resp.StatusCode, body, crawler.client.Jar.Cookies(req.URL)) {
+		return fmt.Errorf("login to %s failed with status %d", config.URL, resp.StatusCode)
+	}
+	Logger.Infof("Logged in to %s", config.URL)
+	return nil
+}
+
+// Get a new session for a request sent with the given generation that found the session expired.
+// Only the first of the requests sent with a session logs in again, and a request is retried
+// once per session. It returns false when the request mustn't be retried.
+func (crawler *Crawler) relogin(r *colly.Request, generation int) bool {
+	s := crawler.session
+	key := r.Method + " " + r.URL.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.retried[key]; ok && last == generation && generation == s.generation {
+		Logger.Warnf("Session still expired after logging in again: %s", r.URL)
+		return false
+	}
+	if generation == s.generation {
+		if s.relogins >= s.config.MaxRelogins {
+			Logger.Warnf("Session expired, maximum number of logins reached: %s", r.URL)
+			return false
+		}
+		s.relogins++
+		if err := crawler.login(); err != nil {
+			Logger.Errorf("Failed to log in again: %s", err)
+			return false
+		}
+		s.generation++
+	}
+	s.retried[key] = s.generation
+	return true
+}
+
+// Detect an expired session in a crawled response, log in again and retry the request.
+// It returns true when the response was retried and must not be processed.
+func (crawler *Crawler) checkSession(response *colly.Response) bool {
+	if crawler.session == nil {
+		return false
+	}
+	r := response.Request
+	generation := crawler.session.answered(r)
+	if !crawler.session.config.Expired(response.StatusCode, response.Body) || !crawler.relogin(r, generation) {
+		return false
+	}
+
+	u := r.URL.String()
+	outputFormat := fmt.Sprintf("[auth-relogin] - %s", u)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     u,
+		OutputType: "auth-relogin",
+		Output:     u,
+	})
+
+	// The cookies of the expired session were added to the headers when the request was sent
+	r.Headers.Del("Cookie")
+	if err := r.Retry(); err != nil {
+		Logger.Debugf("Failed to retry %s: %s", u, err)
+	}
+	return true
+}
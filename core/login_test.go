@@ -0,0 +1,181 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestLoadLoginConfig(t *testing.T) {
+	folder, err := ioutil.TempDir("", "gospider-login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	path := filepath.Join(folder, "login.yaml")
+	config := "url: https://example.com/login\nbody: '{\"user\":\"admin\"}'\nsuccess_regex: welcome\nexpired_status: [401]\n"
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := LoadLoginConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Method != "POST" || l.MaxRelogins != 5 {
+		t.Errorf("defaults not set: %+v", l)
+	}
+	if !l.Succeeded(200, []byte("welcome admin"), nil) || l.Succeeded(200, []byte("wrong password"), nil) {
+		t.Error("success_regex not applied")
+	}
+	if !l.Expired(401, nil) || l.Expired(200, []byte("page")) {
+		t.Error("expired_status not applied")
+	}
+
+	invalid := []string{
+		"url: /login\n",
+		"url: https://example.com/login\nsuccess_regex: '('\n",
+		"url: https://example.com/login\nheaders: [invalid]\n",
+		"url: https://example.com/login\nunknown: field\n",
+	}
+	for _, config := range invalid {
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadLoginConfig(path); err == nil {
+			t.Errorf("LoadLoginConfig(%q) should fail", config)
+		}
+	}
+}
+
+func TestLoginConfigSucceeded(t *testing.T) {
+	l := &LoginConfig{SuccessStatus: 302, SuccessCookie: "session"}
+	cookies := []*http.Cookie{{Name: "session", Value: "abc"}}
+	if !l.Succeeded(302, nil, cookies) || l.Succeeded(200, nil, cookies) || l.Succeeded(302, nil, nil) {
+		t.Error("success_status and success_cookie not applied")
+	}
+	if l := (&LoginConfig{}); !l.Succeeded(200, nil, nil) || l.Succeeded(403, nil, nil) {
+		t.Error("default success condition not applied")
+	}
+}
+
+func TestCrawlerCookieJar(t *testing.T) {
+	var mu sync.Mutex
+	var cookies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			http.SetCookie(w, &http.Cookie{Name: "visit", Value: "1", Path: "/"})
+			fmt.Fprint(w, `<a href="/next">next</a>`)
+			return
+		}
+		mu.Lock()
+		cookies = append(cookies, r.Header.Get("Cookie"))
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Cookie = "static=1"
+		opts.Depth = 2
+	})
+
+	if len(cookies) != 1 || cookies[0] != "static=1; visit=1" {
+		t.Errorf("cookies sent to /next = %q, want the static and the jar cookie", cookies)
+	}
+}
+
+func TestCrawlerRelogin(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		logins   int
+		session  string
+		expired  bool
+		privates []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/login" {
+			if r.Method != "POST" || r.FormValue("password") != "secret" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			logins++
+			session = strconv.Itoa(logins)
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: session, Path: "/"})
+			fmt.Fprint(w, "welcome")
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		if c, err := r.Cookie("session"); err != nil || c.Value != session {
+			fmt.Fprint(w, "please log in")
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<a href="/private">private</a>`)
+		case "/private":
+			privates = append(privates, session)
+			if !expired {
+				// The session expires while crawling
+				expired = true
+				session = ""
+				fmt.Fprint(w, "please log in")
+				return
+			}
+			fmt.Fprint(w, "private page")
+		}
+	}))
+	defer ts.Close()
+
+	folder, err := ioutil.TempDir("", "gospider-login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	config := fmt.Sprintf("url: %s/login\nbody: user=admin&password=secret\nsuccess_cookie: session\nexpired_regex: please log in\n", ts.URL)
+	loginConfig := filepath.Join(folder, "login.yaml")
+	if err := ioutil.WriteFile(loginConfig, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found := crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+		opts.LoginConfig = loginConfig
+	})
+
+	if logins != 2 {
+		t.Errorf("logged in %d times, want 2", logins)
+	}
+	if len(privates) != 2 || privates[1] != "2" {
+		t.Errorf("/private requested with sessions %v, want a retry with the new session", privates)
+	}
+	relogin := false
+	for _, r := range found {
+		if r.OutputType == "auth-relogin" && r.Output == ts.URL+"/private" {
+			relogin = true
+		}
+		if r.OutputType == "url" && r.Output == ts.URL+"/private" && r.Length != len("private page") {
+			t.Errorf("expired page reported: %+v", r)
+		}
+	}
+	if !relogin {
+		t.Errorf("relogin not reported, got %v", found)
+	}
+
+	site, _ := url.Parse(ts.URL)
+	opts := DefaultOptions()
+	opts.LoginConfig = filepath.Join(folder, "missing.yaml")
+	if _, err := NewCrawlerWithOptions(site, opts); err == nil {
+		t.Error("missing login config should fail")
+	}
+}
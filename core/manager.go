@@ -0,0 +1,240 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jaeles-project/gospider/stringset"
+)
+
+// Findings buffered for the reader of Manager.Findings before the crawlers wait for it
+const managerFindingsBuffer = 1000
+
+// crawlShare is what the crawlers of a Manager, or a crawl and its subdomain crawls, have in common
+type crawlShare struct {
+	// Global rate and per host backoff with Options.RateLimit, nil without
+	limits *rateLimits
+	// Budget of the crawl subdomain crawlers were promoted from, nil for a Manager
+	budget *crawlBudget
+
+	mu sync.Mutex
+	// Dedup filters, nil when they aren't shared
+	filters map[string]stringset.Filter
+}
+
+// Dedup filter shared under name, a URL or finding seen by one crawler is skipped by the others
+func (s *crawlShare) filter(name string) stringset.Filter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.filters[name]
+	if !ok {
+		f = stringset.NewStringFilter()
+		s.filters[name] = f
+	}
+	return f
+}
+
+// Manager runs several crawlers for embedders, like the gospider command does for a site list.
+// Its crawlers share their dedup filters, so a URL or finding is only crawled and reported once
+// across sites, and the global rate and per host backoff of Options.RateLimit.
+// Findings of all crawlers can be read from one channel.
+//
+//	m, err := core.NewManager(opts, 5)
+//	m.Add("https://a.example.com/")
+//	m.Add("https://b.example.com/")
+//	findings := m.Findings()
+//	m.StartAll()
+//	for f := range findings {
+//		fmt.Println(f.Output)
+//	}
+//	err = m.Wait()
+type Manager struct {
+	opts    Options
+	threads int
+	cancel  context.CancelFunc
+	share   *crawlShare
+
+	findings   chan SpiderOutput
+	subscribed int32
+
+	mu       sync.Mutex
+	crawlers []*Crawler
+	// Crawlers skipped once the manager was stopped
+	skipped  map[*Crawler]bool
+	started  bool
+	wg       sync.WaitGroup
+	firstErr error
+}
+
+// NewManager creates a Manager crawling its sites with opts, at most threads sites at a time
+// (every site at once when threads < 1). Options.OnResult is still called with every finding.
+// Once Options.Context is done the manager stops like with Stop.
+// Invalid options are refused, see ValidateOptions.
+func NewManager(opts Options, threads int) (*Manager, error) {
+	if errs := ValidateOptions(opts); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	parent := opts.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	m := &Manager{
+		threads:  threads,
+		cancel:   cancel,
+		share:    &crawlShare{filters: make(map[string]stringset.Filter)},
+		findings: make(chan SpiderOutput, managerFindingsBuffer),
+		skipped:  make(map[*Crawler]bool),
+	}
+	if opts.RateLimit > 0 {
+		m.share.limits = newRateLimits(opts.RateLimit, opts.Concurrent)
+	}
+	onResult := opts.OnResult
+	opts.OnResult = func(r SpiderOutput) {
+		if onResult != nil {
+			onResult(r)
+		}
+		if atomic.LoadInt32(&m.subscribed) == 1 {
+			m.findings <- r
+		}
+	}
+	opts.Context = ctx
+	m.opts = opts
+	return m, nil
+}
+
+// Add creates the crawler of rawSite, before StartAll
+func (m *Manager) Add(rawSite string) (*Crawler, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started {
+		return nil, errors.New("manager already started")
+	}
+	site, err := url.Parse(rawSite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", rawSite, err)
+	}
+	crawler, err := newCrawler(site, m.opts, m.share)
+	if err != nil {
+		return nil, fmt.Errorf("failed to crawl %s: %s", rawSite, err)
+	}
+	m.crawlers = append(m.crawlers, crawler)
+	return crawler, nil
+}
+
+// Crawlers returns the crawlers added
+func (m *Manager) Crawlers() []*Crawler {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Crawler(nil), m.crawlers...)
+}
+
+// Results returns the result of each crawler in the order they were added, complete once Wait returns
+func (m *Manager) Results() []SiteResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	results := make([]SiteResult, 0, len(m.crawlers))
+	for _, crawler := range m.crawlers {
+		if m.skipped[crawler] {
+			results = append(results, SiteResult{Site: crawler.site.String(), Status: SiteAborted, Reason: "interrupted"})
+			continue
+		}
+		results = append(results, crawler.Result())
+	}
+	return results
+}
+
+// Findings returns the findings of all crawlers from now on, the channel is closed once
+// every crawler is done. It must be read until then, the crawlers wait when it's full.
+func (m *Manager) Findings() <-chan SpiderOutput {
+	atomic.StoreInt32(&m.subscribed, 1)
+	return m.findings
+}
+
+// StartAll runs the crawlers in the background, at most threads at a time.
+// A crawler that panics is logged and doesn't stop the others, see Wait.
+func (m *Manager) StartAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started {
+		return
+	}
+	m.started = true
+
+	threads := m.threads
+	if threads < 1 || threads > len(m.crawlers) {
+		threads = len(m.crawlers)
+	}
+	crawlers := make(chan *Crawler, len(m.crawlers))
+	for _, crawler := range m.crawlers {
+		crawlers <- crawler
+	}
+	close(crawlers)
+	for i := 0; i < threads; i++ {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			for crawler := range crawlers {
+				m.run(crawler)
+			}
+		}()
+	}
+	go func() {
+		m.wg.Wait()
+		close(m.findings)
+	}()
+}
+
+// Run one crawler, the ones left once the manager is stopped are closed without crawling
+func (m *Manager) run(crawler *Crawler) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.setErr(fmt.Errorf("crawler of %s panicked: %v", crawler.site, r))
+		}
+	}()
+	if m.opts.Context.Err() != nil {
+		Logger.Warnf("Crawl interrupted, skip: %s", crawler.site)
+		m.mu.Lock()
+		m.skipped[crawler] = true
+		m.mu.Unlock()
+		crawler.Close()
+		return
+	}
+	crawler.Run()
+}
+
+func (m *Manager) setErr(err error) {
+	Logger.Error(err)
+	m.mu.Lock()
+	if m.firstErr == nil {
+		m.firstErr = err
+	}
+	m.mu.Unlock()
+}
+
+// Stop stops the running crawlers gracefully, in flight requests finish and the output is
+// flushed. The crawlers not started yet are skipped.
+func (m *Manager) Stop() {
+	m.cancel()
+	m.mu.Lock()
+	started := m.started
+	m.mu.Unlock()
+	// Crawlers never started still hold their output files
+	if !started {
+		m.StartAll()
+	}
+}
+
+// Wait blocks until every crawler is done and returns the first panic of a crawler.
+// It returns at once when the manager wasn't started.
+func (m *Manager) Wait() error {
+	m.wg.Wait()
+	m.cancel()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.firstErr
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestManager(t *testing.T) {
+	var hits int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><a href="/about">about</a><a href="https://partner.example.org/">partner</a></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html>page</html>`)
+	})
+	a := httptest.NewServer(handler)
+	defer a.Close()
+	b := httptest.NewServer(handler)
+	defer b.Close()
+
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.RateLimit = 100
+	var called int32
+	opts.OnResult = func(SpiderOutput) {
+		atomic.AddInt32(&called, 1)
+	}
+	m, err := NewManager(opts, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, site := range []string{a.URL + "/", b.URL + "/"} {
+		if _, err := m.Add(site); err != nil {
+			t.Fatal(err)
+		}
+	}
+	crawlers := m.Crawlers()
+	if crawlers[0].urlSet != crawlers[1].urlSet {
+		t.Error("crawlers don't share their URL filter")
+	}
+
+	findings := m.Findings()
+	m.StartAll()
+	if _, err := m.Add(a.URL + "/other"); err == nil {
+		t.Error("site added to a started manager")
+	}
+	urls := make(map[string]bool)
+	external := 0
+	total := 0
+	for f := range findings {
+		total++
+		switch f.OutputType {
+		case "url":
+			urls[f.Output] = true
+		case "external":
+			external++
+		}
+	}
+	if err := m.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !urls[a.URL+"/about"] || !urls[b.URL+"/about"] {
+		t.Errorf("urls of both sites not found: %v", urls)
+	}
+	if external != 1 {
+		t.Errorf("shared external link reported %d times", external)
+	}
+	if int(atomic.LoadInt32(&called)) != total {
+		t.Errorf("OnResult called %d times for %d findings", called, total)
+	}
+	for _, result := range m.Results() {
+		if result.Status != SiteCompleted {
+			t.Errorf("%s: %+v", result.Site, result)
+		}
+	}
+}
+
+func TestManagerStop(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer ts.Close()
+
+	opts := DefaultOptions()
+	opts.Robots = false
+	opts.Quiet = true
+	m, err := NewManager(opts, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Add(ts.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	m.Stop()
+	if err := m.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 0 {
+		t.Errorf("%d requests sent by a stopped manager", hits)
+	}
+	results := m.Results()
+	if len(results) != 1 || results[0].Status != SiteAborted || results[0].Reason != "interrupted" {
+		t.Errorf("stopped crawler results %+v", results)
+	}
+}
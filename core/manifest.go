@@ -0,0 +1,157 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ManifestFile is written to the output folder to reproduce and audit a run
+const ManifestFile = "run.json"
+
+const redacted = "REDACTED"
+
+// RunManifest records how a run was made: version, effective options, seeds,
+// hashes of the rule files it read and timing
+type RunManifest struct {
+	Version   string     `json:"version"`
+	GoVersion string     `json:"go_version"`
+	Options   Options    `json:"options"`
+	Sites     []string   `json:"sites"`
+	Threads   int        `json:"threads"`
+	RuleFiles []RunFile  `json:"rule_files,omitempty"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time,omitempty"`
+	Duration  string     `json:"duration,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// RunFile is a file a run read its rules or scope from, with its SHA-256
+type RunFile struct {
+	Option string `json:"option"`
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewRunManifest creates the manifest of a run starting now.
+// Credentials (cookie, sensitive headers, URL passwords) are redacted from the options.
+func NewRunManifest(sites []string, opts Options, threads int) *RunManifest {
+	m := &RunManifest{
+		Version:   VERSION,
+		GoVersion: runtime.Version(),
+		Options:   redactOptions(opts),
+		Sites:     sites,
+		Threads:   threads,
+		StartTime: time.Now(),
+	}
+	files := []struct{ option, path string }{
+		{"secret-rules", opts.SecretRules},
+		{"scope-file", opts.ScopeFile},
+		{"out-of-scope", opts.OutOfScope},
+		{"burp", opts.Burp},
+		{"proxy-list", opts.ProxyList},
+		{"login-config", opts.LoginConfig},
+		{"extract-config", opts.ExtractConfig},
+	}
+	if opts.RulesDir != "" {
+		for _, name := range RuleFiles {
+			files = append(files, struct{ option, path string }{"rules-dir", filepath.Join(opts.RulesDir, name)})
+		}
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		file := RunFile{Option: f.option, Path: f.path}
+		data, err := ioutil.ReadFile(f.path)
+		if err == nil {
+			sum := sha256.Sum256(data)
+			file.SHA256 = hex.EncodeToString(sum[:])
+		} else if f.option == "rules-dir" {
+			// Built-in rule file not overridden
+			continue
+		}
+		m.RuleFiles = append(m.RuleFiles, file)
+	}
+	return m
+}
+
+// Finish records the end of the run and its error, if any
+func (m *RunManifest) Finish(err error) {
+	end := time.Now()
+	m.EndTime = &end
+	m.Duration = end.Sub(m.StartTime).Round(time.Millisecond).String()
+	if err != nil {
+		m.Error = err.Error()
+	}
+}
+
+// Write saves the manifest to folder/run.json
+func (m *RunManifest) Write(folder string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(folder, ManifestFile), append(data, '\n'), 0644)
+}
+
+// Copy of opts without credentials
+func redactOptions(opts Options) Options {
+	if opts.Cookie != "" {
+		opts.Cookie = redacted
+	}
+	if opts.AuthBasic != "" {
+		opts.AuthBasic = redacted
+	}
+	if opts.AuthBearer != "" {
+		opts.AuthBearer = redacted
+	}
+	opts.Headers = append([]string(nil), opts.Headers...)
+	for i, header := range opts.Headers {
+		args := strings.SplitN(header, ":", 2)
+		name := strings.ToLower(strings.TrimSpace(args[0]))
+		if len(args) == 2 && (name == "authorization" || name == "cookie" || strings.Contains(name, "token") ||
+			strings.Contains(name, "key") || strings.Contains(name, "secret")) {
+			opts.Headers[i] = args[0] + ": " + redacted
+		}
+	}
+	opts.Proxy = redactURL(opts.Proxy)
+	opts.Redis = redactURL(opts.Redis)
+	opts.OutputSinks = append([]string(nil), opts.OutputSinks...)
+	for i, sink := range opts.OutputSinks {
+		if args := strings.SplitN(sink, "=", 2); len(args) == 2 {
+			opts.OutputSinks[i] = args[0] + "=" + redactURL(args[1])
+		}
+	}
+	return opts
+}
+
+// Replace the password of a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return u.String()
+}
+
+// Create the output folder and write the manifest of a run in it
+func writeManifest(m *RunManifest, folder string) {
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		Logger.Errorf("Failed to write run manifest: %s", err)
+		return
+	}
+	if err := m.Write(folder); err != nil {
+		Logger.Errorf("Failed to write run manifest: %s", err)
+	}
+}
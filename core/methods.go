@@ -0,0 +1,82 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Methods that can modify server state, WebDAV ones included
+var stateChangingMethods = map[string]bool{
+	"PUT":       true,
+	"DELETE":    true,
+	"PATCH":     true,
+	"PROPPATCH": true,
+	"MKCOL":     true,
+	"COPY":      true,
+	"MOVE":      true,
+}
+
+// ParseAllowHeader splits an Allow header (Ex: "GET, HEAD, PUT") into upper-cased methods
+func ParseAllowHeader(allow string) []string {
+	var methods []string
+	for _, m := range strings.Split(allow, ",") {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if m != "" {
+			methods = append(methods, m)
+		}
+	}
+	return Unique(methods)
+}
+
+// StateChangingMethods returns the methods of the list that can modify server state
+func StateChangingMethods(methods []string) []string {
+	var dangerous []string
+	for _, m := range methods {
+		if stateChangingMethods[m] {
+			dangerous = append(dangerous, m)
+		}
+	}
+	return dangerous
+}
+
+// Send OPTIONS to an endpoint and report the methods it allows
+func (crawler *Crawler) findMethods(u *url.URL) {
+	endpoint := u.Scheme + "://" + u.Host + u.Path
+	if crawler.methodSet.Duplicate(endpoint) {
+		return
+	}
+
+	req, err := crawler.newRequest("OPTIONS", endpoint)
+	if err != nil {
+		return
+	}
+	resp, err := crawler.client.Do(req)
+	if err != nil {
+		Logger.Debugf("Failed to send OPTIONS to %s: %s", endpoint, err)
+		return
+	}
+	resp.Body.Close()
+
+	allow := resp.Header.Get("Allow")
+	if allow == "" {
+		// IIS lists them in Public
+		allow = resp.Header.Get("Public")
+	}
+	methods := ParseAllowHeader(allow)
+	if len(methods) == 0 {
+		return
+	}
+
+	outputFormat := fmt.Sprintf("[methods] - [%s] - %s", strings.Join(methods, ", "), endpoint)
+	if dangerous := StateChangingMethods(methods); len(dangerous) > 0 {
+		outputFormat = fmt.Sprintf("[methods] - [%s] - [state-changing: %s] - %s", strings.Join(methods, ", "), strings.Join(dangerous, ", "), endpoint)
+	}
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     endpoint,
+		OutputType: "methods",
+		Output:     endpoint,
+		StatusCode: resp.StatusCode,
+		Methods:    methods,
+	})
+}
@@ -0,0 +1,17 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAllowHeader(t *testing.T) {
+	methods := ParseAllowHeader("get, HEAD,OPTIONS, PUT,  delete")
+	want := []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+	if !reflect.DeepEqual(methods, want) {
+		t.Errorf("ParseAllowHeader() = %v, want %v", methods, want)
+	}
+	if dangerous := StateChangingMethods(methods); !reflect.DeepEqual(dangerous, []string{"PUT", "DELETE"}) {
+		t.Errorf("StateChangingMethods() = %v", dangerous)
+	}
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Media types expected for file extensions, as substrings of the media type
+var extensionMediaTypes = map[string][]string{
+	".js":   {"javascript", "ecmascript"},
+	".mjs":  {"javascript", "ecmascript"},
+	".css":  {"text/css"},
+	".json": {"json"},
+	".xml":  {"xml"},
+	".html": {"text/html"},
+	".htm":  {"text/html"},
+	".pdf":  {"application/pdf"},
+	".png":  {"image/"},
+	".jpg":  {"image/"},
+	".jpeg": {"image/"},
+	".gif":  {"image/"},
+	".svg":  {"image/"},
+	".webp": {"image/"},
+	".ico":  {"image/"},
+}
+
+// MIMEAnomaly tells whether a successful response has a content type that doesn't fit its path
+// or its body: a script served as application/octet-stream or HTML, an API path answering HTML,
+// a JSON content type with an HTML body... It returns the reason and the content expected,
+// an empty reason when the response looks right.
+func MIMEAnomaly(urlPath, contentType string, body []byte) (reason, expected string) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		return "", ""
+	}
+	ext := strings.ToLower(path.Ext(urlPath))
+	if wanted, ok := extensionMediaTypes[ext]; ok {
+		for _, want := range wanted {
+			if strings.Contains(mediaType, want) {
+				return bodyAnomaly(mediaType, body)
+			}
+		}
+		return "extension-mismatch", strings.Join(wanted, " or ")
+	}
+	if mediaType == "text/html" && IsAPIPath(urlPath) {
+		return "html-for-api", "json or xml"
+	}
+	return bodyAnomaly(mediaType, body)
+}
+
+// Content type and body that disagree
+func bodyAnomaly(mediaType string, body []byte) (string, string) {
+	start := bytes.TrimSpace(body)
+	if len(start) > 512 {
+		start = start[:512]
+	}
+	switch {
+	case strings.Contains(mediaType, "json") && bytes.HasPrefix(start, []byte("<")):
+		return "body-mismatch", "json"
+	case mediaType == "text/html" && (bytes.HasPrefix(start, []byte("{")) || bytes.HasPrefix(start, []byte("["))) && json.Valid(bytes.TrimSpace(body)):
+		return "body-mismatch", "html"
+	}
+	return "", ""
+}
+
+// contentTypeStats counts the media types each host answered with
+type contentTypeStats struct {
+	mu    sync.Mutex
+	hosts map[string]map[string]int
+}
+
+func newContentTypeStats() *contentTypeStats {
+	return &contentTypeStats{hosts: make(map[string]map[string]int)}
+}
+
+func (s *contentTypeStats) add(host, mediaType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	types, ok := s.hosts[host]
+	if !ok {
+		types = make(map[string]int)
+		s.hosts[host] = types
+	}
+	types[mediaType]++
+}
+
+// Count the content type of a response and report it when it doesn't fit the response
+func (crawler *Crawler) checkContentType(response *colly.Response) {
+	u := response.Request.URL
+	var contentType string
+	if response.Headers != nil {
+		contentType = response.Headers.Get("Content-Type")
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" {
+		mediaType = "none"
+	}
+	crawler.contentTypes.add(u.Host, mediaType)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 || !crawler.scope.InScope(u) {
+		return
+	}
+	reason, expected := MIMEAnomaly(u.Path, contentType, response.Body)
+	if reason == "" || crawler.anomalySet.Duplicate(u.String()) {
+		return
+	}
+	outputFormat := fmt.Sprintf("[anomaly] - [%s] - [%s] - %s", reason, mediaType, u)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     u.String(),
+		OutputType: "anomaly",
+		Output:     u.String(),
+		StatusCode: response.StatusCode,
+		Rule:       reason,
+		Details:    map[string]string{"content-type": mediaType, "expected": expected},
+	})
+}
+
+// Report the content types each host answered with, the most common first
+func (crawler *Crawler) reportContentTypes() {
+	if crawler.contentTypes == nil {
+		return
+	}
+	crawler.contentTypes.mu.Lock()
+	defer crawler.contentTypes.mu.Unlock()
+
+	hosts := make([]string, 0, len(crawler.contentTypes.hosts))
+	for host := range crawler.contentTypes.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		counts := crawler.contentTypes.hosts[host]
+		types := make([]string, 0, len(counts))
+		details := make(map[string]string, len(counts))
+		for mediaType, n := range counts {
+			types = append(types, mediaType)
+			details[mediaType] = strconv.Itoa(n)
+		}
+		sort.Slice(types, func(i, j int) bool {
+			if counts[types[i]] != counts[types[j]] {
+				return counts[types[i]] > counts[types[j]]
+			}
+			return types[i] < types[j]
+		})
+		var parts []string
+		for _, mediaType := range types {
+			parts = append(parts, fmt.Sprintf("%s: %d", mediaType, counts[mediaType]))
+		}
+		outputFormat := fmt.Sprintf("[content-types] - %s - %s", host, strings.Join(parts, ", "))
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     crawler.site.String(),
+			OutputType: "content-types",
+			Output:     host,
+			Details:    details,
+		})
+	}
+}
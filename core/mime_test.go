@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestMIMEAnomaly(t *testing.T) {
+	tests := []struct {
+		path, contentType, body, reason string
+	}{
+		{"/static/app.js", "application/javascript; charset=utf-8", "var a = 1;", ""},
+		{"/static/app.js", "application/octet-stream", "var a = 1;", "extension-mismatch"},
+		{"/static/app.js", "text/html", "<html>", "extension-mismatch"},
+		{"/logo.png", "image/png", "", ""},
+		{"/api/v1/users", "text/html", "<html>login</html>", "html-for-api"},
+		{"/api/v1/users", "application/json", `{"users":[]}`, ""},
+		{"/data", "application/json", "<html>error</html>", "body-mismatch"},
+		{"/page", "text/html", `{"a": 1}`, "body-mismatch"},
+		{"/page", "text/html", `<html>{"a": 1}</html>`, ""},
+		{"/page", "", "anything", ""},
+	}
+	for _, test := range tests {
+		if reason, _ := MIMEAnomaly(test.path, test.contentType, []byte(test.body)); reason != test.reason {
+			t.Errorf("MIMEAnomaly(%s, %s) = %q, want %q", test.path, test.contentType, reason, test.reason)
+		}
+	}
+}
+
+func TestCrawlerMIMEStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><a href="/api/v1/users">users</a><a href="/about">about</a><script src="/static/app.js"></script></html>`)
+		case "/static/app.js":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, `var a = 1;`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html>page</html>`)
+		}
+	}))
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL + "/")
+	findings := crawlForTest(t, site.String(), func(opts *Options) {
+		opts.Depth = 2
+		opts.MIMEStats = true
+	})
+	anomalies := make(map[string]string)
+	for _, r := range findingsOf(findings, "anomaly") {
+		anomalies[r.Output] = r.Rule
+	}
+	stats := findingsOf(findings, "content-types")
+
+	want := map[string]string{
+		ts.URL + "/static/app.js": "extension-mismatch",
+		ts.URL + "/api/v1/users":  "html-for-api",
+	}
+	for u, reason := range want {
+		if anomalies[u] != reason {
+			t.Errorf("anomaly of %s = %q, want %q", u, anomalies[u], reason)
+		}
+	}
+	if len(anomalies) != len(want) {
+		t.Errorf("anomalies %v", anomalies)
+	}
+	if len(stats) != 1 || stats[0].Output != site.Host || stats[0].Details["text/html"] != "3" || stats[0].Details["application/octet-stream"] == "" {
+		t.Errorf("content types %+v", stats)
+	}
+}
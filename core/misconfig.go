@@ -0,0 +1,67 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+)
+
+// Header sent with TRACE/TRACK, an enabled method echoes it back in the body
+const (
+	traceMarkerHeader = "X-Gospider-Trace"
+	traceMarker       = "gospider-trace-check"
+)
+
+// Check a host once for TRACE/TRACK being enabled and for exposed status pages
+func (crawler *Crawler) checkMisconfig(u *url.URL) {
+	base := u.Scheme + "://" + u.Host
+	if crawler.misconfigSet.Duplicate(base) {
+		return
+	}
+
+	for _, method := range []string{"TRACE", "TRACK"} {
+		body, status, ok := crawler.probeBody(method, base+"/", map[string]string{traceMarkerHeader: traceMarker})
+		if ok && status == 200 && strings.Contains(body, traceMarker) {
+			crawler.reportMisconfig(strings.ToLower(method)+"-enabled", base+"/", status)
+		}
+	}
+
+	for _, page := range crawler.rules.StatusPages {
+		body, status, ok := crawler.probeBody("GET", base+page.Path, nil)
+		if ok && status == 200 && page.re.MatchString(body) {
+			crawler.reportMisconfig(page.Name, base+page.Path, status)
+		}
+	}
+}
+
+// Send a probe request and read at most 64KB of the response body
+func (crawler *Crawler) probeBody(method, u string, headers map[string]string) (string, int, bool) {
+	req, err := crawler.newRequest(method, u)
+	if err != nil {
+		return "", 0, false
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := crawler.client.Do(req)
+	if err != nil {
+		Logger.Debugf("Failed to send %s to %s: %s", method, u, err)
+		return "", 0, false
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return string(body), resp.StatusCode, true
+}
+
+func (crawler *Crawler) reportMisconfig(check, u string, status int) {
+	outputFormat := fmt.Sprintf("[misconfig] - [%s] - %s", check, u)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     u,
+		OutputType: "misconfig",
+		Output:     u,
+		StatusCode: status,
+		Rule:       check,
+	})
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestCheckMisconfig(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "TRACE":
+			// Echo the request like a server with TRACE enabled
+			fmt.Fprintf(w, "TRACE / HTTP/1.1\r\n%s: %s\r\n", traceMarkerHeader, r.Header.Get(traceMarkerHeader))
+		case r.URL.Path == "/server-status":
+			fmt.Fprint(w, "<h1>Apache Server Status for example.com</h1>")
+		case r.URL.Path == "/nginx_status":
+			// Soft 404 must not be reported
+			fmt.Fprint(w, "not found")
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL)
+	opts := DefaultOptions()
+	opts.Quiet = true
+	var mu sync.Mutex
+	found := make(map[string]int)
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		found[r.Rule]++
+	}
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer crawler.Close()
+
+	crawler.checkMisconfig(site)
+	crawler.checkMisconfig(site)
+
+	if found["trace-enabled"] != 1 || found["apache-server-status"] != 1 {
+		t.Errorf("expected trace and server status reported once, got %v", found)
+	}
+	if len(found) != 2 {
+		t.Errorf("unexpected findings %v", found)
+	}
+}
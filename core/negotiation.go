@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"io"
+	"io/ioutil"
+	"mime"
+	"regexp"
+)
+
+var apiPathRegex = regexp.MustCompile(`(?i)/(?:api|rest|graphql|v[0-9]+)(?:/|$)|\.(?:json|xml)$`)
+
+// Accept headers tried on API-looking endpoints
+var negotiationAccepts = []string{"application/json", "application/xml"}
+
+// IsAPIPath reports whether a URL path looks like an API endpoint
+func IsAPIPath(path string) bool {
+	return apiPathRegex.MatchString(path)
+}
+
+// MediaType returns the media type of a Content-Type header without parameters
+func MediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mediaType
+}
+
+// Re-request an API-looking endpoint with other Accept headers and
+// report when it answers with another representation
+func (crawler *Crawler) probeNegotiation(response *colly.Response) {
+	u := response.Request.URL
+	if !IsAPIPath(u.Path) {
+		return
+	}
+	endpoint := u.Scheme + "://" + u.Host + u.Path
+	if crawler.negotiationSet.Duplicate(endpoint) {
+		return
+	}
+
+	original := MediaType(response.Headers.Get("Content-Type"))
+	for _, accept := range negotiationAccepts {
+		if original == accept {
+			continue
+		}
+		req, err := crawler.newRequest("GET", u.String())
+		if err != nil {
+			return
+		}
+		req.Header.Set("Accept", accept)
+		resp, err := crawler.client.Do(req)
+		if err != nil {
+			Logger.Debugf("Failed to probe %s with Accept %s: %s", u, accept, err)
+			continue
+		}
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		negotiated := MediaType(resp.Header.Get("Content-Type"))
+		if resp.StatusCode >= 400 || negotiated == "" || negotiated == original {
+			continue
+		}
+		outputFormat := fmt.Sprintf("[negotiation] - [accept: %s] - [%s -> %s] - %s", accept, original, negotiated, u)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     u.String(),
+			OutputType: "negotiation",
+			Output:     u.String(),
+			StatusCode: resp.StatusCode,
+			Details: map[string]string{
+				"accept":     accept,
+				"original":   original,
+				"negotiated": negotiated,
+			},
+		})
+	}
+}
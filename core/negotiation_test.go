@@ -0,0 +1,25 @@
+package core
+
+import "testing"
+
+func TestIsAPIPath(t *testing.T) {
+	tests := map[string]bool{
+		"/api/users":     true,
+		"/v2/items":      true,
+		"/export.json":   true,
+		"/rest":          true,
+		"/blog/api-tips": false,
+		"/about":         false,
+	}
+	for path, want := range tests {
+		if got := IsAPIPath(path); got != want {
+			t.Errorf("IsAPIPath(%s) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	if got := MediaType("application/json; charset=utf-8"); got != "application/json" {
+		t.Errorf("MediaType() = %s", got)
+	}
+}
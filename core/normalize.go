@@ -0,0 +1,94 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Query parameters of analytics and ad campaigns, they never change the page
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"igshid":  true,
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	return trackingParams[name] || strings.HasPrefix(name, "utm_")
+}
+
+// NormalizeURL returns the dedup key of u with --normalize-urls: the URLPattern of u
+// without its fragment and tracking parameters, so ids, UUIDs, hashes, dates and tokens
+// of the path are collapsed and query parameters sorted without their values
+// (Ex: https://example.com/user/{int}?tab={}).
+func NormalizeURL(u *url.URL) string {
+	stripped := *u
+	stripped.Fragment = ""
+	stripped.RawFragment = ""
+	query := u.Query()
+	for name := range query {
+		if isTrackingParam(name) {
+			query.Del(name)
+		}
+	}
+	stripped.RawQuery = query.Encode()
+	return URLPattern(&stripped)
+}
+
+// Endpoint of the parameters of u: its URLPattern without query
+func parameterEndpoint(u *url.URL) string {
+	endpoint := *u
+	endpoint.RawQuery = ""
+	endpoint.Fragment = ""
+	endpoint.RawFragment = ""
+	return URLPattern(&endpoint)
+}
+
+// Dedup key of a link before it's crawled, the link itself unless --normalize-urls is set
+func (crawler *Crawler) urlKey(urlString string) string {
+	if !crawler.opts.NormalizeURLs {
+		return urlString
+	}
+	u, err := url.Parse(urlString)
+	if err != nil {
+		return urlString
+	}
+	return NormalizeURL(u)
+}
+
+// Report the query parameter names of u not seen before on its endpoint with --params,
+// source is where u was found. Endpoints out of scope are left out.
+func (crawler *Crawler) findParameters(source string, u *url.URL) {
+	if !crawler.opts.Params || u.RawQuery == "" || !crawler.scope.InScope(u) {
+		return
+	}
+	var names []string
+	for name := range u.Query() {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	endpoint := parameterEndpoint(u)
+	for _, name := range names {
+		if crawler.paramSet.Duplicate(endpoint + " " + name) {
+			continue
+		}
+		outputFormat := fmt.Sprintf("[parameter] - [endpoint: %s] - %s", endpoint, name)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     source,
+			OutputType: "parameter",
+			Output:     name,
+			Details:    map[string]string{"endpoint": endpoint},
+		})
+	}
+}
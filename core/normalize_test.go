@@ -0,0 +1,96 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	tests := map[string]string{
+		"https://Example.com/user/17?tab=posts&utm_source=mail#top":                            "https://example.com/user/{int}?tab={}",
+		"https://example.com/user/42?gclid=abc&tab=likes":                                      "https://example.com/user/{int}?tab={}",
+		"https://example.com/order/0d8f2a9c-1e0b-4c1e-9a7b-2f3c4d5e6f70/items?sort=asc&page=2": "https://example.com/order/{uuid}/items?page={}&sort={}",
+		"https://example.com/about":                                                            "https://example.com/about",
+	}
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := NormalizeURL(u); got != want {
+			t.Errorf("NormalizeURL(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func TestCrawlerNormalizeURLs(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			for i := 1; i <= 10; i++ {
+				fmt.Fprintf(w, `<a href="/user/%d?tab=posts&utm_source=list">user</a>`, i)
+			}
+			fmt.Fprint(w, `<a href="/search?q=shoes&sort=price">search</a><a href="/search?q=hats&page=2">more</a>`)
+			fmt.Fprint(w, `<a href="https://other.example.org/track?id=1">partner</a>`)
+			fmt.Fprint(w, `<script>fetch("/api/items?limit=10&offset=0")</script>`)
+			return
+		}
+		fmt.Fprint(w, `<html>page</html>`)
+	}))
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL + "/")
+	findings := crawlForTest(t, site.String(), func(opts *Options) {
+		opts.Depth = 2
+		opts.NormalizeURLs = true
+		opts.Params = true
+	})
+	found := make(map[string]string)
+	for _, r := range findingsOf(findings, "parameter") {
+		found[r.Details["endpoint"]+" "+r.Output] = r.Source
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	users := 0
+	for path, n := range hits {
+		if strings.HasPrefix(path, "/user/") {
+			users += n
+		}
+	}
+	if users != 1 {
+		t.Errorf("%d user pages crawled, want 1 per template", users)
+	}
+	if hits["/search"] != 2 {
+		t.Errorf("search crawled %d times, its two URLs have other parameters", hits["/search"])
+	}
+
+	host := "http://" + site.Host
+	want := map[string]string{
+		host + "/user/{int} tab":        "",
+		host + "/user/{int} utm_source": "",
+		host + "/search q":              "",
+		host + "/search sort":           "",
+		host + "/search page":           "",
+		host + "/api/items limit":       ts.URL + "/",
+		host + "/api/items offset":      ts.URL + "/",
+	}
+	for key, source := range want {
+		got, ok := found[key]
+		if !ok {
+			t.Errorf("parameter %s not reported", key)
+		} else if source != "" && got != source {
+			t.Errorf("parameter %s found in %s, want %s", key, got, source)
+		}
+	}
+	if len(found) != len(want) {
+		t.Errorf("parameters %v", found)
+	}
+}
@@ -0,0 +1,453 @@
+package core
+
+import (
+	"context"
+	"github.com/spf13/cobra"
+	"strings"
+	"time"
+)
+
+// Options configures a Crawler. The CLI flags map one to one to these fields,
+// so gospider can be embedded in other Go programs without cobra.
+type Options struct {
+	// Crawl
+	Depth       int
+	Concurrent  int
+	Delay       time.Duration
+	RandomDelay time.Duration
+	Timeout     time.Duration
+	NoRedirect  bool
+	// RateLimit is the maximum number of requests per second of the site crawl, 0 for no limit.
+	// When set, hosts answering 429/503 are also backed off.
+	RateLimit float64
+	// Retries sends requests failing with a timeout, a connection reset or a 502/503/504 again
+	// this many times, with exponential backoff. TLS and unknown host errors aren't retried.
+	Retries int
+	// JSConcurrent and JSDelay limit the JavaScript fetches of the link finder across all hosts,
+	// apart from the crawl so they can't starve it. 0 uses Concurrent and Delay.
+	JSConcurrent int
+	JSDelay      time.Duration
+	// JSScope is the hosts the link finder fetches JavaScript files from, JSScopeSite (the
+	// default) or JSScopeAll. JSDomainWhitelist are domains (with their subdomains) fetched from
+	// in any scope, JSMaxDepth skips the files of pages deeper than it, 0 for no limit.
+	JSScope           string
+	JSDomainWhitelist []string
+	JSMaxDepth        int
+	// MaxJSFiles is the number of JavaScript files the link finder fetches, apart from MaxURLs, 0 for no limit
+	MaxJSFiles int
+	// SamplePerPattern crawls and reports only this many URLs of each URL pattern, the others
+	// are counted and reported per pattern at the end (see URLPattern), 0 to crawl everything
+	SamplePerPattern int
+	// DepthRules override Depth for matching URLs, in "depth:regex" format (Ex: "5:/api/")
+	DepthRules []string
+	// ContentParsers crawl the links of JSON, XML, RSS/Atom and sitemap responses,
+	// by Content-Type (see ContentParsers), nil to treat them as plain text
+	ContentParsers []string
+	// LinkFinderContent are the contents of responses LinkFinder scans for paths, by Content-Type
+	// (see LinkFinderContents): JavaScript, JSON, the inline scripts of HTML and text
+	LinkFinderContent []string
+	// CanonicalDedup skips the URL variants of the pages already crawled, as told by their
+	// <link rel="canonical">, see canonicalDedup
+	CanonicalDedup bool
+	// LocaleDedup crawls one locale variant of each page (/en/about, /fr/about, /about?lang=de),
+	// see localeDedup
+	LocaleDedup bool
+	// NormalizeURLs dedups the links found by their NormalizeURL template, so only one URL
+	// of each page type is crawled (Ex: /user/{int}?tab={})
+	NormalizeURLs bool
+	// Params reports every query parameter name of each endpoint as a parameter finding
+	Params bool
+	// CrawlForms submits GET forms with default values to discover parameterized endpoints
+	CrawlForms bool
+	// Resume is a state file the crawl progress is saved to and resumed from
+	Resume string
+	// ImportSnapshot is a snapshot file of the snapshot API replacing the progress saved in
+	// the Resume state of its sites before they're crawled
+	ImportSnapshot string
+	// Redis is the address of a Redis server (host:port or redis://[:password@]host:port[/db])
+	// the visited URLs, dedup filters and pending requests of the crawl are shared in, so
+	// instances on several machines crawl a site together, see sharedQueue
+	Redis string
+	// MaxURLs and MaxCrawlDuration stop the crawl of a site once reached, 0 for no limit
+	MaxURLs          int
+	MaxCrawlDuration time.Duration
+
+	// Request
+	Proxy     string // http://, https:// or socks5://, with user:pass@ for authentication
+	ProxyList string // File of proxies used in turn, it overrides Proxy
+	UserAgent string // "web", "mobi" or a custom User-Agent
+	Cookie    string
+	Headers   []string // "Key: Value"
+	Burp      string   // Burp raw request file to load headers and cookie from
+	// AuthMarker is a regex only authenticated pages match, pages without it are reported
+	AuthMarker string
+	// NoCookieJar disables keeping the cookies set by the site across requests
+	NoCookieJar bool
+	// LoginConfig is a YAML login script run before crawling and again when the session expires
+	LoginConfig string
+	// AuthBasic is "user:pass" sent as HTTP Basic authentication, AuthBearer a token sent as
+	// Bearer authentication. Both replace an Authorization header of Headers or Burp.
+	AuthBasic  string
+	AuthBearer string
+
+	// Scope
+	Blacklist         string
+	ExcludeSubdomains []string // Regexes of subdomains to skip
+	IncludeCIDRs      []string // IP ranges crawled in addition to the site domain
+	AllowPaths        []string // Path globs, when set only matching paths are crawled
+	DenyPaths         []string // Path globs never crawled
+	// OutOfScope is a file of hosts, CIDRs and path globs never requested, one per line
+	OutOfScope string
+	// ScopeFile is a Burp project options JSON, its target scope replaces the site domain scope
+	ScopeFile string
+	// WatchScope reloads OutOfScope and ScopeFile when they change during the crawl
+	WatchScope bool
+
+	// Transport
+	MaxIdleConns    int
+	MaxConnsPerHost int
+	IdleConnTimeout time.Duration
+	NoKeepAlive     bool
+	NoHTTP2         bool
+	TLSMinVersion   string
+	TLSMaxVersion   string
+	TLSCiphers      string
+	SNI             string
+	// ClientCert and ClientKey are the PEM certificate and key of mutual TLS,
+	// ClientKey may be empty when ClientCert has both
+	ClientCert string
+	ClientKey  string
+
+	// Rendering
+	Render     bool
+	RenderWait time.Duration
+	ChromePath string
+	// Folder of the screenshots of in-scope HTML pages, with a gallery per site
+	Screenshot string
+
+	// Probes
+	// Methods sends OPTIONS to every crawled endpoint and reports the allowed methods
+	Methods bool
+	// AcceptProbe re-requests API-looking endpoints with JSON/XML Accept headers
+	// and reports when they answer with another representation
+	AcceptProbe bool
+	// Misconfig checks every host once for TRACE/TRACK and exposed server status pages
+	Misconfig bool
+	// GitTree checks every host once for an exposed .git folder and reports the file paths
+	// of its repository, file contents are never downloaded
+	GitTree bool
+	// MIMEStats reports the content types each host answered with, and the responses whose
+	// content type doesn't fit their path or body as anomaly findings, see MIMEAnomaly
+	MIMEStats bool
+	// RestrictedAreas reports the subtrees where every URL answered 401 or 403 as one
+	// restricted-area finding instead of a url finding per URL, see restrictedAreas
+	RestrictedAreas bool
+	// JSLibs reports the versions of the JavaScript libraries found (jQuery, lodash, AngularJS...),
+	// JSLibVulns also their known vulnerabilities (implies JSLibs), see JSLibrary
+	JSLibs     bool
+	JSLibVulns bool
+	// JSFingerprints reports the SHA-256, endpoints and secrets of every JavaScript file fetched,
+	// with Diff the files changed since the previous run too, see fingerprintJS
+	JSFingerprints bool
+	// Archives lists the files of the zip, tar and tar.gz archives crawled and analyzes their text files
+	Archives bool
+	// MaxResponseSize cuts response bodies after this many bytes, the URL is still reported
+	// and the part read scanned, 0 for no limit
+	MaxResponseSize int
+	// HandlerTimeout is the time the handlers of a response (scanners, probes) may hold a
+	// collector worker, the ones taking longer are reported and left to finish, up to
+	// Concurrent at once, 0 for no limit
+	HandlerTimeout time.Duration
+	// MaxArchiveSize is the size in bytes of the largest archive inspected, 0 for no limit
+	MaxArchiveSize int
+	// APIDiscovery probes every host once for GraphQL endpoints and OpenAPI/Swagger documents
+	APIDiscovery bool
+	// CrawlAPIDocs crawls the GET endpoints of OpenAPI/Swagger documents found
+	CrawlAPIDocs bool
+	// BucketListing fetches the listing of S3/GCS buckets found in responses when it's in scope
+	BucketListing bool
+	// CheckBuckets probes whether the S3/GCS/Azure buckets found exist and can be listed
+	// or written anonymously, the write check uploads a small object and deletes it
+	CheckBuckets bool
+
+	// Detection
+	// Secrets scans responses for secrets with the built-in rules
+	Secrets bool
+	// SecretRules is a YAML file of extra secret rules, it implies Secrets
+	SecretRules string
+	// VerifyGoogleKeys checks which Google APIs found keys can call, it implies Secrets
+	VerifyGoogleKeys bool
+	// RulesDir is a folder of rule files replacing the built-in ones of the same name
+	RulesDir string
+	// Custom values reported as [custom:name] findings, name:regex and name:css items
+	// (Ex: "email:[\w.+-]+@example\.com", "csrf:meta[name=csrf-token]")
+	ExtractRules     []string
+	ExtractSelectors []string
+	// ExtractConfig is a YAML file of extract rules, see ExtractRule
+	ExtractConfig string
+
+	// Seed sources
+	// SeedFile holds paths and URLs crawled next to each site, see SeedURLs
+	SeedFile           string
+	Sitemap            bool
+	Robots             bool
+	OtherSource        bool
+	IncludeSubs        bool
+	IncludeOtherSource bool
+	Subs               bool
+	CrawlSubs          bool
+	CrawlSubsLimit     int
+	// ResolveSubs resolves and probes the subdomains found, they are reported with their addresses and liveness
+	ResolveSubs bool
+	// Resolvers are the DNS servers used for the whole crawl, a file or a comma separated list of IP[:port]
+	Resolvers string
+	// SourceIPs are local addresses the connections are made from in turn (Ex: "10.0.0.2")
+	SourceIPs []string
+
+	// Output
+	OutputFolder string
+	// SplitOutput also writes the values of the main findings to per category files
+	// in <OutputFolder>/<hostname>/, see SplitOutput
+	SplitOutput bool
+	// Compress is "gzip" or "zstd" to compress the output files and the saved responses,
+	// their names get a .gz or .zst extension
+	Compress string
+	// Redact masks the values of secrets, API keys, emails and JWTs in every output, the
+	// unmasked findings are written to <OutputFolder>/<filename>-sensitive.jsonl (mode 0600)
+	Redact bool
+	// EncryptOutput is an OpenPGP public key file, the output folder is replaced by a tar.gz
+	// encrypted to its keys once the run is over, see EncryptFolder
+	EncryptOutput string
+	// PolitenessReport writes the requests and bytes per host over time, robots.txt directives
+	// not followed and crawl-delay violations to <OutputFolder>/<filename>-politeness.json
+	PolitenessReport bool
+	// OutputSinks are extra destinations of the findings, in kind=target format
+	// (Ex: webhook=https://example.com/hook, kafka=localhost:9092/topic, elasticsearch=http://localhost:9200/index)
+	OutputSinks []string
+	JSON        bool
+	// Diff is the JSON lines output of a previous run, only the URLs, subdomains, JavaScript files
+	// and secrets it didn't find are reported, then the ones not found again (see diffTypes)
+	Diff string
+	// SaveResponses is the folder to store raw requests and responses in
+	SaveResponses string
+	// WordlistOutput is the folder to write wordlists of the path segments, file names,
+	// parameter names and values found by all the crawls to, see Wordlist
+	WordlistOutput string
+	// ExportBurp is where to write the requests of the endpoints found for replay in a proxy,
+	// a Burp items XML file when it ends with .xml, a folder of raw requests otherwise
+	ExportBurp string
+	// Title shows the page title in plain url findings, JSON records always have it
+	Title bool
+	// Response headers to include in url findings (JSON mode), nil to disable
+	CaptureHeaders []string
+	// Responses not reported as url findings, they are still crawled.
+	// Codes and lengths are numbers or ranges (Ex: "404", "500-599", "1000000-").
+	FilterCodes        []string
+	MatchCodes         []string // When set, only these status codes are reported
+	FilterLengths      []string
+	FilterContentTypes []string // Media types or prefixes (Ex: "image/")
+	FilterRegex        string   // Regex of response bodies not to report (Ex: soft 404 pages)
+	// ReportErrors are the 404, 429 and 5xx status codes or ranges reported as url findings,
+	// by default these answers are dropped
+	ReportErrors []string
+	// MetricsAddr is the address to serve the crawl stats on in the Prometheus format (Ex: ":9090")
+	MetricsAddr string
+	// ControlAddr is the address to serve the endpoints changing the running crawls on, the
+	// loopback one without a host (Ex: ":9091"), see ServeControl
+	ControlAddr string
+	// ControlToken is the bearer token the control endpoints require, ${NAME} references
+	// are replaced with the environment variables
+	ControlToken string
+	// Quiet disables printing findings to stdout
+	Quiet bool
+	// OnResult is called with every finding, it must be safe for concurrent use
+	OnResult func(SpiderOutput) `json:"-"`
+	// Context stops the crawls gracefully when it's done, in flight requests finish
+	// and their findings are written. Sites not started yet are skipped.
+	Context context.Context `json:"-"`
+}
+
+// DefaultOptions returns the Options used by the CLI when no flag is set
+func DefaultOptions() Options {
+	return Options{
+		Depth:             1,
+		Concurrent:        5,
+		Timeout:           10 * time.Second,
+		UserAgent:         "web",
+		MaxIdleConns:      100,
+		MaxConnsPerHost:   1000,
+		IdleConnTimeout:   30 * time.Second,
+		RenderWait:        2 * time.Second,
+		Robots:            true,
+		CrawlSubsLimit:    10,
+		MaxResponseSize:   10 * 1024 * 1024,
+		HandlerTimeout:    time.Minute,
+		MaxArchiveSize:    5 * 1024 * 1024,
+		ContentParsers:    append([]string(nil), ContentParsers...),
+		LinkFinderContent: append([]string(nil), LinkFinderContents...),
+	}
+}
+
+// OptionsFromCommand reads Options from the gospider command flags
+func OptionsFromCommand(cmd *cobra.Command) Options {
+	flags := cmd.Flags()
+	var opts Options
+
+	opts.Depth, _ = flags.GetInt("depth")
+	opts.DepthRules, _ = flags.GetStringArray("depth-rule")
+	opts.Concurrent, _ = flags.GetInt("concurrent")
+	delay, _ := flags.GetInt("delay")
+	opts.Delay = time.Duration(delay) * time.Second
+	opts.JSConcurrent, _ = flags.GetInt("js-concurrent")
+	jsDelay, _ := flags.GetInt("js-delay")
+	opts.JSDelay = time.Duration(jsDelay) * time.Second
+	opts.MaxJSFiles, _ = flags.GetInt("max-js-files")
+	opts.JSScope, _ = flags.GetString("js-scope")
+	opts.JSDomainWhitelist = splitFlagList(flags.GetString("js-domain-whitelist"))
+	opts.JSMaxDepth, _ = flags.GetInt("js-max-depth")
+	opts.SamplePerPattern, _ = flags.GetInt("sample-per-pattern")
+	if parsers := splitFlagList(flags.GetString("content-parsers")); len(parsers) != 1 || parsers[0] != "none" {
+		opts.ContentParsers = parsers
+	}
+	if contents := splitFlagList(flags.GetString("linkfinder-content")); len(contents) != 1 || contents[0] != "none" {
+		opts.LinkFinderContent = contents
+	}
+	randomDelay, _ := flags.GetInt("random-delay")
+	opts.RandomDelay = time.Duration(randomDelay) * time.Second
+	timeout, _ := flags.GetInt("timeout")
+	opts.Timeout = time.Duration(timeout) * time.Second
+	opts.NoRedirect, _ = flags.GetBool("no-redirect")
+	opts.RateLimit, _ = flags.GetFloat64("rate-limit")
+	opts.Retries, _ = flags.GetInt("retries")
+	opts.CrawlForms, _ = flags.GetBool("crawl-forms")
+	opts.CanonicalDedup, _ = flags.GetBool("canonical-dedup")
+	opts.LocaleDedup, _ = flags.GetBool("locale-dedup")
+	opts.NormalizeURLs, _ = flags.GetBool("normalize-urls")
+	opts.Params, _ = flags.GetBool("params")
+	opts.Resume, _ = flags.GetString("resume")
+	opts.ImportSnapshot, _ = flags.GetString("import-snapshot")
+	opts.Redis, _ = flags.GetString("redis")
+	opts.MaxURLs, _ = flags.GetInt("max-urls")
+	maxCrawlDuration, _ := flags.GetInt("max-crawl-duration")
+	opts.MaxCrawlDuration = time.Duration(maxCrawlDuration) * time.Second
+
+	opts.Proxy, _ = flags.GetString("proxy")
+	opts.ProxyList, _ = flags.GetString("proxy-list")
+	opts.UserAgent, _ = flags.GetString("user-agent")
+	opts.Cookie, _ = flags.GetString("cookie")
+	opts.Headers, _ = flags.GetStringArray("header")
+	opts.Burp, _ = flags.GetString("burp")
+	opts.AuthMarker, _ = flags.GetString("auth-marker")
+	opts.NoCookieJar, _ = flags.GetBool("no-cookie-jar")
+	opts.LoginConfig, _ = flags.GetString("login-config")
+	opts.AuthBasic, _ = flags.GetString("auth-basic")
+	opts.AuthBearer, _ = flags.GetString("auth-bearer")
+
+	opts.Blacklist, _ = flags.GetString("blacklist")
+	opts.ExcludeSubdomains, _ = flags.GetStringArray("exclude-subdomain")
+	opts.IncludeCIDRs, _ = flags.GetStringArray("include-cidr")
+	opts.AllowPaths, _ = flags.GetStringArray("allow-path")
+	opts.DenyPaths, _ = flags.GetStringArray("deny-path")
+	opts.OutOfScope, _ = flags.GetString("out-of-scope")
+	opts.ScopeFile, _ = flags.GetString("scope-file")
+	opts.WatchScope, _ = flags.GetBool("watch-scope")
+
+	opts.MaxIdleConns, _ = flags.GetInt("max-idle-conns")
+	opts.MaxConnsPerHost, _ = flags.GetInt("max-conns-per-host")
+	idleConnTimeout, _ := flags.GetInt("idle-conn-timeout")
+	opts.IdleConnTimeout = time.Duration(idleConnTimeout) * time.Second
+	opts.NoKeepAlive, _ = flags.GetBool("no-keep-alive")
+	opts.NoHTTP2, _ = flags.GetBool("no-http2")
+	opts.TLSMinVersion, _ = flags.GetString("tls-min-version")
+	opts.TLSMaxVersion, _ = flags.GetString("tls-max-version")
+	opts.TLSCiphers, _ = flags.GetString("tls-ciphers")
+	opts.SNI, _ = flags.GetString("sni")
+	opts.ClientCert, _ = flags.GetString("client-cert")
+	opts.ClientKey, _ = flags.GetString("client-key")
+
+	opts.Render, _ = flags.GetBool("render")
+	renderWait, _ := flags.GetInt("render-wait")
+	opts.RenderWait = time.Duration(renderWait) * time.Second
+	opts.ChromePath, _ = flags.GetString("chrome-path")
+	opts.Screenshot, _ = flags.GetString("screenshot")
+
+	opts.Methods, _ = flags.GetBool("methods")
+	opts.AcceptProbe, _ = flags.GetBool("accept-probe")
+	opts.Misconfig, _ = flags.GetBool("misconfig")
+	opts.MIMEStats, _ = flags.GetBool("mime-stats")
+	opts.RestrictedAreas, _ = flags.GetBool("restricted-areas")
+	opts.JSLibs, _ = flags.GetBool("js-libs")
+	opts.JSLibVulns, _ = flags.GetBool("js-lib-vulns")
+	opts.JSFingerprints, _ = flags.GetBool("js-fingerprints")
+	opts.GitTree, _ = flags.GetBool("git-tree")
+	opts.Archives, _ = flags.GetBool("archives")
+	maxResponseSize, _ := flags.GetInt("max-response-size")
+	opts.MaxResponseSize = maxResponseSize * 1024
+	handlerTimeout, _ := flags.GetInt("handler-timeout")
+	opts.HandlerTimeout = time.Duration(handlerTimeout) * time.Second
+	maxArchiveSize, _ := flags.GetInt("max-archive-size")
+	opts.MaxArchiveSize = maxArchiveSize * 1024
+	opts.APIDiscovery, _ = flags.GetBool("api-discovery")
+	opts.CrawlAPIDocs, _ = flags.GetBool("crawl-api-docs")
+	opts.BucketListing, _ = flags.GetBool("bucket-listing")
+	opts.CheckBuckets, _ = flags.GetBool("check-buckets")
+
+	opts.Secrets, _ = flags.GetBool("secrets")
+	opts.SecretRules, _ = flags.GetString("secret-rules")
+	opts.VerifyGoogleKeys, _ = flags.GetBool("verify-google-keys")
+	opts.RulesDir, _ = flags.GetString("rules-dir")
+	opts.ExtractRules, _ = flags.GetStringArray("extract-rule")
+	opts.ExtractSelectors, _ = flags.GetStringArray("extract-selector")
+	opts.ExtractConfig, _ = flags.GetString("extract-config")
+
+	opts.SeedFile, _ = flags.GetString("seed-file")
+	opts.Sitemap, _ = flags.GetBool("sitemap")
+	opts.Robots, _ = flags.GetBool("robots")
+	opts.OtherSource, _ = flags.GetBool("other-source")
+	opts.IncludeSubs, _ = flags.GetBool("include-subs")
+	opts.IncludeOtherSource, _ = flags.GetBool("include-other-source")
+	opts.Subs, _ = flags.GetBool("subs")
+	opts.CrawlSubs, _ = flags.GetBool("crawl-subs")
+	opts.CrawlSubsLimit, _ = flags.GetInt("crawl-subs-limit")
+	opts.ResolveSubs, _ = flags.GetBool("resolve-subs")
+	opts.Resolvers, _ = flags.GetString("resolvers")
+	opts.SourceIPs, _ = flags.GetStringArray("source-ip")
+
+	opts.OutputFolder, _ = flags.GetString("output")
+	opts.SplitOutput, _ = flags.GetBool("split-output")
+	opts.Compress, _ = flags.GetString("compress")
+	opts.Redact, _ = flags.GetBool("redact")
+	opts.EncryptOutput, _ = flags.GetString("encrypt-output")
+	opts.PolitenessReport, _ = flags.GetBool("politeness-report")
+	opts.OutputSinks, _ = flags.GetStringArray("output-sink")
+	opts.JSON, _ = flags.GetBool("json")
+	opts.Diff, _ = flags.GetString("diff")
+	opts.SaveResponses, _ = flags.GetString("save-responses")
+	opts.WordlistOutput, _ = flags.GetString("wordlist-output")
+	opts.ExportBurp, _ = flags.GetString("export-burp")
+	opts.Title, _ = flags.GetBool("title")
+	opts.FilterCodes = splitFlagList(flags.GetString("filter-code"))
+	opts.MatchCodes = splitFlagList(flags.GetString("match-code"))
+	opts.FilterLengths = splitFlagList(flags.GetString("filter-length"))
+	opts.FilterContentTypes = splitFlagList(flags.GetString("filter-content-type"))
+	opts.FilterRegex, _ = flags.GetString("filter-regex")
+	opts.ReportErrors = splitFlagList(flags.GetString("report-errors"))
+	opts.MetricsAddr, _ = flags.GetString("metrics-addr")
+	opts.ControlAddr, _ = flags.GetString("control-addr")
+	opts.ControlToken, _ = flags.GetString("control-token")
+	if captureHeaders, _ := flags.GetBool("capture-headers"); captureHeaders {
+		opts.CaptureHeaders = splitFlagList(flags.GetString("capture-header-names"))
+	}
+	return opts
+}
+
+// Split a comma separated flag value, empty items are dropped
+func splitFlagList(value string, _ error) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -10,23 +10,40 @@ import (
 	"sync"
 )
 
+// Fetchers of the 3rd party sources queried by OtherSourcesWithSource, in order
+var otherSourceFetchers = []fetchFn{
+	getWaybackURLs,
+	getCommonCrawlURLs,
+	getVirusTotalURLs,
+	getOtxUrls,
+}
+
+// OtherSourceURL is a URL found by a 3rd party source
+type OtherSourceURL struct {
+	Source string // wayback, commoncrawl, virustotal or otx
+	URL    string
+}
+
 func OtherSources(domain string, includeSubs bool) []string {
+	var urls []string
+	for _, u := range OtherSourcesWithSource(domain, includeSubs) {
+		urls = append(urls, u.URL)
+	}
+	return urls
+}
+
+// OtherSourcesWithSource is like OtherSources but keeps which source found each URL
+func OtherSourcesWithSource(domain string, includeSubs bool) []OtherSourceURL {
 	noSubs := true
 	if includeSubs {
 		noSubs = false
 	}
-	var urls []string
-
-	fetchFns := []fetchFn{
-		getWaybackURLs,
-		getCommonCrawlURLs,
-		getVirusTotalURLs,
-		getOtxUrls,
-	}
+	var urls []OtherSourceURL
+	seen := make(map[string]bool)
 
 	var wg sync.WaitGroup
 
-	for _, fn := range fetchFns {
+	for _, fn := range otherSourceFetchers {
 		wUrlChan := make(chan wurl)
 		wg.Add(1)
 		fetch := fn
@@ -47,15 +64,20 @@ func OtherSources(domain string, includeSubs bool) []string {
 		}()
 
 		for w := range wUrlChan {
-			urls = append(urls, w.url)
+			if seen[w.url] {
+				continue
+			}
+			seen[w.url] = true
+			urls = append(urls, OtherSourceURL{Source: w.source, URL: w.url})
 		}
 	}
-	return Unique(urls)
+	return urls
 }
 
 type wurl struct {
-	date string
-	url  string
+	date   string
+	url    string
+	source string
 }
 
 type fetchFn func(string, bool) ([]wurl, error)
@@ -92,7 +114,7 @@ func getWaybackURLs(domain string, noSubs bool) ([]wurl, error) {
 			skip = false
 			continue
 		}
-		out = append(out, wurl{date: urls[1], url: urls[2]})
+		out = append(out, wurl{date: urls[1], url: urls[2], source: "wayback"})
 	}
 
 	return out, nil
@@ -127,7 +149,7 @@ func getCommonCrawlURLs(domain string, noSubs bool) ([]wurl, error) {
 			continue
 		}
 
-		out = append(out, wurl{date: wrapper.Timestamp, url: wrapper.URL})
+		out = append(out, wurl{date: wrapper.Timestamp, url: wrapper.URL, source: "commoncrawl"})
 	}
 
 	return out, nil
@@ -166,7 +188,7 @@ func getVirusTotalURLs(domain string, noSubs bool) ([]wurl, error) {
 	err = dec.Decode(&wrapper)
 
 	for _, u := range wrapper.URLs {
-		out = append(out, wurl{url: u.URL})
+		out = append(out, wurl{url: u.URL, source: "virustotal"})
 	}
 
 	return out, nil
@@ -204,7 +226,7 @@ func getOtxUrls(domain string, noSubs bool) ([]wurl, error) {
 			return []wurl{}, err
 		}
 		for _, url := range wrapper.URLList {
-			urls = append(urls, wurl{url: url.URL})
+			urls = append(urls, wurl{url: url.URL, source: "otx"})
 		}
 		if !wrapper.HasNext {
 			break
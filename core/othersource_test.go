@@ -1,6 +1,18 @@
 package core
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 var domain = "yahoo.com"
 
@@ -45,3 +57,56 @@ func TestGetOtxUrls(t *testing.T) {
 	t.Log(len(urls))
 	t.Log(urls)
 }
+
+func TestOtherSourcesTagging(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html>page</html>`)
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "gospider-othersource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Offline sources, a URL found by several is tagged with the first one
+	fetchers := otherSourceFetchers
+	defer func() { otherSourceFetchers = fetchers }()
+	otherSourceFetchers = []fetchFn{
+		func(string, bool) ([]wurl, error) {
+			return []wurl{{url: ts.URL + "/old", source: "wayback"}, {url: ts.URL + "/shared", source: "wayback"}}, nil
+		},
+		func(string, bool) ([]wurl, error) {
+			return nil, errors.New("rate limited")
+		},
+		func(string, bool) ([]wurl, error) {
+			return []wurl{{url: ts.URL + "/shared", source: "otx"}, {url: ts.URL + "/api", source: "otx"}}, nil
+		},
+	}
+
+	findings := crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.OtherSource = true
+		opts.IncludeOtherSource = true
+		opts.OutputFolder = dir
+	})
+
+	want := map[string]string{"/old": "wayback", "/shared": "wayback", "/api": "otx"}
+	got := make(map[string]string)
+	for _, r := range findingsOf(findings, "other-sources") {
+		got[strings.TrimPrefix(r.Output, ts.URL)] = r.Source
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("other-sources findings = %v, want %v", got, want)
+	}
+	site, _ := url.Parse(ts.URL)
+	output, _ := ioutil.ReadFile(filepath.Join(dir, HostFileName(site.Host)))
+	for path, source := range want {
+		if line := fmt.Sprintf("[%s] - %s%s\n", source, ts.URL, path); !strings.Contains(string(output), line) {
+			t.Errorf("%q not written, got:\n%s", line, output)
+		}
+	}
+	if urls := outputsOf(findings, "url"); !urls[ts.URL+"/api"] {
+		t.Errorf("URL of a source not crawled, got %v", urls)
+	}
+}
@@ -1,34 +1,147 @@
 package core
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
 )
 
+// SpiderOutput is the structured record of a finding used in JSON mode
+type SpiderOutput struct {
+	Input      string `json:"input"`
+	Source     string `json:"source"`
+	OutputType string `json:"type"`
+	Output     string `json:"output"`
+	StatusCode int    `json:"status,omitempty"`
+	Length     int    `json:"length,omitempty"`
+	Title      string `json:"title,omitempty"`
+	// Rule, check or service that matched, for secret, misconfig, backend-config and aws-identity findings
+	Rule string `json:"rule,omitempty"`
+	// Methods allowed by the endpoint, for methods findings
+	Methods []string `json:"methods,omitempty"`
+	// Object keys seen in the bucket, for bucket-objects findings
+	Objects []string `json:"objects,omitempty"`
+	// Extra fields specific to the finding type
+	Details map[string]string `json:"details,omitempty"`
+	// Response headers selected by Options.CaptureHeaders
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Output is the sink writing findings to a per site file
 type Output struct {
 	mu sync.Mutex
-	f  *os.File
+	f  io.WriteCloser
+}
+
+func NewOutput(folder, filename string) (*Output, error) {
+	return NewCompressedOutput(folder, filename, "")
 }
 
-func NewOutput(folder, filename string) *Output {
-	outFile := filepath.Join(folder, filename)
-	f, err := os.OpenFile(outFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+// NewCompressedOutput writes the findings to filename compressed with algo (gzip or zstd),
+// the file name gets the extension of the compression
+func NewCompressedOutput(folder, filename, algo string) (*Output, error) {
+	outFile := filepath.Join(folder, filename) + CompressExt(algo)
+	f, err := openOutputFile(outFile, os.ModePerm, algo)
 	if err != nil {
-		Logger.Errorf("Failed to open file to write Output: %s", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to open file to write Output: %s", err)
 	}
 	return &Output{
 		f: f,
-	}
+	}, nil
 }
 
 func (o *Output) WriteToFile(msg string) {
+	_ = o.Write(SpiderOutput{}, msg)
+}
+
+func (o *Output) Write(_ SpiderOutput, line string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err := io.WriteString(o.f, line+"\n")
+	return err
+}
+
+func (o *Output) Close() error {
+	return o.f.Close()
+}
+
+// Files of the split output by finding type, the findings of the other types are only in the combined file
+var splitOutputFiles = map[string]string{
+	"url":            "urls.txt",
+	"sitemap":        "urls.txt",
+	"robots":         "urls.txt",
+	"other-sources":  "urls.txt",
+	"xhr":            "urls.txt",
+	"subdomains":     "subdomains.txt",
+	"cert-subdomain": "subdomains.txt",
+	"javascript":     "js.txt",
+	"form":           "forms.txt",
+	"upload-form":    "forms.txt",
+	"secret":         "secrets.txt",
+	"aws-s3":         "aws.txt",
+	"aws-identity":   "aws.txt",
+}
+
+// SplitOutput is the sink writing the value of each finding once to the file of its
+// category (urls.txt, subdomains.txt, js.txt, forms.txt, secrets.txt, aws.txt) in a
+// per site folder, files are created with their first finding
+type SplitOutput struct {
+	folder string
+	// Compression of the files, see Options.Compress
+	compress string
+
+	mu    sync.Mutex
+	files map[string]io.WriteCloser
+	seen  map[string]bool
+}
+
+func NewSplitOutput(folder string) (*SplitOutput, error) {
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create split output folder: %s", err)
+	}
+	return &SplitOutput{
+		folder: folder,
+		files:  make(map[string]io.WriteCloser),
+		seen:   make(map[string]bool),
+	}, nil
+}
+
+func (o *SplitOutput) Write(record SpiderOutput, _ string) error {
+	name := splitOutputFiles[record.OutputType]
+	if name == "" || record.Output == "" {
+		return nil
+	}
 	o.mu.Lock()
 	defer o.mu.Unlock()
-	_, _ = o.f.WriteString(msg + "\n")
+	key := name + "|" + record.Output
+	if o.seen[key] {
+		return nil
+	}
+	o.seen[key] = true
+
+	f, ok := o.files[name]
+	if !ok {
+		var err error
+		f, err = openOutputFile(filepath.Join(o.folder, name)+CompressExt(o.compress), os.ModePerm, o.compress)
+		if err != nil {
+			return fmt.Errorf("failed to open file to write Output: %s", err)
+		}
+		o.files[name] = f
+	}
+	_, err := io.WriteString(f, record.Output+"\n")
+	return err
 }
 
-func (o *Output) Close() {
-	o.f.Close()
+func (o *SplitOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var first error
+	for _, f := range o.files {
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
 }
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o, err := NewSplitOutput(filepath.Join(dir, "example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []SpiderOutput{
+		{OutputType: "url", Output: "https://example.com/"},
+		{OutputType: "sitemap", Output: "https://example.com/about"},
+		{OutputType: "url", Output: "https://example.com/"},
+		{OutputType: "subdomains", Output: "api.example.com"},
+		{OutputType: "javascript", Output: "https://example.com/app.js"},
+		{OutputType: "linkfinder", Output: "/api/v1"},
+	} {
+		if err := o.Write(record, "[line]"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := o.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"urls.txt":       "https://example.com/\nhttps://example.com/about\n",
+		"subdomains.txt": "api.example.com\n",
+		"js.txt":         "https://example.com/app.js\n",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "example.com", name))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v, want %q", name, data, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "example.com", "secrets.txt")); !os.IsNotExist(err) {
+		t.Error("file created without findings")
+	}
+}
+
+func TestCrawlerJSONOutput(t *testing.T) {
+	const home = `<html><title>Home</title><a href="/about">about</a><script src="/app.js"></script></html>`
+	const about = `<html>about</html>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, home)
+			return
+		}
+		fmt.Fprint(w, about)
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "gospider-json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	findings := crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.JSON = true
+		opts.OutputFolder = dir
+	})
+
+	site, _ := url.Parse(ts.URL)
+	data, err := ioutil.ReadFile(filepath.Join(dir, HostFileName(site.Host)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != len(findings) {
+		t.Fatalf("%d lines written for %d findings:\n%s", len(lines), len(findings), data)
+	}
+	records := make(map[string]map[string]interface{})
+	for _, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line %q is not JSON: %s", line, err)
+		}
+		records[fmt.Sprint(record["type"], " ", record["output"])] = record
+	}
+
+	// Numbers are decoded as float64, fields left empty are omitted
+	want := map[string]map[string]interface{}{
+		"url " + ts.URL + "/": {
+			"input": ts.URL + "/", "source": ts.URL + "/", "type": "url", "output": ts.URL + "/",
+			"status": float64(200), "length": float64(len(home)), "title": "Home",
+		},
+		"url " + ts.URL + "/about": {
+			"input": ts.URL + "/", "source": ts.URL + "/about", "type": "url", "output": ts.URL + "/about",
+			"status": float64(200), "length": float64(len(about)),
+		},
+		"javascript " + ts.URL + "/app.js": {
+			"input": ts.URL + "/", "source": ts.URL + "/", "type": "javascript", "output": ts.URL + "/app.js",
+		},
+	}
+	for key, fields := range want {
+		if !reflect.DeepEqual(records[key], fields) {
+			t.Errorf("record %s = %v, want %v", key, records[key], fields)
+		}
+	}
+}
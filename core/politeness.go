@@ -0,0 +1,258 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/temoto/robotstxt"
+)
+
+const (
+	// Size of the robots.txt read for the politeness report
+	maxPolitenessRobotsSize = 512 * 1024
+	// Disallowed URLs listed per host in the politeness report
+	maxDisallowedSamples = 20
+)
+
+// PolitenessReport tells how hard a crawl hit each host, for the owners of the site and
+// to show an engagement kept to its limits. Every request on the wire is counted,
+// probes, retries and robots.txt fetches included.
+type PolitenessReport struct {
+	Site     string    `json:"site"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Requests int       `json:"requests"`
+	// Bytes of the request and response headers and bodies, bodies as read by the client
+	// (decompressed)
+	BytesSent     int64            `json:"bytes_sent"`
+	BytesReceived int64            `json:"bytes_received"`
+	Hosts         []HostPoliteness `json:"hosts"`
+}
+
+// HostPoliteness is the traffic of a crawl to one host
+type HostPoliteness struct {
+	Host          string `json:"host"`
+	Requests      int    `json:"requests"`
+	BytesSent     int64  `json:"bytes_sent"`
+	BytesReceived int64  `json:"bytes_received"`
+	// Highest number of requests sent within one second
+	PeakRequestsPerSecond int `json:"peak_requests_per_second"`
+	// Requests per 10 seconds interval from the first request to the host
+	Rate []RateSample `json:"rate"`
+	// Requests to URLs robots.txt disallows for the User-Agent sent, with a sample of them
+	RobotsDisallowed int      `json:"robots_disallowed"`
+	DisallowedURLs   []string `json:"disallowed_urls,omitempty"`
+	// Crawl-delay of robots.txt in seconds, and requests sent sooner than it after the previous one
+	CrawlDelay           float64 `json:"crawl_delay,omitempty"`
+	CrawlDelayViolations int     `json:"crawl_delay_violations"`
+}
+
+// politenessTransport counts the requests and bytes of every request sent to the hosts
+// of a crawl, and checks them against the robots.txt of the host
+type politenessTransport struct {
+	base http.RoundTripper
+
+	mu     sync.Mutex
+	report PolitenessReport
+	hosts  map[string]*hostTraffic
+}
+
+type hostTraffic struct {
+	HostPoliteness
+	first time.Time
+	last  time.Time
+	// Requests of the last second, to find the peak
+	recent []time.Time
+	// robots.txt of the host, fetched once
+	robotsOnce sync.Once
+	robots     *robotstxt.RobotsData
+}
+
+func newPolitenessTransport(base http.RoundTripper, site string) *politenessTransport {
+	return &politenessTransport{
+		base:   base,
+		report: PolitenessReport{Site: site, Start: time.Now()},
+		hosts:  make(map[string]*hostTraffic),
+	}
+}
+
+func (t *politenessTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := t.host(req.URL.Host)
+	host.robotsOnce.Do(func() {
+		host.robots = t.fetchRobots(req)
+	})
+	t.record(host, req)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.received(host, headerSize(resp.Header)+int64(len(resp.Status)))
+	resp.Body = &countingBody{ReadCloser: resp.Body, count: func(n int) {
+		t.received(host, int64(n))
+	}}
+	return resp, nil
+}
+
+func (t *politenessTransport) host(name string) *hostTraffic {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	host, ok := t.hosts[name]
+	if !ok {
+		host = &hostTraffic{HostPoliteness: HostPoliteness{Host: name}}
+		t.hosts[name] = host
+	}
+	return host
+}
+
+// Count a request about to be sent, rate and robots directives included
+func (t *politenessTransport) record(host *hostTraffic, req *http.Request) {
+	now := time.Now()
+	sent := int64(len(req.Method)+len(req.URL.RequestURI())) + headerSize(req.Header)
+	if req.ContentLength > 0 {
+		sent += req.ContentLength
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.report.Requests++
+	t.report.BytesSent += sent
+	host.Requests++
+	host.BytesSent += sent
+
+	if host.first.IsZero() {
+		host.first = now
+	}
+	slot := int(now.Sub(host.first) / statsInterval)
+	for len(host.Rate) <= slot {
+		host.Rate = append(host.Rate, RateSample{Time: host.first.Add(time.Duration(len(host.Rate)) * statsInterval)})
+	}
+	host.Rate[slot].Requests++
+
+	recent := host.recent[:0]
+	for _, at := range host.recent {
+		if now.Sub(at) < time.Second {
+			recent = append(recent, at)
+		}
+	}
+	host.recent = append(recent, now)
+	if len(host.recent) > host.PeakRequestsPerSecond {
+		host.PeakRequestsPerSecond = len(host.recent)
+	}
+
+	if host.robots != nil {
+		agent := req.Header.Get("User-Agent")
+		if !host.robots.TestAgent(req.URL.RequestURI(), agent) {
+			host.RobotsDisallowed++
+			if len(host.DisallowedURLs) < maxDisallowedSamples {
+				host.DisallowedURLs = append(host.DisallowedURLs, req.URL.String())
+			}
+		}
+		if group := host.robots.FindGroup(agent); group != nil && group.CrawlDelay > 0 {
+			host.CrawlDelay = group.CrawlDelay.Seconds()
+			if !host.last.IsZero() && now.Sub(host.last) < group.CrawlDelay {
+				host.CrawlDelayViolations++
+			}
+		}
+	}
+	host.last = now
+}
+
+func (t *politenessTransport) received(host *hostTraffic, n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.report.BytesReceived += n
+	host.BytesReceived += n
+}
+
+// Fetch the robots.txt of the host of req with its User-Agent, nil when there is none.
+// The fetch is counted like the crawl requests.
+func (t *politenessTransport) fetchRobots(req *http.Request) *robotstxt.RobotsData {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	robotsReq, err := http.NewRequestWithContext(ctx, "GET", req.URL.Scheme+"://"+req.URL.Host+"/robots.txt", nil)
+	if err != nil {
+		return nil
+	}
+	robotsReq.Header.Set("User-Agent", req.Header.Get("User-Agent"))
+	host := t.host(req.URL.Host)
+	t.record(host, robotsReq)
+	resp, err := t.base.RoundTrip(robotsReq)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxPolitenessRobotsSize))
+	t.received(host, headerSize(resp.Header)+int64(len(resp.Status)+len(body)))
+	robots, err := robotstxt.FromStatusAndBytes(resp.StatusCode, body)
+	if err != nil {
+		return nil
+	}
+	return robots
+}
+
+// Report returns the traffic so far, hosts by most requests first
+func (t *politenessTransport) Report() PolitenessReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := t.report
+	report.End = time.Now()
+	report.Hosts = nil
+	for _, host := range t.hosts {
+		h := host.HostPoliteness
+		h.Rate = append([]RateSample(nil), host.Rate...)
+		for i := range h.Rate {
+			h.Rate[i].RequestsPerSecond = float64(h.Rate[i].Requests) / statsInterval.Seconds()
+		}
+		h.DisallowedURLs = append([]string(nil), host.DisallowedURLs...)
+		report.Hosts = append(report.Hosts, h)
+	}
+	sort.Slice(report.Hosts, func(i, j int) bool {
+		if report.Hosts[i].Requests != report.Hosts[j].Requests {
+			return report.Hosts[i].Requests > report.Hosts[j].Requests
+		}
+		return report.Hosts[i].Host < report.Hosts[j].Host
+	})
+	return report
+}
+
+// Write the politeness report to <folder>/<filename>-politeness.json
+func (t *politenessTransport) write(folder, filename string) error {
+	data, err := json.MarshalIndent(t.Report(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(folder, filename+"-politeness.json"), append(data, '\n'), 0644)
+}
+
+// Size of headers as written on the wire
+func headerSize(header http.Header) int64 {
+	var n int64
+	for name, values := range header {
+		for _, value := range values {
+			n += int64(len(name) + len(value) + 4)
+		}
+	}
+	return n
+}
+
+// countingBody reports the bytes read from a response body
+type countingBody struct {
+	io.ReadCloser
+	count func(n int)
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.count(n)
+	}
+	return n, err
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPolitenessReport(t *testing.T) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, "User-agent: *\nDisallow: /admin\nCrawl-delay: 1\n")
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><a href="/admin/users">admin</a><a href="/about">about</a></html>`)
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html>page</html>`)
+		}
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "gospider-politeness")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	site, _ := url.Parse(ts.URL + "/")
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.OutputFolder = dir
+	opts.PolitenessReport = true
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler.Run()
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, crawler.outputName+"-politeness.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report PolitenessReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != int(hits) || len(report.Hosts) != 1 || report.BytesReceived == 0 || report.BytesSent == 0 {
+		t.Fatalf("report of %d requests for %d sent: %s", report.Requests, hits, data)
+	}
+	host := report.Hosts[0]
+	if host.Host != site.Host || host.Requests != int(hits) || len(host.Rate) == 0 || host.Rate[0].Requests != int(hits) {
+		t.Errorf("unexpected host traffic %+v", host)
+	}
+	if host.RobotsDisallowed != 1 || len(host.DisallowedURLs) != 1 || host.DisallowedURLs[0] != ts.URL+"/admin/users" {
+		t.Errorf("robots.txt violations %d: %v", host.RobotsDisallowed, host.DisallowedURLs)
+	}
+	if host.CrawlDelay != 1 || host.CrawlDelayViolations == 0 {
+		t.Errorf("crawl delay %v violated %d times", host.CrawlDelay, host.CrawlDelayViolations)
+	}
+}
@@ -0,0 +1,260 @@
+package core
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// Wait after a 429/503 without a usable Retry-After
+	defaultBackoff = 5 * time.Second
+	maxBackoff     = 2 * time.Minute
+	// Throttled requests are sent again at most this many times
+	maxThrottleRetries = 3
+	// Successful responses needed to give a throttled host one more parallel request
+	recoverAfter = 10
+)
+
+// rateLimitTransport spaces requests to a global rate and backs off per host
+// when the server answers 429 Too Many Requests or 503 Service Unavailable
+type rateLimitTransport struct {
+	base http.RoundTripper
+	// Timeout of each attempt, response body included
+	timeout time.Duration
+	limits  *rateLimits
+}
+
+// rateLimits is the global rate and the per host state, shared by the crawlers of a Manager
+type rateLimits struct {
+	// Time between two requests, 0 for no global limit
+	interval time.Duration
+	// Parallel requests per host when not throttled
+	parallelism int
+
+	mu    sync.Mutex
+	next  time.Time
+	hosts map[string]*hostLimit
+}
+
+// hostLimit is the adaptive state of one host
+type hostLimit struct {
+	cond      *sync.Cond
+	max       int
+	limit     int
+	active    int
+	successes int
+	backoff   time.Duration
+	until     time.Time
+}
+
+func newRateLimitTransport(base http.RoundTripper, rateLimit float64, parallelism int, timeout time.Duration) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:    base,
+		timeout: timeout,
+		limits:  newRateLimits(rateLimit, parallelism),
+	}
+}
+
+func newRateLimits(rateLimit float64, parallelism int) *rateLimits {
+	l := &rateLimits{
+		parallelism: parallelism,
+		hosts:       make(map[string]*hostLimit),
+	}
+	if l.parallelism < 1 {
+		l.parallelism = 1
+	}
+	if rateLimit > 0 {
+		l.interval = time.Duration(float64(time.Second) / rateLimit)
+	}
+	return l
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := t.limits.host(req.URL.Host)
+	for attempt := 0; ; attempt++ {
+		if err := host.acquire(req.Context()); err != nil {
+			return nil, err
+		}
+		if err := t.limits.wait(req.Context()); err != nil {
+			host.release(false)
+			return nil, err
+		}
+		resp, err := sendTimeout(t.base, req, t.timeout)
+		throttled := err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable)
+		if !throttled {
+			host.release(err == nil)
+			return resp, err
+		}
+
+		wait := host.throttle(ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+		Logger.Warnf("%s throttled (%d), backing off %s", req.URL.Host, resp.StatusCode, wait)
+		// Only requests without a body (or with a replayable one) can be sent again
+		if attempt >= maxThrottleRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// Send one attempt of req within the timeout, the timeout ends when the body is closed
+func sendTimeout(base http.RoundTripper, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout == 0 {
+		return base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// Block until the global rate allows a new request
+func (l *rateLimits) wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	return sleepContext(ctx, wait)
+}
+
+func (l *rateLimits) host(name string) *hostLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h, ok := l.hosts[name]
+	if !ok {
+		h = &hostLimit{cond: sync.NewCond(&sync.Mutex{}), max: l.parallelism, limit: l.parallelism}
+		l.hosts[name] = h
+	}
+	return h
+}
+
+// acquire waits for a request slot and the end of any backoff of the host
+func (h *hostLimit) acquire(ctx context.Context) error {
+	h.cond.L.Lock()
+	for h.active >= h.limit {
+		h.cond.Wait()
+	}
+	h.active++
+	until := h.until
+	h.cond.L.Unlock()
+	if err := sleepContext(ctx, time.Until(until)); err != nil {
+		h.release(false)
+		return err
+	}
+	return nil
+}
+
+// release frees a slot, successful responses slowly restore the parallelism
+func (h *hostLimit) release(success bool) {
+	h.cond.L.Lock()
+	h.active--
+	if success && h.backoff > 0 {
+		h.successes++
+		if h.successes >= recoverAfter {
+			h.successes = 0
+			if h.limit < h.max {
+				h.limit++
+			}
+			if h.limit == h.max {
+				h.backoff = 0
+			}
+		}
+	}
+	h.cond.L.Unlock()
+	h.cond.Broadcast()
+}
+
+// throttle halves the parallelism of the host and pauses it,
+// retryAfter (0 when unknown) overrides the exponential backoff
+func (h *hostLimit) throttle(retryAfter time.Duration) time.Duration {
+	h.cond.L.Lock()
+	defer h.cond.L.Unlock()
+	h.active--
+	h.successes = 0
+	if h.limit > 1 {
+		h.limit /= 2
+	}
+	if h.backoff == 0 {
+		h.backoff = defaultBackoff
+	} else if h.backoff < maxBackoff {
+		h.backoff *= 2
+	}
+	wait := h.backoff
+	if retryAfter > 0 {
+		wait = retryAfter
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	if until := time.Now().Add(wait); until.After(h.until) {
+		h.until = until
+	}
+	h.cond.Broadcast()
+	return wait
+}
+
+// Sleep for d, or less when ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ParseRetryAfter reads a Retry-After header in seconds or HTTP date format, 0 when invalid
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Findings whose value is always masked with Options.Redact, emails and JWTs are masked in any finding
+var redactTypes = map[string]bool{
+	"secret":         true,
+	"google-api-key": true,
+}
+
+var (
+	redactEmailRegex = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
+	redactJWTRegex   = regexp.MustCompile(`^eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+)
+
+// RedactValue masks a sensitive value but keeps enough of it to tell findings apart:
+// the domain of emails, the header of JWTs and the first 4 characters of other values
+// (Ex: j***@example.com, eyJhbGciOiJIUzI1NiJ9.***, AKIA****************)
+func RedactValue(value string) string {
+	switch {
+	case redactEmailRegex.MatchString(value):
+		at := strings.LastIndex(value, "@")
+		return value[:1] + "***" + value[at:]
+	case redactJWTRegex.MatchString(value):
+		return value[:strings.Index(value, ".")] + ".***"
+	case len(value) <= 8:
+		return strings.Repeat("*", len(value))
+	}
+	stars := len(value) - 4
+	if stars > 16 {
+		stars = 16
+	}
+	return value[:4] + strings.Repeat("*", stars)
+}
+
+// Whether the value of a finding is masked with Options.Redact
+func redactable(record SpiderOutput) bool {
+	return redactTypes[record.OutputType] || redactEmailRegex.MatchString(record.Output) || redactJWTRegex.MatchString(record.Output)
+}
+
+// Mask the value of a finding in its record and its plain line
+func redactRecord(record SpiderOutput, plain string) (SpiderOutput, string) {
+	masked := RedactValue(record.Output)
+	plain = strings.ReplaceAll(plain, record.Output, masked)
+	record.Output = masked
+	return record, plain
+}
+
+// SensitiveOutput keeps the unmasked findings of a site as JSON lines in a file only
+// its owner can read, the other outputs get them masked
+type SensitiveOutput struct {
+	mu sync.Mutex
+	f  io.WriteCloser
+}
+
+// NewSensitiveOutput writes the findings to file compressed with algo (none when empty),
+// the file name gets the extension of the compression
+func NewSensitiveOutput(file, algo string) (*SensitiveOutput, error) {
+	file += CompressExt(algo)
+	f, err := openOutputFile(file, 0600, algo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file to write sensitive findings: %s", err)
+	}
+	// The file may exist from a previous run with other permissions
+	if err := os.Chmod(file, 0600); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to restrict sensitive findings file: %s", err)
+	}
+	return &SensitiveOutput{f: f}, nil
+}
+
+func (o *SensitiveOutput) Write(record SpiderOutput, _ string) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err = o.f.Write(append(data, '\n'))
+	return err
+}
+
+func (o *SensitiveOutput) Close() error {
+	return o.f.Close()
+}
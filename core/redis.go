@@ -0,0 +1,538 @@
+package core
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/jaeles-project/gospider/stringset"
+)
+
+var (
+	redisTimeout = 10 * time.Second
+	// Idle connections kept by a client
+	redisPoolSize = 16
+	// How often an instance with free slots looks at the shared queue
+	sharedQueuePoll = 200 * time.Millisecond
+	// The crawl of a site is over once its queue stayed empty with no request running
+	// on any instance for this many polls in a row
+	sharedQueueIdlePolls = 10
+)
+
+// Context key of the depth a request popped from the shared queue was discovered at
+const sharedDepthKey = "gospider-shared-depth"
+
+// redisError is an error reply of the server, the connection is still usable
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// redisClient is a minimal Redis client (RESP2) with a connection pool, enough for
+// the sets, lists and counters of the distributed mode
+type redisClient struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// newRedisClient connects to addr, host:port or redis://[user:password@]host:port[/db]
+// (rediss:// for TLS), and checks the server answers
+func newRedisClient(addr string) (*redisClient, error) {
+	c := &redisClient{addr: addr, pool: make(chan *redisConn, redisPoolSize)}
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis address %q: %s", addr, err)
+		}
+		switch u.Scheme {
+		case "redis":
+		case "rediss":
+			c.useTLS = true
+		default:
+			return nil, fmt.Errorf("invalid redis address %q: unknown scheme %s", addr, u.Scheme)
+		}
+		c.addr = u.Host
+		if u.User != nil {
+			c.password, _ = u.User.Password()
+			c.username = u.User.Username()
+		}
+		if db := strings.Trim(u.Path, "/"); db != "" {
+			if c.db, err = strconv.Atoi(db); err != nil {
+				return nil, fmt.Errorf("invalid redis database %q", db)
+			}
+		}
+	}
+	if _, _, err := net.SplitHostPort(c.addr); err != nil {
+		c.addr = net.JoinHostPort(c.addr, "6379")
+	}
+	if _, err := c.do("PING"); err != nil {
+		return nil, fmt.Errorf("failed to connect to redis %s: %s", c.addr, err)
+	}
+	return c, nil
+}
+
+// Take an idle connection or open a new one
+func (c *redisClient) conn() (*redisConn, error) {
+	select {
+	case conn := <-c.pool:
+		return conn, nil
+	default:
+	}
+	dialer := &net.Dialer{Timeout: redisTimeout}
+	var nc net.Conn
+	var err error
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.addr)
+		nc, err = tls.DialWithDialer(dialer, "tcp", c.addr, &tls.Config{ServerName: host})
+	} else {
+		nc, err = dialer.Dial("tcp", c.addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	var setup [][]string
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []string{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+	if len(setup) > 0 {
+		replies, err := conn.send(setup)
+		if err == nil {
+			err = firstRedisError(replies)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// Give a connection back to the pool, or close it after a network error
+func (c *redisClient) put(conn *redisConn, err error) {
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.Close()
+		return
+	}
+	select {
+	case c.pool <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+// do sends a command and returns its reply: a string, an int64, nil or a []interface{}
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	replies, err := c.pipeline([][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := replies[0].(redisError); ok {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// pipeline sends commands together on one connection and returns their replies in order,
+// the error replies of the server are returned as redisError values
+func (c *redisClient) pipeline(cmds [][]string) ([]interface{}, error) {
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+	replies, err := conn.send(cmds)
+	c.put(conn, err)
+	return replies, err
+}
+
+// Close closes the idle connections
+func (c *redisClient) Close() {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (conn *redisConn) send(cmds [][]string) ([]interface{}, error) {
+	_ = conn.SetDeadline(time.Now().Add(redisTimeout))
+	for _, args := range cmds {
+		fmt.Fprintf(conn.w, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(conn.w, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if err := conn.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, len(cmds))
+	for i := range cmds {
+		reply, err := readRedisReply(conn.r)
+		if err != nil {
+			return nil, err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// Read a RESP2 reply, error replies are returned as a redisError value
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return redisError(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = readRedisReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
+
+func firstRedisError(replies []interface{}) error {
+	for _, reply := range replies {
+		if err, ok := reply.(redisError); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// redisFilter is a dedup filter in a Redis set, shared by every instance crawling the site
+type redisFilter struct {
+	client *redisClient
+	key    string
+}
+
+func (f *redisFilter) Duplicate(s string) bool {
+	reply, err := f.client.do("SADD", f.key, strings.ToLower(s))
+	if err != nil {
+		Logger.Errorf("Failed to check shared filter %s: %s", f.key, err)
+		return false
+	}
+	return reply == int64(0)
+}
+
+func (f *redisFilter) Slice() []string {
+	reply, err := f.client.do("SMEMBERS", f.key)
+	if err != nil {
+		Logger.Errorf("Failed to read shared filter %s: %s", f.key, err)
+		return nil
+	}
+	items, _ := reply.([]interface{})
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if value, ok := item.(string); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// sharedQueue lets instances on several machines crawl a site together through Redis.
+// Every GET request of the main collector is claimed in a shared set of seen URLs: the
+// first instance to claim it pushes it to the shared queue with its depth, and whichever
+// instance has a free slot pops and sends it. The link finder fetches are claimed the same
+// way and sent by the instance that found them. A shared counter of the popped requests
+// still running tells when the crawl is over.
+//
+// Keys of a site, under gospider:<site>:
+//
+//	seen:main, seen:linkfinder  URLs claimed by the collectors
+//	queue                       "depth URL" of the requests waiting for an instance
+//	active                      popped requests not finished yet
+//	filter:<name>               dedup filters of the findings (see Crawler.filters)
+type sharedQueue struct {
+	client *redisClient
+	prefix string
+
+	mu sync.Mutex
+	// Requests of this instance being sent, popped ones are counted in active
+	inflight map[*colly.Request]bool
+	// Popped requests and link finder fetches of this instance not finished yet
+	running int
+	// Woken when a request finishes so the next one is popped
+	wake chan struct{}
+}
+
+// openSharedQueue connects to the Redis server at addr to crawl site with the other instances
+func openSharedQueue(addr, site string) (*sharedQueue, error) {
+	client, err := newRedisClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	return &sharedQueue{
+		client:   client,
+		prefix:   "gospider:" + site + ":",
+		inflight: make(map[*colly.Request]bool),
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// filter returns the shared dedup filter called name
+func (q *sharedQueue) filter(name string) stringset.Filter {
+	return &redisFilter{client: q.client, key: q.prefix + "filter:" + name}
+}
+
+// begin is called first for every request of the main collector, it restores the depth
+// of the requests popped from the shared queue and counts them as running
+func (q *sharedQueue) begin(r *colly.Request) {
+	// The context is shared with the requests of the links of the page, the depth is only
+	// the one of the popped request
+	depth := r.Ctx.Get(sharedDepthKey)
+	if depth == "" {
+		return
+	}
+	r.Ctx.Put(sharedDepthKey, "")
+	r.Depth, _ = strconv.Atoi(depth)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inflight[r] = true
+}
+
+// check lets through the requests popped from the shared queue. Others are claimed and
+// pushed to the queue when no instance claimed them before, they aren't sent from here.
+// Requests are sent locally when Redis fails, and so are the ones that aren't GET.
+func (q *sharedQueue) check(r *colly.Request) bool {
+	q.mu.Lock()
+	_, popped := q.inflight[r]
+	q.mu.Unlock()
+	if popped || r.Method != "GET" {
+		return true
+	}
+
+	u := r.URL.String()
+	reply, err := q.client.do("SADD", q.prefix+"seen:main", u)
+	if err == nil && reply == int64(0) {
+		return false
+	}
+	if err == nil {
+		if _, err = q.client.do("RPUSH", q.prefix+"queue", strconv.Itoa(r.Depth)+" "+u); err == nil {
+			Logger.Debugf("Queued: %s", u)
+			return false
+		}
+	}
+	Logger.Errorf("Failed to queue %s, requesting it: %s", u, err)
+	q.track(r)
+	return true
+}
+
+// claim lets through the link finder fetches no other instance claimed
+func (q *sharedQueue) claim(r *colly.Request) bool {
+	reply, err := q.client.do("SADD", q.prefix+"seen:linkfinder", r.URL.String())
+	if err != nil {
+		Logger.Errorf("Failed to claim %s, requesting it: %s", r.URL, err)
+	} else if reply == int64(0) {
+		return false
+	}
+	q.track(r)
+	return true
+}
+
+// Count a request of this instance that isn't in the shared counter
+func (q *sharedQueue) track(r *colly.Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.inflight[r] = false
+	q.running++
+}
+
+// finish is called once a request let through is done or aborted by a later check
+func (q *sharedQueue) finish(r *colly.Request) {
+	q.mu.Lock()
+	popped, ok := q.inflight[r]
+	if ok {
+		delete(q.inflight, r)
+		q.running--
+	}
+	q.mu.Unlock()
+	if !ok {
+		return
+	}
+	if popped {
+		q.release()
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Remove a popped request from the shared counter
+func (q *sharedQueue) release() {
+	if _, err := q.client.do("DECR", q.prefix+"active"); err != nil {
+		Logger.Errorf("Failed to update shared queue: %s", err)
+	}
+}
+
+// pop takes the next request of the shared queue and counts it in active, in one transaction
+// so the other instances never see the queue and the counter empty while it's being sent
+func (q *sharedQueue) pop() (u string, depth int, ok bool, err error) {
+	replies, err := q.client.pipeline([][]string{
+		{"MULTI"},
+		{"LPOP", q.prefix + "queue"},
+		{"INCR", q.prefix + "active"},
+		{"EXEC"},
+	})
+	if err == nil {
+		err = firstRedisError(replies)
+	}
+	if err != nil {
+		return "", 0, false, err
+	}
+	exec, _ := replies[3].([]interface{})
+	var item string
+	if len(exec) > 0 {
+		item, ok = exec[0].(string)
+	}
+	if !ok {
+		q.release()
+		return "", 0, false, nil
+	}
+	args := strings.SplitN(item, " ", 2)
+	if len(args) != 2 {
+		q.release()
+		return "", 0, false, fmt.Errorf("invalid shared queue item %q", item)
+	}
+	depth, _ = strconv.Atoi(args[0])
+	return args[1], depth, true, nil
+}
+
+// Whether nothing is queued or running, on this instance and the others
+func (q *sharedQueue) idle() bool {
+	q.mu.Lock()
+	running := q.running
+	q.mu.Unlock()
+	if running > 0 {
+		return false
+	}
+	replies, err := q.client.pipeline([][]string{
+		{"LLEN", q.prefix + "queue"},
+		{"GET", q.prefix + "active"},
+	})
+	if err == nil {
+		err = firstRedisError(replies)
+	}
+	if err != nil {
+		Logger.Errorf("Failed to read shared queue: %s", err)
+		return false
+	}
+	active, _ := replies[1].(string)
+	return replies[0] == int64(0) && (active == "" || active == "0")
+}
+
+// work sends the requests of the shared queue with c, up to concurrency at once, until
+// the crawl of the site is over on every instance or stopped returns a reason
+func (q *sharedQueue) work(c *colly.Collector, concurrency int, stopped func() string) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	idlePolls := 0
+	for idlePolls < sharedQueueIdlePolls && stopped() == "" {
+		q.mu.Lock()
+		free := q.running < concurrency
+		q.mu.Unlock()
+		if free {
+			u, depth, ok, err := q.pop()
+			if err != nil {
+				Logger.Errorf("Failed to read shared queue: %s", err)
+			} else if ok {
+				idlePolls = 0
+				q.visit(c, u, depth)
+				continue
+			}
+			if q.idle() {
+				idlePolls++
+			} else {
+				idlePolls = 0
+			}
+		}
+		select {
+		case <-q.wake:
+		case <-time.After(sharedQueuePoll):
+		}
+	}
+}
+
+// Send a popped request, its depth is restored by begin
+func (q *sharedQueue) visit(c *colly.Collector, u string, depth int) {
+	q.mu.Lock()
+	q.running++
+	q.mu.Unlock()
+	ctx := colly.NewContext()
+	ctx.Put(sharedDepthKey, strconv.Itoa(depth))
+	if err := c.Request("GET", u, nil, ctx, nil); err != nil {
+		Logger.Debugf("Failed to request %s: %s", u, err)
+		q.mu.Lock()
+		q.running--
+		q.mu.Unlock()
+		q.release()
+	}
+}
+
+// Close closes the connections to Redis
+func (q *sharedQueue) Close() {
+	q.client.Close()
+}
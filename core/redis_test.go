@@ -0,0 +1,254 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis answers the commands of the distributed mode, MULTI included
+type fakeRedis struct {
+	mu     sync.Mutex
+	sets   map[string]map[string]bool
+	lists  map[string][]string
+	values map[string]int64
+}
+
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	f := &fakeRedis{sets: make(map[string]map[string]bool), lists: make(map[string][]string), values: make(map[string]int64)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, l.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	var queued [][]string
+	multi := false
+	for {
+		reply, err := readRedisReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		switch strings.ToUpper(args[0]) {
+		case "MULTI":
+			multi = true
+			writeFakeReply(w, "OK")
+		case "EXEC":
+			var replies []interface{}
+			f.mu.Lock()
+			for _, cmd := range queued {
+				replies = append(replies, f.run(cmd))
+			}
+			f.mu.Unlock()
+			multi, queued = false, nil
+			writeFakeReply(w, replies)
+		default:
+			if multi {
+				queued = append(queued, args)
+				writeFakeReply(w, "QUEUED")
+				break
+			}
+			f.mu.Lock()
+			writeFakeReply(w, f.run(args))
+			f.mu.Unlock()
+		}
+		w.Flush()
+	}
+}
+
+func (f *fakeRedis) run(args []string) interface{} {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "PONG"
+	case "SADD":
+		if f.sets[args[1]] == nil {
+			f.sets[args[1]] = make(map[string]bool)
+		}
+		if f.sets[args[1]][args[2]] {
+			return int64(0)
+		}
+		f.sets[args[1]][args[2]] = true
+		return int64(1)
+	case "SMEMBERS":
+		var members []interface{}
+		for member := range f.sets[args[1]] {
+			members = append(members, member)
+		}
+		return members
+	case "RPUSH":
+		f.lists[args[1]] = append(f.lists[args[1]], args[2])
+		return int64(len(f.lists[args[1]]))
+	case "LPOP":
+		list := f.lists[args[1]]
+		if len(list) == 0 {
+			return nil
+		}
+		f.lists[args[1]] = list[1:]
+		return list[0]
+	case "LLEN":
+		return int64(len(f.lists[args[1]]))
+	case "INCR":
+		f.values[args[1]]++
+		return f.values[args[1]]
+	case "DECR":
+		f.values[args[1]]--
+		return f.values[args[1]]
+	case "GET":
+		if v, ok := f.values[args[1]]; ok {
+			return strconv.FormatInt(v, 10)
+		}
+		return nil
+	}
+	return redisError("ERR unknown command " + args[0])
+}
+
+func writeFakeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case redisError:
+		fmt.Fprintf(w, "-%s\r\n", string(v))
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		if v == "OK" || v == "QUEUED" || v == "PONG" {
+			fmt.Fprintf(w, "+%s\r\n", v)
+		} else {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+		}
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeFakeReply(w, item)
+		}
+	}
+}
+
+func TestRedisFilter(t *testing.T) {
+	_, addr := startFakeRedis(t)
+	queue, err := openSharedQueue("redis://"+addr+"/0", "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+
+	filter := queue.filter("url")
+	if filter.Duplicate("https://example.com/A") || !filter.Duplicate("https://example.com/a") {
+		t.Error("shared filter doesn't dedup case insensitively like StringFilter")
+	}
+	other := queue.filter("js")
+	if other.Duplicate("https://example.com/a") {
+		t.Error("filters share their values")
+	}
+	if got := filter.Slice(); !reflect.DeepEqual(got, []string{"https://example.com/a"}) {
+		t.Errorf("Slice() = %v", got)
+	}
+
+	if _, err := newRedisClient("127.0.0.1:1"); err == nil {
+		t.Error("unreachable server accepted")
+	}
+}
+
+func TestCrawlerRedis(t *testing.T) {
+	defer func(poll time.Duration) { sharedQueuePoll = poll }(sharedQueuePoll)
+	sharedQueuePoll = 10 * time.Millisecond
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			for i := 0; i < 20; i++ {
+				fmt.Fprintf(w, `<a href="/page/%d">page</a>`, i)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+		fmt.Fprint(w, `<a href="/">home</a><a href="/about">about</a>`)
+	}))
+	defer ts.Close()
+	_, addr := startFakeRedis(t)
+
+	site, _ := url.Parse(ts.URL + "/")
+	found := make(map[string]int)
+	crawl := func() {
+		opts := DefaultOptions()
+		opts.Depth = 3
+		opts.Robots = false
+		opts.Quiet = true
+		opts.Redis = addr
+		opts.OnResult = func(r SpiderOutput) {
+			mu.Lock()
+			defer mu.Unlock()
+			if r.OutputType == "url" {
+				found[r.Output]++
+			}
+		}
+		crawler, err := NewCrawlerWithOptions(site, opts)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		crawler.Run()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			crawl()
+		}()
+	}
+	wg.Wait()
+
+	var paths []string
+	for path, n := range hits {
+		paths = append(paths, path)
+		if n != 1 {
+			t.Errorf("%s requested %d times", path, n)
+		}
+	}
+	sort.Strings(paths)
+	if len(paths) != 22 {
+		t.Errorf("requested %v", paths)
+	}
+	for u, n := range found {
+		if n != 1 {
+			t.Errorf("%s reported %d times", u, n)
+		}
+	}
+	if len(found) != 22 {
+		t.Errorf("reported %d URLs", len(found))
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"golang.org/x/net/publicsuffix"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Leading slashes browsers read as a protocol-relative URL,
+// backslashes included (Ex: //google.com, /\google.com, \\google.com)
+var protocolRelativeRegex = regexp.MustCompile(`^[/\\][/\\]+`)
+
+var hostnameRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// Top level domains that are more likely file extensions in a relative path (Ex: main.py/)
+var fileExtTLDs = map[string]bool{
+	"bz": true, "cc": true, "do": true, "md": true, "mov": true, "pl": true,
+	"ps": true, "py": true, "rs": true, "sh": true, "so": true, "zip": true,
+}
+
+// IsProtocolRelative reports whether ref is a protocol-relative reference like //host/path
+func IsProtocolRelative(ref string) bool {
+	return protocolRelativeRegex.MatchString(strings.TrimSpace(ref))
+}
+
+// IsSchemelessURL reports whether ref is a host/path reference without scheme (Ex: cdn.example.com/app.js).
+// The host must end with a known public suffix and be followed by a path, unless it starts with www.
+func IsSchemelessURL(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	end := strings.IndexAny(ref, "/?#")
+	host := ref
+	if end >= 0 {
+		host = ref[:end]
+	}
+	if end < 0 && !strings.HasPrefix(strings.ToLower(host), "www.") {
+		return false
+	}
+	// host:port, a colon followed by anything else is a scheme (Ex: mailto:)
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		if _, err := strconv.Atoi(host[i+1:]); err != nil {
+			return false
+		}
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+	if !hostnameRegex.MatchString(host) {
+		return false
+	}
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	if !icann || suffix == host || fileExtTLDs[suffix] {
+		return false
+	}
+	return true
+}
+
+// Resolve an href/src against its page. Protocol-relative and schemeless references
+// use the scheme of the page instead of being read as a path of the current host.
+func (crawler *Crawler) resolveRef(e *colly.HTMLElement, ref string) string {
+	return crawler.resolveRequestRef(e.Request, ref)
+}
+
+// Resolve a reference found in the response of r, see resolveRef
+func (crawler *Crawler) resolveRequestRef(r *colly.Request, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if IsProtocolRelative(ref) || IsSchemelessURL(ref) {
+		return FixUrl(ref, r.URL)
+	}
+	return FixUrl(r.AbsoluteURL(ref), crawler.site)
+}
+
+// Report links of crawled pages pointing outside the crawl scope, they are never crawled
+func (crawler *Crawler) reportExternalRef(source, ref string) {
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" || crawler.scope.InScope(u) {
+		return
+	}
+	if crawler.externalSet.Duplicate(ref) {
+		return
+	}
+	domain := GetDomain(u)
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	outputFormat := fmt.Sprintf("[external] - [from: %s] - %s", source, ref)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     source,
+		OutputType: "external",
+		Output:     ref,
+		Details:    map[string]string{"domain": domain},
+	})
+}
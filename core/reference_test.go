@@ -0,0 +1,128 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsSchemelessURL(t *testing.T) {
+	tests := map[string]bool{
+		"cdn.example.com/app.js":   true,
+		"www.example.com":          true,
+		"api.example.co.uk:8443/":  true,
+		"example.com?q=1":          true,
+		"console/test.php":         false,
+		"index.html":               false,
+		"main.py/run":              false,
+		"app.min.js/x":             false,
+		"mailto:admin@example.com": false,
+		"javascript:void(0)":       false,
+		"/example.com/path":        false,
+		"example.com":              false,
+		"intranet.local/admin":     false,
+	}
+	for ref, want := range tests {
+		if got := IsSchemelessURL(ref); got != want {
+			t.Errorf("IsSchemelessURL(%q) = %v, want %v", ref, got, want)
+		}
+	}
+}
+
+func TestFixUrl(t *testing.T) {
+	site, _ := url.Parse("https://example.com/blog/")
+	tests := map[string]string{
+		"//cdn.example.net/app.js":   "https://cdn.example.net/app.js",
+		`/\evil.example.org/`:        "https://evil.example.org/",
+		`\\evil.example.org/`:        "https://evil.example.org/",
+		"///evil.example.org/x":      "https://evil.example.org/x",
+		"static.example.net/app.js":  "https://static.example.net/app.js",
+		"http://example.com/a":       "http://example.com/a",
+		"/about":                     "https://example.com/about",
+		"console/test.php":           "https://example.com/console/test.php",
+		"./main.js":                  "https://example.com/main.js",
+		"www.example.org":            "https://www.example.org",
+		"api.example.org:8080/v1/me": "https://api.example.org:8080/v1/me",
+	}
+	for ref, want := range tests {
+		if got := FixUrl(ref, site); got != want {
+			t.Errorf("FixUrl(%q) = %q, want %q", ref, got, want)
+		}
+	}
+}
+
+func TestCrawlerExternalRefs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html>
+			<a href="//evil.example.org/login">a</a>
+			<a href="/\other.example.net/">b</a>
+			<script src="cdn.example.com/lib.js"></script>
+			<a href="docs/page.html">c</a>
+		</html>`)
+	}))
+	defer ts.Close()
+
+	findings := crawlForTest(t, ts.URL, func(opts *Options) { opts.Depth = 2 })
+	external, urls := outputsOf(findings, "external"), outputsOf(findings, "url")
+
+	for _, u := range []string{"http://evil.example.org/login", "http://other.example.net/", "http://cdn.example.com/lib.js"} {
+		if !external[u] {
+			t.Errorf("%s not reported as external, got %v", u, external)
+		}
+	}
+	if len(external) != 3 {
+		t.Errorf("unexpected external findings %v", external)
+	}
+	if !urls[ts.URL+"/docs/page.html"] {
+		t.Errorf("relative link not crawled, got %v", urls)
+	}
+	if urls["http://evil.example.org/login"] {
+		t.Error("external link crawled")
+	}
+}
+
+func TestCrawlerExternalInventory(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html>
+			<a href="https://chat.vendor.example.com/widget?id=1">chat</a>
+			<img src="http://tracker.example.net/pixel.gif">
+			<a href="/about">about</a>
+		</html>`)
+	}))
+	defer ts.Close()
+
+	folder, err := ioutil.TempDir("", "gospider-external")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	domains := make(map[string]string)
+	for _, r := range findingsOf(crawlForTest(t, ts.URL, func(opts *Options) { opts.OutputFolder = folder }), "external") {
+		domains[r.Output] = r.Details["domain"]
+	}
+
+	want := map[string]string{
+		"https://chat.vendor.example.com/widget?id=1": "example.com",
+		"http://tracker.example.net/pixel.gif":        "example.net",
+	}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("external links = %v, want %v", domains, want)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(folder, "external.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], "[external] - ") {
+		t.Errorf("unexpected external.txt %q", data)
+	}
+}
@@ -0,0 +1,251 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Renderer loads pages in a headless Chrome so links built client-side
+// become visible to the collectors
+type Renderer struct {
+	allocCancel   context.CancelFunc
+	browserCtx    context.Context
+	browserCancel context.CancelFunc
+	timeout       time.Duration
+	wait          time.Duration
+	// Requests of the pages excluded by the scope fail, nil to let them all through
+	scope *Scope
+}
+
+// NewRenderer starts a headless Chrome, chromePath may be empty to let chromedp find it.
+// Chrome doesn't request what scope excludes, scope may be nil.
+func NewRenderer(chromePath, proxy string, scope *Scope, timeout, wait time.Duration) (*Renderer, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("ignore-certificate-errors", true),
+	)
+	if chromePath != "" {
+		opts = append(opts, chromedp.ExecPath(chromePath))
+	}
+	if proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(proxy))
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	// Start the browser now so a missing Chrome is reported before crawling
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserCancel()
+		allocCancel()
+		return nil, err
+	}
+
+	return &Renderer{
+		allocCancel:   allocCancel,
+		browserCtx:    browserCtx,
+		browserCancel: browserCancel,
+		timeout:       timeout,
+		wait:          wait,
+		scope:         scope,
+	}, nil
+}
+
+// renderedPage is a response already fetched by the crawl, Chrome gets it instead of
+// requesting the page again
+type renderedPage struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Answer the paused request id with the page
+func (p *renderedPage) fulfill(id fetch.RequestID) chromedp.Action {
+	var headers []*fetch.HeaderEntry
+	for name, values := range p.header {
+		// The body is sent whole
+		if name == "Content-Length" || name == "Transfer-Encoding" {
+			continue
+		}
+		for _, v := range values {
+			headers = append(headers, &fetch.HeaderEntry{Name: name, Value: v})
+		}
+	}
+	return fetch.FulfillRequest(id, int64(p.status)).
+		WithResponseHeaders(headers).
+		WithBody(base64.StdEncoding.EncodeToString(p.body))
+}
+
+// Render loads u in a new tab with the given request headers.
+// It returns the rendered DOM and the XHR/fetch URLs requested by the page.
+func (r *Renderer) Render(u string, headers http.Header) (string, []string, error) {
+	return r.render(u, headers, nil, nil)
+}
+
+// Screenshot loads u in a new tab with the given request headers and returns a PNG of the viewport
+func (r *Renderer) Screenshot(u string, headers http.Header) ([]byte, error) {
+	var shot []byte
+	_, _, err := r.render(u, headers, nil, &shot)
+	return shot, err
+}
+
+// Load u and take its DOM, XHR/fetch URLs and, when shot isn't nil, a screenshot at once.
+// When page is set Chrome is served it for u instead of fetching u itself.
+func (r *Renderer) render(u string, headers http.Header, page *renderedPage, shot *[]byte) (string, []string, error) {
+	tabCtx, tabCancel := chromedp.NewContext(r.browserCtx)
+	defer tabCancel()
+	ctx, cancel := context.WithTimeout(tabCtx, r.timeout+r.wait)
+	defer cancel()
+
+	var mu sync.Mutex
+	var xhrs []string
+	served := false
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch e := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if e.Type == network.ResourceTypeXHR || e.Type == network.ResourceTypeFetch {
+				mu.Lock()
+				xhrs = append(xhrs, e.Request.URL)
+				mu.Unlock()
+			}
+		case *fetch.EventRequestPaused:
+			mu.Lock()
+			action := r.paused(e, page, &served)
+			mu.Unlock()
+			// Commands can't be sent from the listener, it would block the events of the tab
+			go func() {
+				if err := action.Do(cdp.WithExecutor(ctx, chromedp.FromContext(ctx).Target)); err != nil && ctx.Err() == nil {
+					Logger.Debugf("Failed to answer request of %s: %s", e.Request.URL, err)
+				}
+			}()
+		}
+	})
+
+	extraHeaders := make(map[string]string)
+	for k := range headers {
+		extraHeaders[k] = headers.Get(k)
+	}
+	rawHeaders, err := json.Marshal(extraHeaders)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var html string
+	actions := []chromedp.Action{
+		network.Enable(),
+		network.SetExtraHTTPHeaders(network.Headers(rawHeaders)),
+	}
+	if page != nil || r.scope != nil {
+		// Pause the requests of the tab to answer the navigation and block the ones out of scope
+		actions = append(actions, fetch.Enable())
+	}
+	if shot != nil {
+		actions = append(actions, chromedp.EmulateViewport(screenshotWidth, screenshotHeight))
+	}
+	actions = append(actions,
+		chromedp.Navigate(u),
+		chromedp.Sleep(r.wait),
+		chromedp.OuterHTML("html", &html),
+	)
+	if shot != nil {
+		actions = append(actions, chromedp.CaptureScreenshot(shot))
+	}
+	err = chromedp.Run(ctx, actions...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return html, Unique(xhrs), nil
+}
+
+// paused decides what happens to a request of Chrome paused by the fetch domain: the
+// navigation gets the page when there's one, requests excluded by the scope fail and
+// the others go on
+func (r *Renderer) paused(e *fetch.EventRequestPaused, page *renderedPage, served *bool) chromedp.Action {
+	if page != nil && !*served && e.ResourceType == network.ResourceTypeDocument {
+		*served = true
+		return page.fulfill(e.RequestID)
+	}
+	if r.scope != nil {
+		if u, err := url.Parse(e.Request.URL); err == nil && r.scope.Excluded(u) {
+			Logger.Debugf("Out of scope, blocked in Chrome: %s", e.Request.URL)
+			return fetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient)
+		}
+	}
+	return fetch.ContinueRequest(e.RequestID)
+}
+
+// Close stops the browser
+func (r *Renderer) Close() {
+	r.browserCancel()
+	r.allocCancel()
+}
+
+// renderTransport replaces the body of HTML responses with the DOM rendered by Chrome,
+// so the OnHTML handlers of the collectors see the client-side links too
+type renderTransport struct {
+	base     http.RoundTripper
+	renderer *Renderer
+	// Called with XHR/fetch URLs observed while rendering a page
+	onXHR func(pageURL string, urls []string)
+	// Screenshots of the pages are taken while rendering them with --screenshot
+	screenshots *screenshotter
+}
+
+func (t *renderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || req.Method != http.MethodGet || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/html") {
+		return resp, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	// Chrome renders the body fetched by the crawl, unless it's still encoded
+	var page *renderedPage
+	if resp.Header.Get("Content-Encoding") == "" {
+		page = &renderedPage{status: resp.StatusCode, header: resp.Header, body: body}
+	}
+
+	var shot *[]byte
+	if t.screenshots != nil && t.screenshots.claim(req.URL) {
+		shot = new([]byte)
+	}
+	html, xhrs, err := t.renderer.render(req.URL.String(), req.Header, page, shot)
+	if err != nil {
+		Logger.Debugf("Failed to render %s: %s", req.URL, err)
+		return resp, nil
+	}
+	if shot != nil {
+		t.screenshots.save(req.URL.String(), GetTitle(html), *shot)
+	}
+	if len(xhrs) > 0 && t.onXHR != nil {
+		t.onXHR(req.URL.String(), xhrs)
+	}
+
+	resp.Body = ioutil.NopCloser(strings.NewReader(html))
+	resp.ContentLength = int64(len(html))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(html)))
+	// Chrome already decoded the body
+	resp.Header.Del("Content-Encoding")
+	return resp, nil
+}
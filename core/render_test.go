@@ -0,0 +1,85 @@
+package core
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestRendererPaused(t *testing.T) {
+	r := &Renderer{}
+	page := &renderedPage{
+		status: http.StatusOK,
+		header: http.Header{"Content-Type": {"text/html"}, "Content-Length": {"99"}, "Set-Cookie": {"a=1", "b=2"}},
+		body:   []byte("<html>page</html>"),
+	}
+	served := false
+	navigation := &fetch.EventRequestPaused{RequestID: "1", ResourceType: network.ResourceTypeDocument, Request: &network.Request{URL: "https://example.com/"}}
+
+	// The navigation gets the page fetched by the crawl
+	fulfill, ok := r.paused(navigation, page, &served).(*fetch.FulfillRequestParams)
+	if !ok || fulfill.RequestID != "1" || fulfill.ResponseCode != 200 {
+		t.Fatalf("navigation answered with %+v", fulfill)
+	}
+	if body, _ := base64.StdEncoding.DecodeString(fulfill.Body); string(body) != "<html>page</html>" {
+		t.Errorf("body = %q", body)
+	}
+	headers := make(map[string]int)
+	for _, h := range fulfill.ResponseHeaders {
+		headers[h.Name]++
+	}
+	if len(headers) != 2 || headers["Content-Type"] != 1 || headers["Set-Cookie"] != 2 {
+		t.Errorf("headers = %v", headers)
+	}
+
+	// Everything else, a reload included, goes to the network
+	for _, e := range []*fetch.EventRequestPaused{
+		{RequestID: "2", ResourceType: network.ResourceTypeScript, Request: &network.Request{URL: "https://example.com/app.js"}},
+		{RequestID: "3", ResourceType: network.ResourceTypeDocument, Request: &network.Request{URL: "https://example.com/"}},
+	} {
+		if next, ok := r.paused(e, page, &served).(*fetch.ContinueRequestParams); !ok || next.RequestID != e.RequestID {
+			t.Errorf("request %s answered with %+v", e.RequestID, next)
+		}
+	}
+	served = false
+	if _, ok := r.paused(navigation, nil, &served).(*fetch.ContinueRequestParams); !ok {
+		t.Error("navigation without a page not sent to the network")
+	}
+}
+
+func TestRendererPausedScope(t *testing.T) {
+	site, _ := url.Parse("https://example.com/")
+	opts := DefaultOptions()
+	opts.DenyPaths = []string{"/logout*"}
+	scope, err := NewScope(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Renderer{scope: scope}
+
+	served := false
+	for u, blocked := range map[string]bool{
+		"https://example.com/logout?next=/": true,
+		"https://example.com/api/items":     false,
+		// Only exclusions apply, the page still gets its scripts from other hosts
+		"https://cdn.example.net/app.js": false,
+	} {
+		e := &fetch.EventRequestPaused{RequestID: "1", ResourceType: network.ResourceTypeXHR, Request: &network.Request{URL: u}}
+		switch action := r.paused(e, nil, &served).(type) {
+		case *fetch.FailRequestParams:
+			if !blocked || action.ErrorReason != network.ErrorReasonBlockedByClient {
+				t.Errorf("%s failed with %s", u, action.ErrorReason)
+			}
+		case *fetch.ContinueRequestParams:
+			if blocked {
+				t.Errorf("%s sent by Chrome", u)
+			}
+		default:
+			t.Errorf("%s answered with %+v", u, action)
+		}
+	}
+}
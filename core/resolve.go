@@ -0,0 +1,162 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Timeout of one query to a configured resolver
+const resolverTimeout = 5 * time.Second
+
+// ResolveHost looks up the addresses of host, it returns nil when the host does not resolve
+func ResolveHost(host string) []string {
+	return lookupHost(net.DefaultResolver, host)
+}
+
+func lookupHost(resolver *net.Resolver, host string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*resolverTimeout)
+	defer cancel()
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		Logger.Debugf("Failed to resolve %s: %s", host, err)
+		return nil
+	}
+	return addrs
+}
+
+// ProbeHost checks if host answers HTTP requests, trying https first.
+// It returns the working scheme and the status code of the response.
+func ProbeHost(client *http.Client, host string) (string, int, bool) {
+	for _, scheme := range []string{"https", "http"} {
+		resp, err := client.Get(scheme + "://" + host)
+		if err != nil {
+			Logger.Debugf("Failed to probe %s://%s: %s", scheme, host, err)
+			continue
+		}
+		resp.Body.Close()
+		return scheme, resp.StatusCode, true
+	}
+	return "", 0, false
+}
+
+// ParseResolvers reads DNS resolvers from a file (one per line, # comments) or a comma
+// separated list, as IP or IP:port (Ex: "1.1.1.1,8.8.8.8:53"). The port defaults to 53.
+func ParseResolvers(value string) ([]string, error) {
+	var raws []string
+	if f, err := os.Open(value); err == nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				raws = append(raws, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read resolvers: %s", err)
+		}
+	} else {
+		raws = splitFlagList(value, nil)
+	}
+
+	var resolvers []string
+	for _, raw := range raws {
+		host, port, err := net.SplitHostPort(raw)
+		if err != nil {
+			host, port = strings.Trim(raw, "[]"), "53"
+		}
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid resolver %q, use an IP or IP:port", raw)
+		}
+		resolvers = append(resolvers, net.JoinHostPort(host, port))
+	}
+	if len(resolvers) == 0 {
+		return nil, fmt.Errorf("no resolver in %q", value)
+	}
+	return resolvers, nil
+}
+
+// NewResolver returns a resolver querying servers in turn
+func NewResolver(servers []string) *net.Resolver {
+	var next uint32
+	dialer := &net.Dialer{Timeout: resolverTimeout}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			server := servers[int(atomic.AddUint32(&next, 1)-1)%len(servers)]
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// SubdomainStatus is what resolving and probing a subdomain found
+type SubdomainStatus struct {
+	A    []string
+	AAAA []string
+	// Scheme and status code of the first answer, https first
+	Scheme string
+	Status int
+	Alive  bool
+}
+
+// Resolved reports whether the subdomain has an address
+func (s SubdomainStatus) Resolved() bool {
+	return len(s.A) > 0 || len(s.AAAA) > 0
+}
+
+// String formats the status for the plain output (Ex: "[A 1.2.3.4] - [alive https 200]")
+func (s SubdomainStatus) String() string {
+	if !s.Resolved() {
+		return "[unresolved]"
+	}
+	var parts []string
+	if len(s.A) > 0 {
+		parts = append(parts, "[A "+strings.Join(s.A, ", ")+"]")
+	}
+	if len(s.AAAA) > 0 {
+		parts = append(parts, "[AAAA "+strings.Join(s.AAAA, ", ")+"]")
+	}
+	if s.Alive {
+		parts = append(parts, fmt.Sprintf("[alive %s %d]", s.Scheme, s.Status))
+	} else {
+		parts = append(parts, "[dead]")
+	}
+	return strings.Join(parts, " - ")
+}
+
+// Details of the status for the structured output
+func (s SubdomainStatus) details() map[string]string {
+	details := map[string]string{"alive": fmt.Sprint(s.Alive)}
+	if len(s.A) > 0 {
+		details["a"] = strings.Join(s.A, ",")
+	}
+	if len(s.AAAA) > 0 {
+		details["aaaa"] = strings.Join(s.AAAA, ",")
+	}
+	if s.Alive {
+		details["scheme"] = s.Scheme
+	}
+	return details
+}
+
+// Resolve a subdomain with the crawl resolver and probe it over http(s) when it resolves
+func (crawler *Crawler) checkSubdomain(sub string) SubdomainStatus {
+	var status SubdomainStatus
+	for _, addr := range lookupHost(crawler.resolver, sub) {
+		if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil {
+			status.A = append(status.A, addr)
+		} else {
+			status.AAAA = append(status.AAAA, addr)
+		}
+	}
+	if status.Resolved() {
+		status.Scheme, status.Status, status.Alive = ProbeHost(crawler.client, sub)
+	}
+	return status
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Serve A records of hosts over UDP, other names don't exist
+func fakeDNSServer(t *testing.T, hosts map[string]net.IP) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var query dnsmessage.Message
+			if err := query.Unpack(buf[:n]); err != nil || len(query.Questions) == 0 {
+				continue
+			}
+			q := query.Questions[0]
+			answer := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+			}
+			ip, ok := hosts[strings.TrimSuffix(q.Name.String(), ".")]
+			switch {
+			case !ok:
+				answer.RCode = dnsmessage.RCodeNameError
+			case q.Type == dnsmessage.TypeA:
+				var a [4]byte
+				copy(a[:], ip.To4())
+				answer.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: a},
+				}}
+			}
+			packed, err := answer.Pack()
+			if err == nil {
+				_, _ = conn.WriteTo(packed, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestParseResolvers(t *testing.T) {
+	resolvers, err := ParseResolvers("1.1.1.1, 8.8.8.8:5353,[2606:4700:4700::1111]")
+	if want := []string{"1.1.1.1:53", "8.8.8.8:5353", "[2606:4700:4700::1111]:53"}; err != nil || !reflect.DeepEqual(resolvers, want) {
+		t.Errorf("ParseResolvers() = %v, %v, want %v", resolvers, err, want)
+	}
+
+	dir, err := ioutil.TempDir("", "gospider-resolvers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "resolvers.txt")
+	if err := ioutil.WriteFile(path, []byte("# public\n9.9.9.9\n\n1.0.0.1:53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	resolvers, err = ParseResolvers(path)
+	if want := []string{"9.9.9.9:53", "1.0.0.1:53"}; err != nil || !reflect.DeepEqual(resolvers, want) {
+		t.Errorf("ParseResolvers(file) = %v, %v, want %v", resolvers, err, want)
+	}
+
+	for _, value := range []string{"dns.google", "", "1.1.1.1,999.1.1.1"} {
+		if _, err := ParseResolvers(value); err == nil {
+			t.Errorf("ParseResolvers(%q) returned no error", value)
+		}
+	}
+}
+
+func TestSubdomainStatus(t *testing.T) {
+	for _, tt := range []struct {
+		status SubdomainStatus
+		want   string
+	}{
+		{SubdomainStatus{}, "[unresolved]"},
+		{SubdomainStatus{A: []string{"1.2.3.4"}}, "[A 1.2.3.4] - [dead]"},
+		{SubdomainStatus{A: []string{"1.2.3.4", "1.2.3.5"}, AAAA: []string{"::1"}, Scheme: "https", Status: 200, Alive: true}, "[A 1.2.3.4, 1.2.3.5] - [AAAA ::1] - [alive https 200]"},
+	} {
+		if got := tt.status.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestResolverAndProbe(t *testing.T) {
+	server := fakeDNSServer(t, map[string]net.IP{"api.example.test": net.IPv4(127, 0, 0, 1)})
+	resolver := NewResolver([]string{server})
+	if addrs := lookupHost(resolver, "api.example.test"); !reflect.DeepEqual(addrs, []string{"127.0.0.1"}) {
+		t.Errorf("lookupHost() = %v", addrs)
+	}
+	if addrs := lookupHost(resolver, "gone.example.test"); addrs != nil {
+		t.Errorf("lookupHost() of a missing host = %v", addrs)
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	if scheme, status, alive := ProbeHost(ts.Client(), u.Host); !alive || scheme != "http" || status != http.StatusForbidden {
+		t.Errorf("ProbeHost() = %s, %d, %v", scheme, status, alive)
+	}
+}
+
+func TestCrawlerResolveSubs(t *testing.T) {
+	server := fakeDNSServer(t, map[string]net.IP{"api.example.test": net.IPv4(127, 0, 0, 1)})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html>https://api.example.test/ https://gone.example.test/</html>`)
+	}))
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL)
+	opts := DefaultOptions()
+	opts.Robots = false
+	opts.Quiet = true
+	opts.ResolveSubs = true
+	opts.Resolvers = server
+	var mu sync.Mutex
+	found := make(map[string]SpiderOutput)
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.OutputType == "subdomains" {
+			found[r.Output] = r
+		}
+	}
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The test server is an IP, pretend it's a host of the resolved domain
+	crawler.domain = "example.test"
+	crawler.Run()
+
+	if len(found) != 2 {
+		t.Fatalf("found %v", found)
+	}
+	if api := found["api.example.test"]; api.Details["a"] != "127.0.0.1" {
+		t.Errorf("api.example.test reported with %v", api.Details)
+	}
+	if gone := found["gone.example.test"]; gone.Details["a"] != "" || gone.Details["alive"] != "false" {
+		t.Errorf("gone.example.test reported with %v", gone.Details)
+	}
+}
@@ -0,0 +1,309 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// Denied URLs under a subtree answering nothing else before it's reported as a restricted area
+const restrictedAreaMin = 3
+
+// Largest body accepted by the auth API
+const maxAuthUpdate = 64 * 1024
+
+// deniedURL is a 401 or 403 url finding held back until the end of the crawl
+type deniedURL struct {
+	request *colly.Request
+	plain   string
+	record  SpiderOutput
+}
+
+// restrictedAreas holds back the 401 and 403 answers of a crawl with --restricted-areas.
+// At the end of the crawl the subtrees that answered nothing else are reported as one
+// restricted-area finding each, the other denied URLs as url findings like without it.
+type restrictedAreas struct {
+	mu     sync.Mutex
+	denied map[string]*deniedURL
+	// Subtrees with an answer other than 401 or 403
+	allowed map[string]bool
+	// URLs retried with the credentials of the auth API, only once each
+	retried map[string]bool
+}
+
+func newRestrictedAreas() *restrictedAreas {
+	return &restrictedAreas{
+		denied:  make(map[string]*deniedURL),
+		allowed: make(map[string]bool),
+		retried: make(map[string]bool),
+	}
+}
+
+// Subtrees of u from the host root, the path itself included as a folder:
+// https://example.com/admin/users gives https://example.com/, https://example.com/admin/
+// and https://example.com/admin/users/
+func subtrees(u *url.URL) []string {
+	base := u.Scheme + "://" + u.Host + "/"
+	trees := []string{base}
+	for _, segment := range strings.Split(u.EscapedPath(), "/") {
+		if segment == "" {
+			continue
+		}
+		base += segment + "/"
+		trees = append(trees, base)
+	}
+	return trees
+}
+
+func (a *restrictedAreas) allow(u *url.URL) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, tree := range subtrees(u) {
+		a.allowed[tree] = true
+	}
+}
+
+func (a *restrictedAreas) deny(r *colly.Request, plain string, record SpiderOutput) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.denied[r.URL.String()] = &deniedURL{request: r, plain: plain, record: record}
+}
+
+// Retry the denied URLs not retried yet, returns how many were retried
+func (a *restrictedAreas) retry() int {
+	a.mu.Lock()
+	var requests []*colly.Request
+	for u, denied := range a.denied {
+		if a.retried[u] {
+			continue
+		}
+		a.retried[u] = true
+		delete(a.denied, u)
+		requests = append(requests, denied.request)
+	}
+	a.mu.Unlock()
+
+	for _, r := range requests {
+		// The credentials sent the first time were added to the headers
+		r.Headers.Del("Cookie")
+		r.Headers.Del("Authorization")
+		if err := r.Retry(); err != nil {
+			Logger.Debugf("Failed to retry %s: %s", r.URL, err)
+		}
+	}
+	return len(requests)
+}
+
+// restrictedArea is a subtree of a site where every URL crawled was denied
+type restrictedArea struct {
+	URL   string
+	Codes []int
+	URLs  []string
+}
+
+// Split the denied URLs into the restricted areas, shallowest first, and the URLs outside them
+func (a *restrictedAreas) areas() ([]restrictedArea, []*deniedURL) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, denied := range a.denied {
+		for _, tree := range subtrees(denied.request.URL) {
+			counts[tree]++
+		}
+	}
+	var candidates []string
+	for tree, n := range counts {
+		if n >= restrictedAreaMin && !a.allowed[tree] {
+			candidates = append(candidates, tree)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(candidates[i]) != len(candidates[j]) {
+			return len(candidates[i]) < len(candidates[j])
+		}
+		return candidates[i] < candidates[j]
+	})
+	var areas []restrictedArea
+	for _, tree := range candidates {
+		nested := false
+		for _, area := range areas {
+			if strings.HasPrefix(tree, area.URL) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			areas = append(areas, restrictedArea{URL: tree})
+		}
+	}
+
+	var others []*deniedURL
+	for u, denied := range a.denied {
+		inArea := false
+		for i := range areas {
+			if area := &areas[i]; strings.HasPrefix(strings.TrimSuffix(u, "/")+"/", area.URL) {
+				area.URLs = append(area.URLs, u)
+				if !containsCode(area.Codes, denied.record.StatusCode) {
+					area.Codes = append(area.Codes, denied.record.StatusCode)
+				}
+				inArea = true
+				break
+			}
+		}
+		if !inArea {
+			others = append(others, denied)
+		}
+	}
+	for i := range areas {
+		sort.Strings(areas[i].URLs)
+		sort.Ints(areas[i].Codes)
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].record.Output < others[j].record.Output
+	})
+	return areas, others
+}
+
+func containsCode(codes []int, code int) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Hold back a 401 or 403 url finding with --restricted-areas, returns false for other answers
+func (crawler *Crawler) holdDenied(response *colly.Response, plain string, record SpiderOutput) bool {
+	if crawler.restricted == nil || (response.StatusCode != http.StatusUnauthorized && response.StatusCode != http.StatusForbidden) {
+		return false
+	}
+	crawler.restricted.deny(response.Request, plain, record)
+	return true
+}
+
+// Report the restricted areas and the denied URLs held back outside them
+func (crawler *Crawler) reportRestricted() {
+	if crawler.restricted == nil {
+		return
+	}
+	areas, others := crawler.restricted.areas()
+	for _, area := range areas {
+		codes := make([]string, len(area.Codes))
+		for i, code := range area.Codes {
+			codes[i] = strconv.Itoa(code)
+		}
+		outputFormat := fmt.Sprintf("[restricted-area] - [code-%s] - [urls-%d] - %s", strings.Join(codes, ","), len(area.URLs), area.URL)
+		record := SpiderOutput{
+			Source:     area.URLs[0],
+			OutputType: "restricted-area",
+			Output:     area.URL,
+			Details:    map[string]string{"codes": strings.Join(codes, ","), "urls": strconv.Itoa(len(area.URLs))},
+		}
+		if len(area.Codes) == 1 {
+			record.StatusCode = area.Codes[0]
+		}
+		crawler.Report(outputFormat, record)
+	}
+	for _, denied := range others {
+		crawler.Report(denied.plain, denied.record)
+	}
+}
+
+// authCredentials are the headers posted to the auth API, sent with the requests of every
+// crawl from then on
+type authCredentials struct {
+	mu     sync.Mutex
+	header http.Header
+	// Denied URLs of the running crawls, retried with new credentials
+	areas map[*restrictedAreas]bool
+}
+
+var liveCredentials = &authCredentials{header: make(http.Header), areas: make(map[*restrictedAreas]bool)}
+
+func (c *authCredentials) register(areas *restrictedAreas) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.areas[areas] = true
+}
+
+func (c *authCredentials) unregister(areas *restrictedAreas) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.areas, areas)
+}
+
+// Set the posted credentials on the headers of a request
+func (c *authCredentials) apply(header http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range c.header {
+		header[k] = append([]string(nil), v...)
+	}
+}
+
+// Set headers, the denied URLs of the running crawls are retried with them.
+// It returns the number of URLs retried.
+func (c *authCredentials) set(header http.Header) int {
+	c.mu.Lock()
+	for k, v := range header {
+		c.header[k] = v
+	}
+	areas := make([]*restrictedAreas, 0, len(c.areas))
+	for a := range c.areas {
+		areas = append(areas, a)
+	}
+	c.mu.Unlock()
+
+	retried := 0
+	for _, a := range areas {
+		retried += a.retry()
+	}
+	return retried
+}
+
+// serveAuth sets the headers of the body, one "Name: value" per line, on the requests of
+// every crawl from now on. The URLs denied so far with --restricted-areas are retried once.
+//
+//	curl -H "Authorization: Bearer $GOSPIDER_CONTROL_TOKEN" --data-binary 'Authorization: Bearer eyJhbGciOi...' http://localhost:9091/auth
+func serveAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	header := make(http.Header)
+	sc := bufio.NewScanner(io.LimitReader(r.Body, maxAuthUpdate))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		args := strings.SplitN(line, ":", 2)
+		if len(args) != 2 || strings.TrimSpace(args[0]) == "" {
+			http.Error(w, fmt.Sprintf("invalid header %q, use Name: value", line), http.StatusBadRequest)
+			return
+		}
+		header.Set(strings.TrimSpace(args[0]), strings.TrimSpace(args[1]))
+	}
+	if err := sc.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(header) == 0 {
+		http.Error(w, "no header", http.StatusBadRequest)
+		return
+	}
+	retried := liveCredentials.set(header)
+	Logger.Infof("New credentials for the crawls, %d denied URLs retried", retried)
+	fmt.Fprintf(w, "%d headers set, %d denied URLs retried\n", len(header), retried)
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func TestRestrictedAreas(t *testing.T) {
+	a := newRestrictedAreas()
+	for _, raw := range []string{
+		"https://example.com/admin/users",
+		"https://example.com/admin/settings/mail",
+		"https://example.com/admin",
+		"https://example.com/api/private",
+		"https://example.com/api/keys",
+		"https://example.com/api/tokens",
+		"https://example.com/server-status",
+	} {
+		u, _ := url.Parse(raw)
+		code := 403
+		if strings.Contains(raw, "settings") {
+			code = 401
+		}
+		a.deny(&colly.Request{URL: u}, "", SpiderOutput{Output: raw, StatusCode: code})
+	}
+	public, _ := url.Parse("https://example.com/api/docs")
+	a.allow(public)
+
+	areas, others := a.areas()
+	if len(areas) != 1 || areas[0].URL != "https://example.com/admin/" || len(areas[0].URLs) != 3 || fmt.Sprint(areas[0].Codes) != "[401 403]" {
+		t.Errorf("areas = %+v", areas)
+	}
+	var rest []string
+	for _, denied := range others {
+		rest = append(rest, denied.record.Output)
+	}
+	if strings.Join(rest, " ") != "https://example.com/api/keys https://example.com/api/private https://example.com/api/tokens https://example.com/server-status" {
+		t.Errorf("denied URLs outside the areas: %v", rest)
+	}
+}
+
+func crawlRestricted(t *testing.T, handler http.HandlerFunc) []SpiderOutput {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	return crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.RestrictedAreas = true
+	})
+}
+
+func TestCrawlerRestrictedAreas(t *testing.T) {
+	findings := crawlRestricted(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `<a href="/admin/a">a</a><a href="/admin/b">b</a><a href="/admin/c">c</a><a href="/server-status">s</a><a href="/about">about</a>`)
+		case strings.HasPrefix(r.URL.Path, "/admin/"), r.URL.Path == "/server-status":
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+
+	areas, denied := 0, 0
+	for _, f := range findings {
+		switch {
+		case f.OutputType == "restricted-area":
+			areas++
+			if !strings.HasSuffix(f.Output, "/admin/") || f.StatusCode != http.StatusForbidden || f.Details["urls"] != "3" {
+				t.Errorf("restricted area %+v", f)
+			}
+		case f.OutputType == "url" && f.StatusCode == http.StatusForbidden:
+			denied++
+			if !strings.HasSuffix(f.Output, "/server-status") {
+				t.Errorf("denied URL reported apart from its area: %s", f.Output)
+			}
+		}
+	}
+	if areas != 1 || denied != 1 {
+		t.Errorf("%d restricted areas and %d denied URLs reported", areas, denied)
+	}
+}
+
+func TestRetryRestricted(t *testing.T) {
+	defer func() { liveCredentials.header = make(http.Header) }()
+
+	findings := crawlRestricted(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			fmt.Fprint(w, `<a href="/admin/a">a</a><a href="/admin/b">b</a><a href="/admin/c">c</a><a href="/slow">slow</a>`)
+		case r.URL.Path == "/slow":
+			// Credentials obtained while the crawl goes on
+			time.Sleep(200 * time.Millisecond)
+			rec := httptest.NewRecorder()
+			serveAuth(rec, httptest.NewRequest("POST", "/auth", strings.NewReader("Authorization: Bearer ok\n")))
+			if !strings.Contains(rec.Body.String(), "3 denied URLs retried") {
+				t.Errorf("auth API answered %q", rec.Body.String())
+			}
+		case strings.HasPrefix(r.URL.Path, "/admin/"):
+			if r.Header.Get("Authorization") != "Bearer ok" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}
+	})
+
+	allowed := 0
+	for _, f := range findings {
+		if f.OutputType == "restricted-area" || f.StatusCode == http.StatusUnauthorized {
+			t.Errorf("denied after the retry: %+v", f)
+		}
+		if f.OutputType == "url" && strings.Contains(f.Output, "/admin/") && f.StatusCode == http.StatusOK {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("%d admin pages crawled with the credentials", allowed)
+	}
+}
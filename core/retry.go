@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// Wait before the first retry, doubled for each of the next ones
+	retryBackoff = 500 * time.Millisecond
+	// Longest wait between two attempts, Retry-After included
+	maxRetryBackoff = 30 * time.Second
+)
+
+// Classes of transport errors, see ErrorClass
+const (
+	ErrorTimeout           = "timeout"
+	ErrorConnectionReset   = "connection-reset"
+	ErrorConnectionRefused = "connection-refused"
+	ErrorDNSNotFound       = "dns-not-found"
+	ErrorDNSTemporary      = "dns-temporary"
+	ErrorTLS               = "tls"
+	ErrorCanceled          = "canceled"
+	ErrorOther             = "other"
+)
+
+// Error classes worth another attempt, the others fail the same way again
+var transientErrors = map[string]bool{
+	ErrorTimeout:           true,
+	ErrorConnectionReset:   true,
+	ErrorConnectionRefused: true,
+	ErrorDNSTemporary:      true,
+}
+
+// Status codes of an overloaded or restarting upstream
+var transientStatusCodes = map[int]bool{
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// ErrorClass tells what kind of failure a transport error is (Ex: "timeout", "tls")
+func ErrorClass(err error) string {
+	var dnsErr *net.DNSError
+	var certErr x509.CertificateInvalidError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, errCrawlStopped):
+		return ErrorCanceled
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return ErrorDNSNotFound
+		}
+		return ErrorDNSTemporary
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr),
+		errors.As(err, &recordErr), strings.Contains(err.Error(), "tls: "):
+		return ErrorTLS
+	case errors.Is(err, context.DeadlineExceeded), isTimeout(err):
+		return ErrorTimeout
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNABORTED), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return ErrorConnectionReset
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorConnectionRefused
+	}
+	return ErrorOther
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryTransport sends requests again after transient failures (timeouts, connection
+// resets, 502/503/504) with an exponential backoff and jitter. Permanent failures like
+// TLS handshake errors and unknown hosts are returned at once.
+type retryTransport struct {
+	base    http.RoundTripper
+	retries int
+	// Timeout of each attempt, response body included, 0 when the client has one
+	timeout time.Duration
+	backoff time.Duration
+}
+
+func newRetryTransport(base http.RoundTripper, retries int, timeout time.Duration) *retryTransport {
+	return &retryTransport{base: base, retries: retries, timeout: timeout, backoff: retryBackoff}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := sendTimeout(t.base, req, t.timeout)
+		var retryAfter time.Duration
+		reason := ""
+		if err != nil {
+			if class := ErrorClass(err); transientErrors[class] && req.Context().Err() == nil {
+				reason = class
+			}
+		} else if transientStatusCodes[resp.StatusCode] {
+			reason = http.StatusText(resp.StatusCode)
+			retryAfter = ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		// Only requests without a body (or with a replayable one) can be sent again
+		if reason == "" || attempt >= t.retries || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		wait := t.wait(attempt, retryAfter)
+		Logger.Debugf("Retrying %s in %s (%s, attempt %d of %d)", req.URL, wait, reason, attempt+1, t.retries)
+		if err := sleepContext(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// Backoff before the retry following attempt: the exponential wait with half of it
+// random, so requests failing together aren't retried together. retryAfter overrides it.
+func (t *retryTransport) wait(attempt int, retryAfter time.Duration) time.Duration {
+	wait := retryAfter
+	if wait == 0 {
+		wait = t.backoff << uint(attempt)
+		if wait <= 0 || wait > maxRetryBackoff {
+			wait = maxRetryBackoff
+		}
+		wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+	}
+	if wait > maxRetryBackoff {
+		wait = maxRetryBackoff
+	}
+	return wait
+}
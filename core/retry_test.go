@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{&net.DNSError{Err: "no such host", Name: "nx.example.com", IsNotFound: true}, ErrorDNSNotFound},
+		{&net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}, ErrorDNSTemporary},
+		{fmt.Errorf("read tcp: %w", syscall.ECONNRESET), ErrorConnectionReset},
+		{fmt.Errorf("dial tcp: %w", syscall.ECONNREFUSED), ErrorConnectionRefused},
+		{fmt.Errorf("get: %w", context.DeadlineExceeded), ErrorTimeout},
+		{fmt.Errorf("get: %w", x509.UnknownAuthorityError{}), ErrorTLS},
+		{fmt.Errorf("get: %w", context.Canceled), ErrorCanceled},
+		{fmt.Errorf("unsupported protocol scheme"), ErrorOther},
+	} {
+		if got := ErrorClass(tc.err); got != tc.want {
+			t.Errorf("ErrorClass(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first two requests
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	send := func(retries int) int {
+		rt := newRetryTransport(http.DefaultTransport, retries, time.Second)
+		rt.backoff = time.Millisecond
+		resp, err := (&http.Client{Transport: rt}).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := send(1); status != http.StatusBadGateway || requests != 2 {
+		t.Errorf("got %d after %d requests with 1 retry", status, requests)
+	}
+	atomic.StoreInt32(&requests, 0)
+	if status := send(2); status != http.StatusOK || requests != 3 {
+		t.Errorf("got %d after %d requests with 2 retries", status, requests)
+	}
+
+	// A certificate the client doesn't trust fails the same way every time
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+	var handshakes int32
+	rt := newRetryTransport(&countingTransport{base: http.DefaultTransport, count: &handshakes}, 3, time.Second)
+	rt.backoff = time.Millisecond
+	if _, err := (&http.Client{Transport: rt}).Get(tlsServer.URL); err == nil || ErrorClass(err) != ErrorTLS || handshakes != 1 {
+		t.Errorf("untrusted certificate error %v (%s) after %d attempts", err, ErrorClass(err), handshakes)
+	}
+}
+
+type countingTransport struct {
+	base  http.RoundTripper
+	count *int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(t.count, 1)
+	return t.base.RoundTrip(req)
+}
+
+func TestRetryWait(t *testing.T) {
+	rt := newRetryTransport(nil, 5, 0)
+	for attempt := 0; attempt < 10; attempt++ {
+		full := retryBackoff << uint(attempt)
+		if full > maxRetryBackoff {
+			full = maxRetryBackoff
+		}
+		if wait := rt.wait(attempt, 0); wait < full/2 || wait > full {
+			t.Errorf("wait of attempt %d = %s, want between %s and %s", attempt, wait, full/2, full)
+		}
+	}
+	if wait := rt.wait(0, 10*time.Second); wait != 10*time.Second {
+		t.Errorf("Retry-After wait = %s", wait)
+	}
+}
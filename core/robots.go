@@ -2,23 +2,24 @@ package core
 
 import (
 	"fmt"
-	"github.com/gocolly/colly/v2"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
 	"sync"
 )
 
-func ParseRobots(site *url.URL, output *Output, c *colly.Collector, wg *sync.WaitGroup) {
+var sitemapDirectiveRegex = regexp.MustCompile(`(?i)^\s*sitemap:\s*`)
+
+func ParseRobots(site *url.URL, crawler *Crawler, wg *sync.WaitGroup) {
 	defer wg.Done()
-	robotsURL := site.String() + "/robots.txt"
+	robotsURL := site.Scheme + "://" + site.Host + "/robots.txt"
 
-	resp, err := http.Get(robotsURL)
+	resp, err := crawler.client.Get(robotsURL)
 	if err != nil {
 		return
 	}
+	defer resp.Body.Close()
 	if resp.StatusCode == 200 {
 		Logger.Infof("Found robots.txt: %s", robotsURL)
 		body, err := ioutil.ReadAll(resp.Body)
@@ -29,6 +30,7 @@ func ParseRobots(site *url.URL, output *Output, c *colly.Collector, wg *sync.Wai
 
 		var re = regexp.MustCompile(".*llow: ")
 		for _, line := range lines {
+			line = strings.TrimSpace(line)
 			if strings.Contains(line, "llow: ") {
 				url := re.ReplaceAllString(line, "")
 				url = FixUrl(url, site)
@@ -36,11 +38,25 @@ func ParseRobots(site *url.URL, output *Output, c *colly.Collector, wg *sync.Wai
 					continue
 				}
 				outputFormat := fmt.Sprintf("[robots] - %s", url)
-				fmt.Println(outputFormat)
-				if output != nil {
-					output.WriteToFile(outputFormat)
+				crawler.Report(outputFormat, SpiderOutput{
+					Source:     robotsURL,
+					OutputType: "robots",
+					Output:     url,
+				})
+				crawler.mainVisits.visit(url)
+			} else if sitemapDirectiveRegex.MatchString(line) {
+				// Sitemap: https://example.com/sitemap_index.xml
+				sitemapURL := FixUrl(sitemapDirectiveRegex.ReplaceAllString(line, ""), site)
+				if sitemapURL == "" {
+					continue
 				}
-				_ = c.Visit(url)
+				outputFormat := fmt.Sprintf("[robots] - %s", sitemapURL)
+				crawler.Report(outputFormat, SpiderOutput{
+					Source:     robotsURL,
+					OutputType: "robots",
+					Output:     sitemapURL,
+				})
+				crawler.parseSitemap(sitemapURL, 0)
 			}
 		}
 	}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Rule files of the detectors, built in the binary. A file of the same name in
+// --rules-dir replaces the built-in one.
+const (
+	secretRulesFile    = "secrets.yaml"
+	statusPagesFile    = "status-pages.yaml"
+	graphqlPathsFile   = "graphql-paths.txt"
+	openAPIPathsFile   = "openapi-paths.txt"
+	includeDomainsFile = "include-domains.txt"
+	jsLibrariesFile    = "js-libraries.yaml"
+)
+
+// RuleFiles are the names of the rule files, in the order they're loaded
+var RuleFiles = []string{secretRulesFile, statusPagesFile, graphqlPathsFile, openAPIPathsFile, includeDomainsFile, jsLibrariesFile}
+
+//go:embed rules
+var embeddedRules embed.FS
+
+// Rules are the rule sets, fingerprints and wordlists of the detectors
+type Rules struct {
+	// Secrets are the default secret rules, --secret-rules adds to them
+	Secrets []SecretRule
+	// StatusPages are probed by --misconfig
+	StatusPages []StatusPage
+	// GraphQLPaths and OpenAPIPaths are probed by --api-discovery
+	GraphQLPaths []string
+	OpenAPIPaths []string
+	// IncludeDomains are trusted providers of scripts and stylesheets, with their subdomains
+	IncludeDomains []string
+	// JSLibraries are found by --js-libs
+	JSLibraries []JSLibrary
+}
+
+// StatusPage is a server status page leaking internals, Marker only matches the real page
+type StatusPage struct {
+	Name   string `yaml:"name"`
+	Path   string `yaml:"path"`
+	Marker string `yaml:"marker"`
+
+	re *regexp.Regexp
+}
+
+// LoadRules loads the built-in rules, overridden by the rule files found in dir when it's not empty
+func LoadRules(dir string) (*Rules, error) {
+	var rules Rules
+	for _, name := range RuleFiles {
+		data, err := readRuleFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case secretRulesFile:
+			if err := yaml.UnmarshalStrict(data, &rules.Secrets); err != nil {
+				return nil, fmt.Errorf("failed to parse rule file %s: %s", name, err)
+			}
+		case statusPagesFile:
+			if err := yaml.UnmarshalStrict(data, &rules.StatusPages); err != nil {
+				return nil, fmt.Errorf("failed to parse rule file %s: %s", name, err)
+			}
+			for i, page := range rules.StatusPages {
+				if page.Name == "" || !strings.HasPrefix(page.Path, "/") {
+					return nil, fmt.Errorf("status page %q of %s needs a name and an absolute path", page.Name, name)
+				}
+				if rules.StatusPages[i].re, err = compileRegex(page.Marker); err != nil {
+					return nil, fmt.Errorf("invalid marker of status page %s: %s", page.Name, err)
+				}
+			}
+		case graphqlPathsFile:
+			rules.GraphQLPaths = readRuleList(data)
+		case openAPIPathsFile:
+			rules.OpenAPIPaths = readRuleList(data)
+		case includeDomainsFile:
+			for _, domain := range readRuleList(data) {
+				rules.IncludeDomains = append(rules.IncludeDomains, strings.ToLower(domain))
+			}
+		case jsLibrariesFile:
+			if err := yaml.UnmarshalStrict(data, &rules.JSLibraries); err != nil {
+				return nil, fmt.Errorf("failed to parse rule file %s: %s", name, err)
+			}
+			for i := range rules.JSLibraries {
+				if err := rules.JSLibraries[i].compile(); err != nil {
+					return nil, fmt.Errorf("rule file %s: %s", name, err)
+				}
+			}
+		}
+	}
+	return &rules, nil
+}
+
+// Read a rule file from dir, or the built-in one when dir doesn't have it
+func readRuleFile(dir, name string) ([]byte, error) {
+	if dir != "" {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			Logger.Debugf("Using rule file %s", filepath.Join(dir, name))
+			return data, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read rule file: %s", err)
+		}
+	}
+	return embeddedRules.ReadFile("rules/" + name)
+}
+
+// One item per line, blank lines and # comments are skipped
+func readRuleList(data []byte) []string {
+	var list []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			list = append(list, line)
+		}
+	}
+	return list
+}
+
+// Whether host is one of IncludeDomains or a subdomain of one. The public suffix
+// list has some of them (Ex: googleapis.com), so the registrable domain isn't enough.
+func (r *Rules) isCommonIncludeHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range r.IncludeDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// Built-in rules, the rule files are part of the binary so they always load
+func defaultRules() *Rules {
+	rules, err := LoadRules("")
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
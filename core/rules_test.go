@@ -0,0 +1,69 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadRulesBuiltIn(t *testing.T) {
+	rules, err := LoadRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.Secrets) == 0 || len(rules.StatusPages) == 0 || len(rules.GraphQLPaths) == 0 ||
+		len(rules.OpenAPIPaths) == 0 || len(rules.IncludeDomains) == 0 {
+		t.Fatalf("built-in rules missing: %+v", rules)
+	}
+	if _, err := CompileSecretRules(rules.Secrets); err != nil {
+		t.Error(err)
+	}
+	if rules.GraphQLPaths[0] != "/graphql" {
+		t.Errorf("comment not skipped: %v", rules.GraphQLPaths)
+	}
+	if !rules.isCommonIncludeHost("ajax.googleapis.com") || rules.isCommonIncludeHost("evil-googleapis.com") {
+		t.Error("include domains not matched with their subdomains only")
+	}
+}
+
+func TestLoadRulesOverride(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(openAPIPathsFile, "# custom\n/internal/swagger.json\n\n")
+	write(statusPagesFile, "- name: custom-status\n  path: /custom-status\n  marker: 'uptime: \\d+'\n")
+
+	rules, err := LoadRules(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rules.OpenAPIPaths, []string{"/internal/swagger.json"}) {
+		t.Errorf("OpenAPIPaths = %v", rules.OpenAPIPaths)
+	}
+	if len(rules.StatusPages) != 1 || !rules.StatusPages[0].re.MatchString("uptime: 12") {
+		t.Errorf("StatusPages = %+v", rules.StatusPages)
+	}
+	builtIn, _ := LoadRules("")
+	if !reflect.DeepEqual(rules.GraphQLPaths, builtIn.GraphQLPaths) || len(rules.Secrets) != len(builtIn.Secrets) {
+		t.Error("rule files not overridden aren't the built-in ones")
+	}
+
+	write(statusPagesFile, "- name: custom-status\n  path: /custom-status\n  marker: '('\n")
+	if _, err := LoadRules(dir); err == nil {
+		t.Error("invalid status page marker should fail")
+	}
+	write(statusPagesFile, "- name: custom-status\n  url: /custom-status\n")
+	if _, err := LoadRules(dir); err == nil {
+		t.Error("unknown status page field should fail")
+	}
+}
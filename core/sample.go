@@ -0,0 +1,182 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	uuidSegmentRegex  = regexp.MustCompile(`(?i)^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	hashSegmentRegex  = regexp.MustCompile(`(?i)^[0-9a-f]{16,}$`)
+	dateSegmentRegex  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tokenSegmentRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+	// Numbers of 3 digits or more inside a segment (Ex: product-12345.html), v1 and page2 stay apart
+	numberRunRegex = regexp.MustCompile(`\d{3,}`)
+)
+
+// Findings whose URLs are sampled, the others are reported in full
+var sampleTypes = map[string]bool{
+	"url":           true,
+	"sitemap":       true,
+	"robots":        true,
+	"other-sources": true,
+	"xhr":           true,
+	"linkfinder":    true,
+}
+
+// URLPattern returns the template clustering u with the URLs of the same page type:
+// ids, UUIDs, hashes, dates and tokens of the path are replaced by placeholders
+// and query values dropped (Ex: https://example.com/product/{int}?color={}).
+func URLPattern(u *url.URL) string {
+	var b strings.Builder
+	if u.Host != "" {
+		b.WriteString(strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host))
+	}
+	segments := strings.Split(u.EscapedPath(), "/")
+	for i, segment := range segments {
+		if i > 0 {
+			b.WriteString("/")
+		}
+		b.WriteString(patternSegment(segment))
+	}
+
+	query := u.Query()
+	if len(query) > 0 {
+		names := make([]string, 0, len(query))
+		for name := range query {
+			names = append(names, name+"={}")
+		}
+		sort.Strings(names)
+		b.WriteString("?" + strings.Join(names, "&"))
+	}
+	return b.String()
+}
+
+func patternSegment(segment string) string {
+	switch {
+	case segment == "":
+		return segment
+	case isDigits(segment):
+		return "{int}"
+	case uuidSegmentRegex.MatchString(segment):
+		return "{uuid}"
+	case dateSegmentRegex.MatchString(segment):
+		return "{date}"
+	case hashSegmentRegex.MatchString(segment) && strings.ContainsAny(segment, "0123456789"):
+		return "{hash}"
+	case tokenSegmentRegex.MatchString(segment) && strings.ContainsAny(segment, "0123456789") && strings.IndexFunc(segment, isLetter) >= 0:
+		return "{token}"
+	}
+	return numberRunRegex.ReplaceAllString(segment, "{int}")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// urlSampler keeps the first n URLs of each pattern as its representatives,
+// the others of the pattern are only counted
+type urlSampler struct {
+	n int
+
+	mu       sync.Mutex
+	patterns map[string]*urlSample
+	order    []string
+}
+
+type urlSample struct {
+	representatives map[string]bool
+	// Requests of the pattern sent, and scheduled in total (sampled out ones included)
+	crawled int
+	total   int
+}
+
+func newURLSampler(n int) *urlSampler {
+	return &urlSampler{n: n, patterns: make(map[string]*urlSample)}
+}
+
+// keep reports whether u is a representative of its pattern, it becomes one while the pattern has room
+func (s *urlSampler) keep(u *url.URL) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keepLocked(u, false)
+}
+
+// visit counts a URL about to be requested, false when it's not a representative
+func (s *urlSampler) visit(u *url.URL) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keepLocked(u, true)
+}
+
+func (s *urlSampler) keepLocked(u *url.URL, count bool) bool {
+	pattern := URLPattern(u)
+	sample, ok := s.patterns[pattern]
+	if !ok {
+		sample = &urlSample{representatives: make(map[string]bool)}
+		s.patterns[pattern] = sample
+		s.order = append(s.order, pattern)
+	}
+	if count {
+		sample.total++
+	}
+	raw := u.String()
+	keep := sample.representatives[raw]
+	if !keep && len(sample.representatives) < s.n {
+		sample.representatives[raw] = true
+		keep = true
+	}
+	if count && keep {
+		sample.crawled++
+	}
+	return keep
+}
+
+// Check run before each request, URLs beyond the representatives of their pattern aren't crawled
+func (s *urlSampler) check(r *colly.Request) bool {
+	if s.visit(r.URL) {
+		return true
+	}
+	Logger.Debugf("Sampled out: %s", r.URL)
+	return false
+}
+
+// Report the patterns with URLs left out, with how many were crawled
+func (crawler *Crawler) reportSamples() {
+	if crawler.sampler == nil {
+		return
+	}
+	crawler.sampler.mu.Lock()
+	defer crawler.sampler.mu.Unlock()
+	for _, pattern := range crawler.sampler.order {
+		sample := crawler.sampler.patterns[pattern]
+		if sample.total <= sample.crawled {
+			continue
+		}
+		outputFormat := fmt.Sprintf("[url-pattern] - %s - [crawled %d of %d]", pattern, sample.crawled, sample.total)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     crawler.site.String(),
+			OutputType: "url-pattern",
+			Output:     pattern,
+			Details: map[string]string{
+				"crawled": strconv.Itoa(sample.crawled),
+				"total":   strconv.Itoa(sample.total),
+			},
+		})
+	}
+}
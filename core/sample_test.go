@@ -0,0 +1,86 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestURLPattern(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://Example.com/product/12345":                                 "https://example.com/product/{int}",
+		"https://example.com/product/12345?color=red&size=M":                "https://example.com/product/{int}?color={}&size={}",
+		"https://example.com/u/0b9e5c3e-6f3a-4c6e-9a53-2f1e4d0c7b21/avatar": "https://example.com/u/{uuid}/avatar",
+		"https://example.com/static/9f86d081884c7d65/app.js":                "https://example.com/static/{hash}/app.js",
+		"https://example.com/blog/2020-01-31/release-notes":                 "https://example.com/blog/{date}/release-notes",
+		"https://example.com/reset/eyJhbGciOiJIUzI1NiJ9abc123":              "https://example.com/reset/{token}",
+		"https://example.com/p/red-shoe-10492.html":                         "https://example.com/p/red-shoe-{int}.html",
+		"https://example.com/api/v1/users":                                  "https://example.com/api/v1/users",
+		"/docs/page2":                                                       "/docs/page2",
+	} {
+		u, _ := url.Parse(raw)
+		if got := URLPattern(u); got != want {
+			t.Errorf("URLPattern(%s) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestURLSampler(t *testing.T) {
+	s := newURLSampler(2)
+	parse := func(raw string) *url.URL {
+		u, _ := url.Parse(raw)
+		return u
+	}
+	if !s.keep(parse("https://example.com/item/1")) || !s.visit(parse("https://example.com/item/1")) {
+		t.Error("reported representative not crawled")
+	}
+	if !s.visit(parse("https://example.com/item/2")) || s.visit(parse("https://example.com/item/3")) || s.keep(parse("https://example.com/item/4")) {
+		t.Error("more than 2 representatives kept")
+	}
+	if !s.visit(parse("https://example.com/about")) {
+		t.Error("other pattern sampled out")
+	}
+	if sample := s.patterns["https://example.com/item/{int}"]; sample.crawled != 2 || sample.total != 3 {
+		t.Errorf("counted %d crawled of %d", sample.crawled, sample.total)
+	}
+}
+
+func TestCrawlerSamplePerPattern(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path != "/" {
+			fmt.Fprint(w, `<html>product</html>`)
+			return
+		}
+		for i := 100; i < 120; i++ {
+			fmt.Fprintf(w, `<a href="/product/%d">product</a>`, i)
+		}
+		fmt.Fprint(w, `<a href="/about">about</a>`)
+	}))
+	defer ts.Close()
+
+	findings := crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+		opts.SamplePerPattern = 3
+	})
+	urls, patterns := findingsOf(findings, "url"), findingsOf(findings, "url-pattern")
+
+	products := 0
+	for _, u := range urls {
+		if strings.Contains(u.Output, "/product/") {
+			products++
+		}
+	}
+	if products != 3 || len(urls) != 5 || requests != 5 {
+		t.Errorf("crawled %d requests, reported %v", requests, urls)
+	}
+	if len(patterns) != 1 || patterns[0].Output != ts.URL+"/product/{int}" || patterns[0].Details["crawled"] != "3" || patterns[0].Details["total"] != "20" {
+		t.Errorf("patterns = %+v", patterns)
+	}
+}
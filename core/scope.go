@@ -0,0 +1,357 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Scope decides which URLs the crawler may request.
+// By default the site host, its domain and all subdomains are in scope,
+// Options narrow (or with a Burp scope, replace) that.
+type Scope struct {
+	host   string
+	domain string
+
+	excludeSubs  []*regexp.Regexp
+	includeCIDRs []*net.IPNet
+	allowPaths   []*regexp.Regexp
+	denyPaths    []*regexp.Regexp
+
+	// Guards the rules loaded from files, reloaded with --watch-scope or added through the scope API
+	mu sync.RWMutex
+
+	// Out of scope hosts, IP ranges and paths
+	outHosts []string
+	outCIDRs []*net.IPNet
+	outPaths []*regexp.Regexp
+
+	burpInclude []burpScopeRule
+	burpExclude []burpScopeRule
+
+	// Out of scope entries added during the crawl, kept across reloads
+	added []string
+}
+
+// NewScope builds the scope of a crawl of site from the Scope fields of opts
+func NewScope(site *url.URL, opts Options) (*Scope, error) {
+	s := &Scope{
+		host:   strings.ToLower(site.Hostname()),
+		domain: strings.ToLower(GetDomain(site)),
+	}
+
+	for _, raw := range opts.ExcludeSubdomains {
+		re, err := compileRegex(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude subdomain regex %q: %s", raw, err)
+		}
+		s.excludeSubs = append(s.excludeSubs, re)
+	}
+	for _, raw := range opts.IncludeCIDRs {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %s", raw, err)
+		}
+		s.includeCIDRs = append(s.includeCIDRs, ipNet)
+	}
+	for _, glob := range opts.AllowPaths {
+		s.allowPaths = append(s.allowPaths, GlobToRegex(glob))
+	}
+	for _, glob := range opts.DenyPaths {
+		s.denyPaths = append(s.denyPaths, GlobToRegex(glob))
+	}
+
+	if opts.OutOfScope != "" {
+		if err := s.loadOutOfScope(opts.OutOfScope); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ScopeFile != "" {
+		if err := s.loadBurpScope(opts.ScopeFile); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// InScope reports whether u may be crawled
+func (s *Scope) InScope(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.excluded(u) {
+		return false
+	}
+
+	if len(s.burpInclude) > 0 {
+		for _, rule := range s.burpInclude {
+			if rule.match(u) {
+				return true
+			}
+		}
+		return false
+	}
+
+	host := asciiHost(strings.ToLower(u.Hostname()))
+	if host == s.host {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ipInNets(ip, s.includeCIDRs)
+	}
+	return s.domain != "" && (host == s.domain || strings.HasSuffix(host, "."+s.domain))
+}
+
+// Excluded reports whether u is explicitly excluded: out of scope hosts,
+// excluded subdomains, denied (or not allowed) paths and Burp exclude rules.
+// Unlike InScope it doesn't restrict the domain, so other hosts pass.
+func (s *Scope) Excluded(u *url.URL) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.excluded(u)
+}
+
+func (s *Scope) excluded(u *url.URL) bool {
+	host := asciiHost(strings.ToLower(u.Hostname()))
+	if ip := net.ParseIP(host); ip != nil {
+		if ipInNets(ip, s.outCIDRs) {
+			return true
+		}
+	}
+	for _, out := range s.outHosts {
+		if host == out || strings.HasSuffix(host, "."+out) {
+			return true
+		}
+	}
+
+	if host != s.host && s.domain != "" && strings.HasSuffix(host, "."+s.domain) {
+		for _, re := range s.excludeSubs {
+			if re.MatchString(host) {
+				return true
+			}
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, re := range s.denyPaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	for _, re := range s.outPaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	if len(s.allowPaths) > 0 {
+		allowed := false
+		for _, re := range s.allowPaths {
+			if re.MatchString(path) {
+				allowed = true
+				break
+			}
+		}
+		// Always let the site root through so the crawl can start
+		if !allowed && path != "/" {
+			return true
+		}
+	}
+
+	for _, rule := range s.burpExclude {
+		if rule.match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// Load a list of out of scope hosts, CIDRs and path globs (starting with /), one per line,
+// # starts a comment
+func (s *Scope) loadOutOfScope(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open out of scope list: %s", err)
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if err := s.addOutOfScope(sc.Text()); err != nil {
+			return fmt.Errorf("%s in out of scope list", err)
+		}
+	}
+	return sc.Err()
+}
+
+// Add a line of an out of scope list, blank lines and comments are ignored
+func (s *Scope) addOutOfScope(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	if strings.HasPrefix(line, "/") {
+		s.outPaths = append(s.outPaths, GlobToRegex(line))
+		return nil
+	}
+	line = strings.ToLower(line)
+	if strings.Contains(line, "/") {
+		_, ipNet, err := net.ParseCIDR(line)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q: %s", line, err)
+		}
+		s.outCIDRs = append(s.outCIDRs, ipNet)
+		return nil
+	}
+	if ip := net.ParseIP(line); ip != nil {
+		s.outCIDRs = append(s.outCIDRs, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		return nil
+	}
+	s.outHosts = append(s.outHosts, strings.TrimPrefix(line, "*."))
+	return nil
+}
+
+// Burp Suite project options export, only the target scope is used
+type burpProjectOptions struct {
+	Target struct {
+		Scope struct {
+			AdvancedMode bool            `json:"advanced_mode"`
+			Include      []burpScopeRule `json:"include"`
+			Exclude      []burpScopeRule `json:"exclude"`
+		} `json:"scope"`
+	} `json:"target"`
+}
+
+// burpScopeRule is a Burp scope entry, either a URL prefix (normal mode)
+// or protocol/host/port/file regexes (advanced mode)
+type burpScopeRule struct {
+	Enabled  bool   `json:"enabled"`
+	Prefix   string `json:"prefix"`
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     string `json:"port"`
+	File     string `json:"file"`
+
+	host *regexp.Regexp
+	port *regexp.Regexp
+	file *regexp.Regexp
+}
+
+func (s *Scope) loadBurpScope(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read scope file: %s", err)
+	}
+	var project burpProjectOptions
+	if err := json.Unmarshal(data, &project); err != nil {
+		return fmt.Errorf("failed to parse scope file: %s", err)
+	}
+
+	scope := project.Target.Scope
+	s.burpInclude, err = compileBurpRules(scope.Include, scope.AdvancedMode)
+	if err != nil {
+		return err
+	}
+	s.burpExclude, err = compileBurpRules(scope.Exclude, scope.AdvancedMode)
+	return err
+}
+
+func compileBurpRules(rules []burpScopeRule, advanced bool) ([]burpScopeRule, error) {
+	var compiled []burpScopeRule
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if !advanced {
+			if rule.Prefix != "" {
+				compiled = append(compiled, burpScopeRule{Enabled: true, Prefix: rule.Prefix})
+			}
+			continue
+		}
+		for _, field := range []struct {
+			raw string
+			re  **regexp.Regexp
+		}{{rule.Host, &rule.host}, {rule.Port, &rule.port}, {rule.File, &rule.file}} {
+			if field.raw == "" {
+				continue
+			}
+			re, err := regexp.Compile("(?i)" + field.raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q in scope file: %s", field.raw, err)
+			}
+			*field.re = re
+		}
+		rule.Prefix = ""
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+func (r burpScopeRule) match(u *url.URL) bool {
+	if r.Prefix != "" {
+		return strings.HasPrefix(u.String(), r.Prefix)
+	}
+	if r.Protocol != "" && r.Protocol != "any" && r.Protocol != u.Scheme {
+		return false
+	}
+	if r.host != nil && !r.host.MatchString(u.Hostname()) {
+		return false
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if r.port != nil && !r.port.MatchString(port) {
+		return false
+	}
+	file := u.EscapedPath()
+	if u.RawQuery != "" {
+		file += "?" + u.RawQuery
+	}
+	if r.file != nil && !r.file.MatchString(file) {
+		return false
+	}
+	return true
+}
+
+// GlobToRegex converts a path glob to an anchored regex,
+// * matches any characters (including /) and ? matches one character
+func GlobToRegex(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for _, ch := range glob {
+		switch ch {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checkScope(t *testing.T, scope *Scope, tests map[string]bool) {
+	t.Helper()
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := scope.InScope(u); got != want {
+			t.Errorf("InScope(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestScopeDefault(t *testing.T) {
+	site, _ := url.Parse("https://www.example.com/")
+	scope, err := NewScope(site, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScope(t, scope, map[string]bool{
+		"https://www.example.com/a":       true,
+		"http://api.example.com/":         true,
+		"https://example.com/":            true,
+		"https://example.com.evil.com/":   false,
+		"https://notexample.com/":         false,
+		"ftp://www.example.com/file":      false,
+		"http://10.0.0.1/":                false,
+		"https://deep.sub.example.com/x/": true,
+	})
+}
+
+func TestScopeRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outOfScope := filepath.Join(dir, "out.txt")
+	content := "# third parties\nblog.example.com\n192.168.1.0/24\n"
+	if err := ioutil.WriteFile(outOfScope, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	site, _ := url.Parse("https://example.com/")
+	opts := DefaultOptions()
+	opts.ExcludeSubdomains = []string{`^dev\.`}
+	opts.IncludeCIDRs = []string{"10.0.0.0/24", "192.168.0.0/16"}
+	opts.DenyPaths = []string{"/logout*"}
+	opts.OutOfScope = outOfScope
+	scope, err := NewScope(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScope(t, scope, map[string]bool{
+		"https://dev.example.com/":        false,
+		"https://www.example.com/":        true,
+		"https://blog.example.com/post":   false,
+		"https://a.blog.example.com/":     false,
+		"http://10.0.0.7/":                true,
+		"http://192.168.2.1/":             true,
+		"http://192.168.1.1/":             false,
+		"https://example.com/logout?x=1":  false,
+		"https://example.com/logout/all":  false,
+		"https://example.com/account/out": true,
+	})
+}
+
+func TestScopeAllowPaths(t *testing.T) {
+	site, _ := url.Parse("https://example.com/")
+	opts := DefaultOptions()
+	opts.AllowPaths = []string{"/api/*"}
+	scope, err := NewScope(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScope(t, scope, map[string]bool{
+		"https://example.com/":            true,
+		"https://example.com/api/v1/user": true,
+		"https://example.com/blog":        false,
+	})
+}
+
+func TestScopeBurpFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	scopeFile := filepath.Join(dir, "burp.json")
+	content := `{"target":{"scope":{"advanced_mode":true,
+		"include":[{"enabled":true,"protocol":"https","host":"^(www\\.)?example\\.com$","port":"^443$"},
+			{"enabled":true,"protocol":"any","host":"^partner\\.net$"}],
+		"exclude":[{"enabled":true,"protocol":"any","host":"^www\\.example\\.com$","file":"^/admin.*"},
+			{"enabled":false,"protocol":"any","host":"^partner\\.net$"}]}}}`
+	if err := ioutil.WriteFile(scopeFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	site, _ := url.Parse("https://www.example.com/")
+	opts := DefaultOptions()
+	opts.ScopeFile = scopeFile
+	scope, err := NewScope(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScope(t, scope, map[string]bool{
+		"https://www.example.com/":         true,
+		"https://www.example.com/admin/x":  false,
+		"http://www.example.com/":          false,
+		"https://www.example.com:8443/":    false,
+		"https://api.example.com/":         false,
+		"http://partner.net/path?a=b":      true,
+		"https://example.com/admin?x=true": true,
+	})
+}
+
+func TestGlobToRegex(t *testing.T) {
+	re := GlobToRegex("/static/*.js")
+	if !re.MatchString("/static/js/app.js") || re.MatchString("/static/app.json") {
+		t.Errorf("unexpected glob match for %s", re)
+	}
+}
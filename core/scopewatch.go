@@ -0,0 +1,185 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How often --watch-scope checks the scope files for changes
+var scopeWatchInterval = 2 * time.Second
+
+// Largest body accepted by the scope API
+const maxScopeUpdate = 1 << 20
+
+// Reload loads the out of scope list and the Burp scope of opts again and replaces the rules
+// loaded from them, the entries added with Add are kept. The scope is left as is on error.
+func (s *Scope) Reload(opts Options) error {
+	next := &Scope{}
+	if opts.OutOfScope != "" {
+		if err := next.loadOutOfScope(opts.OutOfScope); err != nil {
+			return err
+		}
+	}
+	if opts.ScopeFile != "" {
+		if err := next.loadBurpScope(opts.ScopeFile); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range s.added {
+		if err := next.addOutOfScope(line); err != nil {
+			return err
+		}
+	}
+	s.outHosts, s.outCIDRs, s.outPaths = next.outHosts, next.outCIDRs, next.outPaths
+	s.burpInclude, s.burpExclude = next.burpInclude, next.burpExclude
+	return nil
+}
+
+// Add excludes more hosts, CIDRs and path globs (starting with /) from the scope, in the format
+// of the out of scope list. Nothing is added when one of the entries is invalid.
+func (s *Scope) Add(entries []string) error {
+	for _, line := range entries {
+		if err := (&Scope{}).addOutOfScope(line); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, line := range entries {
+		_ = s.addOutOfScope(line)
+		s.added = append(s.added, line)
+	}
+	return nil
+}
+
+// scopeRegistry is the scopes of the running crawls, the scope API updates them
+type scopeRegistry struct {
+	mu     sync.Mutex
+	scopes map[*Scope]bool
+	// Entries added through the API, also applied to the crawls started later
+	added []string
+}
+
+var liveScopes = &scopeRegistry{scopes: make(map[*Scope]bool)}
+
+func (r *scopeRegistry) register(scope *Scope) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scopes[scope] = true
+	if len(r.added) > 0 {
+		_ = scope.Add(r.added)
+	}
+}
+
+func (r *scopeRegistry) unregister(scope *Scope) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.scopes, scope)
+}
+
+// Add entries to the scopes of every crawl, returns the number of running crawls updated
+func (r *scopeRegistry) add(entries []string) (int, error) {
+	if err := (&Scope{}).Add(entries); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.added = append(r.added, entries...)
+	for scope := range r.scopes {
+		_ = scope.Add(entries)
+	}
+	return len(r.scopes), nil
+}
+
+// serveScope lists the out of scope entries added on GET and adds the lines of the body on POST,
+// in the format of the --out-of-scope list:
+//
+//	curl -H "Authorization: Bearer $GOSPIDER_CONTROL_TOKEN" --data-binary $'/calendar/*\ntracker.example.com' http://localhost:9091/scope
+func serveScope(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		liveScopes.mu.Lock()
+		added := append([]string{}, liveScopes.added...)
+		liveScopes.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string][]string{"added": added})
+	case http.MethodPost:
+		var entries []string
+		sc := bufio.NewScanner(io.LimitReader(r.Body, maxScopeUpdate))
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				entries = append(entries, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		crawls, err := liveScopes.add(entries)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		Logger.Infof("Out of scope from now on: %s", strings.Join(entries, " "))
+		fmt.Fprintf(w, "%d entries added to %d running crawls\n", len(entries), crawls)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Reload the scope files when they change until stop is closed, a file that fails to load
+// leaves the scope as is
+func (crawler *Crawler) watchScope(stop chan struct{}) {
+	files := []string{crawler.opts.OutOfScope, crawler.opts.ScopeFile}
+	modified := make([]time.Time, len(files))
+	for i, path := range files {
+		modified[i] = fileModTime(path)
+	}
+	ticker := time.NewTicker(scopeWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed := false
+			for i, path := range files {
+				if t := fileModTime(path); !t.Equal(modified[i]) {
+					modified[i] = t
+					changed = true
+				}
+			}
+			if !changed {
+				continue
+			}
+			if err := crawler.scope.Reload(crawler.opts); err != nil {
+				Logger.Errorf("Failed to reload the scope of %s, keeping the previous one: %s", crawler.site, err)
+				continue
+			}
+			Logger.Infof("Scope of %s reloaded", crawler.site)
+		}
+	}
+}
+
+// Modification time of path, zero when it's not set or can't be read
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScopeReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outOfScope := filepath.Join(dir, "out.txt")
+	if err := ioutil.WriteFile(outOfScope, []byte("blog.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	site, _ := url.Parse("https://www.example.com/")
+	opts := DefaultOptions()
+	opts.OutOfScope = outOfScope
+	scope, err := NewScope(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scope.Add([]string{"tracker.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := scope.Add([]string{"/ok", "10.0.0.0/33"}); err == nil {
+		t.Error("invalid CIDR added")
+	}
+	checkScope(t, scope, map[string]bool{
+		"https://www.example.com/ok":      true,
+		"https://blog.example.com/":       false,
+		"https://tracker.example.com/":    false,
+		"https://www.example.com/noisy/1": true,
+	})
+
+	content := "# found mid-run\n/noisy/*\n"
+	if err := ioutil.WriteFile(outOfScope, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := scope.Reload(opts); err != nil {
+		t.Fatal(err)
+	}
+	checkScope(t, scope, map[string]bool{
+		"https://blog.example.com/":       true,
+		"https://tracker.example.com/":    false,
+		"https://www.example.com/noisy/1": false,
+		"https://www.example.com/noisy":   true,
+	})
+
+	if err := ioutil.WriteFile(outOfScope, []byte("10.0.0.0/33\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := scope.Reload(opts); err == nil {
+		t.Error("invalid out of scope list reloaded")
+	}
+	checkScope(t, scope, map[string]bool{"https://www.example.com/noisy/1": false})
+}
+
+func TestWatchScope(t *testing.T) {
+	defer func(interval time.Duration) { scopeWatchInterval = interval }(scopeWatchInterval)
+	scopeWatchInterval = 10 * time.Millisecond
+
+	dir, err := ioutil.TempDir("", "gospider-scope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outOfScope := filepath.Join(dir, "out.txt")
+	if err := ioutil.WriteFile(outOfScope, []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var hits []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits = append(hits, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			// The noisy path is excluded while the first page loads, before its links are followed
+			if err := ioutil.WriteFile(outOfScope, []byte("/calendar/*\n"), 0644); err != nil {
+				t.Error(err)
+			}
+			later := time.Now().Add(time.Minute)
+			_ = os.Chtimes(outOfScope, later, later)
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte(`<a href="/calendar/2024">calendar</a><a href="/about">about</a>`))
+		}
+	}))
+	defer ts.Close()
+
+	crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.Concurrent = 1
+		opts.OutOfScope = outOfScope
+		opts.WatchScope = true
+	})
+
+	mu.Lock()
+	got := strings.Join(hits, " ")
+	mu.Unlock()
+	if strings.Contains(got, "/calendar/") || !strings.Contains(got, "/about") {
+		t.Errorf("requests after the scope change: %s", got)
+	}
+}
+
+func TestServeScope(t *testing.T) {
+	defer func(added []string) { liveScopes.added = added }(liveScopes.added)
+
+	site, _ := url.Parse("https://www.example.com/")
+	scope, err := NewScope(site, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	liveScopes.register(scope)
+	defer liveScopes.unregister(scope)
+
+	ts := httptest.NewServer(http.HandlerFunc(serveScope))
+	defer ts.Close()
+	resp, err := http.Post(ts.URL, "text/plain", strings.NewReader("# noisy\n/calendar/*\ntracker.example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	resp, err = http.Post(ts.URL, "text/plain", strings.NewReader("10.0.0.0/33\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid entry answered %d", resp.StatusCode)
+	}
+
+	checkScope(t, scope, map[string]bool{
+		"https://www.example.com/calendar/1": false,
+		"https://tracker.example.com/":       false,
+		"https://www.example.com/about":      true,
+	})
+
+	// Crawls started later get the entries too
+	later, _ := NewScope(site, DefaultOptions())
+	liveScopes.register(later)
+	defer liveScopes.unregister(later)
+	checkScope(t, later, map[string]bool{"https://tracker.example.com/": false})
+
+	resp, err = http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `"/calendar/*","tracker.example.com"`) {
+		t.Errorf("GET /scope = %s", body)
+	}
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Viewport of the screenshots
+	screenshotWidth  = 1280
+	screenshotHeight = 800
+	// Screenshots taken at the same time apart from --render, each one loads the page in a tab
+	screenshotWorkers = 4
+	// Screenshot gallery written next to the screenshots of a site
+	screenshotGallery = "index.html"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+var screenshotGalleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Screenshots of {{.Site}}</title>
+<style>
+body { font-family: sans-serif; margin: 20px; background: #f4f4f4; }
+figure { display: inline-block; vertical-align: top; width: 420px; margin: 10px; padding: 8px; background: #fff; box-shadow: 0 1px 3px #aaa; }
+img { width: 100%; border: 1px solid #ddd; }
+figcaption { font-size: 13px; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>Screenshots of {{.Site}} ({{len .Shots}})</h1>
+{{range .Shots}}<figure>
+<a href="{{.File}}"><img src="{{.File}}" loading="lazy" alt="{{.URL}}"></a>
+<figcaption><a href="{{.URL}}">{{.URL}}</a>{{if .Title}}<br>{{.Title}}{{end}}</figcaption>
+</figure>
+{{end}}</body>
+</html>
+`))
+
+// Screenshot is a page captured with --screenshot, File is relative to the gallery
+type Screenshot struct {
+	URL   string
+	Title string
+	File  string
+}
+
+// screenshotter captures each in-scope HTML page once, deduplicated by normalized URL,
+// into a per site folder with an index.html gallery
+type screenshotter struct {
+	site  string
+	dir   string
+	scope *Scope
+	// NormalizeURL dedup with --normalize-urls, one screenshot per page template
+	normalize bool
+	// Capture a page apart from the crawl, when it isn't rendered already
+	capture func(u string) ([]byte, error)
+	// Called with the file of each screenshot saved
+	report func(u, path string)
+
+	mu    sync.Mutex
+	seen  map[string]bool
+	shots []Screenshot
+
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newScreenshotter(site string, dir string, scope *Scope, normalize bool) (*screenshotter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create screenshot folder: %s", err)
+	}
+	return &screenshotter{
+		site:      site,
+		dir:       dir,
+		scope:     scope,
+		normalize: normalize,
+		seen:      make(map[string]bool),
+		slots:     make(chan struct{}, screenshotWorkers),
+	}, nil
+}
+
+// Dedup key of a page: its URL with lowercase scheme and host, sorted query and no fragment
+func (s *screenshotter) key(u *url.URL) string {
+	if s.normalize {
+		return NormalizeURL(u)
+	}
+	// A dedup without ignored parameters keys the whole sorted query
+	return (&canonicalDedup{}).key(u)
+}
+
+// claim reports whether u is in scope and not captured yet, it's captured by the caller then
+func (s *screenshotter) claim(u *url.URL) bool {
+	if s.scope != nil && !s.scope.InScope(u) {
+		return false
+	}
+	key := s.key(u)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.seen[key] {
+		return false
+	}
+	s.seen[key] = true
+	return true
+}
+
+// take captures u in the background unless it was already, at most screenshotWorkers at a time
+func (s *screenshotter) take(u *url.URL, title string) {
+	if !s.claim(u) {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+		png, err := s.capture(u.String())
+		if err != nil {
+			Logger.Debugf("Failed to take screenshot of %s: %s", u, err)
+			return
+		}
+		s.save(u.String(), title, png)
+	}()
+}
+
+// save writes the screenshot of u and adds it to the gallery
+func (s *screenshotter) save(u, title string, png []byte) {
+	if len(png) == 0 {
+		return
+	}
+	file := screenshotFile(u)
+	path := filepath.Join(s.dir, file)
+	if err := ioutil.WriteFile(path, png, 0644); err != nil {
+		Logger.Errorf("Failed to write screenshot: %s", err)
+		return
+	}
+	s.mu.Lock()
+	s.shots = append(s.shots, Screenshot{URL: u, Title: title, File: file})
+	s.mu.Unlock()
+	if s.report != nil {
+		s.report(u, path)
+	}
+}
+
+// File name of the screenshot of u, readable and unique
+func screenshotFile(u string) string {
+	name := u
+	if parsed, err := url.Parse(u); err == nil {
+		name = parsed.Host + parsed.EscapedPath()
+	}
+	name = strings.Trim(unsafeFileChars.ReplaceAllString(name, "_"), "_.")
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	sum := sha1.Sum([]byte(u))
+	return fmt.Sprintf("%s_%x.png", name, sum[:6])
+}
+
+// wait for the screenshots being taken
+func (s *screenshotter) wait() {
+	s.wg.Wait()
+}
+
+// Write the index.html gallery of the screenshots taken
+func (s *screenshotter) writeGallery() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sort.Slice(s.shots, func(i, j int) bool {
+		return s.shots[i].URL < s.shots[j].URL
+	})
+	f, err := os.Create(filepath.Join(s.dir, screenshotGallery))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return screenshotGalleryTemplate.Execute(f, struct {
+		Site  string
+		Shots []Screenshot
+	}{s.site, s.shots})
+}
+
+// Capture a page with the headers of the crawl
+func (crawler *Crawler) captureScreenshot(u string) ([]byte, error) {
+	return crawler.renderer.Screenshot(u, renderHeaders(crawler.headers))
+}
+
+func (crawler *Crawler) reportScreenshot(u, path string) {
+	outputFormat := fmt.Sprintf("[screenshot] - %s - %s", u, path)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     u,
+		OutputType: "screenshot",
+		Output:     path,
+	})
+}
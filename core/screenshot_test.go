@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestScreenshotFile(t *testing.T) {
+	a := screenshotFile("https://example.com/account/settings?tab=1")
+	b := screenshotFile("https://example.com/account/settings?tab=2")
+	if !strings.HasPrefix(a, "example.com_account_settings_") || !strings.HasSuffix(a, ".png") {
+		t.Errorf("unexpected file name %s", a)
+	}
+	if a == b {
+		t.Errorf("URLs with other queries share the file %s", a)
+	}
+	long := screenshotFile("https://example.com/" + strings.Repeat("../a", 100))
+	if strings.Contains(long, "/") || len(long) > 120 {
+		t.Errorf("unsafe file name %s", long)
+	}
+}
+
+func TestCrawlerScreenshots(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><title>Home</title><a href="/about#team">about</a><a href="/about">about</a>`)
+			fmt.Fprint(w, `<a href="/data.json">data</a><a href="/missing">missing</a><a href="https://other.example.org/">other</a></html>`)
+		case "/about":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><title>About us</title></html>`)
+		case "/data.json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"ok":true}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "gospider-screenshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	site, _ := url.Parse(ts.URL + "/")
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	var mu sync.Mutex
+	reported := make(map[string]string)
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.OutputType == "screenshot" {
+			reported[r.Source] = r.Output
+		}
+	}
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without Chrome in tests, capture a fake PNG of the URL
+	shots, err := newScreenshotter(site.String(), filepath.Join(dir, crawler.outputName), crawler.scope, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shots.capture = func(u string) ([]byte, error) {
+		return []byte("png " + u), nil
+	}
+	shots.report = crawler.reportScreenshot
+	crawler.screenshots = shots
+	crawler.Run()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 2 || reported[ts.URL+"/"] == "" || reported[ts.URL+"/about"] == "" {
+		t.Fatalf("screenshots reported %v", reported)
+	}
+	for u, path := range reported {
+		data, err := ioutil.ReadFile(path)
+		if err != nil || string(data) != "png "+u {
+			t.Errorf("screenshot of %s at %s: %q %v", u, path, data, err)
+		}
+	}
+	gallery, err := ioutil.ReadFile(filepath.Join(dir, crawler.outputName, screenshotGallery))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"About us", "Home", filepath.Base(reported[ts.URL+"/about"])} {
+		if !strings.Contains(string(gallery), want) {
+			t.Errorf("gallery without %q: %s", want, gallery)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"regexp"
+)
+
+// SecretRule is a named regex matching secrets in response bodies
+type SecretRule struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+
+	re *regexp.Regexp
+}
+
+// DefaultSecretRules are the built-in secret rules, from the embedded rules/secrets.yaml
+var DefaultSecretRules = defaultRules().Secrets
+
+// SecretMatch is a secret found by a rule
+type SecretMatch struct {
+	Rule  string
+	Match string
+}
+
+// LoadSecretRules reads a YAML list of rules, each one with a name and a regex
+func LoadSecretRules(path string) ([]SecretRule, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret rules: %s", err)
+	}
+	var rules []SecretRule
+	if err := yaml.Unmarshal(raw, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse secret rules %s: %s", path, err)
+	}
+	return rules, nil
+}
+
+// CompileSecretRules compiles the regex of every rule
+func CompileSecretRules(rules []SecretRule) ([]SecretRule, error) {
+	compiled := make([]SecretRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("secret rule %q has no name", rule.Regex)
+		}
+		re, err := compileRegex(rule.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex in secret rule %s: %s", rule.Name, err)
+		}
+		rule.re = re
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// FindSecrets returns the unique matches of the compiled rules in source
+func FindSecrets(source string, rules []SecretRule) []SecretMatch {
+	var secrets []SecretMatch
+	seen := make(map[SecretMatch]bool)
+	for _, rule := range rules {
+		for _, match := range rule.re.FindAllString(source, -1) {
+			s := SecretMatch{Rule: rule.Name, Match: match}
+			if !seen[s] {
+				seen[s] = true
+				secrets = append(secrets, s)
+			}
+		}
+	}
+	return secrets
+}
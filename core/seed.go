@@ -0,0 +1,62 @@
+package core
+
+import (
+	"bufio"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ReadSeedFile reads one path or URL per line from path, blank lines and # comments are skipped
+func ReadSeedFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var seeds []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		seeds = append(seeds, line)
+	}
+	return seeds, sc.Err()
+}
+
+// SeedURLs resolves the seeds of site: paths are resolved against it, absolute URLs are kept
+// when they're in scope, so one file may hold the entry points of several targets
+func SeedURLs(site *url.URL, scope *Scope, seeds []string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, seed := range seeds {
+		ref, err := url.Parse(seed)
+		if err != nil {
+			Logger.Debugf("Invalid seed %s: %s", seed, err)
+			continue
+		}
+		u := site.ResolveReference(ref)
+		u.Fragment = ""
+		if scope != nil && !scope.InScope(u) {
+			continue
+		}
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			urls = append(urls, s)
+		}
+	}
+	return urls
+}
+
+// Queue the seeds of --seed-file next to the site
+func (crawler *Crawler) visitSeeds() {
+	for _, u := range SeedURLs(crawler.site, crawler.scope, crawler.seeds) {
+		if crawler.urlSet.Duplicate(crawler.urlKey(u)) {
+			continue
+		}
+		crawler.mainVisits.visit(u)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestSeedURLs(t *testing.T) {
+	site, _ := url.Parse("https://app.example.com/shop/")
+	scope, err := NewScope(site, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds := []string{"/api/v2/users", "cart#items", "https://app.example.com/beta/", "https://other.example.org/api", "/api/v2/users"}
+	want := []string{
+		"https://app.example.com/api/v2/users",
+		"https://app.example.com/shop/cart",
+		"https://app.example.com/beta/",
+	}
+	if got := SeedURLs(site, scope, seeds); !reflect.DeepEqual(got, want) {
+		t.Errorf("SeedURLs = %v, want %v", got, want)
+	}
+}
+
+func TestCrawlerSeedFile(t *testing.T) {
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/hidden/" {
+			fmt.Fprint(w, `<html><a href="/hidden/next">next</a><a href="/">home</a></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html>page</html>`)
+	}))
+	defer ts.Close()
+	dir, err := ioutil.TempDir("", "gospider-seed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "seeds.txt")
+	_ = ioutil.WriteFile(file, []byte("# entry points\n/hidden/\n\n"+ts.URL+"/api/status\n"), 0644)
+
+	crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.SeedFile = file
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/", "/hidden/", "/hidden/next", "/api/status"} {
+		if hits[path] != 1 {
+			t.Errorf("%s requested %d times", path, hits[path])
+		}
+	}
+}
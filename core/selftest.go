@@ -0,0 +1,197 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Fixture site and golden findings of the self test
+//
+//go:embed selftest selftest/site/_redirects
+var selfTestFiles embed.FS
+
+// Redirects of a fixture site, "from to status" per line
+const fixtureRedirects = "_redirects"
+
+// FixtureSite returns the built-in fixture site of the self test: pages, forms, a single page
+// application whose routes are in its JavaScript bundle, redirects, robots.txt and a sitemap
+func FixtureSite() fs.FS {
+	site, _ := fs.Sub(selfTestFiles, "selftest/site")
+	return site
+}
+
+// GoldenFindings returns the findings expected from the crawl of the built-in fixture site
+func GoldenFindings() []string {
+	raw, _ := selfTestFiles.ReadFile("selftest/golden.txt")
+	return ParseGolden(raw)
+}
+
+// ParseGolden reads a golden findings file, one finding per line, # for comments
+func ParseGolden(raw []byte) []string {
+	var findings []string
+	sc := bufio.NewScanner(bytes.NewReader(raw))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			findings = append(findings, line)
+		}
+	}
+	return findings
+}
+
+// FixtureHandler serves a fixture site. A path is served from the file of the same name, the
+// .html or .json file of that name, or the index.html of that folder, query strings are
+// ignored. {{origin}} in the files is replaced by the scheme and host of the server, and
+// the redirects of the _redirects file at the root are answered first.
+func FixtureHandler(site fs.FS) (http.Handler, error) {
+	redirects := make(map[string]struct {
+		to   string
+		code int
+	})
+	if raw, err := fs.ReadFile(site, fixtureRedirects); err == nil {
+		for _, line := range ParseGolden(raw) {
+			fields := strings.Fields(line)
+			code := http.StatusFound
+			if len(fields) == 3 {
+				code, err = strconv.Atoi(fields[2])
+			}
+			if len(fields) < 2 || len(fields) > 3 || err != nil || code < 300 || code > 399 {
+				return nil, fmt.Errorf("invalid redirect %q, use: from to [status]", line)
+			}
+			redirects[fields[0]] = struct {
+				to   string
+				code int
+			}{fields[1], code}
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if redirect, ok := redirects[r.URL.Path]; ok {
+			http.Redirect(w, r, redirect.to, redirect.code)
+			return
+		}
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if strings.HasSuffix(r.URL.Path, "/") {
+			name = path.Join(name, "index.html")
+		}
+		for _, candidate := range []string{name, name + ".html", name + ".json", path.Join(name, "index.html")} {
+			if strings.HasPrefix(path.Base(candidate), "_") {
+				continue
+			}
+			body, err := fs.ReadFile(site, candidate)
+			if err != nil {
+				continue
+			}
+			contentType := mime.TypeByExtension(path.Ext(candidate))
+			if contentType == "" {
+				contentType = http.DetectContentType(body)
+			}
+			if strings.HasPrefix(contentType, "text/") || strings.Contains(contentType, "javascript") ||
+				strings.Contains(contentType, "json") || strings.Contains(contentType, "xml") {
+				body = bytes.ReplaceAll(body, []byte("{{origin}}"), []byte("http://"+r.Host))
+			}
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write(body)
+			return
+		}
+		http.NotFound(w, r)
+	}), nil
+}
+
+// SelfTestResult compares the findings of the crawl of a fixture site with the golden ones
+type SelfTestResult struct {
+	Findings []string
+	// Golden findings the crawl didn't report
+	Missing []string
+	// Findings of the crawl not in the golden ones
+	Unexpected []string
+}
+
+// OK reports whether the crawl found exactly the golden findings
+func (r SelfTestResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0
+}
+
+// Options of the self test crawl, every extractor of the fixture site enabled
+func selfTestOptions() Options {
+	opts := DefaultOptions()
+	opts.Depth = 3
+	opts.Sitemap = true
+	opts.CrawlForms = true
+	opts.Quiet = true
+	opts.UserAgent = "gospider-selftest"
+	return opts
+}
+
+// Finding as written in a golden file: type, status and output, the server origin replaced by {{origin}}
+func goldenLine(record SpiderOutput, origin string) string {
+	line := record.OutputType + " - "
+	if record.StatusCode > 0 {
+		line += fmt.Sprintf("[code-%d] - ", record.StatusCode)
+	}
+	return line + strings.ReplaceAll(record.Output, origin, "{{origin}}")
+}
+
+// SelfTest crawls the fixture site served on a local address and compares the findings
+// with golden, the sorted unique findings are returned in the result
+func SelfTest(site fs.FS, golden []string) (SelfTestResult, error) {
+	handler, err := FixtureHandler(site)
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	var mu sync.Mutex
+	found := make(map[string]bool)
+	opts := selfTestOptions()
+	opts.OnResult = func(record SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		found[goldenLine(record, ts.URL)] = true
+	}
+	start, _ := url.Parse(ts.URL + "/")
+	crawler, err := NewCrawlerWithOptions(start, opts)
+	if err != nil {
+		return SelfTestResult{}, err
+	}
+	crawler.Run()
+
+	var result SelfTestResult
+	for line := range found {
+		result.Findings = append(result.Findings, line)
+	}
+	sort.Strings(result.Findings)
+	expected := make(map[string]bool, len(golden))
+	for _, line := range golden {
+		expected[line] = true
+		if !found[line] {
+			result.Missing = append(result.Missing, line)
+		}
+	}
+	for _, line := range result.Findings {
+		if !expected[line] {
+			result.Unexpected = append(result.Unexpected, line)
+		}
+	}
+	sort.Strings(result.Missing)
+	return result, nil
+}
+
+// WriteGolden writes findings to a golden file
+func WriteGolden(path string, findings []string) error {
+	content := "# Findings of the gospider self test crawl: type - [code-status] - output\n" + strings.Join(findings, "\n") + "\n"
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,5 @@
+document.addEventListener("DOMContentLoaded", function () {
+  var xhr = new XMLHttpRequest();
+  xhr.open("GET", "/api/v1/notifications");
+  xhr.send();
+});
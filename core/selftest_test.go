@@ -0,0 +1,81 @@
+package core
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite selftest/golden.txt with the findings of the self test")
+
+func TestFixtureHandler(t *testing.T) {
+	handler, err := FixtureHandler(fstest.MapFS{
+		"_redirects":       {Data: []byte("# moved\n/old /new/ 301\n/tmp /new/\n")},
+		"new/index.html":   {Data: []byte(`<a href="{{origin}}/page">page</a>`)},
+		"page.html":        {Data: []byte("page")},
+		"api/status.json":  {Data: []byte(`{"ok":true}`)},
+		"static/app.js":    {Data: []byte(`fetch("/api/status")`)},
+		"static/logo.data": {Data: []byte("\x89PNG\r\n\x1a\n")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	for _, tc := range []struct {
+		path, location, contentType, body string
+		code                              int
+	}{
+		{"/old", "/new/", "", "", 301},
+		{"/tmp", "/new/", "", "", 302},
+		{"/new/", "", "text/html", `<a href="` + ts.URL + `/page">page</a>`, 200},
+		{"/new", "", "text/html", "", 200},
+		{"/page?id=1", "", "text/html", "page", 200},
+		{"/api/status", "", "application/json", `{"ok":true}`, 200},
+		{"/static/app.js", "", "javascript", "", 200},
+		{"/static/logo.data", "", "image/png", "", 200},
+		{"/_redirects", "", "", "", 404},
+		{"/missing", "", "", "", 404},
+	} {
+		resp, err := client.Get(ts.URL + tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.code || resp.Header.Get("Location") != tc.location ||
+			!strings.Contains(resp.Header.Get("Content-Type"), tc.contentType) || (tc.body != "" && string(body) != tc.body) {
+			t.Errorf("%s: %d %s %s %q", tc.path, resp.StatusCode, resp.Header.Get("Location"), resp.Header.Get("Content-Type"), body)
+		}
+	}
+
+	if _, err := FixtureHandler(fstest.MapFS{"_redirects": {Data: []byte("/old /new/ 200\n")}}); err == nil {
+		t.Error("redirect with status 200 accepted")
+	}
+}
+
+// Run with -update to rewrite the golden findings after an extraction change, then review the diff
+func TestSelfTest(t *testing.T) {
+	result, err := SelfTest(FixtureSite(), GoldenFindings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updateGolden {
+		if err := WriteGolden("selftest/golden.txt", result.Findings); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	for _, line := range result.Missing {
+		t.Errorf("missing: %s", line)
+	}
+	for _, line := range result.Unexpected {
+		t.Errorf("unexpected: %s", line)
+	}
+}
@@ -0,0 +1,129 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewOutputSinkInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"webhook",
+		"webhook=",
+		"ftp=example.com",
+		"webhook=ftp://example.com/",
+		"kafka=localhost:9092",
+		"kafka=localhost:9092/",
+		"elasticsearch=http://localhost:9200",
+		"elasticsearch=http://localhost:9200/a/b",
+	} {
+		if sink, err := NewOutputSink(spec, "site"); err == nil {
+			_ = sink.Close()
+			t.Errorf("NewOutputSink(%q) should fail", spec)
+		}
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]SpiderOutput
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []SpiderOutput
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	batchSize := sinkBatchSize
+	sinkBatchSize = 2
+	defer func() { sinkBatchSize = batchSize }()
+
+	sink, err := NewOutputSink("webhook="+ts.URL, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := sink.Write(SpiderOutput{OutputType: "url", Output: fmt.Sprint(i)}, ""); err != nil {
+			t.Error(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if len(batches) != 2 || len(batches[0]) != 2 || len(batches[1]) != 1 || batches[1][0].Output != "2" {
+		t.Errorf("unexpected batches %v", batches)
+	}
+}
+
+func TestElasticsearchSink(t *testing.T) {
+	var lines []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			t.Errorf("unexpected bulk request %s %s", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if strings.Contains(strings.Join(lines, "\n"), "rejected") {
+			fmt.Fprint(w, `{"errors":true,"items":[]}`)
+			return
+		}
+		fmt.Fprint(w, `{"errors":false,"items":[]}`)
+	}))
+	defer ts.Close()
+
+	sink, err := NewOutputSink("elasticsearch="+ts.URL+"/gospider", "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = sink.Write(SpiderOutput{OutputType: "url", Output: "https://example.com/"}, "")
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 || lines[0] != `{"index":{"_index":"gospider"}}` || !strings.Contains(lines[1], `"output":"https://example.com/"`) {
+		t.Errorf("unexpected bulk body %v", lines)
+	}
+
+	sink, _ = NewOutputSink("elasticsearch="+ts.URL+"/gospider", "site")
+	_ = sink.Write(SpiderOutput{OutputType: "url", Output: "rejected"}, "")
+	if err := sink.Close(); err == nil {
+		t.Error("bulk item errors should be reported")
+	}
+}
+
+func TestCrawlerOutputSinks(t *testing.T) {
+	var mu sync.Mutex
+	found := make(map[string]bool)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []SpiderOutput
+		_ = json.NewDecoder(r.Body).Decode(&batch)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, record := range batch {
+			found[record.OutputType+" "+record.Output] = true
+		}
+	}))
+	defer hook.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html>sink</html>`)
+	}))
+	defer ts.Close()
+
+	crawlForTest(t, ts.URL, func(opts *Options) { opts.OutputSinks = []string{"webhook=" + hook.URL} })
+
+	if !found["url "+ts.URL] {
+		t.Errorf("url finding not sent to the webhook, got %v", found)
+	}
+}
@@ -1,31 +1,110 @@
 package core
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
-	"github.com/gocolly/colly/v2"
 	sitemap "github.com/oxffaa/gopher-parse-sitemap"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"sync"
 )
 
-func ParseSiteMap(site *url.URL, output *Output, c *colly.Collector, wg *sync.WaitGroup) {
+// Maximum nesting of sitemap indexes to follow
+const maxSitemapDepth = 3
+
+// Maximum size of a decompressed sitemap, the limit of the sitemap protocol
+var maxSitemapSize int64 = 50 * 1024 * 1024
+
+func ParseSiteMap(site *url.URL, crawler *Crawler, wg *sync.WaitGroup) {
 	defer wg.Done()
 	sitemapUrls := []string{"/sitemap.xml", "/sitemap_news.xml", "/sitemap_index.xml", "/sitemap-index.xml", "/sitemapindex.xml",
 		"/sitemap-news.xml", "/post-sitemap.xml", "/page-sitemap.xml", "/portfolio-sitemap.xml", "/home_slider-sitemap.xml", "/category-sitemap.xml",
-		"/author-sitemap.xml"}
+		"/author-sitemap.xml", "/sitemap.xml.gz"}
 
 	for _, path := range sitemapUrls {
 		// Ignore error when that not valid sitemap.xml path
-		Logger.Infof("Trying to find %s", site.String()+path)
-		_ = sitemap.ParseFromSite(site.String()+path, func(entry sitemap.Entry) error {
-			outputFormat := fmt.Sprintf("[sitemap] - %s", entry.GetLocation())
-			fmt.Println(outputFormat)
-			if output != nil {
-				output.WriteToFile(outputFormat)
-			}
-			_ = c.Visit(entry.GetLocation())
-			return nil
+		sitemapURL := site.Scheme + "://" + site.Host + path
+		Logger.Infof("Trying to find %s", sitemapURL)
+		crawler.parseSitemap(sitemapURL, 0)
+	}
+
+}
+
+// Parse a sitemap or sitemap index, plain or gzip, and follow nested sitemaps in scope
+func (crawler *Crawler) parseSitemap(sitemapURL string, depth int) {
+	u, err := url.Parse(sitemapURL)
+	if err != nil || !crawler.scope.InScope(u) {
+		Logger.Debugf("Sitemap out of scope, skip: %s", sitemapURL)
+		return
+	}
+	if crawler.sitemapSet.Duplicate(sitemapURL) {
+		return
+	}
+
+	body, err := fetchSitemap(crawler.client, sitemapURL)
+	if err != nil {
+		Logger.Debugf("Failed to fetch sitemap %s: %s", sitemapURL, err)
+		return
+	}
+	crawler.parseSitemapBody(sitemapURL, body, depth)
+}
+
+// Report and crawl the entries of a fetched sitemap, and follow the nested sitemaps of an index
+func (crawler *Crawler) parseSitemapBody(sitemapURL string, body []byte, depth int) {
+	_ = sitemap.Parse(bytes.NewReader(body), func(entry sitemap.Entry) error {
+		outputFormat := fmt.Sprintf("[sitemap] - %s", entry.GetLocation())
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     sitemapURL,
+			OutputType: "sitemap",
+			Output:     entry.GetLocation(),
 		})
+		crawler.mainVisits.visit(entry.GetLocation())
+		return nil
+	})
+
+	if depth >= maxSitemapDepth {
+		return
+	}
+	_ = sitemap.ParseIndex(bytes.NewReader(body), func(entry sitemap.IndexEntry) error {
+		crawler.parseSitemap(entry.GetLocation(), depth+1)
+		return nil
+	})
+}
+
+// Fetch a sitemap body, transparently decompressing gzip sitemaps
+func fetchSitemap(client *http.Client, sitemapURL string) ([]byte, error) {
+	resp, err := client.Get(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code %d", resp.StatusCode)
 	}
 
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// gzip magic number
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		body, err := ioutil.ReadAll(io.LimitReader(gr, maxSitemapSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(body)) > maxSitemapSize {
+			return nil, fmt.Errorf("decompressed sitemap larger than %d bytes", maxSitemapSize)
+		}
+		return body, nil
+	}
+	return body, nil
 }
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseSiteMap(t *testing.T) {
+	var gzSitemap bytes.Buffer
+	gw := gzip.NewWriter(&gzSitemap)
+	_, _ = gw.Write([]byte(`<urlset><url><loc>https://example.com/nested</loc></url></urlset>`))
+	_ = gw.Close()
+
+	// Another host, out of scope
+	var outside int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&outside, 1)
+		fmt.Fprint(w, `<urlset><url><loc>https://example.com/secret</loc></url></urlset>`)
+	}))
+	defer other.Close()
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprintf(w, "Sitemap: %s/outside-sitemap.xml\n", otherURL)
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%s/posts.xml.gz</loc></sitemap><sitemap><loc>%s/nested.xml</loc></sitemap><sitemap><loc>%s/private/sitemap.xml</loc></sitemap></sitemapindex>`, ts.URL, otherURL, ts.URL)
+		case "/posts.xml.gz":
+			_, _ = w.Write(gzSitemap.Bytes())
+		case "/private/sitemap.xml":
+			t.Error("sitemap fetched from a denied path")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL)
+	opts := DefaultOptions()
+	opts.Quiet = true
+	opts.DenyPaths = []string{"/private/*"}
+	var mu sync.Mutex
+	var found []string
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.OutputType == "sitemap" {
+			found = append(found, r.Output)
+		}
+	}
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	ParseRobots(site, crawler, &wg)
+	ParseSiteMap(site, crawler, &wg)
+
+	if len(found) != 1 || found[0] != "https://example.com/nested" {
+		t.Errorf("unexpected sitemap entries: %v", found)
+	}
+	if n := atomic.LoadInt32(&outside); n != 0 {
+		t.Errorf("%d sitemaps fetched out of scope", n)
+	}
+}
+
+func TestFetchSitemapGzipLimit(t *testing.T) {
+	defer func(size int64) { maxSitemapSize = size }(maxSitemapSize)
+	maxSitemapSize = 1024
+
+	var bomb bytes.Buffer
+	gw := gzip.NewWriter(&bomb)
+	_, _ = gw.Write(bytes.Repeat([]byte(" "), 4096))
+	_ = gw.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(bomb.Bytes())
+	}))
+	defer ts.Close()
+
+	if body, err := fetchSitemap(http.DefaultClient, ts.URL+"/sitemap.xml.gz"); err == nil {
+		t.Errorf("got %d bytes, want an error", len(body))
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ReadSiteList reads one site per line from path, blank lines are skipped
+func ReadSiteList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var sites []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			sites = append(sites, line)
+		}
+	}
+	return sites, sc.Err()
+}
+
+// CrawlSites crawls every site with its own Crawler, at most threads sites at a time.
+// Each site keeps its own filters and output file, findings of all sites go to one stdout stream.
+// A site that fails to start or panics is logged and doesn't stop the others,
+// the first of these errors is returned once all sites are done.
+// With an output folder, the run is recorded in its run.json and the result of each site in its index.json.
+// With a metrics address, the stats of the crawls are served in the Prometheus format during the run.
+// Once Options.Context is done the running crawls stop gracefully and the other sites are skipped.
+// Invalid options fail the run before any site is crawled, see ValidateOptions.
+func CrawlSites(sites []string, opts Options, threads int) error {
+	if threads < 1 {
+		threads = 1
+	}
+
+	// Bad patterns and rule files would fail every site, stop before crawling any
+	if errs := ValidateOptions(opts); len(errs) > 0 {
+		for _, err := range errs {
+			Logger.Error(err)
+		}
+		return errs[0]
+	}
+
+	if opts.MetricsAddr != "" {
+		server, err := ServeMetrics(opts.MetricsAddr)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+	}
+	if opts.ControlAddr != "" {
+		token, _ := ExpandEnv(opts.ControlToken)
+		server, err := ServeControl(opts.ControlAddr, token)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+	}
+
+	var manifest *RunManifest
+	var index *RunIndex
+	if opts.OutputFolder != "" {
+		manifest = NewRunManifest(sites, opts, threads)
+		writeManifest(manifest, opts.OutputFolder)
+		index = newRunIndex(opts.OutputFolder, sites)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	setErr := func(err error) {
+		Logger.Error(err)
+		errMu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		errMu.Unlock()
+	}
+
+	inputChan := make(chan string, threads)
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rawSite := range inputChan {
+				var result SiteResult
+				var err error
+				if opts.Context != nil && opts.Context.Err() != nil {
+					Logger.Warnf("Crawl interrupted, skip: %s", rawSite)
+					result = SiteResult{Status: SiteAborted, Reason: "interrupted"}
+				} else if result, err = crawlSite(rawSite, opts); err != nil {
+					setErr(err)
+					result = SiteResult{Status: SiteAborted, Reason: err.Error()}
+				}
+				if index != nil {
+					index.add(rawSite, result)
+				}
+			}
+		}()
+	}
+
+	for _, site := range sites {
+		inputChan <- site
+	}
+	close(inputChan)
+	wg.Wait()
+
+	if manifest != nil {
+		manifest.Finish(firstErr)
+		writeManifest(manifest, opts.OutputFolder)
+	}
+	// Only the encrypted archive is left once every file is written
+	if opts.EncryptOutput != "" && opts.OutputFolder != "" {
+		archive, err := EncryptFolder(opts.OutputFolder, opts.EncryptOutput)
+		if err != nil {
+			setErr(err)
+		} else {
+			Logger.Infof("Output encrypted to %s", archive)
+		}
+	}
+	return firstErr
+}
+
+// Crawl one site of a site list, recovering from panics so the worker stays alive
+func crawlSite(rawSite string, opts Options) (result SiteResult, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("crawler of %s panicked: %v", rawSite, r)
+		}
+	}()
+
+	site, err := url.Parse(rawSite)
+	if err != nil {
+		return result, fmt.Errorf("failed to parse %s: %s", rawSite, err)
+	}
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		return result, fmt.Errorf("failed to crawl %s: %s", rawSite, err)
+	}
+	crawler.Run()
+	return crawler.Result(), nil
+}
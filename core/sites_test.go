@@ -0,0 +1,65 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestReadSiteList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-sites")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sites.txt")
+	content := "https://a.example.com\n\n  https://b.example.com  \n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sites, err := ReadSiteList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://a.example.com", "https://b.example.com"}
+	if !reflect.DeepEqual(sites, want) {
+		t.Errorf("ReadSiteList() = %v, want %v", sites, want)
+	}
+}
+
+func TestCrawlSitesIsolatesFailures(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><a href="/about">about</a></html>`)
+	}))
+	defer ts.Close()
+
+	opts := DefaultOptions()
+	opts.Robots = false
+	opts.Quiet = true
+
+	var mu sync.Mutex
+	inputs := make(map[string]bool)
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		inputs[r.Input] = true
+	}
+
+	// The unparsable site must not keep the live one from being crawled
+	err := CrawlSites([]string{"http://%zz", ts.URL}, opts, 2)
+	if err == nil {
+		t.Error("CrawlSites() returned no error for invalid site")
+	}
+	if !inputs[ts.URL] {
+		t.Errorf("live site not crawled, got %v", inputs)
+	}
+}
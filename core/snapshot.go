@@ -0,0 +1,180 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrawlSnapshot is the queue of a site crawl at one time, in a JSON form meant to be edited:
+// removing pending requests prunes a branch, adding some crawls them, removing visited
+// ones lets them be requested again
+type CrawlSnapshot struct {
+	Site    string            `json:"site"`
+	Time    time.Time         `json:"time"`
+	Visited []SnapshotRequest `json:"visited"`
+	Pending []SnapshotRequest `json:"pending"`
+}
+
+// SnapshotRequest is a request of a collector ("main" for pages, "linkfinder" for
+// JavaScript and other files scanned for links), Depth is the depth of a pending one
+type SnapshotRequest struct {
+	Collector string `json:"collector"`
+	URL       string `json:"url"`
+	Depth     int    `json:"depth,omitempty"`
+}
+
+// Collectors of the requests in a snapshot
+var snapshotCollectors = map[string]bool{"main": true, "linkfinder": true}
+
+// Snapshot returns the finished requests and the ones queued or in flight
+func (s *CrawlState) Snapshot() CrawlSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := CrawlSnapshot{
+		Site:    string(s.site),
+		Time:    time.Now().UTC(),
+		Visited: []SnapshotRequest{},
+		Pending: []SnapshotRequest{},
+	}
+	for key := range s.done {
+		snap.Visited = append(snap.Visited, snapshotRequest(key, 0))
+	}
+	pending := make(map[string]int)
+	for key, depth := range s.resume {
+		pending[key] = depth
+	}
+	for _, req := range s.inflight {
+		pending[req.key] = req.depth
+	}
+	for key, depth := range pending {
+		snap.Pending = append(snap.Pending, snapshotRequest(key, depth))
+	}
+	sortSnapshotRequests(snap.Visited)
+	sortSnapshotRequests(snap.Pending)
+	return snap
+}
+
+func snapshotRequest(key string, depth int) SnapshotRequest {
+	args := strings.SplitN(key, " ", 2)
+	return SnapshotRequest{Collector: args[0], URL: args[1], Depth: depth}
+}
+
+func sortSnapshotRequests(requests []SnapshotRequest) {
+	sort.Slice(requests, func(i, j int) bool {
+		if requests[i].URL != requests[j].URL {
+			return requests[i].URL < requests[j].URL
+		}
+		return requests[i].Collector < requests[j].Collector
+	})
+}
+
+// Import replaces the finished and pending requests of the state with the ones of snap,
+// the pending ones are visited when the crawl starts
+func (s *CrawlState) Import(snap CrawlSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = make(map[string]bool)
+	s.resume = make(map[string]int)
+	for _, req := range snap.Visited {
+		s.done[req.Collector+" "+req.URL] = true
+	}
+	for _, req := range snap.Pending {
+		s.resume[req.Collector+" "+req.URL] = req.Depth
+	}
+}
+
+// LoadSnapshots reads a snapshot file, the JSON array served by the snapshot API
+func LoadSnapshots(path string) ([]CrawlSnapshot, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %s", err)
+	}
+	var snaps []CrawlSnapshot
+	if err := json.Unmarshal(raw, &snaps); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %s", path, err)
+	}
+	for _, snap := range snaps {
+		for _, req := range append(append([]SnapshotRequest{}, snap.Visited...), snap.Pending...) {
+			if !snapshotCollectors[req.Collector] || req.URL == "" {
+				return nil, fmt.Errorf("invalid request %+v of %s in snapshot %s, use the main or linkfinder collector", req, snap.Site, path)
+			}
+		}
+	}
+	return snaps, nil
+}
+
+// Replace the progress of the state with the snapshot of its site in the file at path,
+// the state of a site without snapshot is left as is
+func importSnapshot(state *CrawlState, path string) error {
+	snaps, err := LoadSnapshots(path)
+	if err != nil {
+		return err
+	}
+	for _, snap := range snaps {
+		if snap.Site == string(state.site) {
+			state.Import(snap)
+			Logger.Infof("Imported %d visited and %d pending requests of %s", len(snap.Visited), len(snap.Pending), snap.Site)
+			return nil
+		}
+	}
+	return nil
+}
+
+// stateRegistry is the resume states of the running crawls, exported by the snapshot API
+type stateRegistry struct {
+	mu     sync.Mutex
+	states map[*CrawlState]bool
+}
+
+var liveStates = &stateRegistry{states: make(map[*CrawlState]bool)}
+
+func (r *stateRegistry) register(state *CrawlState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state] = true
+}
+
+func (r *stateRegistry) unregister(state *CrawlState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.states, state)
+}
+
+// Snapshots of the running crawls, by site
+func (r *stateRegistry) snapshots() []CrawlSnapshot {
+	r.mu.Lock()
+	states := make([]*CrawlState, 0, len(r.states))
+	for state := range r.states {
+		states = append(states, state)
+	}
+	r.mu.Unlock()
+
+	snaps := make([]CrawlSnapshot, 0, len(states))
+	for _, state := range states {
+		snaps = append(snaps, state.Snapshot())
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Site < snaps[j].Site })
+	return snaps
+}
+
+// serveSnapshot answers the snapshots of the crawls running with --resume, to edit and
+// import in the next run with --import-snapshot:
+//
+//	curl -s -H "Authorization: Bearer $GOSPIDER_CONTROL_TOKEN" http://localhost:9091/snapshot > queue.json
+func serveSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(liveStates.snapshots())
+}
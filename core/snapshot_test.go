@@ -0,0 +1,104 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCrawlStateSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	state, err := OpenCrawlState(filepath.Join(dir, "state.db"), "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	home, _ := url.Parse("https://example.com/")
+	app, _ := url.Parse("https://example.com/app.js")
+	homeReq := &colly.Request{URL: home, Depth: 1}
+	state.enqueue("main", homeReq)
+	state.enqueue("linkfinder", &colly.Request{URL: app, Depth: 2})
+	state.finish(homeReq)
+
+	liveStates.register(state)
+	rec := httptest.NewRecorder()
+	serveSnapshot(rec, httptest.NewRequest("GET", "/snapshot", nil))
+	liveStates.unregister(state)
+	var snaps []CrawlSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &snaps); err != nil || len(snaps) != 1 {
+		t.Fatalf("snapshot API answered %s", rec.Body.String())
+	}
+	snap := snaps[0]
+	if snap.Site != "https://example.com" ||
+		!reflect.DeepEqual(snap.Visited, []SnapshotRequest{{Collector: "main", URL: "https://example.com/"}}) ||
+		!reflect.DeepEqual(snap.Pending, []SnapshotRequest{{Collector: "linkfinder", URL: "https://example.com/app.js", Depth: 2}}) {
+		t.Errorf("snapshot = %+v", snap)
+	}
+
+	snap.Pending = []SnapshotRequest{{Collector: "main", URL: "https://example.com/docs", Depth: 3}}
+	state.Import(snap)
+	if want := map[string][]string{"main": {"https://example.com/docs"}}; !reflect.DeepEqual(state.Pending(), want) {
+		t.Errorf("Pending() = %v, want %v", state.Pending(), want)
+	}
+
+	path := filepath.Join(dir, "invalid.json")
+	_ = ioutil.WriteFile(path, []byte(`[{"site": "https://example.com", "pending": [{"collector": "render", "url": "https://example.com/"}]}]`), 0644)
+	if _, err := LoadSnapshots(path); err == nil {
+		t.Error("unknown collector accepted")
+	}
+}
+
+func TestCrawlerImportSnapshot(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<html><a href="/calendar/1">calendar</a><a href="/about">about</a></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gospider-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The calendar branch pruned from the queue of an interrupted crawl
+	snap := []CrawlSnapshot{{
+		Site:    ts.URL,
+		Visited: []SnapshotRequest{{Collector: "main", URL: ts.URL}},
+		Pending: []SnapshotRequest{{Collector: "main", URL: ts.URL + "/about", Depth: 2}},
+	}}
+	raw, _ := json.Marshal(snap)
+	snapshotPath := filepath.Join(dir, "queue.json")
+	_ = ioutil.WriteFile(snapshotPath, raw, 0644)
+
+	crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Depth = 2
+		opts.Resume = filepath.Join(dir, "state.db")
+		opts.ImportSnapshot = snapshotPath
+	})
+
+	if !reflect.DeepEqual(requested, []string{"/about"}) {
+		t.Errorf("requested %v", requested)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ParseSourceIPs parses the local addresses to send the requests from, each one must be
+// assigned to an interface of the machine
+func ParseSourceIPs(raws []string) ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the local addresses: %s", err)
+	}
+	var ips []net.IP
+	for _, raw := range raws {
+		ip := net.ParseIP(strings.TrimSpace(raw))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source IP %q", raw)
+		}
+		local := false
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				local = true
+				break
+			}
+		}
+		if !local {
+			return nil, fmt.Errorf("source IP %s isn't assigned to an interface", ip)
+		}
+		ips = append(ips, ip)
+	}
+	return ips, nil
+}
+
+// SourceIPDialer dials each connection from the next of ips in turn, resolving hosts with
+// resolver. An address of the other family than the destination is skipped for the next one.
+func SourceIPDialer(ips []net.IP, resolver *net.Resolver) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialers := make([]*net.Dialer, len(ips))
+	for i, ip := range ips {
+		dialers[i] = &net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Resolver:  resolver,
+			LocalAddr: &net.TCPAddr{IP: ip},
+		}
+	}
+	var next uint32
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := int(atomic.AddUint32(&next, 1) - 1)
+		var err error
+		for i := range dialers {
+			var conn net.Conn
+			conn, err = dialers[(start+i)%len(dialers)].DialContext(ctx, network, addr)
+			var addrErr *net.AddrError
+			if err == nil || !errors.As(err, &addrErr) {
+				return conn, err
+			}
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestParseSourceIPs(t *testing.T) {
+	ips, err := ParseSourceIPs([]string{"127.0.0.1"})
+	if err != nil || len(ips) != 1 || !ips[0].Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("ParseSourceIPs(127.0.0.1) = %v, %v", ips, err)
+	}
+	for _, raw := range []string{"localhost", "192.0.2.77"} {
+		if _, err := ParseSourceIPs([]string{raw}); err == nil {
+			t.Errorf("ParseSourceIPs(%s) accepted", raw)
+		}
+	}
+}
+
+func TestSourceIPDialer(t *testing.T) {
+	var mu sync.Mutex
+	sources := make(map[string]int)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := net.SplitHostPort(r.RemoteAddr)
+		mu.Lock()
+		sources[host]++
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	// The IPv6 address can't reach the IPv4 server, the next one is used
+	ips := []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}
+	transport := &http.Transport{DialContext: SourceIPDialer(ips, net.DefaultResolver), DisableKeepAlives: true}
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 6; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if sources["127.0.0.1"] != 4 || sources["127.0.0.2"] != 2 {
+		t.Errorf("requests by source address: %v", sources)
+	}
+
+	dial := SourceIPDialer([]net.IP{net.ParseIP("::1")}, net.DefaultResolver)
+	if _, err := dial(context.Background(), "tcp", ts.Listener.Addr().String()); err == nil {
+		t.Error("IPv4 address dialed from an IPv6 one")
+	}
+}
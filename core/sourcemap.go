@@ -0,0 +1,194 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Sourcemaps are larger than the bundle they describe
+const maxSourceMapSize = 20 * 1024 * 1024
+
+var sourceMappingURLRegex = regexp.MustCompile(`(?m)^\s*//[#@]\s*sourceMappingURL=(\S+)\s*$`)
+
+// SourceMap is a version 3 sourcemap with the original sources it embeds
+type SourceMap struct {
+	Version        int       `json:"version"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+}
+
+// ParseSourceMap reads a sourcemap, ok is false when data isn't one
+func ParseSourceMap(data []byte) (SourceMap, bool) {
+	var sm SourceMap
+	if err := json.Unmarshal(data, &sm); err != nil || sm.Version != 3 || len(sm.Sources) == 0 {
+		return SourceMap{}, false
+	}
+	return sm, true
+}
+
+// Content returns the original content of the i-th source, ok is false when the map doesn't embed it
+func (sm SourceMap) Content(i int) (string, bool) {
+	if i >= len(sm.SourcesContent) || sm.SourcesContent[i] == nil {
+		return "", false
+	}
+	return *sm.SourcesContent[i], true
+}
+
+// SourceMapURL returns the sourcemap location of a JavaScript file: the SourceMap header,
+// the sourceMappingURL comment (possibly an inline data: URL) or the file URL with .map appended
+func SourceMapURL(jsURL *url.URL, headers http.Header, body string) string {
+	ref := headers.Get("SourceMap")
+	if ref == "" {
+		ref = headers.Get("X-SourceMap")
+	}
+	if ref == "" {
+		if matches := sourceMappingURLRegex.FindAllStringSubmatch(body, -1); len(matches) > 0 {
+			ref = matches[len(matches)-1][1]
+		}
+	}
+	if strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+	if ref != "" {
+		if u, err := jsURL.Parse(ref); err == nil {
+			return u.String()
+		}
+	}
+	mapURL := *jsURL
+	mapURL.RawQuery = ""
+	mapURL.Fragment = ""
+	mapURL.Path += ".map"
+	mapURL.RawPath = ""
+	return mapURL.String()
+}
+
+// Decode an inline sourcemap data: URL
+func decodeDataURL(ref string) ([]byte, bool) {
+	i := strings.Index(ref, ",")
+	if i < 0 {
+		return nil, false
+	}
+	meta, data := ref[len("data:"):i], ref[i+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		return decoded, err == nil
+	}
+	decoded, err := url.PathUnescape(data)
+	return []byte(decoded), err == nil
+}
+
+// SourceFilePath turns a source name (Ex: webpack:///./src/api.js) into a relative
+// file path that can't leave the folder it's joined to
+func SourceFilePath(name string) string {
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	name = strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if name == "" {
+		return "source"
+	}
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = safeFileName(segment)
+	}
+	return filepath.Join(segments...)
+}
+
+// Fetch the sourcemap of a JavaScript file, then report and analyze its original sources
+func (crawler *Crawler) findSourceMap(jsURL *url.URL, headers http.Header, body string) {
+	mapURL := SourceMapURL(jsURL, headers, body)
+	label := mapURL
+	if strings.HasPrefix(mapURL, "data:") {
+		label = jsURL.String() + "#inline-sourcemap"
+	}
+	if crawler.sourceMapSet.Duplicate(label) {
+		return
+	}
+
+	var data []byte
+	if strings.HasPrefix(mapURL, "data:") {
+		var ok bool
+		if data, ok = decodeDataURL(mapURL); !ok {
+			return
+		}
+	} else {
+		u, err := url.Parse(mapURL)
+		if err != nil || crawler.scope.Excluded(u) {
+			return
+		}
+		req, err := crawler.newRequest("GET", mapURL)
+		if err != nil {
+			return
+		}
+		resp, err := crawler.client.Do(req)
+		if err != nil {
+			Logger.Debugf("Failed to get sourcemap %s: %s", mapURL, err)
+			return
+		}
+		data, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxSourceMapSize))
+		resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return
+		}
+	}
+
+	sm, ok := ParseSourceMap(data)
+	if !ok {
+		return
+	}
+	outputFormat := fmt.Sprintf("[sourcemap] - [from: %s] - [sources: %d] - %s", jsURL, len(sm.Sources), label)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     jsURL.String(),
+		OutputType: "sourcemap",
+		Output:     label,
+		Details:    map[string]string{"sources": strconv.Itoa(len(sm.Sources))},
+	})
+
+	for i, name := range sm.Sources {
+		name = sm.SourceRoot + name
+		// Dependencies and bundler runtime aren't the application code
+		if strings.Contains(name, "node_modules/") || strings.Contains(name, "webpack/bootstrap") {
+			continue
+		}
+		outputFormat := fmt.Sprintf("[sourcemap-source] - [from: %s] - %s", label, name)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     label,
+			OutputType: "sourcemap-source",
+			Output:     name,
+		})
+
+		content, ok := sm.Content(i)
+		if !ok {
+			continue
+		}
+		crawler.saveSource(jsURL, name, content)
+		crawler.analyzeJS(label+"#"+name, jsURL, content)
+	}
+}
+
+// Save an original source to <output>/sourcemaps/<host>/<source path>
+func (crawler *Crawler) saveSource(jsURL *url.URL, name, content string) {
+	if crawler.opts.OutputFolder == "" {
+		return
+	}
+	file := filepath.Join(crawler.opts.OutputFolder, "sourcemaps", hostDirName(jsURL.Host), SourceFilePath(name))
+	if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+		Logger.Errorf("Failed to save source %s: %s", name, err)
+		return
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		Logger.Errorf("Failed to save source %s: %s", name, err)
+	}
+}
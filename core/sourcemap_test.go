@@ -0,0 +1,111 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceFilePath(t *testing.T) {
+	tests := map[string]string{
+		"webpack:///./src/api.js":     filepath.Join("src", "api.js"),
+		"webpack:///../../etc/passwd": filepath.Join("etc", "passwd"),
+		`..\..\windows\win.ini`:       filepath.Join("windows", "win.ini"),
+		"/abs/path.ts":                filepath.Join("abs", "path.ts"),
+		"webpack:///":                 "source",
+	}
+	for name, want := range tests {
+		if got := SourceFilePath(name); got != want {
+			t.Errorf("SourceFilePath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSourceMapURL(t *testing.T) {
+	js, _ := url.Parse("https://example.com/static/app.js?v=1")
+	if got := SourceMapURL(js, http.Header{}, "var a;"); got != "https://example.com/static/app.js.map" {
+		t.Errorf("default sourcemap URL = %q", got)
+	}
+	if got := SourceMapURL(js, http.Header{}, "var a;\n//# sourceMappingURL=maps/app.map\n"); got != "https://example.com/static/maps/app.map" {
+		t.Errorf("comment sourcemap URL = %q", got)
+	}
+	headers := http.Header{}
+	headers.Set("SourceMap", "/sm/app.js.map")
+	if got := SourceMapURL(js, headers, "//# sourceMappingURL=other.map"); got != "https://example.com/sm/app.js.map" {
+		t.Errorf("header sourcemap URL = %q", got)
+	}
+}
+
+func TestFindSourceMap(t *testing.T) {
+	source := "fetch('/api/v2/internal/users');\nconst token = 'ghp_" + "abcdefghijklmnopqrstuvwxyz0123456789';"
+	sm, _ := json.Marshal(map[string]interface{}{
+		"version":        3,
+		"sources":        []string{"webpack:///./src/api.js", "webpack:///./node_modules/lib/index.js"},
+		"sourcesContent": []string{source, "module.exports = 1"},
+		"mappings":       "AAAA",
+	})
+	inline, _ := json.Marshal(map[string]interface{}{
+		"version":        3,
+		"sources":        []string{"inline.ts"},
+		"sourcesContent": []string{"fetch('/api/inline/ping')"},
+	})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><script src="/app.js"></script><script src="/inline.js"></script></html>`)
+		case "/app.js":
+			fmt.Fprint(w, "!function(){}();\n//# sourceMappingURL=app.js.map")
+		case "/app.js.map":
+			_, _ = w.Write(sm)
+		case "/inline.js":
+			fmt.Fprint(w, "!function(){}();\n//# sourceMappingURL=data:application/json;base64,"+base64.StdEncoding.EncodeToString(inline))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	folder, err := ioutil.TempDir("", "gospider-sourcemap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	findings := crawlForTest(t, ts.URL, func(opts *Options) {
+		opts.Secrets = true
+		opts.OutputFolder = folder
+	})
+
+	sourcemaps, sources := outputsOf(findings, "sourcemap"), outputsOf(findings, "sourcemap-source")
+	if !sourcemaps[ts.URL+"/app.js.map"] || !sourcemaps[ts.URL+"/inline.js#inline-sourcemap"] {
+		t.Errorf("sourcemaps not reported, got %v", sourcemaps)
+	}
+	if !sources["webpack:///./src/api.js"] || sources["webpack:///./node_modules/lib/index.js"] {
+		t.Errorf("unexpected sourcemap sources %v", sources)
+	}
+	if paths := outputsOf(findings, "linkfinder"); !paths["/api/v2/internal/users"] || !paths["/api/inline/ping"] {
+		t.Errorf("paths of original sources not found, got %v", paths)
+	}
+	if secrets := outputsOf(findings, "secret"); len(secrets) != 1 {
+		t.Errorf("secret of original source not found, got %v", secrets)
+	}
+	for _, r := range findingsOf(findings, "secret") {
+		if r.Source != ts.URL+"/app.js.map#webpack:///./src/api.js" {
+			t.Errorf("secret reported from %s", r.Source)
+		}
+	}
+
+	saved, err := ioutil.ReadFile(filepath.Join(folder, "sourcemaps", hostDirName(ts.Listener.Addr().String()), "src", "api.js"))
+	if err != nil || string(saved) != source {
+		t.Errorf("original source not saved: %v", err)
+	}
+}
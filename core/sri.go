@@ -0,0 +1,110 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"net/url"
+	"strings"
+)
+
+// Include is a script or stylesheet loaded by a page
+type Include struct {
+	Kind        string // "script" or "style"
+	URL         string
+	Integrity   string
+	CrossOrigin string
+}
+
+// ParseInclude reads a script or stylesheet element, ok is false for other elements
+func ParseInclude(e *colly.HTMLElement) (include Include, ok bool) {
+	switch e.Name {
+	case "script":
+		include.Kind = "script"
+		include.URL = e.Attr("src")
+	case "link":
+		rel := strings.Fields(strings.ToLower(e.Attr("rel")))
+		as := strings.ToLower(e.Attr("as"))
+		for _, r := range rel {
+			switch {
+			case r == "stylesheet", r == "preload" && as == "style":
+				include.Kind = "style"
+			case r == "modulepreload", r == "preload" && as == "script":
+				include.Kind = "script"
+			}
+		}
+		include.URL = e.Attr("href")
+	}
+	if include.Kind == "" || strings.TrimSpace(include.URL) == "" {
+		return Include{}, false
+	}
+	include.URL = e.Request.AbsoluteURL(strings.TrimSpace(include.URL))
+	include.Integrity = strings.TrimSpace(e.Attr("integrity"))
+	include.CrossOrigin = e.Attr("crossorigin")
+	return include, include.URL != ""
+}
+
+// Algorithms of an integrity attribute (Ex: "sha384-... sha512-..." gives "sha384,sha512")
+func integrityAlgorithms(integrity string) string {
+	var algorithms []string
+	for _, hash := range strings.Fields(integrity) {
+		if i := strings.Index(hash, "-"); i > 0 {
+			algorithms = append(algorithms, hash[:i])
+		}
+	}
+	return strings.Join(Unique(algorithms), ",")
+}
+
+// Report scripts and stylesheets a page loads from other domains and whether they have
+// Subresource Integrity, the ones from uncommon domains without it are flagged as sri-missing
+func (crawler *Crawler) checkInclude(e *colly.HTMLElement) {
+	include, ok := ParseInclude(e)
+	if !ok {
+		return
+	}
+	u, err := url.Parse(include.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	domain := GetDomain(u)
+	if domain == "" {
+		domain = u.Hostname()
+	}
+	if pageDomain := GetDomain(e.Request.URL); domain == pageDomain || u.Host == e.Request.URL.Host {
+		return
+	}
+	// Inventory per host, the same include is reported once for every host using it
+	if crawler.includeSet.Duplicate(e.Request.URL.Host + " " + include.URL) {
+		return
+	}
+
+	page := e.Request.URL.String()
+	sri := integrityAlgorithms(include.Integrity)
+	if sri == "" {
+		sri = "none"
+	}
+	details := map[string]string{
+		"kind":      include.Kind,
+		"domain":    domain,
+		"integrity": include.Integrity,
+	}
+	if include.CrossOrigin != "" {
+		details["crossorigin"] = include.CrossOrigin
+	}
+	outputFormat := fmt.Sprintf("[third-party] - [from: %s] - [%s] - [sri: %s] - %s", page, include.Kind, sri, include.URL)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     page,
+		OutputType: "third-party",
+		Output:     include.URL,
+		Details:    details,
+	})
+
+	if include.Integrity == "" && !crawler.rules.isCommonIncludeHost(u.Hostname()) {
+		outputFormat = fmt.Sprintf("[sri-missing] - [from: %s] - [%s] - %s", page, include.Kind, include.URL)
+		crawler.Report(outputFormat, SpiderOutput{
+			Source:     page,
+			OutputType: "sri-missing",
+			Output:     include.URL,
+			Details:    details,
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIntegrityAlgorithms(t *testing.T) {
+	if got := integrityAlgorithms("sha384-abc sha512-def sha384-ghi"); got != "sha384,sha512" {
+		t.Errorf("integrityAlgorithms() = %q", got)
+	}
+	if got := integrityAlgorithms(""); got != "" {
+		t.Errorf("integrityAlgorithms(\"\") = %q", got)
+	}
+}
+
+func TestCheckInclude(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head>
+			<script src="https://cdn.jsdelivr.net/npm/lib.js" integrity="sha384-abc" crossorigin="anonymous"></script>
+			<script src="https://ajax.googleapis.com/ajax/libs/jquery.min.js"></script>
+			<script src="https://widgets.vendor.io/chat.js"></script>
+			<link rel="stylesheet" href="//fonts.vendor.io/font.css">
+			<link rel="preload" as="script" href="https://cdn.other.io/app.js">
+			<link rel="icon" href="https://cdn.other.io/favicon.ico">
+			<script src="/local.js"></script>
+		</head></html>`)
+	}))
+	defer ts.Close()
+
+	found := make(map[string]map[string]string)
+	for _, r := range crawlForTest(t, ts.URL, nil) {
+		if r.OutputType == "third-party" || r.OutputType == "sri-missing" {
+			if found[r.OutputType] == nil {
+				found[r.OutputType] = make(map[string]string)
+			}
+			found[r.OutputType][r.Output] = r.Details["kind"]
+		}
+	}
+
+	wantThirdParty := map[string]string{
+		"https://cdn.jsdelivr.net/npm/lib.js":                 "script",
+		"https://ajax.googleapis.com/ajax/libs/jquery.min.js": "script",
+		"https://widgets.vendor.io/chat.js":                   "script",
+		"http://fonts.vendor.io/font.css":                     "style",
+		"https://cdn.other.io/app.js":                         "script",
+	}
+	if len(found["third-party"]) != len(wantThirdParty) {
+		t.Errorf("third-party = %v, want %v", found["third-party"], wantThirdParty)
+	}
+	for u, kind := range wantThirdParty {
+		if found["third-party"][u] != kind {
+			t.Errorf("%s not reported as third party %s, got %v", u, kind, found["third-party"])
+		}
+	}
+
+	wantMissing := []string{"https://widgets.vendor.io/chat.js", "http://fonts.vendor.io/font.css", "https://cdn.other.io/app.js"}
+	if len(found["sri-missing"]) != len(wantMissing) {
+		t.Errorf("unexpected sri-missing findings %v", found["sri-missing"])
+	}
+	for _, u := range wantMissing {
+		if _, ok := found["sri-missing"][u]; !ok {
+			t.Errorf("%s not flagged as sri-missing", u)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"github.com/jaeles-project/gospider/stringset"
+	bolt "go.etcd.io/bbolt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets of a site in the state file
+var (
+	doneBucket    = []byte("done")
+	pendingBucket = []byte("pending")
+	filterPrefix  = "filter:"
+)
+
+// CrawlState persists the progress of a site crawl (finished and pending requests,
+// dedup filters) so an interrupted crawl can continue where it left off
+type CrawlState struct {
+	db   *bolt.DB
+	path string
+	site []byte
+
+	mu sync.Mutex
+	// Finished requests, "collector url" keys
+	done map[string]bool
+	// Requests sent or waiting for a slot, not finished yet
+	inflight map[*colly.Request]stateRequest
+	// Pending requests of the previous run with their depth, to be visited again
+	resume map[string]int
+}
+
+type stateRequest struct {
+	key   string
+	depth int
+}
+
+// State files are shared by the crawlers of a run (site list, subdomains),
+// bolt only allows one handle per file
+var (
+	stateDBMu   sync.Mutex
+	stateDBs    = make(map[string]*bolt.DB)
+	stateDBRefs = make(map[string]int)
+)
+
+func openStateDB(path string) (*bolt.DB, error) {
+	stateDBMu.Lock()
+	defer stateDBMu.Unlock()
+	if db, ok := stateDBs[path]; ok {
+		stateDBRefs[path]++
+		return db, nil
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state file %s: %s", path, err)
+	}
+	stateDBs[path] = db
+	stateDBRefs[path] = 1
+	return db, nil
+}
+
+func closeStateDB(path string) error {
+	stateDBMu.Lock()
+	defer stateDBMu.Unlock()
+	stateDBRefs[path]--
+	if stateDBRefs[path] > 0 {
+		return nil
+	}
+	db := stateDBs[path]
+	delete(stateDBs, path)
+	delete(stateDBRefs, path)
+	return db.Close()
+}
+
+// OpenCrawlState opens (or creates) the state of site in the state file at path
+func OpenCrawlState(path, site string) (*CrawlState, error) {
+	db, err := openStateDB(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &CrawlState{
+		db:       db,
+		path:     path,
+		site:     []byte(site),
+		done:     make(map[string]bool),
+		inflight: make(map[*colly.Request]stateRequest),
+		resume:   make(map[string]int),
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.site)
+		if b == nil {
+			return nil
+		}
+		if done := b.Bucket(doneBucket); done != nil {
+			_ = done.ForEach(func(k, _ []byte) error {
+				s.done[string(k)] = true
+				return nil
+			})
+		}
+		if pending := b.Bucket(pendingBucket); pending != nil {
+			_ = pending.ForEach(func(k, v []byte) error {
+				depth, _ := strconv.Atoi(string(v))
+				s.resume[string(k)] = depth
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		_ = closeStateDB(path)
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadFilters fills the dedup filters with the values saved by the previous run
+func (s *CrawlState) LoadFilters(filters map[string]stringset.Filter) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.site)
+		if b == nil {
+			return nil
+		}
+		for name, filter := range filters {
+			fb := b.Bucket([]byte(filterPrefix + name))
+			if fb == nil {
+				continue
+			}
+			_ = fb.ForEach(func(k, _ []byte) error {
+				filter.Duplicate(string(k))
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// Pending returns the requests left unfinished by the previous run, by collector
+func (s *CrawlState) Pending() map[string][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending := make(map[string][]string)
+	for key := range s.resume {
+		args := strings.SplitN(key, " ", 2)
+		pending[args[0]] = append(pending[args[0]], args[1])
+	}
+	return pending
+}
+
+// begin is called first for every request, it returns false for requests
+// finished by the previous run and restores the depth of resumed ones
+func (s *CrawlState) begin(collector string, r *colly.Request) bool {
+	key := collector + " " + r.URL.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done[key] {
+		return false
+	}
+	if depth, ok := s.resume[key]; ok {
+		delete(s.resume, key)
+		if depth > 0 {
+			r.Depth = depth
+		}
+	}
+	return true
+}
+
+// enqueue records a request that passed all checks
+func (s *CrawlState) enqueue(collector string, r *colly.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inflight[r] = stateRequest{key: collector + " " + r.URL.String(), depth: r.Depth}
+}
+
+// finish marks a request done, after its links were queued
+func (s *CrawlState) finish(r *colly.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req, ok := s.inflight[r]; ok {
+		delete(s.inflight, r)
+		s.done[req.key] = true
+	}
+}
+
+// Checkpoint writes the current progress and filters to the state file
+func (s *CrawlState) Checkpoint(filters map[string]stringset.Filter) error {
+	s.mu.Lock()
+	var doneKeys []string
+	for key := range s.done {
+		doneKeys = append(doneKeys, key)
+	}
+	pending := make(map[string]int)
+	for key, depth := range s.resume {
+		pending[key] = depth
+	}
+	for _, req := range s.inflight {
+		pending[req.key] = req.depth
+	}
+	s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		// Rewrite the whole site bucket, the state is small compared to the crawl
+		if tx.Bucket(s.site) != nil {
+			if err := tx.DeleteBucket(s.site); err != nil {
+				return err
+			}
+		}
+		b, err := tx.CreateBucket(s.site)
+		if err != nil {
+			return err
+		}
+
+		done, err := b.CreateBucket(doneBucket)
+		if err != nil {
+			return err
+		}
+		for _, key := range doneKeys {
+			if err := done.Put([]byte(key), []byte{}); err != nil {
+				return err
+			}
+		}
+
+		pb, err := b.CreateBucket(pendingBucket)
+		if err != nil {
+			return err
+		}
+		for key, depth := range pending {
+			if err := pb.Put([]byte(key), []byte(strconv.Itoa(depth))); err != nil {
+				return err
+			}
+		}
+
+		for name, filter := range filters {
+			fb, err := b.CreateBucket([]byte(filterPrefix + name))
+			if err != nil {
+				return err
+			}
+			for _, value := range filter.Slice() {
+				if value == "" {
+					continue
+				}
+				if err := fb.Put([]byte(value), []byte{}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Close releases the state file
+func (s *CrawlState) Close() error {
+	return closeStateDB(s.path)
+}
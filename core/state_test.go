@@ -0,0 +1,99 @@
+package core
+
+import (
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"github.com/jaeles-project/gospider/stringset"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCrawlStateCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.db")
+
+	state, err := OpenCrawlState(path, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, _ := url.Parse("https://example.com/")
+	pending, _ := url.Parse("https://example.com/about")
+	doneReq := &colly.Request{URL: done, Depth: 1}
+	pendingReq := &colly.Request{URL: pending, Depth: 2}
+	state.enqueue("main", doneReq)
+	state.enqueue("main", pendingReq)
+	state.finish(doneReq)
+
+	urlSet := stringset.NewStringFilter()
+	urlSet.Duplicate("https://example.com/")
+	if err := state.Checkpoint(map[string]stringset.Filter{"url": urlSet}); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = OpenCrawlState(path, "https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+
+	if want := map[string][]string{"main": {"https://example.com/about"}}; !reflect.DeepEqual(state.Pending(), want) {
+		t.Errorf("Pending() = %v, want %v", state.Pending(), want)
+	}
+	if state.begin("main", &colly.Request{URL: done}) {
+		t.Error("finished request not skipped")
+	}
+	resumed := &colly.Request{URL: pending, Depth: 1}
+	if !state.begin("main", resumed) || resumed.Depth != 2 {
+		t.Errorf("pending request not resumed at its depth, got depth %d", resumed.Depth)
+	}
+
+	loaded := stringset.NewStringFilter()
+	if err := state.LoadFilters(map[string]stringset.Filter{"url": loaded}); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Duplicate("https://example.com/") {
+		t.Error("url filter not restored")
+	}
+}
+
+func TestCrawlerResume(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><a href="/about">about</a></html>`)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gospider-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	crawl := func() []SpiderOutput {
+		return findingsOf(crawlForTest(t, ts.URL, func(opts *Options) {
+			opts.Depth = 2
+			opts.Resume = filepath.Join(dir, "state.db")
+		}), "url")
+	}
+
+	if found := crawl(); len(found) != 2 {
+		t.Fatalf("first run found %v", found)
+	}
+	// A finished crawl has nothing left to resume
+	if found := crawl(); len(found) != 0 {
+		t.Errorf("resumed finished crawl found %v", found)
+	}
+}
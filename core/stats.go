@@ -0,0 +1,353 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Width of the request rate samples
+	statsInterval = 10 * time.Second
+	// Samples kept, the last 10 minutes
+	statsWindow = 60
+)
+
+// CrawlStats are the counters of a crawl, safe for concurrent use
+type CrawlStats struct {
+	mu        sync.Mutex
+	requests  int
+	js        int
+	inFlight  int
+	responses map[string]int
+	bytes     int64
+	findings  map[string]int
+	depths    map[int]int
+	rate      []RateSample
+	running   bool
+}
+
+// RateSample is the request rate during one interval of the crawl
+type RateSample struct {
+	// Start of the interval
+	Time              time.Time `json:"time"`
+	Requests          int       `json:"requests"`
+	RequestsPerSecond float64   `json:"requests_per_second"`
+}
+
+// StatsSnapshot is a copy of the counters of a crawl
+type StatsSnapshot struct {
+	// Requests sent by the collectors, probes excluded
+	Requests int `json:"requests"`
+	// JSRequests sent by the link finder, counted in Requests too
+	JSRequests int `json:"js_requests"`
+	// InFlight requests are scheduled or sent and not answered yet
+	InFlight int `json:"in_flight"`
+	// Responses by status class ("2xx", "4xx"...), "error" when no response was received
+	Responses map[string]int `json:"responses"`
+	Bytes     int64          `json:"bytes"`
+	// Findings by output type
+	Findings map[string]int `json:"findings"`
+	// Requests by crawl depth, the site is depth 1
+	Depths map[int]int `json:"depths"`
+	// Request rate over the last 10 minutes in 10 seconds intervals, oldest first
+	Rate []RateSample `json:"rate"`
+}
+
+func newCrawlStats() *CrawlStats {
+	return &CrawlStats{
+		responses: make(map[string]int),
+		findings:  make(map[string]int),
+		depths:    make(map[int]int),
+	}
+}
+
+// send counts a request at depth
+func (s *CrawlStats) send(depth int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count(depth)
+}
+
+// sendJS counts a link finder request at depth
+func (s *CrawlStats) sendJS(depth int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.js++
+	s.count(depth)
+}
+
+func (s *CrawlStats) count(depth int) {
+	s.requests++
+	s.inFlight++
+	s.depths[depth]++
+	s.sample(time.Now())
+}
+
+// Count a request in the rate sample of now, idle intervals get an empty sample
+func (s *CrawlStats) sample(now time.Time) {
+	start := now.Truncate(statsInterval)
+	if n := len(s.rate); n > 0 {
+		last := s.rate[n-1].Time
+		if !start.After(last) {
+			s.rate[n-1].Requests++
+			return
+		}
+		if start.Sub(last) > statsWindow*statsInterval {
+			s.rate = s.rate[:0]
+		}
+		for t := last.Add(statsInterval); len(s.rate) > 0 && t.Before(start); t = t.Add(statsInterval) {
+			s.rate = append(s.rate, RateSample{Time: t})
+		}
+	}
+	s.rate = append(s.rate, RateSample{Time: start, Requests: 1})
+	if len(s.rate) > statsWindow {
+		s.rate = append(s.rate[:0], s.rate[len(s.rate)-statsWindow:]...)
+	}
+}
+
+// answered counts the response of a request, status 0 when it failed
+func (s *CrawlStats) answered(status int, size int) {
+	class := "error"
+	if status >= 100 && status < 600 {
+		class = fmt.Sprintf("%dxx", status/100)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inFlight--
+	s.responses[class]++
+	s.bytes += int64(size)
+}
+
+func (s *CrawlStats) found(outputType string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.findings[outputType]++
+}
+
+func (s *CrawlStats) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running = running
+}
+
+// Snapshot copies the current counters
+func (s *CrawlStats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := StatsSnapshot{
+		Requests:   s.requests,
+		JSRequests: s.js,
+		InFlight:   s.inFlight,
+		Responses:  make(map[string]int, len(s.responses)),
+		Bytes:      s.bytes,
+		Findings:   make(map[string]int, len(s.findings)),
+		Depths:     make(map[int]int, len(s.depths)),
+		Rate:       make([]RateSample, len(s.rate)),
+	}
+	for k, v := range s.responses {
+		snapshot.Responses[k] = v
+	}
+	for k, v := range s.findings {
+		snapshot.Findings[k] = v
+	}
+	for k, v := range s.depths {
+		snapshot.Depths[k] = v
+	}
+	for i, sample := range s.rate {
+		sample.RequestsPerSecond = float64(sample.Requests) / statsInterval.Seconds()
+		snapshot.Rate[i] = sample
+	}
+	return snapshot
+}
+
+// Answered is the number of requests that got a response
+func (s StatsSnapshot) Answered() int {
+	answered := 0
+	for class, n := range s.Responses {
+		if class != "error" {
+			answered += n
+		}
+	}
+	return answered
+}
+
+// TotalFindings is the number of findings of all types
+func (s StatsSnapshot) TotalFindings() int {
+	total := 0
+	for _, n := range s.Findings {
+		total += n
+	}
+	return total
+}
+
+// One line summary of the counters (Ex: "12 requests (2xx: 10, 4xx: 2), 1.2 MB, 10 urls, 2 subdomains, 3 javascript, 20 findings")
+func (s StatsSnapshot) String() string {
+	var classes []string
+	for class, n := range s.Responses {
+		classes = append(classes, fmt.Sprintf("%s: %d", class, n))
+	}
+	sort.Strings(classes)
+	summary := fmt.Sprintf("%d requests", s.Requests)
+	if len(classes) > 0 {
+		summary += " (" + strings.Join(classes, ", ") + ")"
+	}
+	return fmt.Sprintf("%s, %s, %d urls, %d subdomains, %d javascript, %d findings",
+		summary, formatBytes(s.Bytes), s.Findings["url"], s.Findings["subdomains"], s.Findings["javascript"], s.TotalFindings())
+}
+
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// metricsRegistry holds the stats of every crawl of the process once metrics are served
+type metricsRegistry struct {
+	mu      sync.Mutex
+	enabled bool
+	sites   []string
+	stats   []*CrawlStats
+}
+
+var crawlMetrics = &metricsRegistry{}
+
+func (m *metricsRegistry) register(site string, stats *CrawlStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.enabled {
+		m.sites = append(m.sites, site)
+		m.stats = append(m.stats, stats)
+	}
+}
+
+// SiteStats are the stats of the crawl of a site
+type SiteStats struct {
+	Site    string `json:"site"`
+	Running bool   `json:"running"`
+	StatsSnapshot
+}
+
+// Stats of the crawls, in start order
+func (m *metricsRegistry) snapshot() []SiteStats {
+	m.mu.Lock()
+	sites := append([]string(nil), m.sites...)
+	stats := append([]*CrawlStats(nil), m.stats...)
+	m.mu.Unlock()
+
+	all := make([]SiteStats, len(stats))
+	for i, s := range stats {
+		all[i] = SiteStats{Site: sites[i], StatsSnapshot: s.Snapshot()}
+		s.mu.Lock()
+		all[i].Running = s.running
+		s.mu.Unlock()
+	}
+	return all
+}
+
+// ServeJSON writes the stats of the crawls as JSON, for dashboards
+func (m *metricsRegistry) ServeJSON(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(struct {
+		Sites []SiteStats `json:"sites"`
+	}{m.snapshot()})
+}
+
+// ServeHTTP writes the stats of the crawls in the Prometheus text format
+func (m *metricsRegistry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	all := m.snapshot()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	metric := func(name, kind, help string, values func(s SiteStats, site string)) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, s := range all {
+			values(s, promLabel(s.Site))
+		}
+	}
+	metric("gospider_requests_total", "counter", "Requests sent by the crawlers.", func(s SiteStats, site string) {
+		fmt.Fprintf(w, "gospider_requests_total{site=\"%s\"} %d\n", site, s.Requests)
+	})
+	metric("gospider_responses_total", "counter", "Responses by status class, error when the request failed.", func(s SiteStats, site string) {
+		for _, class := range sortedKeys(s.Responses) {
+			fmt.Fprintf(w, "gospider_responses_total{site=\"%s\",class=\"%s\"} %d\n", site, class, s.Responses[class])
+		}
+	})
+	metric("gospider_response_bytes_total", "counter", "Bytes of response bodies downloaded.", func(s SiteStats, site string) {
+		fmt.Fprintf(w, "gospider_response_bytes_total{site=\"%s\"} %d\n", site, s.Bytes)
+	})
+	metric("gospider_requests_by_depth_total", "counter", "Requests by crawl depth.", func(s SiteStats, site string) {
+		depths := make([]int, 0, len(s.Depths))
+		for depth := range s.Depths {
+			depths = append(depths, depth)
+		}
+		sort.Ints(depths)
+		for _, depth := range depths {
+			fmt.Fprintf(w, "gospider_requests_by_depth_total{site=\"%s\",depth=\"%d\"} %d\n", site, depth, s.Depths[depth])
+		}
+	})
+	metric("gospider_findings_total", "counter", "Findings by output type.", func(s SiteStats, site string) {
+		for _, outputType := range sortedKeys(s.Findings) {
+			fmt.Fprintf(w, "gospider_findings_total{site=\"%s\",type=\"%s\"} %d\n", site, promLabel(outputType), s.Findings[outputType])
+		}
+	})
+	metric("gospider_requests_in_flight", "gauge", "Requests scheduled or sent and not answered yet.", func(s SiteStats, site string) {
+		fmt.Fprintf(w, "gospider_requests_in_flight{site=\"%s\"} %d\n", site, s.InFlight)
+	})
+	metric("gospider_crawl_running", "gauge", "Whether the crawl of the site is running.", func(s SiteStats, site string) {
+		value := 0
+		if s.Running {
+			value = 1
+		}
+		fmt.Fprintf(w, "gospider_crawl_running{site=\"%s\"} %d\n", site, value)
+	})
+}
+
+// Escape a Prometheus label value
+func promLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ServeMetrics serves the stats of the crawls started from now on at addr/metrics
+// in the Prometheus text format and at addr/stats in JSON, until the returned server is closed.
+// The endpoints changing the crawls or dumping their queues are served by ServeControl.
+func ServeMetrics(addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serve metrics: %s", err)
+	}
+	crawlMetrics.mu.Lock()
+	crawlMetrics.enabled = true
+	crawlMetrics.mu.Unlock()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", crawlMetrics)
+	mux.HandleFunc("/stats", crawlMetrics.ServeJSON)
+	server := &http.Server{Addr: ln.Addr().String(), Handler: mux}
+	go func() {
+		if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			Logger.Errorf("Metrics server failed: %s", err)
+		}
+	}()
+	Logger.Infof("Serving metrics on http://%s/metrics", ln.Addr())
+	return server, nil
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCrawlStats(t *testing.T) {
+	s := newCrawlStats()
+	for i := 0; i < 3; i++ {
+		s.send(1)
+	}
+	s.answered(200, 1500)
+	s.answered(404, 500)
+	s.found("url")
+	s.found("url")
+	s.found("subdomains")
+
+	snapshot := s.Snapshot()
+	if snapshot.Requests != 3 || snapshot.InFlight != 1 || snapshot.Bytes != 2000 || snapshot.Answered() != 2 || snapshot.TotalFindings() != 3 {
+		t.Errorf("unexpected snapshot %+v", snapshot)
+	}
+	want := "3 requests (2xx: 1, 4xx: 1), 2.0 kB, 2 urls, 1 subdomains, 0 javascript, 3 findings"
+	if got := snapshot.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	s.answered(0, 0)
+	if snapshot := s.Snapshot(); snapshot.Responses["error"] != 1 || snapshot.InFlight != 0 {
+		t.Errorf("failed request not counted: %+v", snapshot)
+	}
+	s.send(2)
+	if snapshot := s.Snapshot(); snapshot.Depths[1] != 3 || snapshot.Depths[2] != 1 {
+		t.Errorf("unexpected depths %v", snapshot.Depths)
+	}
+}
+
+func TestCrawlStatsRate(t *testing.T) {
+	s := newCrawlStats()
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.sample(start)
+	s.sample(start.Add(5 * time.Second))
+	s.sample(start.Add(30 * time.Second))
+	snapshot := s.Snapshot()
+	want := []RateSample{
+		{Time: start, Requests: 2, RequestsPerSecond: 0.2},
+		{Time: start.Add(10 * time.Second)},
+		{Time: start.Add(20 * time.Second)},
+		{Time: start.Add(30 * time.Second), Requests: 1, RequestsPerSecond: 0.1},
+	}
+	if !reflect.DeepEqual(snapshot.Rate, want) {
+		t.Errorf("Rate = %+v, want %+v", snapshot.Rate, want)
+	}
+
+	// Only the last 10 minutes are kept, a longer pause starts over
+	for i := 4; i < statsWindow+10; i++ {
+		s.sample(start.Add(time.Duration(i) * statsInterval))
+	}
+	if rate := s.Snapshot().Rate; len(rate) != statsWindow || !rate[0].Time.Equal(start.Add(10*statsInterval)) {
+		t.Errorf("window not kept: %d samples from %s", len(rate), rate[0].Time)
+	}
+	s.sample(start.Add(time.Hour))
+	if rate := s.Snapshot().Rate; len(rate) != 1 || rate[0].Requests != 1 {
+		t.Errorf("samples before a long pause kept: %+v", rate)
+	}
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	m := &metricsRegistry{}
+	s := newCrawlStats()
+	m.register("https://disabled.example.com", s)
+	m.enabled = true
+	m.register(`https://example.com/"quoted"`, s)
+	s.send(1)
+	s.send(2)
+	s.answered(301, 10)
+	s.found("javascript")
+	s.setRunning(true)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE gospider_requests_total counter",
+		`gospider_requests_total{site="https://example.com/\"quoted\""} 2`,
+		`gospider_responses_total{site="https://example.com/\"quoted\"",class="3xx"} 1`,
+		`gospider_response_bytes_total{site="https://example.com/\"quoted\""} 10`,
+		`gospider_requests_by_depth_total{site="https://example.com/\"quoted\"",depth="2"} 1`,
+		`gospider_findings_total{site="https://example.com/\"quoted\"",type="javascript"} 1`,
+		`gospider_requests_in_flight{site="https://example.com/\"quoted\""} 1`,
+		`gospider_crawl_running{site="https://example.com/\"quoted\""} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("metrics miss %q:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, "disabled.example.com") {
+		t.Error("crawl registered before metrics were enabled")
+	}
+
+	rec = httptest.NewRecorder()
+	m.ServeJSON(rec, httptest.NewRequest("GET", "/stats", nil))
+	var stats struct {
+		Sites []SiteStats `json:"sites"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Sites) != 1 || !stats.Sites[0].Running || stats.Sites[0].Requests != 2 || stats.Sites[0].Depths[2] != 1 || len(stats.Sites[0].Rate) != 1 {
+		t.Errorf("unexpected JSON stats %s", rec.Body)
+	}
+}
+
+func TestCrawlerStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<html><a href="/missing">missing</a><script src="/app.js"></script></html>`)
+	}))
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL)
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crawler.Run()
+
+	stats := crawler.Stats()
+	if stats.Requests != 3 || stats.InFlight != 0 || stats.Responses["2xx"] != 2 || stats.Responses["4xx"] != 1 {
+		t.Errorf("unexpected request stats %+v", stats)
+	}
+	if stats.Findings["url"] != 1 || stats.Findings["javascript"] != 1 || stats.Bytes == 0 {
+		t.Errorf("unexpected finding stats %+v", stats)
+	}
+	// The linked page is one level deeper, the script is fetched at depth 1 by the link finder
+	if stats.Depths[1] != 2 || stats.Depths[2] != 1 {
+		t.Errorf("unexpected depth stats %v", stats.Depths)
+	}
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cloud storage providers, also the labels of their findings
+const (
+	StorageS3    = "aws-s3"
+	StorageGCS   = "gcs"
+	StorageAzure = "azure-blob"
+)
+
+// GCS buckets: virtual host, path style and gs:// URLs
+var GCSBucket = regexp.MustCompile(`(?i)[a-z0-9._-]+\.storage\.googleapis\.com|//storage\.googleapis\.com/[a-z0-9._-]+|gs://[a-z0-9._-]+`)
+
+// Azure blob storage accounts, with their container when the URL has one
+var AzureBlob = regexp.MustCompile(`(?i)[a-z0-9]{3,24}\.blob\.core\.windows\.net(?:/[a-z0-9$][a-z0-9-]{2,62})?`)
+
+// First path segments of storage.googleapis.com that are APIs, not buckets
+var gcsAPIPaths = map[string]bool{"storage": true, "upload": true, "download": true, "batch": true}
+
+const (
+	// Requests per second of --check-buckets, apart from the rate limit of the crawl
+	bucketCheckRate = 5
+	// Buckets checked at the same time
+	bucketCheckWorkers = 4
+)
+
+// StorageBucket is a cloud storage bucket (an Azure container) found in a response,
+// Match is the text it was found by and Name is empty when the bucket isn't known
+// (an Azure account without container)
+type StorageBucket struct {
+	Provider string
+	Name     string
+	Match    string
+}
+
+// GetStorageBuckets finds S3, GCS and Azure blob storage URLs in source
+func GetStorageBuckets(source string) []StorageBucket {
+	var buckets []StorageBucket
+	for _, match := range GetAWSS3(source) {
+		buckets = append(buckets, StorageBucket{Provider: StorageS3, Name: s3BucketName(match), Match: match})
+	}
+	scanChunks(source, func(chunk string) {
+		for _, match := range GCSBucket.FindAllString(chunk, -1) {
+			match = DecodeChars(match)
+			name := strings.ToLower(match)
+			if i := strings.Index(name, ".storage.googleapis.com"); i >= 0 {
+				name = name[:i]
+			} else {
+				name = name[strings.LastIndex(name, "/")+1:]
+				if strings.Contains(match, "storage.googleapis.com") && gcsAPIPaths[name] {
+					continue
+				}
+			}
+			buckets = append(buckets, StorageBucket{Provider: StorageGCS, Name: name, Match: match})
+		}
+		for _, match := range AzureBlob.FindAllString(chunk, -1) {
+			name := ""
+			if i := strings.Index(match, "/"); i >= 0 {
+				account := strings.ToLower(match[:strings.Index(match, ".")])
+				name = account + "/" + strings.ToLower(match[i+1:])
+			}
+			buckets = append(buckets, StorageBucket{Provider: StorageAzure, Name: name, Match: match})
+		}
+	})
+	return buckets
+}
+
+// Bucket name of a match of AWSS3
+func s3BucketName(match string) string {
+	match = strings.ToLower(match)
+	if strings.HasPrefix(match, "//") {
+		return match[strings.LastIndex(match, "/")+1:]
+	}
+	if i := strings.Index(match, ".s3"); i >= 0 {
+		return match[:i]
+	}
+	return match
+}
+
+// Report the cloud storage URLs of a response, and check their buckets with --check-buckets
+func (crawler *Crawler) findCloudStorage(source, resp string) {
+	for _, bucket := range GetStorageBuckets(resp) {
+		if !crawler.awsSet.Duplicate(bucket.Match) {
+			outputFormat := fmt.Sprintf("[%s] - %s", bucket.Provider, bucket.Match)
+			crawler.Report(outputFormat, SpiderOutput{
+				Source:     source,
+				OutputType: bucket.Provider,
+				Output:     bucket.Match,
+			})
+		}
+		if crawler.bucketChecks != nil && bucket.Name != "" && !crawler.bucketCheckSet.Duplicate(bucket.Provider+":"+bucket.Name) {
+			bucket := bucket
+			crawler.bucketChecks.check(func() {
+				crawler.checkBucket(source, bucket)
+			})
+		}
+	}
+}
+
+// bucketCheckURL returns the URL of key in a bucket, the listing URL of the bucket when key is empty
+var bucketCheckURL = func(provider, bucket, key string) string {
+	switch provider {
+	case StorageGCS:
+		return "https://storage.googleapis.com/" + bucket + "/" + key
+	case StorageAzure:
+		args := strings.SplitN(bucket, "/", 2)
+		if key == "" {
+			return "https://" + args[0] + ".blob.core.windows.net/" + args[1] + "?restype=container&comp=list"
+		}
+		return "https://" + args[0] + ".blob.core.windows.net/" + args[1] + "/" + key
+	}
+	return "https://" + bucket + ".s3.amazonaws.com/" + key
+}
+
+// BucketAccess is what anonymous requests can do with a bucket
+type BucketAccess struct {
+	Exists bool
+	Read   bool
+	Write  bool
+}
+
+func (a BucketAccess) String() string {
+	if !a.Exists {
+		return "missing"
+	}
+	var access []string
+	if a.Read {
+		access = append(access, "public-read")
+	}
+	if a.Write {
+		access = append(access, "public-write")
+	}
+	if len(access) == 0 {
+		return "private"
+	}
+	return strings.Join(access, ", ")
+}
+
+// bucketChecker runs the --check-buckets probes in the background with their own client,
+// rate limited apart from the crawl so probing doesn't slow it down or get it throttled
+type bucketChecker struct {
+	client *http.Client
+	slots  chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newBucketChecker(base http.RoundTripper, timeout time.Duration) *bucketChecker {
+	return &bucketChecker{
+		client: &http.Client{
+			Transport: newRateLimitTransport(base, bucketCheckRate, bucketCheckWorkers, timeout),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		slots: make(chan struct{}, bucketCheckWorkers),
+	}
+}
+
+func (b *bucketChecker) check(probe func()) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.slots <- struct{}{}
+		defer func() { <-b.slots }()
+		probe()
+	}()
+}
+
+func (b *bucketChecker) wait() {
+	b.wg.Wait()
+}
+
+// Check a bucket and report what anonymous requests can do with it, unless the scope excludes it
+func (crawler *Crawler) checkBucket(source string, bucket StorageBucket) {
+	// Probes fail once the crawl is stopped, don't report the bucket missing
+	if crawler.budget.stopped() != "" {
+		return
+	}
+	if u, err := url.Parse(bucketCheckURL(bucket.Provider, bucket.Name, "")); err != nil || crawler.scope.Excluded(u) {
+		Logger.Debugf("Bucket %s:%s out of scope, skip", bucket.Provider, bucket.Name)
+		return
+	}
+	access, err := CheckBucket(crawler.bucketChecks.client, bucket.Provider, bucket.Name)
+	if err != nil {
+		Logger.Debugf("Failed to check bucket %s:%s: %s", bucket.Provider, bucket.Name, err)
+		return
+	}
+	if access.Write {
+		Logger.Warnf("Bucket %s:%s is publicly writable", bucket.Provider, bucket.Name)
+	}
+	outputFormat := fmt.Sprintf("[%s] - %s - [%s]", bucket.Provider, bucket.Name, access)
+	crawler.Report(outputFormat, SpiderOutput{
+		Source:     source,
+		OutputType: "bucket",
+		Output:     bucket.Name,
+		Details:    map[string]string{"provider": bucket.Provider, "access": access.String()},
+	})
+}
+
+// CheckBucket tells whether a bucket exists and whether anonymous requests can list it and
+// write to it. The write check uploads a small object and deletes it.
+func CheckBucket(client *http.Client, provider, bucket string) (BucketAccess, error) {
+	var access BucketAccess
+	resp, err := client.Get(bucketCheckURL(provider, bucket, ""))
+	if err != nil {
+		// Azure accounts that don't exist have no DNS record
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return access, nil
+		}
+		return access, err
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && (strings.Contains(string(body), "NoSuchBucket") || strings.Contains(string(body), "ContainerNotFound")) {
+		return access, nil
+	}
+	access.Exists = true
+	access.Read = resp.StatusCode == http.StatusOK
+
+	var suffix [6]byte
+	_, _ = rand.Read(suffix[:])
+	objectURL := bucketCheckURL(provider, bucket, "gospider-write-check-"+hex.EncodeToString(suffix[:])+".txt")
+	req, err := http.NewRequest("PUT", objectURL, strings.NewReader("gospider bucket write check\n"))
+	if err != nil {
+		return access, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if provider == StorageAzure {
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		return access, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
+		access.Write = true
+		// Don't leave the test object behind
+		if req, err := http.NewRequest("DELETE", objectURL, nil); err == nil {
+			if resp, err := client.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	return access, nil
+}
@@ -0,0 +1,162 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGetStorageBuckets(t *testing.T) {
+	source := `
+		var a = "https://assets.s3.amazonaws.com/logo.png";
+		var b = "//s3-eu-west-1.amazonaws.com/backups";
+		var c = "https://media.storage.googleapis.com/img.png";
+		var d = "https://storage.googleapis.com/exports/2020.csv";
+		var e = "gs://raw-data";
+		var f = "https://storage.googleapis.com/storage/v1/b/x";
+		var g = "https://examplecdn.blob.core.windows.net/uploads/a.png";
+		var h = "https://examplelogs.blob.core.windows.net";
+	`
+	found := make(map[string]bool)
+	for _, bucket := range GetStorageBuckets(source) {
+		found[bucket.Provider+":"+bucket.Name] = true
+	}
+	for _, want := range []string{"aws-s3:assets", "aws-s3:backups", "gcs:media", "gcs:exports", "gcs:raw-data", "azure-blob:examplecdn/uploads", "azure-blob:"} {
+		if !found[want] {
+			t.Errorf("bucket %s not found in %v", want, found)
+		}
+	}
+	if found["gcs:storage"] {
+		t.Error("GCS API path reported as a bucket")
+	}
+}
+
+func TestCrawlerCheckBuckets(t *testing.T) {
+	var mu sync.Mutex
+	var written, deleted []string
+	buckets := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		args := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 3)
+		bucket := args[1]
+		switch {
+		case bucket == "missing":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchBucket</Code></Error>`)
+		case bucket == "private":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code></Error>`)
+		case r.Method == "GET":
+			fmt.Fprint(w, `<ListBucketResult><Name>open</Name></ListBucketResult>`)
+		case r.Method == "PUT":
+			mu.Lock()
+			written = append(written, r.URL.Path)
+			mu.Unlock()
+		case r.Method == "DELETE":
+			mu.Lock()
+			deleted = append(deleted, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer buckets.Close()
+	defaultURL := bucketCheckURL
+	bucketCheckURL = func(provider, bucket, key string) string {
+		return buckets.URL + "/" + provider + "/" + strings.ReplaceAll(bucket, "/", "_") + "/" + key
+	}
+	defer func() { bucketCheckURL = defaultURL }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><script>
+			var a = "https://open.s3.amazonaws.com/a.png";
+			var b = "https://private.storage.googleapis.com/b.png";
+			var c = "https://missing.s3.amazonaws.com/c.png";
+			var d = "https://missing.s3.amazonaws.com/d.png";
+		</script></html>`)
+	}))
+	defer ts.Close()
+
+	found := make(map[string]string)
+	for _, r := range findingsOf(crawlForTest(t, ts.URL+"/", func(opts *Options) { opts.CheckBuckets = true }), "bucket") {
+		if _, ok := found[r.Details["provider"]+":"+r.Output]; ok {
+			t.Errorf("bucket %s checked twice", r.Output)
+		}
+		found[r.Details["provider"]+":"+r.Output] = r.Details["access"]
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := map[string]string{
+		"aws-s3:open":    "public-read, public-write",
+		"gcs:private":    "private",
+		"aws-s3:missing": "missing",
+	}
+	for bucket, access := range want {
+		if found[bucket] != access {
+			t.Errorf("bucket %s access %q, want %q", bucket, found[bucket], access)
+		}
+	}
+	if len(written) != 1 || len(deleted) != 1 || written[0] != deleted[0] {
+		t.Errorf("write check objects written %v, deleted %v", written, deleted)
+	}
+}
+
+func TestCrawlerCheckBucketsScope(t *testing.T) {
+	var mu sync.Mutex
+	probed := make(map[string]bool)
+	// Proxy for the site and the buckets, every bucket is writable
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host == "example.test" {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<html><script>
+				var a = "https://open.s3.amazonaws.com/a.png";
+				var b = "https://thirdparty.s3.amazonaws.com/b.png";
+			</script></html>`)
+			return
+		}
+		mu.Lock()
+		probed[r.Method+" "+r.Host] = true
+		mu.Unlock()
+	}))
+	defer proxy.Close()
+	defaultURL := bucketCheckURL
+	bucketCheckURL = func(provider, bucket, key string) string {
+		return "http://" + bucket + ".buckets.test/" + key
+	}
+	defer func() { bucketCheckURL = defaultURL }()
+
+	dir, err := ioutil.TempDir("", "gospider-buckets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outOfScope := filepath.Join(dir, "out-of-scope.txt")
+	if err := ioutil.WriteFile(outOfScope, []byte("thirdparty.buckets.test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found := outputsOf(crawlForTest(t, "http://example.test/", func(opts *Options) {
+		opts.Proxy = proxy.URL
+		opts.CheckBuckets = true
+		opts.OutOfScope = outOfScope
+	}), "bucket")
+
+	if !found["open"] || found["thirdparty"] {
+		t.Errorf("buckets checked %v", found)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !probed["PUT open.buckets.test"] {
+		t.Errorf("in scope bucket not probed, got %v", probed)
+	}
+	for probe := range probed {
+		if strings.HasSuffix(probe, "thirdparty.buckets.test") {
+			t.Errorf("out of scope bucket probed: %s", probe)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ResponseStore saves raw requests and responses to disk.
+// Each response goes to <dir>/<hostname>/<sha1 of url>.txt and
+// <dir>/index.txt maps every URL to its file.
+type ResponseStore struct {
+	dir string
+	// Compression of the response files, see Options.Compress. The index isn't compressed.
+	compress string
+	mu       sync.Mutex
+	index    *os.File
+}
+
+func NewResponseStore(dir string) (*ResponseStore, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create response folder: %s", err)
+	}
+	index, err := os.OpenFile(filepath.Join(dir, "index.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open response index: %s", err)
+	}
+	return &ResponseStore{dir: dir, index: index}, nil
+}
+
+// Save writes the request and response of r, it returns the file path relative to the store folder
+func (s *ResponseStore) Save(r *colly.Response) (string, error) {
+	u := r.Request.URL
+	hash := sha1.Sum([]byte(u.String()))
+	relPath := filepath.Join(HostFileName(u.Host), hex.EncodeToString(hash[:])+".txt"+CompressExt(s.compress))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s %s HTTP/1.1\r\n", r.Request.Method, u.RequestURI())
+	fmt.Fprintf(&buf, "Host: %s\r\n", u.Host)
+	if r.Request.Headers != nil {
+		_ = r.Request.Headers.Write(&buf)
+	}
+	buf.WriteString("\r\n")
+
+	fmt.Fprintf(&buf, "HTTP/1.1 %d %s\r\n", r.StatusCode, http.StatusText(r.StatusCode))
+	if r.Headers != nil {
+		_ = r.Headers.Write(&buf)
+	}
+	buf.WriteString("\r\n")
+	buf.Write(r.Body)
+
+	fullPath := filepath.Join(s.dir, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	data := buf.Bytes()
+	if s.compress != "" {
+		var err error
+		if data, err = compressBytes(data, s.compress); err != nil {
+			return "", err
+		}
+	}
+	if err := ioutil.WriteFile(fullPath, data, 0644); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.index.WriteString(u.String() + " " + relPath + "\n")
+	return relPath, err
+}
+
+func (s *ResponseStore) Close() {
+	s.index.Close()
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"github.com/gocolly/colly/v2"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponseStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gospider-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewResponseStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	u, _ := url.Parse("https://example.com/admin?a=1")
+	headers := http.Header{"Content-Type": {"text/html"}}
+	relPath, err := store.Save(&colly.Response{
+		StatusCode: 200,
+		Body:       []byte("<html>admin</html>"),
+		Headers:    &headers,
+		Request:    &colly.Request{URL: u, Method: "GET"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(dir, relPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(raw), "GET /admin?a=1 HTTP/1.1\r\n") || !strings.HasSuffix(string(raw), "\r\n\r\n<html>admin</html>") {
+		t.Errorf("unexpected stored response:\n%s", raw)
+	}
+
+	index, _ := ioutil.ReadFile(filepath.Join(dir, "index.txt"))
+	if string(index) != u.String()+" "+relPath+"\n" {
+		t.Errorf("unexpected index: %q", index)
+	}
+}
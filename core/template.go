@@ -0,0 +1,88 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// References to environment variables, expanded once when the crawl starts
+var envRefRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Placeholders rendered for each request
+var placeholderRe = regexp.MustCompile(`\{\{\s*([a-z]+)\s*\}\}`)
+
+var placeholders = map[string]func() string{
+	"uuid":      newUUID,
+	"date":      func() string { return time.Now().UTC().Format(time.RFC3339) },
+	"timestamp": func() string { return strconv.FormatInt(time.Now().Unix(), 10) },
+}
+
+// ExpandEnv replaces the ${NAME} references of s with the environment variables,
+// an unset variable is an error so a typo doesn't send an empty secret
+func ExpandEnv(s string) (string, error) {
+	var missing []string
+	expanded := envRefRe.ReplaceAllStringFunc(s, func(ref string) string {
+		name := envRefRe.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// ParseTemplate expands the environment variables of a header value and checks its
+// {{placeholders}}, the result is rendered for each request with RenderTemplate
+func ParseTemplate(s string) (string, error) {
+	expanded, err := ExpandEnv(s)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range placeholderRe.FindAllStringSubmatch(expanded, -1) {
+		if _, ok := placeholders[m[1]]; !ok {
+			return "", fmt.Errorf("unknown placeholder %s, use {{uuid}}, {{date}} or {{timestamp}}", m[0])
+		}
+	}
+	return expanded, nil
+}
+
+// RenderTemplate replaces the placeholders of a parsed template with new values
+func RenderTemplate(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return placeholderRe.ReplaceAllStringFunc(s, func(p string) string {
+		if render, ok := placeholders[placeholderRe.FindStringSubmatch(p)[1]]; ok {
+			return render()
+		}
+		return p
+	})
+}
+
+// Render the templates of the configured headers for one request
+func renderHeaders(templates http.Header) http.Header {
+	header := make(http.Header, len(templates))
+	for k := range templates {
+		header.Set(k, RenderTemplate(templates.Get(k)))
+	}
+	return header
+}
+
+// Random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
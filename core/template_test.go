@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	os.Setenv("GOSPIDER_TEST_TOKEN", "s3cret")
+	defer os.Unsetenv("GOSPIDER_TEST_TOKEN")
+	os.Unsetenv("GOSPIDER_TEST_UNSET")
+
+	if got, err := ParseTemplate("Bearer ${GOSPIDER_TEST_TOKEN} {{uuid}}"); err != nil || got != "Bearer s3cret {{uuid}}" {
+		t.Errorf("ParseTemplate = %q, %v", got, err)
+	}
+	// Shell variables without braces and other braces are kept
+	if got, _ := ParseTemplate("$HOME {a} {{Name}}"); got != "$HOME {a} {{Name}}" {
+		t.Errorf("ParseTemplate = %q", got)
+	}
+	for _, raw := range []string{"${GOSPIDER_TEST_UNSET}", "{{random}}"} {
+		if _, err := ParseTemplate(raw); err == nil {
+			t.Errorf("ParseTemplate(%s) accepted", raw)
+		}
+	}
+
+	uuidRe := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, second := RenderTemplate("{{uuid}}"), RenderTemplate("{{ uuid }}")
+	if !uuidRe.MatchString(first) || !uuidRe.MatchString(second) || first == second {
+		t.Errorf("uuids %s and %s", first, second)
+	}
+	if got := RenderTemplate("{{timestamp}}"); !regexp.MustCompile(`^\d{10,}$`).MatchString(got) {
+		t.Errorf("timestamp %s", got)
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	os.Unsetenv("GOSPIDER_TEST_UNSET")
+	opts := DefaultOptions()
+	opts.Headers = []string{"X-Api-Key: ${GOSPIDER_TEST_UNSET}"}
+	opts.Cookie = "id={{nonce}}"
+	opts.AuthBasic = "admin:${GOSPIDER_TEST_UNSET}"
+	errs := ValidateOptions(opts)
+	if fmt.Sprint(errs) != "[--auth-basic: environment variable GOSPIDER_TEST_UNSET not set "+
+		"--header: environment variable GOSPIDER_TEST_UNSET not set "+
+		"--cookie: unknown placeholder {{nonce}}, use {{uuid}}, {{date}} or {{timestamp}}]" {
+		t.Errorf("ValidateOptions = %v", errs)
+	}
+}
+
+func TestCrawlerHeaderTemplates(t *testing.T) {
+	os.Setenv("GOSPIDER_TEST_TOKEN", "s3cret")
+	defer os.Unsetenv("GOSPIDER_TEST_TOKEN")
+
+	var mu sync.Mutex
+	ids := make(map[string]bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			t.Errorf("Authorization %q", r.Header.Get("Authorization"))
+		}
+		mu.Lock()
+		ids[r.Header.Get("X-Request-ID")] = true
+		mu.Unlock()
+		if r.URL.Path == "/" {
+			fmt.Fprint(w, `<a href="/a">a</a><a href="/b">b</a>`)
+		}
+	}))
+	defer ts.Close()
+
+	crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.AuthBearer = "${GOSPIDER_TEST_TOKEN}"
+		opts.Headers = []string{"X-Request-ID: {{uuid}}"}
+	})
+
+	if len(ids) != 3 || ids["{{uuid}}"] {
+		t.Errorf("request IDs %v", ids)
+	}
+}
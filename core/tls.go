@@ -0,0 +1,68 @@
+package core
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion converts version like "1.0" or "1.2" to the crypto/tls constant
+func ParseTLSVersion(version string) (uint16, error) {
+	v := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "tls")
+	if tlsVersion, ok := tlsVersions[v]; ok {
+		return tlsVersion, nil
+	}
+	return 0, fmt.Errorf("unknown TLS version %q, use one of 1.0, 1.1, 1.2, 1.3", version)
+}
+
+// ParseCipherSuites converts a comma separated list of cipher suite names
+// (Ex: TLS_RSA_WITH_AES_128_CBC_SHA) to their IDs, insecure suites included
+func ParseCipherSuites(rawCiphers string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, s := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		suites[s.Name] = s.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(rawCiphers, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		id, ok := suites[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// LoadClientCertificate loads the PEM certificate and private key presented to servers
+// asking for a client certificate (mutual TLS), keyFile may be empty when certFile has both
+func LoadClientCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client certificate: %s", err)
+	}
+	return cert, nil
+}
+
+// BasicAuthorization is the Authorization header value of HTTP Basic credentials "user:pass"
+func BasicAuthorization(credentials string) (string, error) {
+	if !strings.Contains(credentials, ":") {
+		return "", fmt.Errorf("invalid basic credentials, use \"user:pass\"")
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials)), nil
+}
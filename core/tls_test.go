@@ -0,0 +1,111 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestParseTLSVersion(t *testing.T) {
+	v, err := ParseTLSVersion("TLS1.0")
+	if err != nil || v != tls.VersionTLS10 {
+		t.Errorf("ParseTLSVersion(TLS1.0) = %v, %v", v, err)
+	}
+	if _, err := ParseTLSVersion("1.4"); err == nil {
+		t.Error("ParseTLSVersion(1.4) should fail")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites("TLS_RSA_WITH_3DES_EDE_CBC_SHA, TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || ids[0] != tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA {
+		t.Errorf("unexpected cipher suites: %v", ids)
+	}
+	if _, err := ParseCipherSuites("TLS_FAKE"); err == nil {
+		t.Error("ParseCipherSuites(TLS_FAKE) should fail")
+	}
+}
+
+func TestCrawlerClientAuth(t *testing.T) {
+	clientCert := testCertificate(t, "gospider")
+	var mu sync.Mutex
+	var requests []*http.Request
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		mu.Unlock()
+		if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "s3cr:t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><a href="/private">private</a></html>`)
+	}))
+	pool := x509.NewCertPool()
+	leaf, _ := x509.ParseCertificate(clientCert.Certificate[0])
+	pool.AddCert(leaf)
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gospider-clientauth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "client.pem")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(clientCert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemData := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCert.Certificate[0]}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...)
+	_ = ioutil.WriteFile(certFile, pemData, 0600)
+	burpFile := filepath.Join(dir, "burp.txt")
+	_ = ioutil.WriteFile(burpFile, []byte("GET / HTTP/1.1\r\nHost: example.com\r\nAuthorization: Bearer old\r\nX-Api-Version: 2\r\n\r\n"), 0644)
+
+	crawlForTest(t, ts.URL+"/", func(opts *Options) {
+		opts.Depth = 2
+		opts.Burp = burpFile
+		opts.AuthBasic = "admin:s3cr:t"
+		opts.ClientCert = certFile
+		if errs := ValidateOptions(*opts); len(errs) != 0 {
+			t.Fatal(errs)
+		}
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	var private bool
+	for _, r := range requests {
+		if r.Header.Get("X-Api-Version") != "2" {
+			t.Errorf("%s sent without the headers of the Burp request", r.URL)
+		}
+		if _, _, ok := r.BasicAuth(); !ok {
+			t.Errorf("%s sent with Authorization %q", r.URL, r.Header.Get("Authorization"))
+		}
+		private = private || r.URL.Path == "/private"
+	}
+	if !private {
+		t.Errorf("authenticated page not crawled, %d requests", len(requests))
+	}
+
+	opts := DefaultOptions()
+	opts.AuthBasic = "admin"
+	opts.AuthBearer = "token"
+	opts.ClientKey = certFile
+	if errs := ValidateOptions(opts); len(errs) != 3 {
+		t.Errorf("ValidateOptions() = %v", errs)
+	}
+}
@@ -0,0 +1,397 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/gocolly/colly/v2"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Time between two redraws of the TUI screen
+	tuiRefresh = 500 * time.Millisecond
+	// Findings shown under each type
+	tuiRecent = 3
+	// Sites shown, running ones first
+	tuiSites = 10
+)
+
+// errBlacklisted fails the requests dropped because their path was blacklisted at runtime
+var errBlacklisted = errors.New("path blacklisted")
+
+// crawlControl pauses, throttles and blacklists paths of the crawls of the process at runtime,
+// it's driven by the TUI commands
+type crawlControl struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	enabled bool
+	paused  bool
+	// Requests in flight per site, 0 for the --concurrent value
+	concurrency int
+	blacklist   []string
+	denyPaths   []*regexp.Regexp
+	crawlers    []*Crawler
+}
+
+var crawlControls = newCrawlControl()
+
+func newCrawlControl() *crawlControl {
+	c := &crawlControl{}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *crawlControl) register(crawler *Crawler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.crawlers = append(c.crawlers, crawler)
+}
+
+// Wake the requests waiting for a slot so they see a pause, limit or stop change
+func (c *crawlControl) wake() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+func (c *crawlControl) setPaused(paused bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = paused
+	c.cond.Broadcast()
+}
+
+func (c *crawlControl) setConcurrency(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.concurrency = n
+	c.cond.Broadcast()
+}
+
+// Drop the requests to paths matching glob from now on, scheduled ones included
+func (c *crawlControl) blacklistPath(glob string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blacklist = append(c.blacklist, glob)
+	c.denyPaths = append(c.denyPaths, GlobToRegex(glob))
+	c.cond.Broadcast()
+}
+
+func (c *crawlControl) blocked(u *url.URL) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.blockedLocked(u)
+}
+
+func (c *crawlControl) blockedLocked(u *url.URL) bool {
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, re := range c.denyPaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check of the blacklisted paths, run before the budget so dropped requests aren't counted
+func (c *crawlControl) check(r *colly.Request) bool {
+	if c.blocked(r.URL) {
+		Logger.Debugf("Blacklisted: %s", r.URL)
+		return false
+	}
+	return true
+}
+
+// controlTransport holds requests while the crawls are paused or the site has as many requests
+// in flight as allowed, colly runs the request callbacks before waiting for a free slot
+// so scheduled requests are checked against the blacklist again here
+type controlTransport struct {
+	base    http.RoundTripper
+	control *crawlControl
+	budget  *crawlBudget
+	// Request timeout, waiting for a slot mustn't count as request time (0 when the base applies it)
+	timeout time.Duration
+	// Upper bound of the requests in flight, colly doesn't allow more
+	parallelism int
+	// Requests in flight, guarded by control.mu
+	active int
+}
+
+func (t *controlTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.acquire(req.URL); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	var once sync.Once
+	release := func() {
+		once.Do(t.release)
+	}
+	resp, err := sendTimeout(t.base, req, t.timeout)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: release}
+	return resp, nil
+}
+
+func (t *controlTransport) acquire(u *url.URL) error {
+	c := t.control
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		if t.budget.stopped() != "" {
+			return errCrawlStopped
+		}
+		if c.blockedLocked(u) {
+			return errBlacklisted
+		}
+		limit := c.concurrency
+		if limit <= 0 || limit > t.parallelism {
+			limit = t.parallelism
+		}
+		if !c.paused && t.active < limit {
+			t.active++
+			return nil
+		}
+		c.cond.Wait()
+	}
+}
+
+func (t *controlTransport) release() {
+	t.control.mu.Lock()
+	defer t.control.mu.Unlock()
+	t.active--
+	t.control.cond.Broadcast()
+}
+
+// TUI is a terminal screen of the crawls for long interactive sessions: findings grouped by type
+// and the progress of each site, redrawn periodically. Commands read from the input line by line
+// pause the crawls, change their concurrency or blacklist paths on the fly.
+type TUI struct {
+	in   io.Reader
+	out  io.Writer
+	quit func()
+
+	mu       sync.Mutex
+	findings map[string]int
+	recent   map[string][]string
+	// Result of the last command
+	message string
+
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+// StartTUI enables the runtime controls of the crawls started from now on and draws their screen
+// on out until Close. quit is called by the q command, it should stop the crawls gracefully.
+func StartTUI(in io.Reader, out io.Writer, quit func()) *TUI {
+	crawlControls.mu.Lock()
+	crawlControls.enabled = true
+	crawlControls.mu.Unlock()
+
+	t := &TUI{
+		in:       in,
+		out:      out,
+		quit:     quit,
+		findings: make(map[string]int),
+		recent:   make(map[string][]string),
+		done:     make(chan struct{}),
+	}
+	// The input can't be interrupted, the reader ends with the process
+	go t.readCommands()
+	t.stopped.Add(1)
+	go func() {
+		defer t.stopped.Done()
+		ticker := time.NewTicker(tuiRefresh)
+		defer ticker.Stop()
+		for {
+			t.draw()
+			select {
+			case <-ticker.C:
+			case <-t.done:
+				return
+			}
+		}
+	}()
+	return t
+}
+
+// Add shows a finding, use it as Options.OnResult
+func (t *TUI) Add(result SpiderOutput) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.findings[result.OutputType]++
+	recent := append(t.recent[result.OutputType], result.Output)
+	if len(recent) > tuiRecent {
+		recent = recent[len(recent)-tuiRecent:]
+	}
+	t.recent[result.OutputType] = recent
+}
+
+// Close stops redrawing and draws the final screen
+func (t *TUI) Close() {
+	close(t.done)
+	t.stopped.Wait()
+	t.draw()
+}
+
+func (t *TUI) readCommands() {
+	scanner := bufio.NewScanner(t.in)
+	for scanner.Scan() {
+		t.command(scanner.Text())
+		t.draw()
+	}
+}
+
+// Run one command line: p (pause/resume), c N (concurrency), b GLOB (blacklist path), q (stop)
+func (t *TUI) command(line string) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return
+	}
+	var message string
+	switch arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0])); fields[0] {
+	case "p", "pause":
+		crawlControls.mu.Lock()
+		paused := !crawlControls.paused
+		crawlControls.mu.Unlock()
+		crawlControls.setPaused(paused)
+		message = "Resumed"
+		if paused {
+			message = "Paused, requests in flight finish"
+		}
+	case "c", "concurrency":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			message = fmt.Sprintf("Invalid concurrency %q, use c N (0 for --concurrent)", arg)
+			break
+		}
+		crawlControls.setConcurrency(n)
+		message = fmt.Sprintf("Concurrency set to %d requests per site", n)
+		if n == 0 {
+			message = "Concurrency reset to --concurrent"
+		}
+	case "b", "blacklist":
+		if arg == "" {
+			message = "Missing path glob, use b GLOB (Ex: b /logout*)"
+			break
+		}
+		crawlControls.blacklistPath(arg)
+		message = fmt.Sprintf("Blacklisted %s", arg)
+	case "q", "quit":
+		message = "Stopping, waiting for requests in flight"
+		if t.quit != nil {
+			t.quit()
+		}
+	default:
+		message = fmt.Sprintf("Unknown command %q", fields[0])
+	}
+	t.mu.Lock()
+	t.message = message
+	t.mu.Unlock()
+}
+
+func (t *TUI) draw() {
+	var sb strings.Builder
+	// Move home and clear the screen
+	sb.WriteString("\x1b[H\x1b[2J")
+	t.render(&sb)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.out, sb.String())
+}
+
+// Write the screen content
+func (t *TUI) render(w io.Writer) {
+	c := crawlControls
+	c.mu.Lock()
+	paused, concurrency := c.paused, c.concurrency
+	blacklist := append([]string(nil), c.blacklist...)
+	crawlers := append([]*Crawler(nil), c.crawlers...)
+	c.mu.Unlock()
+
+	type siteLine struct {
+		running bool
+		line    string
+	}
+	var sites []siteLine
+	running := 0
+	for _, crawler := range crawlers {
+		stats := crawler.stats.Snapshot()
+		crawler.stats.mu.Lock()
+		isRunning := crawler.stats.running
+		crawler.stats.mu.Unlock()
+		_, elapsed, reason := crawler.budget.summary()
+		status := "done"
+		switch {
+		case isRunning && reason != "":
+			status = "stopping"
+		case isRunning:
+			status = "running"
+			running++
+		case reason != "":
+			status = "stopped"
+		}
+		line := fmt.Sprintf("  %-8s %s - %s, %d in flight, %s", status, crawler.site, stats, stats.InFlight, elapsed.Round(time.Second))
+		if reason != "" {
+			line += " (" + reason + ")"
+		}
+		sites = append(sites, siteLine{running: isRunning, line: line})
+	}
+	sort.SliceStable(sites, func(i, j int) bool {
+		return sites[i].running && !sites[j].running
+	})
+
+	state := "crawling"
+	if paused {
+		state = "PAUSED"
+	}
+	limit := "--concurrent"
+	if concurrency > 0 {
+		limit = strconv.Itoa(concurrency)
+	}
+	fmt.Fprintf(w, "%s %s - %s - %d of %d sites running - concurrency %s\n", CLIName, VERSION, state, running, len(sites), limit)
+	if len(blacklist) > 0 {
+		fmt.Fprintf(w, "Blacklist: %s\n", strings.Join(blacklist, " "))
+	}
+
+	fmt.Fprintln(w, "\nSites")
+	for i, site := range sites {
+		if i == tuiSites {
+			fmt.Fprintf(w, "  ... %d more\n", len(sites)-tuiSites)
+			break
+		}
+		fmt.Fprintln(w, site.line)
+	}
+
+	t.mu.Lock()
+	fmt.Fprintln(w, "\nFindings")
+	for _, outputType := range sortedKeys(t.findings) {
+		fmt.Fprintf(w, "  %s (%d)\n", outputType, t.findings[outputType])
+		for _, output := range t.recent[outputType] {
+			fmt.Fprintf(w, "    %s\n", output)
+		}
+	}
+	message := t.message
+	t.mu.Unlock()
+
+	fmt.Fprintln(w, "\nCommands: p pause/resume | c N concurrency per site (0 for --concurrent) | b GLOB blacklist path | q stop")
+	if message != "" {
+		fmt.Fprintf(w, "> %s\n", message)
+	}
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Replace the process controls for the duration of a test
+func withCrawlControl(t *testing.T) *crawlControl {
+	saved := crawlControls
+	crawlControls = newCrawlControl()
+	t.Cleanup(func() { crawlControls = saved })
+	return crawlControls
+}
+
+func TestTUICommands(t *testing.T) {
+	control := withCrawlControl(t)
+	quit := false
+	tui := StartTUI(strings.NewReader(""), ioutil.Discard, func() { quit = true })
+	defer tui.Close()
+
+	for _, tt := range []struct {
+		command string
+		message string
+	}{
+		{"p", "Paused, requests in flight finish"},
+		{"c 2", "Concurrency set to 2 requests per site"},
+		{"c many", `Invalid concurrency "many", use c N (0 for --concurrent)`},
+		{"b   /admin*", "Blacklisted /admin*"},
+		{"b", "Missing path glob, use b GLOB (Ex: b /logout*)"},
+		{"x", `Unknown command "x"`},
+		{"q", "Stopping, waiting for requests in flight"},
+	} {
+		tui.command(tt.command)
+		if tui.message != tt.message {
+			t.Errorf("command(%q) message = %q, want %q", tt.command, tui.message, tt.message)
+		}
+	}
+	if !control.enabled || !control.paused || control.concurrency != 2 || !quit {
+		t.Errorf("commands not applied: paused %v, concurrency %d, quit %v", control.paused, control.concurrency, quit)
+	}
+	if u, _ := url.Parse("https://example.com/admin/users"); !control.blocked(u) {
+		t.Error("blacklisted path not blocked")
+	}
+	tui.command("pause")
+	if control.paused {
+		t.Error("second pause didn't resume")
+	}
+
+	for i := 0; i < 5; i++ {
+		tui.Add(SpiderOutput{OutputType: "url", Output: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	tui.Add(SpiderOutput{OutputType: "subdomains", Output: "api.example.com"})
+	var screen bytes.Buffer
+	tui.render(&screen)
+	for _, want := range []string{"concurrency 2", "Blacklist: /admin*", "  subdomains (1)\n    api.example.com", "  url (5)\n    https://example.com/2\n"} {
+		if !strings.Contains(screen.String(), want) {
+			t.Errorf("screen misses %q:\n%s", want, screen.String())
+		}
+	}
+	if strings.Contains(screen.String(), "https://example.com/1\n") {
+		t.Errorf("screen shows more than %d findings per type:\n%s", tuiRecent, screen.String())
+	}
+}
+
+func TestCrawlControl(t *testing.T) {
+	control := withCrawlControl(t)
+	control.enabled = true
+
+	var mu sync.Mutex
+	sent, active, maxActive := 0, 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent++
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		w.Header().Set("Content-Type", "text/html")
+		if r.URL.Path == "/" {
+			for i := 0; i < 20; i++ {
+				fmt.Fprintf(w, `<a href="/page%d">page</a>`, i)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprint(w, `<html>page</html>`)
+	}))
+	defer ts.Close()
+
+	site, _ := url.Parse(ts.URL)
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.Concurrent = 5
+	var found []string
+	opts.OnResult = func(r SpiderOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.OutputType == "url" {
+			found = append(found, r.Output)
+		}
+	}
+	crawler, err := NewCrawlerWithOptions(site, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	control.setPaused(true)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		crawler.Run()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	if sent != 0 {
+		t.Errorf("%d requests sent while paused", sent)
+	}
+	mu.Unlock()
+
+	control.blacklistPath("/page1*")
+	control.setConcurrency(1)
+	control.setPaused(false)
+	<-done
+
+	// The home page and pages 0, 2-9, page1 and page10-19 are blacklisted
+	if len(found) != 10 {
+		t.Errorf("found %d URLs, want 10: %v", len(found), found)
+	}
+	if maxActive != 1 {
+		t.Errorf("%d requests in flight, want 1", maxActive)
+	}
+	if len(control.crawlers) != 1 {
+		t.Errorf("%d crawlers registered, want 1", len(control.crawlers))
+	}
+}
@@ -31,15 +31,19 @@ func GetDomain(site *url.URL) string {
 
 func FixUrl(url string, site *url.URL) string {
 	var newUrl string
-	if strings.HasPrefix(url, "//") {
-		// //google.com/example.php
-		newUrl = site.Scheme + ":" + url
+	if loc := protocolRelativeRegex.FindStringIndex(url); loc != nil {
+		// //google.com/example.php || /\google.com/example.php
+		newUrl = site.Scheme + "://" + url[loc[1]:]
 
 	} else if strings.HasPrefix(url, "http") {
 		// http://google.com || https://google.com
 		newUrl = url
 
-	} else if !strings.HasPrefix(url, "//") {
+	} else if IsSchemelessURL(url) {
+		// google.com/example.php
+		newUrl = site.Scheme + "://" + url
+
+	} else {
 		if strings.HasPrefix(url, "/") {
 			// Ex: /?thread=10
 			newUrl = site.Scheme + "://" + site.Host + url
@@ -143,12 +147,3 @@ func DecodeChars(s string) string {
 	s = replacer.Replace(s)
 	return s
 }
-
-func InScope(u *url.URL, regexps []*regexp.Regexp) bool {
-	for _, r := range regexps {
-		if r.MatchString(u.Hostname()) {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,318 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/net/idna"
+	"net"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// compileRegex compiles a user supplied regex, the error tells what is wrong and where
+// (Ex: "invalid nested repetition operator at offset 4 in `**`")
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(pattern)
+	if err == nil {
+		return re, nil
+	}
+	var syntaxErr *syntax.Error
+	if !errors.As(err, &syntaxErr) {
+		return nil, err
+	}
+	msg := string(syntaxErr.Code)
+	if i := strings.Index(pattern, syntaxErr.Expr); i > 0 && syntaxErr.Expr != "" {
+		msg += fmt.Sprintf(" at offset %d in `%s`", i, syntaxErr.Expr)
+	} else if syntaxErr.Expr != "" {
+		msg += fmt.Sprintf(" in `%s`", syntaxErr.Expr)
+	}
+	// Globs are the most common mistake
+	if syntaxErr.Code == syntax.ErrMissingRepeatArgument {
+		msg += ", regexes aren't globs: use .* to match anything"
+	}
+	return nil, errors.New(msg)
+}
+
+// ValidateOptions checks the patterns, rule files and other user input of opts before any site
+// is crawled and returns every problem found, each one prefixed by the flag it comes from
+func ValidateOptions(opts Options) []error {
+	var errs []error
+	check := func(flag string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("--%s: %s", flag, err))
+		}
+	}
+
+	if opts.Concurrent < 1 {
+		check("concurrent", fmt.Errorf("must be at least 1, got %d", opts.Concurrent))
+	}
+	if opts.Depth < 0 {
+		check("depth", fmt.Errorf("must be 0 (no limit) or more, got %d", opts.Depth))
+	}
+	if opts.Retries < 0 {
+		check("retries", fmt.Errorf("must be 0 (no retry) or more, got %d", opts.Retries))
+	}
+	if opts.MaxURLs < 0 {
+		check("max-urls", fmt.Errorf("must be 0 (no limit) or more, got %d", opts.MaxURLs))
+	}
+	if opts.SamplePerPattern < 0 {
+		check("sample-per-pattern", fmt.Errorf("must be 0 (no sampling) or more, got %d", opts.SamplePerPattern))
+	}
+	if opts.Redis != "" && opts.Resume != "" {
+		check("redis", errors.New("the crawl progress is kept by the redis server, --resume can't be used with it"))
+	}
+	if opts.ImportSnapshot != "" {
+		_, err := LoadSnapshots(opts.ImportSnapshot)
+		if err == nil && opts.Resume == "" {
+			err = errors.New("the snapshot is imported into the state file, set --resume")
+		}
+		check("import-snapshot", err)
+	}
+	if opts.EncryptOutput != "" {
+		if opts.OutputFolder == "" {
+			check("encrypt-output", errors.New("the output folder is what gets encrypted, set --output"))
+		} else if _, err := ReadRecipients(opts.EncryptOutput); err != nil {
+			check("encrypt-output", err)
+		} else {
+			check("encrypt-output", checkEncryptedOutput(opts.OutputFolder))
+		}
+	}
+	if opts.ControlAddr != "" {
+		if _, err := controlListenAddr(opts.ControlAddr); err != nil {
+			check("control-addr", err)
+		}
+		if token, err := ExpandEnv(opts.ControlToken); err != nil {
+			check("control-token", err)
+		} else if token == "" {
+			check("control-token", errors.New("the control endpoints require a bearer token"))
+		}
+	}
+	if opts.PolitenessReport && opts.OutputFolder == "" {
+		check("politeness-report", errors.New("the report is written to the output folder, set --output"))
+	}
+	if opts.SplitOutput && opts.OutputFolder == "" {
+		check("split-output", errors.New("the split files are written to the output folder, set --output"))
+	}
+	if opts.JSConcurrent < 0 {
+		check("js-concurrent", fmt.Errorf("must be 0 (same as --concurrent) or more, got %d", opts.JSConcurrent))
+	}
+	if opts.MaxResponseSize < 0 {
+		check("max-response-size", fmt.Errorf("must be 0 (no limit) or more, got %d", opts.MaxResponseSize/1024))
+	}
+	if opts.HandlerTimeout < 0 {
+		check("handler-timeout", fmt.Errorf("must be 0 (no limit) or more, got %s", opts.HandlerTimeout))
+	}
+	if opts.MaxArchiveSize < 0 {
+		check("max-archive-size", fmt.Errorf("must be 0 (no limit) or more, got %d", opts.MaxArchiveSize/1024))
+	}
+	if opts.MaxJSFiles < 0 {
+		check("max-js-files", fmt.Errorf("must be 0 (no limit) or more, got %d", opts.MaxJSFiles))
+	}
+	if scope := strings.ToLower(opts.JSScope); scope != "" && scope != JSScopeSite && scope != JSScopeAll {
+		check("js-scope", fmt.Errorf("must be %s or %s, got %q", JSScopeSite, JSScopeAll, opts.JSScope))
+	}
+	if opts.JSMaxDepth < 0 {
+		check("js-max-depth", fmt.Errorf("must be 0 (no limit) or more, got %d", opts.JSMaxDepth))
+	}
+
+	for _, parser := range opts.ContentParsers {
+		known := false
+		for _, name := range ContentParsers {
+			known = known || strings.EqualFold(parser, name)
+		}
+		if !known {
+			check("content-parsers", fmt.Errorf("unknown parser %q, use %s or none", parser, strings.Join(ContentParsers, ", ")))
+		}
+	}
+	for _, content := range opts.LinkFinderContent {
+		known := false
+		for _, name := range LinkFinderContents {
+			known = known || strings.EqualFold(content, name)
+		}
+		if !known {
+			check("linkfinder-content", fmt.Errorf("unknown content %q, use %s or none", content, strings.Join(LinkFinderContents, ", ")))
+		}
+	}
+
+	if opts.Blacklist != "" {
+		_, err := compileRegex(opts.Blacklist)
+		check("blacklist", err)
+	}
+	if opts.AuthMarker != "" {
+		_, err := newAuthTracker(opts.AuthMarker)
+		check("auth-marker", err)
+	}
+	for _, raw := range opts.DepthRules {
+		_, err := ParseDepthRule(raw)
+		check("depth-rule", err)
+	}
+	_, err := parseIntRanges("filter code", opts.FilterCodes)
+	check("filter-code", err)
+	_, err = parseIntRanges("match code", opts.MatchCodes)
+	check("match-code", err)
+	_, err = parseIntRanges("status code", opts.ReportErrors)
+	check("report-errors", err)
+	_, err = parseIntRanges("filter length", opts.FilterLengths)
+	check("filter-length", err)
+	if opts.FilterRegex != "" {
+		_, err := compileRegex(opts.FilterRegex)
+		check("filter-regex", err)
+	}
+
+	for _, raw := range opts.ExcludeSubdomains {
+		_, err := compileRegex(raw)
+		check("exclude-subdomain", err)
+	}
+	for _, raw := range opts.IncludeCIDRs {
+		_, _, err := net.ParseCIDR(strings.TrimSpace(raw))
+		check("include-cidr", err)
+	}
+	if opts.OutOfScope != "" {
+		check("out-of-scope", (&Scope{}).loadOutOfScope(opts.OutOfScope))
+	}
+	if opts.SeedFile != "" {
+		_, err := ReadSeedFile(opts.SeedFile)
+		check("seed-file", err)
+	}
+	check("compress", ValidCompression(opts.Compress))
+	if opts.Diff != "" {
+		_, err := LoadPreviousRun(opts.Diff)
+		check("diff", err)
+	}
+	if opts.ScopeFile != "" {
+		check("scope-file", (&Scope{}).loadBurpScope(opts.ScopeFile))
+	}
+	if opts.WatchScope && opts.OutOfScope == "" && opts.ScopeFile == "" {
+		check("watch-scope", errors.New("nothing to watch without --out-of-scope or --scope-file"))
+	}
+
+	if opts.SecretRules != "" {
+		rules, err := LoadSecretRules(opts.SecretRules)
+		if err == nil {
+			_, err = CompileSecretRules(rules)
+		}
+		check("secret-rules", err)
+	}
+	for _, raw := range opts.ExtractRules {
+		rule, err := ParseExtractRule(raw, false)
+		if err == nil {
+			_, err = CompileExtractRules([]ExtractRule{rule})
+		}
+		check("extract-rule", err)
+	}
+	for _, raw := range opts.ExtractSelectors {
+		rule, err := ParseExtractRule(raw, true)
+		if err == nil {
+			_, err = CompileExtractRules([]ExtractRule{rule})
+		}
+		check("extract-selector", err)
+	}
+	if opts.ExtractConfig != "" {
+		rules, err := LoadExtractRules(opts.ExtractConfig)
+		if err == nil {
+			_, err = CompileExtractRules(rules)
+		}
+		check("extract-config", err)
+	}
+	if opts.RulesDir != "" {
+		_, err := LoadRules(opts.RulesDir)
+		check("rules-dir", err)
+	}
+	if opts.LoginConfig != "" {
+		_, err := LoadLoginConfig(opts.LoginConfig)
+		check("login-config", err)
+		if opts.NoCookieJar {
+			check("no-cookie-jar", errors.New("the login config needs the cookie jar"))
+		}
+	}
+
+	if opts.Resolvers != "" {
+		_, err := ParseResolvers(opts.Resolvers)
+		check("resolvers", err)
+	}
+	if len(opts.SourceIPs) > 0 {
+		_, err := ParseSourceIPs(opts.SourceIPs)
+		check("source-ip", err)
+	}
+	if opts.ProxyList != "" {
+		_, err := LoadProxyList(opts.ProxyList)
+		check("proxy-list", err)
+	} else if opts.Proxy != "" {
+		_, err := ParseProxy(opts.Proxy)
+		check("proxy", err)
+	}
+	if opts.TLSMinVersion != "" {
+		_, err := ParseTLSVersion(opts.TLSMinVersion)
+		check("tls-min-version", err)
+	}
+	if opts.TLSMaxVersion != "" {
+		_, err := ParseTLSVersion(opts.TLSMaxVersion)
+		check("tls-max-version", err)
+	}
+	if opts.TLSCiphers != "" {
+		_, err := ParseCipherSuites(opts.TLSCiphers)
+		check("tls-ciphers", err)
+	}
+	if opts.AuthBasic != "" {
+		credentials, err := ExpandEnv(opts.AuthBasic)
+		if err == nil {
+			_, err = BasicAuthorization(credentials)
+		}
+		check("auth-basic", err)
+		if opts.AuthBearer != "" {
+			check("auth-bearer", errors.New("use either basic or bearer authentication"))
+		}
+	}
+	if opts.ClientCert != "" {
+		_, err := LoadClientCertificate(opts.ClientCert, opts.ClientKey)
+		check("client-cert", err)
+	} else if opts.ClientKey != "" {
+		check("client-key", errors.New("the client key needs --client-cert"))
+	}
+	if opts.AuthBearer != "" {
+		_, err := ParseTemplate(opts.AuthBearer)
+		check("auth-bearer", err)
+	}
+	if opts.Burp == "" {
+		for _, h := range opts.Headers {
+			args := strings.SplitN(h, ":", 2)
+			if len(args) != 2 {
+				check("header", fmt.Errorf("invalid header %q, use \"Key: Value\"", h))
+				continue
+			}
+			_, err := ParseTemplate(args[1])
+			check("header", err)
+		}
+		if opts.Cookie != "" {
+			_, err := ParseTemplate(opts.Cookie)
+			check("cookie", err)
+		}
+	}
+	for _, spec := range opts.OutputSinks {
+		_, _, err := parseSinkSpec(spec)
+		check("output-sink", err)
+	}
+	return errs
+}
+
+// ValidateSite checks a site of the input is an absolute http(s) URL
+func ValidateSite(rawSite string) error {
+	site, err := url.Parse(rawSite)
+	if err != nil {
+		return fmt.Errorf("invalid site %q: %s", rawSite, err)
+	}
+	if site.Scheme != "http" && site.Scheme != "https" {
+		return fmt.Errorf("invalid site %q: use an http:// or https:// URL", rawSite)
+	}
+	if site.Hostname() == "" {
+		return fmt.Errorf("invalid site %q: no host", rawSite)
+	}
+	if !isASCII(site.Hostname()) {
+		if _, err := idna.Lookup.ToASCII(site.Hostname()); err != nil {
+			return fmt.Errorf("invalid site %q: %s", rawSite, err)
+		}
+	}
+	return nil
+}
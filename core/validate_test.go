@@ -0,0 +1,116 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompileRegex(t *testing.T) {
+	for _, tt := range []struct {
+		pattern string
+		err     string
+	}{
+		{`^/api/(v1|v2)$`, ""},
+		{`*.pdf`, "missing argument to repetition operator in `*`, regexes aren't globs: use .* to match anything"},
+		{`/admin/(users`, "missing closing ) in `/admin/(users`"},
+		{`/api/[a-`, "missing closing ] at offset 5 in `[a-`"},
+		{`/a{3,1}`, "invalid repeat count at offset 2 in `{3,1}`"},
+	} {
+		_, err := compileRegex(tt.pattern)
+		if (err == nil && tt.err != "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("compileRegex(%q) error = %v, want %q", tt.pattern, err, tt.err)
+		}
+	}
+}
+
+func TestValidateOptions(t *testing.T) {
+	if errs := ValidateOptions(DefaultOptions()); len(errs) != 0 {
+		t.Errorf("default options are invalid: %v", errs)
+	}
+
+	dir, err := ioutil.TempDir("", "gospider-validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretRules := filepath.Join(dir, "secrets.yaml")
+	if err := ioutil.WriteFile(secretRules, []byte("- name: token\n  regex: 'tok_(['\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Concurrent = 0
+	opts.Blacklist = "*.pdf"
+	opts.DepthRules = []string{"3:/api/(v1"}
+	opts.FilterCodes = []string{"5xx"}
+	opts.ExcludeSubdomains = []string{"^dev\\."}
+	opts.IncludeCIDRs = []string{"10.0.0.0/33"}
+	opts.SecretRules = secretRules
+	opts.ExtractSelectors = []string{"next:a[["}
+	opts.OutputSinks = []string{"ftp=example.com"}
+	opts.Headers = []string{"X-Api-Key"}
+	opts.ControlAddr = ":9091"
+	errs := ValidateOptions(opts)
+
+	flags := []string{"--concurrent", "--blacklist", "--depth-rule", "--filter-code", "--include-cidr", "--secret-rules", "--extract-selector", "--header", "--output-sink", "--control-token"}
+	if len(errs) != len(flags) {
+		t.Errorf("got %d errors, want %d: %v", len(errs), len(flags), errs)
+	}
+	for _, flag := range flags {
+		found := false
+		for _, err := range errs {
+			found = found || strings.HasPrefix(err.Error(), flag+": ")
+		}
+		if !found {
+			t.Errorf("no error for %s: %v", flag, errs)
+		}
+	}
+}
+
+func TestValidateSite(t *testing.T) {
+	for site, valid := range map[string]bool{
+		"https://example.com/app": true,
+		"http://127.0.0.1:8080":   true,
+		"example.com":             false,
+		"ftp://example.com":       false,
+		"http://%zz":              false,
+		"https://":                false,
+	} {
+		if err := ValidateSite(site); (err == nil) != valid {
+			t.Errorf("ValidateSite(%q) = %v", site, err)
+		}
+	}
+}
+
+func TestCrawlSitesInvalidOptions(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer ts.Close()
+
+	opts := DefaultOptions()
+	opts.Robots = false
+	opts.Quiet = true
+	opts.FilterRegex = "x{2,1}"
+	if err := CrawlSites([]string{ts.URL}, opts, 1); err == nil || !strings.HasPrefix(err.Error(), "--filter-regex: ") {
+		t.Errorf("CrawlSites() error = %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("%d requests sent with invalid options", requests)
+	}
+}
+
+func TestGetSubdomainsRegexDomain(t *testing.T) {
+	// Hosts may contain regex metacharacters
+	subs := GetSubdomains(`"api.a+b.com" "api.aab.com"`, "a+b.com")
+	if len(subs) != 1 || subs[0] != "api.a+b.com" {
+		t.Errorf("GetSubdomains() = %v", subs)
+	}
+}
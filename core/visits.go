@@ -0,0 +1,110 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// visitGate sends the requests of a collector found outside its own fetches: by the other
+// collector, handlers left running, seed sources. colly's Wait must not run while an idle
+// collector gets woken up, so the requests are held while Run waits on the collector and
+// sent once it's idle.
+type visitGate struct {
+	mu      sync.Mutex
+	c       *colly.Collector
+	waiting bool
+	closed  bool
+	held    []func()
+	// Requests sent since the end of the last wait
+	sent int
+}
+
+func newVisitGate(c *colly.Collector) *visitGate {
+	return &visitGate{c: c}
+}
+
+// visit requests u with the collector
+func (g *visitGate) visit(u string) {
+	g.send(u, func() { _ = g.c.Visit(u) })
+}
+
+// visitFrom requests u as a link of r, one level deeper. The error is the one of colly when
+// the request is sent at once, held requests return nil.
+func (g *visitGate) visitFrom(r *colly.Request, u string) error {
+	var err error
+	g.send(u, func() { err = r.Visit(u) })
+	return err
+}
+
+func (g *visitGate) send(u string, visit func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch {
+	case g.closed:
+		Logger.Debugf("Crawl finished, skip: %s", u)
+	case g.waiting:
+		g.held = append(g.held, visit)
+	default:
+		g.sent++
+		visit()
+	}
+}
+
+// wait blocks until the collector is idle, then sends the requests held meanwhile
+func (g *visitGate) wait() {
+	g.mu.Lock()
+	g.waiting = true
+	g.sent = 0
+	g.mu.Unlock()
+
+	g.c.Wait()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.waiting = false
+	held := g.held
+	g.held = nil
+	g.sent = len(held)
+	for _, visit := range held {
+		visit()
+	}
+}
+
+// idle reports whether no request was sent since the end of the last wait
+func (g *visitGate) idle() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.sent == 0
+}
+
+// close drops the requests coming after the end of the crawl
+func (g *visitGate) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+}
+
+// Wait until both collectors and the handlers left running are done. A request sent to a
+// collector after it was waited on means another round.
+func (crawler *Crawler) wait() {
+	idle := func() bool {
+		return crawler.mainVisits.idle() && crawler.linkFinderVisits.idle()
+	}
+	for {
+		crawler.mainVisits.wait()
+		crawler.linkFinderVisits.wait()
+		if !idle() {
+			continue
+		}
+		// No response is handled anymore, only the handlers past their deadline and the
+		// seeds of certificate subdomains may send requests
+		crawler.slowHandlers.wait()
+		crawler.certSeedWg.Wait()
+		if idle() {
+			crawler.mainVisits.close()
+			crawler.linkFinderVisits.close()
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+func TestVisitGate(t *testing.T) {
+	c := colly.NewCollector(colly.Async(true))
+	g := newVisitGate(c)
+	// Answer the first request once the gate waits
+	waiting := make(chan struct{})
+	go func() {
+		for {
+			g.mu.Lock()
+			w := g.waiting
+			g.mu.Unlock()
+			if w {
+				close(waiting)
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/first" {
+			<-waiting
+		}
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var requested []string
+	c.OnResponse(func(r *colly.Response) {
+		mu.Lock()
+		requested = append(requested, r.Request.URL.Path)
+		mu.Unlock()
+		// Found during the wait, sent once the collector is idle
+		if r.Request.URL.Path == "/first" {
+			g.visit(ts.URL + "/second")
+		}
+	})
+
+	g.visit(ts.URL + "/first")
+	if g.idle() {
+		t.Error("gate idle after a request")
+	}
+	g.wait()
+	if g.idle() {
+		t.Error("gate idle with a held request sent")
+	}
+	g.wait()
+	if !g.idle() {
+		t.Error("gate not idle after the last wait")
+	}
+	g.close()
+	g.visit(ts.URL + "/third")
+	c.Wait()
+
+	sort.Strings(requested)
+	if len(requested) != 2 || requested[0] != "/first" || requested[1] != "/second" {
+		t.Errorf("requested %v", requested)
+	}
+}
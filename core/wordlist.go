@@ -0,0 +1,155 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Files written by a wordlist, one word per line
+const (
+	WordlistPaths  = "paths.txt"
+	WordlistFiles  = "files.txt"
+	WordlistParams = "params.txt"
+	WordlistValues = "values.txt"
+)
+
+// Words longer than this are tokens or encoded blobs, not worth fuzzing with
+const maxWordLength = 100
+
+// Findings holding URLs or paths worth tokenizing, links to other sites are left out
+var wordlistTypes = map[string]bool{
+	"url":           true,
+	"form":          true,
+	"upload-form":   true,
+	"javascript":    true,
+	"linkfinder":    true,
+	"sitemap":       true,
+	"robots":        true,
+	"xhr":           true,
+	"other-sources": true,
+	"openapi":       true,
+}
+
+// Wordlist aggregates the path segments, file names, parameter names and parameter values of
+// the URLs and forms found by the crawls, deduplicated, to feed fuzzers like ffuf or gobuster.
+// It's a sink shared by every crawl writing to the same folder, each Close writes all the words.
+type Wordlist struct {
+	folder string
+
+	mu     sync.Mutex
+	paths  map[string]bool
+	files  map[string]bool
+	params map[string]bool
+	values map[string]bool
+}
+
+var (
+	wordlistsMu sync.Mutex
+	wordlists   = make(map[string]*Wordlist)
+)
+
+// OpenWordlist returns the wordlist of folder, shared by all the crawls of the process
+func OpenWordlist(folder string) (*Wordlist, error) {
+	folder = filepath.Clean(folder)
+	wordlistsMu.Lock()
+	defer wordlistsMu.Unlock()
+	if w, ok := wordlists[folder]; ok {
+		return w, nil
+	}
+	if err := os.MkdirAll(folder, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("failed to create wordlist folder: %s", err)
+	}
+	w := &Wordlist{
+		folder: folder,
+		paths:  make(map[string]bool),
+		files:  make(map[string]bool),
+		params: make(map[string]bool),
+		values: make(map[string]bool),
+	}
+	wordlists[folder] = w
+	return w, nil
+}
+
+// Write tokenizes the URL of a finding, and the parameters of forms
+func (w *Wordlist) Write(record SpiderOutput, _ string) error {
+	if !wordlistTypes[record.OutputType] {
+		return nil
+	}
+	w.AddURL(record.Output)
+	if params, ok := record.Details["params"]; ok {
+		w.addQuery(params)
+	}
+	return nil
+}
+
+// AddURL adds the words of an absolute or relative URL
+func (w *Wordlist) AddURL(raw string) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return
+	}
+	segments := strings.Split(u.Path, "/")
+	w.mu.Lock()
+	for i, segment := range segments {
+		if i == len(segments)-1 && strings.Contains(segment, ".") {
+			addWord(w.files, segment)
+		} else {
+			addWord(w.paths, segment)
+		}
+	}
+	w.mu.Unlock()
+	w.addQuery(u.RawQuery)
+}
+
+func (w *Wordlist) addQuery(rawQuery string) {
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil && len(query) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for name, values := range query {
+		addWord(w.params, name)
+		for _, value := range values {
+			addWord(w.values, value)
+		}
+	}
+}
+
+func addWord(words map[string]bool, word string) {
+	if word = strings.TrimSpace(word); word != "" && len(word) <= maxWordLength && !strings.ContainsAny(word, "\r\n") {
+		words[word] = true
+	}
+}
+
+// Close writes the words found so far, sorted
+func (w *Wordlist) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for name, words := range map[string]map[string]bool{
+		WordlistPaths:  w.paths,
+		WordlistFiles:  w.files,
+		WordlistParams: w.params,
+		WordlistValues: w.values,
+	} {
+		list := make([]string, 0, len(words))
+		for word := range words {
+			list = append(list, word)
+		}
+		sort.Strings(list)
+		data := strings.Join(list, "\n")
+		if len(list) > 0 {
+			data += "\n"
+		}
+		if err := ioutil.WriteFile(filepath.Join(w.folder, name), []byte(data), 0644); err != nil {
+			return fmt.Errorf("failed to write wordlist: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readWordlist(t *testing.T, folder, name string) []string {
+	data, err := ioutil.ReadFile(filepath.Join(folder, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestWordlist(t *testing.T) {
+	folder, err := ioutil.TempDir("", "gospider-wordlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	w, err := OpenWordlist(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shared, _ := OpenWordlist(folder + "/"); shared != w {
+		t.Error("wordlist of the same folder not shared")
+	}
+	_ = w.Write(SpiderOutput{OutputType: "url", Output: "https://example.com/api/v1/users.json?id=42&sort=name"}, "")
+	_ = w.Write(SpiderOutput{OutputType: "linkfinder", Output: "/api/v2/admin%20panel/"}, "")
+	_ = w.Write(SpiderOutput{OutputType: "form", Output: "https://example.com/login", Details: map[string]string{"params": "user=&remember=on"}}, "")
+	_ = w.Write(SpiderOutput{OutputType: "external", Output: "https://other.com/ignored"}, "")
+	_ = w.Write(SpiderOutput{OutputType: "url", Output: "https://example.com/" + strings.Repeat("a", maxWordLength+1)}, "")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string][]string{
+		WordlistPaths:  {"admin panel", "api", "login", "v1", "v2"},
+		WordlistFiles:  {"users.json"},
+		WordlistParams: {"id", "remember", "sort", "user"},
+		WordlistValues: {"42", "name", "on"},
+	} {
+		if got := readWordlist(t, folder, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCrawlerWordlist(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><a href="/docs/index.html?lang=en">docs</a>
+<form action="/search"><input name="q" value="shoes"></form></html>`)
+	}))
+	defer ts.Close()
+
+	folder, err := ioutil.TempDir("", "gospider-wordlist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	opts := DefaultOptions()
+	opts.Depth = 2
+	opts.Robots = false
+	opts.Quiet = true
+	opts.WordlistOutput = folder
+	if err := CrawlSites([]string{ts.URL}, opts, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := readWordlist(t, folder, WordlistParams); !reflect.DeepEqual(got, []string{"lang", "q"}) {
+		t.Errorf("params = %v", got)
+	}
+	if got := readWordlist(t, folder, WordlistFiles); !reflect.DeepEqual(got, []string{"index.html"}) {
+		t.Errorf("files = %v", got)
+	}
+	if got := readWordlist(t, folder, WordlistPaths); !reflect.DeepEqual(got, []string{"docs", "search"}) {
+		t.Errorf("paths = %v", got)
+	}
+}
@@ -5,6 +5,15 @@ package stringset
 
 import "sync"
 
+// Filter lets each string through once, StringFilter keeps the strings seen in memory
+// while other implementations share them between processes.
+type Filter interface {
+	// Duplicate checks if the string provided has been seen before by this filter.
+	Duplicate(s string) bool
+	// Slice returns the strings seen by this filter.
+	Slice() []string
+}
+
 // StringFilter implements an object that performs filtering of strings
 // to ensure that only unique items get through the filter.
 type StringFilter struct {
@@ -31,3 +40,11 @@ func (sf *StringFilter) Duplicate(s string) bool {
 	sf.filter.Insert(s)
 	return false
 }
+
+// Slice returns the names seen by this filter.
+func (sf *StringFilter) Slice() []string {
+	sf.lock.Lock()
+	defer sf.lock.Unlock()
+
+	return sf.filter.Slice()
+}